@@ -1,37 +1,106 @@
 // ABOUTME: Apply command for gh-context - applies repo's bound context
-// ABOUTME: Reads .ghcontext from repo root and switches to that context
+// ABOUTME: Reads .ghcontext from repo root and switches, or reconciles a tree with --all
 
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
 	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/peterjmorgan/gh-context/internal/logging"
+	"github.com/peterjmorgan/gh-context/internal/resolve"
+	"github.com/peterjmorgan/gh-context/internal/switcher"
 	"github.com/spf13/cobra"
 )
 
 var applyCmd = &cobra.Command{
 	Use:   "apply",
 	Short: "Read .ghcontext in this repo and switch to it",
-	Long:  `Apply the context bound to the current repository by reading .ghcontext and switching.`,
-	Args:  cobra.NoArgs,
-	RunE:  runApply,
+	Long: `Apply the context bound to the current repository by reading .ghcontext and switching.
+
+With --all, every git repository under --root (default: the current directory)
+is reconciled instead: each bound repo's context is checked and its git config
+entries written to the repo's local config, without switching the global gh account or SSH key. Repos
+without a .ghcontext are skipped.
+
+If the repository has no .ghcontext, the context is inferred from the origin
+remote's host (e.g. git@ghes.corp:org/repo picks the one context for ghes.corp,
+and a remote using an SSH Host alias picks the context with that SSH_HOST). An
+explicit .ghcontext always wins; pass --infer=false to require one. Inference
+only happens when you run apply yourself: the shell hooks run apply
+--infer=false, so they never switch to an inferred context, and --all only
+reconciles repos with a binding (.ghcontext, a local binding or a workspace
+.ghcontext).
+
+APPLY_ALLOW and APPLY_DENY in the settings file limit where apply (and so the
+shell hook) runs, e.g. APPLY_ALLOW=~/work/** leaves personal repos alone.
+Explicit 'gh context use' is never restricted.
+
+--quiet (or APPLY_QUIET=true in the settings file) switches without printing
+anything but errors. A context can override that either way with ANNOUNCE=true
+or ANNOUNCE=false in its file, e.g. to always announce a client's account.
+
+In CI, set GH_CONTEXT=<name> to skip all of this: apply then switches to that
+context in any directory, even outside a repository, ahead of .ghcontext,
+inference and APPLY_ALLOW/APPLY_DENY. --all ignores GH_CONTEXT.
+
+Example:
+  gh context apply --all --root ~/src`,
+	Args: cobra.NoArgs,
+	RunE: runApply,
+}
+
+var (
+	applyAll   bool
+	applyRoot  string
+	applyInfer bool
+	applyQuiet bool
+	applyFlags switchFlags
+)
+
+func init() {
+	applyCmd.Flags().BoolVar(&applyInfer, "infer", true, "Without a .ghcontext, pick the context whose host matches the origin remote")
+	applyCmd.Flags().BoolVar(&applyAll, "all", false, "Reconcile every bound repository under --root")
+	applyCmd.Flags().StringVar(&applyRoot, "root", ".", "Directory to search with --all")
+	applyCmd.Flags().BoolVarP(&applyQuiet, "quiet", "q", false, "Switch without printing anything but errors, unless the context sets ANNOUNCE=true")
+	applyFlags.register(applyCmd)
 }
 
 func runApply(cmd *cobra.Command, args []string) error {
+	if applyAll {
+		return runApplyAll()
+	}
+
 	// Verify we're in a git repo
 	root, err := git.RepoRoot()
 	if err != nil {
 		return err
 	}
-	if root == "" {
+	if root == "" && os.Getenv(resolve.EnvOverride) == "" {
 		printErr("Not inside a Git repository")
 		return nil
 	}
 
 	// Get binding
-	binding, bindErr := git.GetBinding()
+	binding, reason, bindErr := resolveBinding("")
 	if bindErr != nil {
+		printResolveErr(bindErr)
 		return bindErr
 	}
+	if reason == resolve.ReasonStale {
+		return fmt.Errorf("context '%s' no longer exists", binding) // Already warned
+	}
+	if reason == resolve.ReasonExcluded {
+		printInfo("Not applying: %s is excluded by APPLY_ALLOW/APPLY_DENY in the settings file", root)
+		printInfo("Switch explicitly with: gh context use <name>")
+		return nil
+	}
 	if binding == "" {
 		printErr("No .ghcontext file found in repository")
 		printInfo("Create one with: gh context bind <name>")
@@ -39,5 +108,174 @@ func runApply(cmd *cobra.Command, args []string) error {
 	}
 
 	// Use the bound context (reuse the use command logic)
-	return runUse(cmd, []string{binding})
+	quietOutput = !applyFlags.DryRun && !announces(binding)
+	defer func() { quietOutput = false }()
+	return useWith(cmd, []string{binding}, applyFlags)
+}
+
+// announces reports whether applying the named context should print what it
+// did: the context's own ANNOUNCE wins over --quiet and APPLY_QUIET. A dry
+// run always prints its plan.
+func announces(name string) bool {
+	if ctx, err := config.Load(name); err == nil && ctx.Announce != nil {
+		return *ctx.Announce
+	}
+	return !applyQuiet
+}
+
+// runApplyAll reconciles every bound repository under applyRoot.
+func runApplyAll() error {
+	root, err := filepath.Abs(applyRoot)
+	if err != nil {
+		return err
+	}
+
+	repos, err := git.FindRepos(root)
+	if err != nil {
+		return err
+	}
+
+	n := reconcileRepos(root, repos)
+
+	fmt.Println()
+	summary := fmt.Sprintf("%d applied, %d failed, %d skipped (no .ghcontext)", n.applied, n.failed, n.skipped)
+	if n.stale > 0 {
+		summary += fmt.Sprintf(", %d bound to missing contexts", n.stale)
+	}
+	if n.excluded > 0 {
+		summary += fmt.Sprintf(", %d excluded by APPLY_ALLOW/APPLY_DENY", n.excluded)
+	}
+	printPlain("%s of %d repositories", summary, len(repos))
+	if n.failed > 0 {
+		return fmt.Errorf("%d repositories could not be reconciled", n.failed)
+	}
+	return nil
+}
+
+// applyCounts tallies what apply --all did with each repository.
+type applyCounts struct {
+	applied, failed, skipped, stale, excluded int
+}
+
+// reconcileRepos applies each repo's bound context to it, printing one line
+// per repo applied or failed. Paths are shown relative to root.
+func reconcileRepos(root string, repos []string) applyCounts {
+	// Only bound repos are reconciled: neither an origin remote nor
+	// GH_CONTEXT, which would stamp one context on every repo, picks one
+	opts := resolve.Options{
+		Workspaces: workspaces,
+		Allow:      applyAllow,
+		Deny:       applyDeny,
+	}
+
+	var n applyCounts
+	for _, repo := range repos {
+		rel, relErr := filepath.Rel(root, repo)
+		if relErr != nil {
+			rel = repo
+		}
+
+		binding, reason, err := resolveBindingWith(repo, opts)
+		if err != nil {
+			printErr("%s: %v", rel, err)
+			n.failed++
+			continue
+		}
+		if reason == resolve.ReasonStale {
+			n.stale++
+			continue
+		}
+		if reason == resolve.ReasonExcluded {
+			n.excluded++
+			continue
+		}
+		if binding == "" {
+			n.skipped++
+			continue
+		}
+
+		if err := applyRepo(repo, binding); err != nil {
+			printErr("%s: %v", rel, err)
+			n.failed++
+			continue
+		}
+		printOk("%s → %s", rel, binding)
+		n.applied++
+	}
+	return n
+}
+
+// applyRepo applies the per-repository parts of a context to the repo at
+// dir. The global gh account and SSH key are left alone.
+func applyRepo(dir, name string) error {
+	ctx, err := config.Load(name)
+	if err != nil {
+		return err
+	}
+	if !auth.IsUserLoggedIn(ctx.Hostname, ctx.User) {
+		return fmt.Errorf("context '%s': %s is not logged in to %s", name, ctx.User, ctx.Hostname)
+	}
+	if _, err := git.ApplyConfigIn(dir, switcher.RepoGitConfig(ctx, switcher.Options{NoSSH: applyFlags.NoSSH, RepoGit: bindingOverrides(dir, name)})); err != nil {
+		return fmt.Errorf("context '%s': %w", name, err)
+	}
+	return nil
+}
+
+// resolveBinding returns the context for the repo at dir ("" = working
+// directory): the one GH_CONTEXT names, else its .ghcontext binding (its own,
+// or one inherited from a WORKSPACE_ROOTS directory), or with --infer the
+// context matching origin's host. Ambiguous inference is reported before returning the error.
+// A binding to a deleted context is warned about and returned as ReasonStale,
+// which callers must not switch to.
+func resolveBinding(dir string) (string, resolve.Reason, error) {
+	return resolveBindingWith(dir, resolve.Options{
+		Env:        os.Getenv(resolve.EnvOverride),
+		Workspaces: workspaces,
+		Infer:      applyInfer,
+		Allow:      applyAllow,
+		Deny:       applyDeny,
+	})
+}
+
+// resolveBindingWith is resolveBinding with the resolution rules given by opts.
+func resolveBindingWith(dir string, opts resolve.Options) (string, resolve.Reason, error) {
+	name, reason, err := resolve.Resolve(dir, opts)
+	var ambiguous *resolve.AmbiguousError
+	if errors.As(err, &ambiguous) {
+		printErr("Origin remote host %s matches several contexts: %s", ambiguous.Host, strings.Join(ambiguous.Matches, ", "))
+		printInfo("Pick one with: gh context bind <name>")
+		return "", reason, err
+	}
+	if err != nil {
+		return "", reason, err
+	}
+	if reason == resolve.ReasonStale {
+		warnStaleBinding(dir, name)
+		return name, reason, nil
+	}
+	if reason == resolve.ReasonRemoteAlias || reason == resolve.ReasonHost {
+		logging.Info("inferred context from origin remote", "context", name, "reason", string(reason))
+	}
+	return name, reason, nil
+}
+
+// printResolveErr reports an error from resolveBinding, unless it was an
+// ambiguous inference that resolveBinding already explained.
+func printResolveErr(err error) {
+	var ambiguous *resolve.AmbiguousError
+	if !errors.As(err, &ambiguous) {
+		printErr("%v", err)
+	}
+}
+
+// warnStaleBinding reports a repo bound to a context that no longer exists.
+func warnStaleBinding(dir, name string) {
+	repo, _ := git.RepoRootIn(dir)
+	if own, _ := git.FindMarkerIn(dir); own == "" {
+		if path, _ := resolve.WorkspaceMarker(dir, workspaces); path != "" {
+			printErr("%s inherits context '%s' from %s, which no longer exists; edit or remove that file", repo, name, path)
+			return
+		}
+	}
+	printErr("%s is bound to context '%s', which no longer exists; remove the binding with: gh context unbind", repo, name)
 }
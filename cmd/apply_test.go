@@ -0,0 +1,425 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/peterjmorgan/gh-context/internal/resolve"
+)
+
+// setupCmd gives the test its own HOME, config dir and gh (see fakeGh),
+// holding the given contexts, and runs it in HOME, outside any repository.
+func setupCmd(t *testing.T, contexts ...*config.Context) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gh is a shell script")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GH_CONFIG_DIR", filepath.Join(home, "gh-config"))
+	for _, env := range []string{"GH_CONTEXT", "GH_TOKEN", "GITHUB_TOKEN", "GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"} {
+		t.Setenv(env, "")
+	}
+	config.SetDir(filepath.Join(home, "contexts"))
+	t.Cleanup(func() { config.SetDir("") })
+	fakeGh(t, home)
+	chdir(t, home)
+
+	for _, ctx := range contexts {
+		if err := ctx.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// chdir changes the working directory for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// fakeGh points GH_PATH at a script standing in for gh, logging every call
+// to dir/gh.log. "auth status" prints dir/gh.status (see loginAs); "auth
+// switch" records the host's account (see ghUser), failing if dir/gh.fail-HOST
+// exists and switching to the account in dir/gh.as-HOST instead if that
+// does; "auth token" answers with the account name as the token, which
+// fakeAPI hands back as the login.
+func fakeGh(t *testing.T, dir string) {
+	t.Helper()
+	script := filepath.Join(dir, "gh")
+	content := `#!/bin/sh
+echo "$*" >> "$0.log"
+host= user= prev=
+for arg; do
+  case "$prev" in --hostname|-h) host=$arg ;; --user) user=$arg ;; esac
+  prev=$arg
+done
+case "$1 $2" in
+"auth status") cat "$0.status" 2>/dev/null ;;
+"auth switch")
+  [ -e "$0.fail-$host" ] && exit 1
+  [ -e "$0.as-$host" ] && user=$(cat "$0.as-$host")
+  echo "$user" > "$0.user-$host" ;;
+"auth token") if [ -n "$user" ]; then echo "$user"; else cat "$0.user-$host" 2>/dev/null || exit 1; fi ;;
+esac
+`
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GH_PATH", script)
+}
+
+// loginAs makes the fake gh report these accounts ("host/user") logged in.
+func loginAs(t *testing.T, accounts ...string) {
+	t.Helper()
+	var b strings.Builder
+	for _, a := range accounts {
+		host, user, _ := strings.Cut(a, "/")
+		b.WriteString("  ✓ Logged in to " + host + " account " + user + " (keyring)\n")
+	}
+	if err := os.WriteFile(os.Getenv("GH_PATH")+".status", []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setGhUser makes user the fake gh's active account on host.
+func setGhUser(t *testing.T, host, user string) {
+	t.Helper()
+	if err := os.WriteFile(os.Getenv("GH_PATH")+".user-"+host, []byte(user+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ghUser returns the fake gh's active account on host.
+func ghUser(t *testing.T, host string) string {
+	t.Helper()
+	data, _ := os.ReadFile(os.Getenv("GH_PATH") + ".user-" + host)
+	return strings.TrimSpace(string(data))
+}
+
+// fakeAPI serves GET /user, answering with the token as the login (401
+// without a token, or a rate-limit response for the token "rate-limited"),
+// and returns its URL for use as a context's PROXY. Contexts on github.localhost (or
+// api.github.localhost) then talk to it over plain HTTP.
+func fakeAPI(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login := strings.TrimPrefix(r.Header.Get("Authorization"), "token ")
+		if login == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if login == "rate-limited" {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "{\"login\": %q}", login)
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { auth.SetProxy(auth.Proxy{}) })
+	return srv.URL
+}
+
+// newRepo creates a git repository at dir, with an origin remote and
+// .ghcontext binding if given.
+func newRepo(t *testing.T, dir, origin, binding string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q")
+	if origin != "" {
+		run("remote", "add", "origin", origin)
+	}
+	if binding != "" {
+		if err := os.WriteFile(filepath.Join(dir, git.MarkerFile), []byte(binding+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReconcileRepos(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "work", Hostname: "github.com", User: "work", Transport: "https",
+			GitConfig: map[string]string{"user.email": "work@example.com"}},
+		&config.Context{Name: "corp", Hostname: "ghes.corp", User: "corp", Transport: "https",
+			GitConfig: map[string]string{"user.email": "corp@example.com"}},
+	)
+	loginAs(t, "github.com/work")
+
+	root := t.TempDir()
+	bound := newRepo(t, filepath.Join(root, "bound"), "", "work")
+	nested := newRepo(t, filepath.Join(root, "group", "nested"), "", "work")
+	unbound := newRepo(t, filepath.Join(root, "unbound"), "", "")
+	inferable := newRepo(t, filepath.Join(root, "inferable"), "git@github.com:o/r.git", "")
+	newRepo(t, filepath.Join(root, "stale"), "", "gone")
+	loggedOut := newRepo(t, filepath.Join(root, "logged-out"), "", "corp")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos, err := git.FindRepos(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reconcileRepos(root, repos)
+	want := applyCounts{applied: 2, failed: 1, skipped: 2, stale: 1}
+	if got != want {
+		t.Errorf("reconcileRepos = %+v, want %+v", got, want)
+	}
+
+	for dir, email := range map[string]string{bound: "work@example.com", nested: "work@example.com", unbound: "", inferable: "", loggedOut: ""} {
+		if v, err := git.GetConfigIn(dir, "user.email"); err != nil || v != email {
+			t.Errorf("%s: user.email = %q (%v), want %q", filepath.Base(dir), v, err, email)
+		}
+	}
+	if now, _ := os.Getwd(); now != wd {
+		t.Errorf("working directory changed to %s", now)
+	}
+}
+
+func TestResolveBindingEnv(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "ci", Hostname: "github.com", User: "ci", Transport: "https"},
+		&config.Context{Name: "work", Hostname: "github.com", User: "work", Transport: "https"},
+	)
+	repo := newRepo(t, filepath.Join(t.TempDir(), "repo"), "", "work")
+
+	t.Setenv(resolve.EnvOverride, "ci")
+	if name, reason, err := resolveBinding(repo); err != nil || name != "ci" || reason != resolve.ReasonEnv {
+		t.Errorf("with GH_CONTEXT set: resolveBinding = %q (%s, %v), want ci (env)", name, reason, err)
+	}
+
+	t.Setenv(resolve.EnvOverride, "")
+	if name, reason, err := resolveBinding(repo); err != nil || name != "work" || reason != resolve.ReasonMarker {
+		t.Errorf("with GH_CONTEXT unset: resolveBinding = %q (%s, %v), want work (marker)", name, reason, err)
+	}
+}
+
+func TestReconcileReposIgnoresEnv(t *testing.T) {
+	setupCmd(t, &config.Context{Name: "ci", Hostname: "github.com", User: "ci", Transport: "https",
+		GitConfig: map[string]string{"user.email": "ci@example.com"}})
+	loginAs(t, "github.com/ci")
+	t.Setenv(resolve.EnvOverride, "ci")
+
+	// APPLY_DENY is matched against the repo root as git reports it
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	unbound := newRepo(t, filepath.Join(root, "unbound"), "", "")
+	denied := newRepo(t, filepath.Join(root, "denied"), "", "ci")
+	applyDeny = []string{filepath.Join(root, "denied")}
+	t.Cleanup(func() { applyDeny = nil })
+
+	got := reconcileRepos(root, []string{unbound, denied})
+	if want := (applyCounts{skipped: 1, excluded: 1}); got != want {
+		t.Errorf("reconcileRepos = %+v, want %+v", got, want)
+	}
+	for _, dir := range []string{unbound, denied} {
+		if v, _ := git.GetConfigIn(dir, "user.email"); v != "" {
+			t.Errorf("%s: user.email = %q, want unset", filepath.Base(dir), v)
+		}
+	}
+}
+
+func TestApplyFlagsAreItsOwn(t *testing.T) {
+	t.Cleanup(func() {
+		applyFlags = switchFlags{}
+		applyCmd.Flags().Lookup("no-ssh").Changed = false
+		applyCmd.Flags().Lookup("dry-run").Changed = false
+	})
+	for _, flag := range []string{"no-ssh", "dry-run"} {
+		if err := applyCmd.Flags().Set(flag, "true"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !applyFlags.NoSSH || !applyFlags.DryRun {
+		t.Errorf("apply flags = %+v, want no-ssh and dry-run set", applyFlags)
+	}
+	if useFlags != (switchFlags{}) {
+		t.Errorf("setting apply's flags changed use's: %+v", useFlags)
+	}
+}
+
+func TestApplyLocalBinding(t *testing.T) {
+	proxy := fakeAPI(t)
+	setupCmd(t,
+		&config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "https", Proxy: proxy},
+		&config.Context{Name: "team", Hostname: "github.localhost", User: "team", Transport: "https", Proxy: proxy},
+	)
+	loginAs(t, "github.localhost/me", "github.localhost/team")
+	repo := newRepo(t, filepath.Join(os.Getenv("HOME"), "repo"), "", "")
+	chdir(t, repo)
+
+	bindLocal = true
+	t.Cleanup(func() { bindLocal = false })
+	if err := runBind(bindCmd, []string{"work"}); err != nil {
+		t.Fatalf("bind --local: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, git.MarkerFile)); !os.IsNotExist(err) {
+		t.Errorf("bind --local wrote a work-tree marker (%v)", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".git", "info", "ghcontext")); err != nil {
+		t.Errorf("bind --local wrote no .git/info/ghcontext: %v", err)
+	}
+
+	// The local-only binding applies
+	if err := runApply(applyCmd, nil); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if active, _ := config.GetActive(); active != "work" {
+		t.Errorf("active context = %q, want work from the local binding", active)
+	}
+
+	// A work-tree marker wins over it
+	if err := os.WriteFile(filepath.Join(repo, git.MarkerFile), []byte("team\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runApply(applyCmd, nil); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if active, _ := config.GetActive(); active != "team" {
+		t.Errorf("active context = %q, want team from .ghcontext", active)
+	}
+}
+
+func TestApplyStaleBinding(t *testing.T) {
+	setupCmd(t, &config.Context{Name: "work", Hostname: "github.com", User: "work", Transport: "https"})
+	loginAs(t, "github.com/work")
+	if err := config.SetActive("work"); err != nil {
+		t.Fatal(err)
+	}
+	// Origin would infer work, but the dangling binding must not be replaced
+	repo := newRepo(t, filepath.Join(os.Getenv("HOME"), "repo"), "git@github.com:o/r.git", "gone")
+	chdir(t, repo)
+
+	var err error
+	_, stderr := captureOutput(t, func() { err = runApply(applyCmd, nil) })
+	if err == nil {
+		t.Error("apply succeeded with a binding to a deleted context")
+	}
+	for _, want := range []string{repo + " is bound to context 'gone', which no longer exists", "gh context unbind"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr = %q, want %q", stderr, want)
+		}
+	}
+	if n := strings.Count(stderr, "no longer exists"); n != 1 {
+		t.Errorf("warned %d times, want once:\n%s", n, stderr)
+	}
+	if active, _ := config.GetActive(); active != "work" {
+		t.Errorf("active context = %q, want work kept", active)
+	}
+	if log, _ := os.ReadFile(os.Getenv("GH_PATH") + ".log"); strings.Contains(string(log), "auth switch") {
+		t.Errorf("apply switched gh accounts:\n%s", log)
+	}
+}
+
+func TestApplyAllowDeny(t *testing.T) {
+	// Globs are matched against the repo root as git reports it
+	home, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		repo  string // Relative to home
+		allow []string
+		deny  []string
+		want  bool // Whether apply switches
+	}{
+		{"allowed", "work/api", []string{"~/work/**"}, nil, true},
+		{"outside allowlist", "personal/blog", []string{"~/work/**"}, nil, false},
+		{"denied", "work/secret", nil, []string{"~/work/secret"}, false},
+		{"deny beats allow", "work/secret", []string{"~/work/**"}, []string{"~/work/secret"}, false},
+		{"no rules", "personal/blog", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCmd(t, &config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "https", Proxy: fakeAPI(t)})
+			t.Setenv("HOME", home)
+			loginAs(t, "github.localhost/me")
+			repo := newRepo(t, filepath.Join(home, tt.repo), "", "work")
+			chdir(t, repo)
+			applyAllow, applyDeny = tt.allow, tt.deny
+			t.Cleanup(func() { applyAllow, applyDeny = nil, nil })
+
+			if err := runApply(applyCmd, nil); err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			active, _ := config.GetActive()
+			if got := active == "work"; got != tt.want {
+				t.Errorf("apply in ~/%s switched %v, want %v", tt.repo, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyAnnounce(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name     string
+		announce *bool
+		quiet    bool
+		want     bool // Whether apply prints the switch
+	}{
+		{"default", nil, false, true},
+		{"quiet", nil, true, false},
+		{"announce beats quiet", &yes, true, true},
+		{"silent context", &no, false, false},
+		{"silent and quiet", &no, true, false},
+		{"announce without quiet", &yes, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCmd(t, &config.Context{Name: "client", Hostname: "github.localhost", User: "me", Transport: "https", Proxy: fakeAPI(t), Announce: tt.announce})
+			loginAs(t, "github.localhost/me")
+			chdir(t, newRepo(t, filepath.Join(os.Getenv("HOME"), "repo"), "", "client"))
+			applyQuiet = tt.quiet
+			t.Cleanup(func() { applyQuiet = false })
+
+			var err error
+			stdout, stderr := captureOutput(t, func() { err = runApply(applyCmd, nil) })
+			if err != nil {
+				t.Fatalf("apply: %v\n%s", err, stderr)
+			}
+			if active, _ := config.GetActive(); active != "client" {
+				t.Errorf("active context = %q, want client", active)
+			}
+			if got := strings.Contains(stdout, "client"); got != tt.want {
+				t.Errorf("apply printed %v, want %v:\n%s", got, tt.want, stdout)
+			}
+		})
+	}
+}
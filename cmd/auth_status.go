@@ -1,13 +1,17 @@
 // ABOUTME: Auth-status command for gh-context - shows authentication status
-// ABOUTME: Displays auth state for all saved contexts with verification
+// ABOUTME: Displays auth state for all saved contexts, joined with gh's live account list for tables and JSON
 
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"text/tabwriter"
 
 	"github.com/peterjmorgan/gh-context/internal/auth"
 	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
 	"github.com/peterjmorgan/gh-context/internal/ssh"
 	"github.com/spf13/cobra"
 )
@@ -15,12 +19,87 @@ import (
 var authStatusCmd = &cobra.Command{
 	Use:   "auth-status",
 	Short: "Display authentication status for all contexts",
-	Long:  `Show the authentication status for all saved contexts, indicating which are ready to use.`,
-	Args:  cobra.NoArgs,
-	RunE:  runAuthStatus,
+	Long: `Show the authentication status for all saved contexts, indicating which are ready to use.
+
+-o table and -o json join the saved contexts with a single 'gh auth status'
+call: each context is listed with whether its account is logged in
+(logged-in), missing or failing on a host gh knows (logged-out), or on a host
+gh has no accounts for at all (unknown-host), and whether it is gh's active
+account there. JSON also lists gh's accounts that no context uses.`,
+	Args: cobra.NoArgs,
+	RunE: runAuthStatus,
+}
+
+var authStatusOutput string
+
+func init() {
+	authStatusCmd.Flags().StringVarP(&authStatusOutput, "output", "o", "text", "Output format (text, table or json)")
+}
+
+// Login states of a saved context's account, as joined with gh auth status.
+const (
+	loginLoggedIn    = "logged-in"
+	loginLoggedOut   = "logged-out"   // gh knows the host, but not this account (or its login failed)
+	loginUnknownHost = "unknown-host" // gh has no accounts on the context's host
+)
+
+// accountRecord is the JSON form of one gh account, tagged with its saved context.
+type accountRecord struct {
+	auth.AccountStatus
+	Context string `json:"context,omitempty"`
+	Login   string `json:"login,omitempty"` // For context records: logged-in, logged-out or unknown-host
+}
+
+// joinAuthStatus pairs each saved context with gh's record of its account,
+// then appends the accounts no context uses.
+func joinAuthStatus(contexts []*config.Context, accounts []auth.AccountStatus) []accountRecord {
+	knownHosts := make(map[string]bool)
+	for _, acct := range accounts {
+		knownHosts[acct.Hostname] = true
+	}
+
+	used := make(map[int]bool)
+	records := make([]accountRecord, 0, len(contexts)+len(accounts))
+	for _, ctx := range contexts {
+		rec := accountRecord{
+			AccountStatus: auth.AccountStatus{Hostname: ctx.Hostname, User: ctx.User},
+			Context:       ctx.Name,
+			Login:         loginUnknownHost,
+		}
+		if knownHosts[ctx.Hostname] {
+			rec.Login = loginLoggedOut
+		}
+		for i, acct := range accounts {
+			if acct.Hostname == ctx.Hostname && acct.User == ctx.User {
+				rec.AccountStatus = acct
+				if acct.LoggedIn {
+					rec.Login = loginLoggedIn
+				}
+				used[i] = true
+				break
+			}
+		}
+		records = append(records, rec)
+	}
+	for i, acct := range accounts {
+		if !used[i] {
+			records = append(records, accountRecord{AccountStatus: acct})
+		}
+	}
+	return records
 }
 
 func runAuthStatus(cmd *cobra.Command, args []string) error {
+	switch authStatusOutput {
+	case "text":
+	case "table":
+		return runAuthStatusTable()
+	case "json":
+		return runAuthStatusJSON()
+	default:
+		return usageErrorf("output must be 'text', 'table' or 'json', got: %s", authStatusOutput)
+	}
+
 	printPlain("Authentication status for all contexts:")
 	fmt.Println()
 
@@ -37,7 +116,10 @@ func runAuthStatus(cmd *cobra.Command, args []string) error {
 	active, _ := config.GetActive()
 
 	// Get current SSH config state
-	sshCfg, _ := ssh.ParseConfig("")
+	sshCfg, _ := loadSSHConfig()
+
+	// Token sources are per host, not per context
+	tokenSources := make(map[string]string)
 
 	for _, ctx := range contexts {
 		indicator := ""
@@ -47,6 +129,9 @@ func runAuthStatus(cmd *cobra.Command, args []string) error {
 
 		fmt.Printf("Context: %s%s\n", ctx.Name, indicator)
 		fmt.Printf("  Host: %s\n", ctx.Hostname)
+		if ctx.SSHHost != "" {
+			fmt.Printf("  SSH Host: %s\n", ctx.SSHHost)
+		}
 		fmt.Printf("  User: %s\n", ctx.User)
 		fmt.Printf("  Transport: %s\n", ctx.Transport)
 
@@ -60,8 +145,8 @@ func runAuthStatus(cmd *cobra.Command, args []string) error {
 
 			// Check if this key is active in SSH config
 			if sshCfg != nil {
-				activeKey := sshCfg.GetActiveIdentityFile(ctx.Hostname)
-				if activeKey != "" && ssh.ExpandPath(activeKey) == ssh.ExpandPath(ctx.SSHKey) {
+				activeKey := sshCfg.GetActiveIdentityFile(ctx.SSHBlockHost())
+				if activeKey != "" && fsutil.ExpandPath(activeKey) == fsutil.ExpandPath(ctx.SSHKey) {
 					fmt.Printf("  SSH Active: ✅ (currently active in ~/.ssh/config)\n")
 				} else {
 					fmt.Printf("  SSH Active: ❌ (not active in ~/.ssh/config)\n")
@@ -69,13 +154,36 @@ func runAuthStatus(cmd *cobra.Command, args []string) error {
 			}
 		}
 
+		// Agent-based auth bypasses IdentityFile entirely
+		if sshCfg != nil {
+			if agent := sshCfg.GetIdentityAgent(ctx.SSHBlockHost()); agent != "" {
+				fmt.Printf("  SSH Agent: authenticating via agent: %s\n", agent)
+			}
+		}
+
 		// Check authentication status
 		authIcon := "❌"
-		if auth.IsUserLoggedIn(ctx.Hostname, ctx.User) {
+		loggedIn, authErr := auth.CheckLogin(ctx.Hostname, ctx.User)
+		if authErr != nil {
+			fmt.Printf("  GH Auth: ⚠️  unreachable (%v)\n", authErr)
+			fmt.Println()
+			continue
+		}
+		if loggedIn {
 			authIcon = "✅"
 		}
 
 		fmt.Printf("  GH Auth: %s\n", authIcon)
+		if loggedIn {
+			source, ok := tokenSources[ctx.Hostname]
+			if !ok {
+				source, _ = auth.TokenSource(ctx.Hostname)
+				tokenSources[ctx.Hostname] = source
+			}
+			if source != "" {
+				fmt.Printf("  Token: %s\n", describeTokenSource(ctx.Hostname, source))
+			}
+		}
 
 		// Show login command if not authenticated
 		if authIcon == "❌" {
@@ -92,3 +200,88 @@ func runAuthStatus(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runAuthStatusJSON() error {
+	contexts, err := config.ListContexts()
+	if err != nil {
+		return err
+	}
+
+	accounts, err := auth.GetStatus("")
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(joinAuthStatus(contexts, accounts))
+}
+
+// runAuthStatusTable prints one row per saved context with its account's
+// live status, and tells how to log in the ones that aren't.
+func runAuthStatusTable() error {
+	contexts, err := config.ListContexts()
+	if err != nil {
+		return err
+	}
+	if len(contexts) == 0 {
+		printInfo("No contexts found")
+		return nil
+	}
+
+	accounts, err := auth.GetStatus("")
+	if err != nil {
+		return err
+	}
+	records := joinAuthStatus(contexts, accounts)
+	active, _ := config.GetActive()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTEXT\tHOST\tUSER\tGH AUTH\tACTIVE ACCOUNT")
+	var broken []accountRecord
+	for _, rec := range records {
+		if rec.Context == "" {
+			continue
+		}
+		name := rec.Context
+		if name == active {
+			name += " *"
+		}
+		activeAccount := "no"
+		if rec.Active {
+			activeAccount = "yes"
+		}
+		status := "✅ " + rec.Login
+		if rec.Login != loginLoggedIn {
+			status = "❌ " + rec.Login
+			activeAccount = "-"
+			broken = append(broken, rec)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, rec.Hostname, rec.User, status, activeAccount)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(broken) > 0 {
+		fmt.Println()
+		for _, rec := range broken {
+			printErr("%s: %s isn't logged in on %s", rec.Context, rec.User, rec.Hostname)
+			printInfo("  To fix: gh auth login --hostname %s --username %s --scopes repo,read:org", rec.Hostname, rec.User)
+		}
+	}
+	if active != "" {
+		fmt.Println()
+		printPlain("* = active context")
+	}
+	return nil
+}
+
+// describeTokenSource explains a token source, flagging env vars because they
+// take precedence over whatever account gh-context switches to.
+func describeTokenSource(hostname, source string) string {
+	if source == auth.EnvTokenVar(hostname) {
+		return fmt.Sprintf("from %s (overrides keyring and gh auth switch)", source)
+	}
+	return "from " + source
+}
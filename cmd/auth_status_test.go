@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+func TestAuthStatusShowsIdentityAgent(t *testing.T) {
+	setupCmd(t, &config.Context{Name: "work", Hostname: "github.com", User: "me", Transport: "ssh"})
+	loginAs(t, "github.com/me")
+	sshConfig := filepath.Join(os.Getenv("HOME"), ".ssh", "config")
+	if err := os.MkdirAll(filepath.Dir(sshConfig), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sshConfig, []byte("Host github.com\n    IdentityAgent ~/.1password/agent.sock\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	stdout, _ := captureOutput(t, func() { err = runAuthStatus(authStatusCmd, nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, "SSH Agent: authenticating via agent: ~/.1password/agent.sock") {
+		t.Errorf("auth-status doesn't report the agent:\n%s", stdout)
+	}
+}
+
+func TestAuthStatusJSON(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "work", Hostname: "github.com", User: "me", Transport: "https"},
+		&config.Context{Name: "old", Hostname: "github.com", User: "old", Transport: "https"},
+		&config.Context{Name: "broken", Hostname: "github.com", User: "bob", Transport: "https"},
+		&config.Context{Name: "corp", Hostname: "ghes.corp", User: "admin", Transport: "https"},
+	)
+	status := "github.com\n" +
+		"  ✓ Logged in to github.com account me (keyring)\n" +
+		"  - Active account: true\n" +
+		"  X Failed to log in to github.com account bob (keyring)\n" +
+		"  - Active account: false\n" +
+		"  ✓ Logged in to github.com account spare (keyring)\n" +
+		"  - Active account: false\n"
+	if err := os.WriteFile(os.Getenv("GH_PATH")+".status", []byte(status), 0644); err != nil {
+		t.Fatal(err)
+	}
+	authStatusOutput = "json"
+	t.Cleanup(func() { authStatusOutput = "text" })
+
+	var err error
+	stdout, _ := captureOutput(t, func() { err = runAuthStatus(authStatusCmd, nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []accountRecord
+	if err := json.Unmarshal([]byte(stdout), &records); err != nil {
+		t.Fatalf("auth-status -o json: %v\n%s", err, stdout)
+	}
+
+	type row struct{ context, user, login string }
+	var got []row
+	for _, r := range records {
+		got = append(got, row{r.Context, r.User, r.Login})
+	}
+	want := []row{
+		{"broken", "bob", loginLoggedOut}, // Login failed
+		{"corp", "admin", loginUnknownHost},
+		{"old", "old", loginLoggedOut}, // Host known, account not logged in
+		{"work", "me", loginLoggedIn},
+		{"", "spare", ""}, // An account no context uses
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %+v, want %+v", got, want)
+	}
+	for _, r := range records {
+		if r.Context == "work" && !r.Active {
+			t.Error("work's account isn't reported active")
+		}
+	}
+}
@@ -13,13 +13,43 @@ var bindCmd = &cobra.Command{
 	Use:   "bind <name>",
 	Short: "Write .ghcontext in repo root",
 	Long: `Bind the current repository to a context by creating a .ghcontext file.
-When using shell hooks, the context will be automatically applied when entering this repo.`,
-	Args: cobra.ExactArgs(1),
+When using shell hooks, the context will be automatically applied when entering this repo.
+
+Any saved context can be bound, not just the active one, and binding never
+switches: the active context and gh accounts are left alone, so a script can
+bind many repositories without thrashing the active account. The context may
+be named as an argument or with --context.
+
+Use --local to write the binding to .git/info/ghcontext instead, which is never
+committed. A .ghcontext in the work tree takes precedence over a local binding.
+
+Example:
+  for repo in ~/work/*; do (cd "$repo" && gh context bind --context work); done`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runBind,
 }
 
+var (
+	bindLocal   bool
+	bindContext string
+)
+
+func init() {
+	bindCmd.Flags().BoolVar(&bindLocal, "local", false, "Store the binding in .git/info/ghcontext (never committed)")
+	bindCmd.Flags().StringVar(&bindContext, "context", "", "Context to bind (or pass it as an argument)")
+}
+
 func runBind(cmd *cobra.Command, args []string) error {
-	name := args[0]
+	name := bindContext
+	if len(args) > 0 {
+		if name != "" && name != args[0] {
+			return usageErrorf("context name given twice: '%s' and --context '%s'", args[0], name)
+		}
+		name = args[0]
+	}
+	if name == "" {
+		return usageErrorf("context name is required: gh context bind <name> or --context <name>")
+	}
 
 	// Verify context exists
 	exists, err := config.Exists(name)
@@ -27,6 +57,7 @@ func runBind(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	if !exists {
+		reportMissingContext(name)
 		_, loadErr := config.Load(name) // This will return proper "not found" error
 		return loadErr
 	}
@@ -41,6 +72,18 @@ func runBind(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if bindLocal {
+		if err := git.SetLocalBinding(name); err != nil {
+			return err
+		}
+		bindingPath, _ := git.LocalBindingPath()
+		printOk("Bound repo to context '%s' locally (%s)", name, bindingPath)
+		if hasBinding, _ := git.HasBinding(); hasBinding {
+			printInfo("Note: the work tree's .ghcontext takes precedence over this local binding")
+		}
+		return nil
+	}
+
 	// Create binding
 	if err := git.SetBinding(name); err != nil {
 		return err
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/git"
+)
+
+func TestBindNamedContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		context string // --context
+		want    string // Binding written; empty for none
+		code    int
+	}{
+		{"argument", []string{"work"}, "", "work", ExitOK},
+		{"--context", nil, "work", "work", ExitOK},
+		{"both agreeing", []string{"work"}, "work", "work", ExitOK},
+		{"both differing", []string{"work"}, "personal", "", ExitUsage},
+		{"neither", nil, "", "", ExitUsage},
+		{"nonexistent", nil, "wrok", "", ExitNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCmd(t,
+				&config.Context{Name: "work", Hostname: "github.com", User: "work", Transport: "https"},
+				&config.Context{Name: "personal", Hostname: "github.com", User: "me", Transport: "https"},
+			)
+			if err := config.SetActive("personal"); err != nil {
+				t.Fatal(err)
+			}
+			repo := newRepo(t, filepath.Join(os.Getenv("HOME"), "repo"), "", "")
+			chdir(t, repo)
+			bindContext = tt.context
+			t.Cleanup(func() { bindContext = "" })
+
+			var err error
+			captureOutput(t, func() { err = runBind(bindCmd, tt.args) })
+			if got := ExitCode(err); got != tt.code {
+				t.Errorf("exit code %d (%v), want %d", got, err, tt.code)
+			}
+			data, _ := os.ReadFile(filepath.Join(repo, git.MarkerFile))
+			if got := strings.TrimSpace(string(data)); got != tt.want {
+				t.Errorf("binding = %q, want %q", got, tt.want)
+			}
+			if active, _ := config.GetActive(); active != "personal" {
+				t.Errorf("active context = %q, want personal untouched", active)
+			}
+			if log, _ := os.ReadFile(os.Getenv("GH_PATH") + ".log"); len(log) > 0 {
+				t.Errorf("bind ran gh:\n%s", log)
+			}
+		})
+	}
+}
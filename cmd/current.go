@@ -5,17 +5,40 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
 
+	"github.com/peterjmorgan/gh-context/internal/auth"
 	"github.com/peterjmorgan/gh-context/internal/config"
 	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/peterjmorgan/gh-context/internal/resolve"
 	"github.com/spf13/cobra"
 )
 
 var currentCmd = &cobra.Command{
 	Use:   "current",
 	Short: "Show active context and repo-bound context",
-	Long:  `Display the currently active context and any repository-specific context binding.`,
-	RunE:  runCurrent,
+	Long: `Display the currently active context and any repository-specific context binding.
+
+Use --detect to also query the gh session on every known host and report the
+account that is actually active, flagging drift from the stored active context
+(e.g. after a manual 'gh auth switch').
+
+Use --porcelain in scripts: it prints one tab-separated "name<TAB>host<TAB>user"
+line for the active context (an empty line if none), a format that stays stable
+across versions.`,
+	RunE: runCurrent,
+}
+
+var (
+	currentDetect    bool
+	currentQuiet     bool
+	currentPorcelain bool
+)
+
+func init() {
+	currentCmd.Flags().BoolVar(&currentDetect, "detect", false, "Report the actual gh account on each known host and flag mismatches")
+	currentCmd.Flags().BoolVarP(&currentQuiet, "quiet", "q", false, "Print only the active context name (nothing if none), for prompts")
+	currentCmd.Flags().BoolVar(&currentPorcelain, "porcelain", false, "Print a stable name<TAB>host<TAB>user line for scripts (empty if none)")
 }
 
 func runCurrent(cmd *cobra.Command, args []string) error {
@@ -24,6 +47,20 @@ func runCurrent(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if currentPorcelain {
+		if currentQuiet || currentDetect {
+			return usageErrorf("--porcelain can't be combined with --quiet or --detect")
+		}
+		return printPorcelain(active)
+	}
+
+	if currentQuiet {
+		if active != "" {
+			fmt.Println(active)
+		}
+		return nil
+	}
+
 	if active == "" {
 		printPlain("No active context")
 	} else {
@@ -42,6 +79,12 @@ func runCurrent(cmd *cobra.Command, args []string) error {
 		printPlain("Active: %s (%s@%s, %s%s)", ctx.Name, ctx.User, ctx.Hostname, ctx.Transport, sshInfo)
 	}
 
+	if currentDetect {
+		if err := detectSessionAccounts(active); err != nil {
+			return err
+		}
+	}
+
 	// Check for repo binding
 	root, err := git.RepoRoot()
 	if err != nil {
@@ -49,14 +92,89 @@ func runCurrent(cmd *cobra.Command, args []string) error {
 	}
 
 	if root != "" {
-		binding, err := git.GetBinding()
+		binding, reason, err := resolve.Resolve("", resolve.Options{})
 		if err != nil {
 			return err
 		}
 		if binding != "" {
-			bindingPath, _ := git.BindingPath()
-			printPlain("Repo-bound: %s (in %s)", binding, bindingPath)
+			bindingPath, _ := git.FindMarker()
+			if reason == resolve.ReasonStale {
+				printPlain("Repo-bound: %s (in %s), but that context no longer exists", binding, bindingPath)
+				printInfo("Remove the binding with: gh context unbind")
+			} else {
+				printPlain("Repo-bound: %s (in %s)", binding, bindingPath)
+			}
+		}
+	}
+
+	return nil
+}
+
+// printPorcelain prints the active context as "name\thost\tuser", or an empty
+// line if there is none. The format is a stable contract for scripts.
+func printPorcelain(active string) error {
+	if active == "" {
+		fmt.Println()
+		return nil
+	}
+	ctx, err := config.Load(active)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\t%s\t%s\n", ctx.Name, ctx.Hostname, ctx.User)
+	return nil
+}
+
+// detectSessionAccounts reports the gh session user on every host used by a
+// saved context, and flags a mismatch with the active context's user.
+func detectSessionAccounts(active string) error {
+	contexts, err := config.ListContexts()
+	if err != nil {
+		return err
+	}
+
+	var activeCtx *config.Context
+	hostSet := make(map[string]bool)
+	for _, ctx := range contexts {
+		hostSet[ctx.Hostname] = true
+		if ctx.Name == active {
+			activeCtx = ctx
+		}
+	}
+
+	hosts := make([]string, 0, len(hostSet))
+	for h := range hostSet {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	printPlain("gh session accounts:")
+	skipped, limitErr := runChecks(len(hosts), func(i int) error {
+		host := hosts[i]
+		user, err := auth.GetCurrentUserFromSession(host)
+		if auth.IsRateLimited(err) {
+			// Throttling says nothing about the session, so don't report it as logged out
+			return err
+		}
+		if err != nil {
+			printPlain("  %s\t(not logged in)", host)
+			return nil
+		}
+
+		printPlain("  %s\t%s", host, user)
+		if activeCtx != nil && activeCtx.Hostname == host && activeCtx.User != user {
+			printErr("gh is using %s on %s, but active context '%s' expects %s", user, host, activeCtx.Name, activeCtx.User)
+			printInfo("Re-sync with: gh context use %s", activeCtx.Name)
 		}
+		return nil
+	})
+	if skipped > 0 {
+		printErr("%v, %d of %d checks skipped; try again after the limit resets", limitErr, skipped, len(hosts))
 	}
 
 	return nil
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+func TestDetectSessionAccounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		session  string
+		mismatch bool
+	}{
+		{"session matches", "me", false},
+		{"session diverges", "other", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := fakeAPI(t)
+			setupCmd(t, &config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "https"})
+			if err := auth.SetProxy(auth.Proxy{URL: proxy}); err != nil {
+				t.Fatal(err)
+			}
+			setGhUser(t, "github.localhost", tt.session)
+
+			var err error
+			stdout, stderr := captureOutput(t, func() { err = detectSessionAccounts("work") })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(stdout, "github.localhost\t"+tt.session) {
+				t.Errorf("stdout doesn't report %s as the session user:\n%s", tt.session, stdout)
+			}
+			want := "gh is using other on github.localhost, but active context 'work' expects me"
+			if got := strings.Contains(stderr, want); got != tt.mismatch {
+				t.Errorf("mismatch reported %v, want %v:\n%s", got, tt.mismatch, stderr)
+			}
+		})
+	}
+}
+
+func TestDetectSessionAccountsLoggedOut(t *testing.T) {
+	proxy := fakeAPI(t)
+	setupCmd(t, &config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "https"})
+	if err := auth.SetProxy(auth.Proxy{URL: proxy}); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _ := captureOutput(t, func() { detectSessionAccounts("work") })
+	if !strings.Contains(stdout, "github.localhost\t(not logged in)") {
+		t.Errorf("stdout doesn't report the host logged out:\n%s", stdout)
+	}
+}
+
+func TestCurrentQuiet(t *testing.T) {
+	setupCmd(t, &config.Context{Name: "work", Hostname: "github.com", User: "me", Transport: "https"})
+	currentQuiet = true
+	t.Cleanup(func() { currentQuiet = false })
+
+	for _, tt := range []struct {
+		active string
+		want   string
+	}{
+		{"", ""},
+		{"work", "work\n"},
+	} {
+		if tt.active != "" {
+			if err := config.SetActive(tt.active); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var err error
+		stdout, stderr := captureOutput(t, func() { err = runCurrent(currentCmd, nil) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stdout != tt.want || stderr != "" {
+			t.Errorf("current --quiet with %q active: stdout %q, stderr %q; want stdout %q only", tt.active, stdout, stderr, tt.want)
+		}
+	}
+}
+
+func TestCurrentPorcelain(t *testing.T) {
+	setupCmd(t, &config.Context{Name: "work", Hostname: "ghes.corp", User: "me", Transport: "https"})
+	currentPorcelain = true
+	t.Cleanup(func() { currentPorcelain = false })
+
+	for _, tt := range []struct {
+		active string
+		want   string
+	}{
+		{"", "\n"},
+		{"work", "work\tghes.corp\tme\n"},
+	} {
+		if tt.active != "" {
+			if err := config.SetActive(tt.active); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var err error
+		stdout, stderr := captureOutput(t, func() { err = runCurrent(currentCmd, nil) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stdout != tt.want || stderr != "" {
+			t.Errorf("current --porcelain with %q active: stdout %q, stderr %q; want stdout %q only", tt.active, stdout, stderr, tt.want)
+		}
+	}
+
+	currentQuiet = true
+	t.Cleanup(func() { currentQuiet = false })
+	if err := runCurrent(currentCmd, nil); ExitCode(err) != ExitUsage {
+		t.Errorf("--porcelain --quiet: exit code %d (%v), want %d", ExitCode(err), err, ExitUsage)
+	}
+}
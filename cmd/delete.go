@@ -4,22 +4,144 @@
 package cmd
 
 import (
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
 	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
 	"github.com/spf13/cobra"
 )
 
 var deleteCmd = &cobra.Command{
-	Use:     "delete <name>",
+	Use:     "delete <name|pattern>",
 	Aliases: []string{"rm", "remove"},
 	Short:   "Remove a saved context",
-	Long:    `Delete a saved context. Clears the active pointer if the deleted context was active.`,
-	Args:    cobra.ExactArgs(1),
-	RunE:    runDelete,
+	Long: `Delete a saved context. Clears the active pointer if the deleted context was active.
+
+With --purge, also remove the context's IdentityFile lines from ~/.ssh/config
+and the .ghcontext (or .git/info/ghcontext) bindings naming it: in the current
+repository, and in every repository under --root if given. IdentityFile lines
+another saved context still uses in the same Host block are kept. Each
+destructive step is confirmed unless --yes is passed.
+
+A name containing *, ? or [ is a glob: every matching context is listed and,
+once confirmed (or with --yes), deleted. The active context is skipped unless
+--force is passed. Quote the pattern so the shell doesn't expand it.
+
+Examples:
+  gh context delete old-work
+  gh context delete old-work --purge --root ~/src --yes
+  gh context delete 'proj-*'`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDelete,
+}
+
+var (
+	deletePurge bool
+	deleteYes   bool
+	deleteRoot  string
+	deleteForce bool
+)
+
+func init() {
+	deleteCmd.Flags().BoolVar(&deletePurge, "purge", false, "Also remove the context's SSH IdentityFile lines and repo bindings")
+	deleteCmd.Flags().BoolVarP(&deleteYes, "yes", "y", false, "Don't ask before deleting by pattern or before each --purge step")
+	deleteCmd.Flags().BoolVar(&deleteForce, "force", false, "When deleting by pattern, include the active context")
+	deleteCmd.Flags().StringVar(&deleteRoot, "root", "", "With --purge, also remove bindings in repositories under this directory")
 }
 
 func runDelete(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
+	if deleteRoot != "" && !deletePurge {
+		return usageErrorf("--root only applies with --purge")
+	}
+
+	if strings.ContainsAny(name, "*?[") {
+		return runDeleteGlob(name)
+	}
+	if deleteForce {
+		return usageErrorf("--force only applies when deleting by pattern")
+	}
+
+	if exists, _ := config.Exists(name); !exists {
+		reportMissingContext(name)
+		return &config.NotFoundError{Kind: "context", Name: name}
+	}
+	return deleteContext(name, []string{name})
+}
+
+// runDeleteGlob deletes every context whose name matches pattern, after
+// listing them and confirming. The active context is left out unless --force.
+func runDeleteGlob(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return usageErrorf("invalid pattern '%s': %v", pattern, err)
+	}
+	names, err := config.List()
+	if err != nil {
+		return err
+	}
+	active, _ := config.GetActive()
+
+	var matched []string
+	for _, name := range names {
+		if ok, _ := path.Match(pattern, name); !ok {
+			continue
+		}
+		if name == active && !deleteForce {
+			printInfo("Skipping active context '%s' (pass --force to delete it too)", name)
+			continue
+		}
+		matched = append(matched, name)
+	}
+	if len(matched) == 0 {
+		printErr("No contexts to delete match '%s'", pattern)
+		return &config.NotFoundError{Kind: "context", Name: pattern}
+	}
+
+	printPlain("Contexts matching '%s':", pattern)
+	for _, name := range matched {
+		indicator := ""
+		if name == active {
+			indicator = " *"
+		}
+		printPlain("  %s%s", name, indicator)
+	}
+	if !deleteYes && !confirm("Delete %d context(s)?", len(matched)) {
+		printInfo("Nothing deleted")
+		return nil
+	}
+
+	for _, name := range matched {
+		if err := deleteContext(name, matched); err != nil {
+			printErr("Failed to delete '%s': %v", name, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteContext deletes one existing context, purging its keys and
+// bindings first with --purge. batch names every context being deleted in
+// this run (including name), whose keys needn't be kept for each other.
+func deleteContext(name string, batch []string) error {
+	if deletePurge {
+		ctx, err := config.Load(name)
+		if err != nil {
+			return err
+		}
+		if err := purgeSSHKeys(ctx, batch); err != nil {
+			return err
+		}
+		if err := purgeBindings(name); err != nil {
+			return err
+		}
+	}
+
 	// Check if we need to clear active pointer
 	active, _ := config.GetActive()
 	willClearActive := active == name
@@ -35,3 +157,180 @@ func runDelete(cmd *cobra.Command, args []string) error {
 	printOk("Deleted context '%s'", name)
 	return nil
 }
+
+// hostKey is a key some context activates in a Host block.
+type hostKey struct{ host, key string }
+
+// contextKeys returns the Host block and key pairs of ctx, its extra hosts'
+// included. A context that doesn't manage SSH config has none.
+func contextKeys(ctx *config.Context) []hostKey {
+	if !ctx.SSHManaged {
+		return nil
+	}
+	var keys []hostKey
+	if ctx.SSHKey != "" {
+		keys = append(keys, hostKey{ctx.SSHBlockHost(), ctx.SSHKey})
+	}
+	for _, e := range ctx.Extra {
+		if e.SSHKey != "" {
+			keys = append(keys, hostKey{e.SSHBlockHost(), e.SSHKey})
+		}
+	}
+	return keys
+}
+
+// unsharedKeys returns the pairs in targets that no context in others uses
+// (same Host block name, ignoring case, and the same key file).
+func unsharedKeys(targets []hostKey, others []*config.Context) []hostKey {
+	var unshared []hostKey
+	for _, t := range targets {
+		shared := false
+		for _, other := range others {
+			for _, k := range contextKeys(other) {
+				if strings.EqualFold(k.host, t.host) && ssh.SamePath(k.key, t.key) {
+					shared = true
+				}
+			}
+		}
+		if !shared {
+			unshared = append(unshared, t)
+		}
+	}
+	return unshared
+}
+
+// purgeSSHKeys removes the IdentityFile lines of a context's keys (including
+// its extra hosts) from their Host blocks, after confirmation. Lines another
+// saved context outside batch still uses are kept.
+func purgeSSHKeys(ctx *config.Context, batch []string) error {
+	targets := contextKeys(ctx)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	contexts, err := config.ListContexts()
+	if err != nil {
+		return err
+	}
+	var others []*config.Context
+	for _, other := range contexts {
+		if !slices.Contains(batch, other.Name) {
+			others = append(others, other)
+		}
+	}
+	unshared := unsharedKeys(targets, others)
+	for _, t := range targets {
+		if !slices.Contains(unshared, t) {
+			printInfo("Keeping IdentityFile %s in Host %s: another context uses it", t.key, t.host)
+		}
+	}
+	targets = unshared
+	if len(targets) == 0 {
+		return nil
+	}
+
+	sshCfg, err := loadSSHConfig()
+	if err != nil {
+		printErr("Failed to read SSH config: %v", err)
+		return err
+	}
+
+	var listed []hostKey
+	for _, t := range targets {
+		if block := sshCfg.FindHostBlock(t.host); block != nil && block.HasIdentityFile(t.key) {
+			listed = append(listed, t)
+		}
+	}
+	if len(listed) == 0 {
+		printInfo("No IdentityFile lines for this context in SSH config")
+		return nil
+	}
+
+	for _, t := range listed {
+		printPlain("  IdentityFile %s in Host %s", t.key, t.host)
+	}
+	if !deleteYes && !confirm("Remove %d IdentityFile line(s) from SSH config?", len(listed)) {
+		printInfo("Keeping SSH config entries")
+		return nil
+	}
+
+	for _, t := range listed {
+		if _, err := sshCfg.RemoveIdentityFile(t.host, t.key); err != nil {
+			printErr("Failed to remove %s from Host %s: %v", t.key, t.host, err)
+			return withExitCode(ExitSSH, err)
+		}
+	}
+	if err := sshCfg.Save(); err != nil {
+		printErr("Failed to save SSH config: %v", err)
+		return withExitCode(ExitSSH, err)
+	}
+	printSSHSaved(sshCfg)
+	return nil
+}
+
+// purgeBindings removes the markers binding repositories to name: in the
+// current repository and, with --root, every repository beneath it.
+func purgeBindings(name string) error {
+	markers, err := boundMarkers(name, deleteRoot)
+	if err != nil {
+		return err
+	}
+
+	if len(markers) == 0 {
+		if deleteRoot == "" {
+			printInfo("Other repositories may still be bound; pass --root to search for them")
+		}
+		return nil
+	}
+
+	for _, m := range markers {
+		printPlain("  %s", m)
+	}
+	if !deleteYes && !confirm("Remove %d binding(s) to '%s'?", len(markers), name) {
+		printInfo("Keeping repository bindings")
+		return nil
+	}
+
+	for _, m := range markers {
+		if err := os.Remove(m); err != nil && !os.IsNotExist(err) {
+			printErr("Failed to remove %s: %v", m, err)
+			continue
+		}
+		printOk("Removed binding %s", m)
+	}
+	return nil
+}
+
+// boundMarkers returns the marker files binding a repository to name: the
+// current repository's and, with root, those of every repository beneath it.
+func boundMarkers(name, root string) ([]string, error) {
+	var repos []string
+	if repo, _ := git.RepoRoot(); repo != "" {
+		repos = append(repos, repo)
+	}
+	if root != "" {
+		dir, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		found, err := git.FindRepos(dir)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, found...)
+	}
+
+	var markers []string
+	seen := make(map[string]bool)
+	for _, repo := range repos {
+		marker, err := git.FindMarkerIn(repo)
+		if err != nil || marker == "" || seen[marker] {
+			continue
+		}
+		seen[marker] = true
+		if binding, _ := git.GetBindingIn(repo); binding == name {
+			markers = append(markers, marker)
+		}
+	}
+	return markers, nil
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/git"
+)
+
+func TestUnsharedKeys(t *testing.T) {
+	deleted := &config.Context{
+		Name: "old", Hostname: "github.com", SSHKey: "~/.ssh/id_work", SSHManaged: true,
+		Extra: []config.HostEntry{{Hostname: "ghec.corp", User: "me", SSHKey: "~/.ssh/id_ghec"}},
+	}
+	targets := contextKeys(deleted)
+
+	tests := []struct {
+		name   string
+		others []*config.Context
+		want   []hostKey
+	}{
+		{"no other contexts", nil, targets},
+		{
+			"same key and block elsewhere",
+			[]*config.Context{{Name: "work", Hostname: "GitHub.com", SSHKey: "id_work", SSHManaged: true}},
+			[]hostKey{{"ghec.corp", "~/.ssh/id_ghec"}},
+		},
+		{
+			"extra host of another context",
+			[]*config.Context{{Name: "multi", Hostname: "gitlab.example", SSHManaged: true,
+				Extra: []config.HostEntry{{Hostname: "ghec.corp", SSHKey: "~/.ssh/id_ghec"}}}},
+			[]hostKey{{"github.com", "~/.ssh/id_work"}},
+		},
+		{
+			"same key in another block",
+			[]*config.Context{{Name: "alias", Hostname: "github.com", SSHHost: "github-work", SSHKey: "~/.ssh/id_work", SSHManaged: true}},
+			targets,
+		},
+		{
+			"other context doesn't manage SSH",
+			[]*config.Context{{Name: "token", Hostname: "github.com", SSHKey: "~/.ssh/id_work", SSHManaged: false}},
+			targets,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unsharedKeys(targets, tt.others)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unsharedKeys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// withStdin feeds input to the prompts read from stdin for the rest of the test.
+func withStdin(t *testing.T, input string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = oldStdin
+		f.Close()
+	})
+}
+
+func TestDeleteGlob(t *testing.T) {
+	tests := []struct {
+		name  string
+		force bool
+		yes   bool
+		stdin string
+		want  []string // Contexts left
+	}{
+		{"skips the active context", false, true, "", []string{"other", "proj-b"}},
+		{"forced", true, true, "", []string{"other"}},
+		{"confirmed", false, false, "y\n", []string{"other", "proj-b"}},
+		{"declined", false, false, "n\n", []string{"other", "proj-a", "proj-b", "proj-c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCmd(t,
+				&config.Context{Name: "proj-a", Hostname: "github.com", User: "a", Transport: "https"},
+				&config.Context{Name: "proj-b", Hostname: "github.com", User: "b", Transport: "https"},
+				&config.Context{Name: "proj-c", Hostname: "github.com", User: "c", Transport: "https"},
+				&config.Context{Name: "other", Hostname: "github.com", User: "d", Transport: "https"},
+			)
+			if err := config.SetActive("proj-b"); err != nil {
+				t.Fatal(err)
+			}
+			withStdin(t, tt.stdin)
+			deleteForce, deleteYes = tt.force, tt.yes
+			t.Cleanup(func() { deleteForce, deleteYes = false, false })
+
+			var err error
+			stdout, stderr := captureOutput(t, func() { err = runDelete(deleteCmd, []string{"proj-*"}) })
+			if err != nil {
+				t.Fatalf("delete: %v\n%s", err, stderr)
+			}
+			if got, _ := config.List(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("contexts left = %v, want %v", got, tt.want)
+			}
+			if skipped := strings.Contains(stdout, "Skipping active context 'proj-b'"); skipped == tt.force {
+				t.Errorf("skipped the active context: %v, want %v:\n%s", skipped, !tt.force, stdout)
+			}
+			if active, _ := config.GetActive(); (active == "") != tt.force {
+				t.Errorf("active = %q after deleting with --force=%v", active, tt.force)
+			}
+		})
+	}
+
+	t.Run("no match", func(t *testing.T) {
+		setupCmd(t, &config.Context{Name: "other", Hostname: "github.com", User: "d", Transport: "https"})
+		var err error
+		captureOutput(t, func() { err = runDelete(deleteCmd, []string{"proj-*"}) })
+		if ExitCode(err) != ExitNotFound {
+			t.Errorf("delete 'proj-*' = %v, want exit %d", err, ExitNotFound)
+		}
+	})
+}
+
+func TestDeletePurge(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "old", Hostname: "github.com", User: "old", Transport: "ssh", SSHKey: "~/.ssh/id_old", SSHManaged: true,
+			Extra: []config.HostEntry{{Hostname: "ghes.corp", User: "old", SSHKey: "~/.ssh/id_shared"}}},
+		&config.Context{Name: "twin", Hostname: "ghes.corp", User: "twin", Transport: "ssh", SSHKey: "~/.ssh/id_shared", SSHManaged: true},
+		&config.Context{Name: "keep", Hostname: "github.com", User: "me", Transport: "ssh", SSHKey: "~/.ssh/id_keep", SSHManaged: true},
+	)
+	home := os.Getenv("HOME")
+	sshConfig := filepath.Join(home, ".ssh", "config")
+	if err := os.MkdirAll(filepath.Dir(sshConfig), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sshConfig, []byte(`Host github.com
+    IdentityFile ~/.ssh/id_keep
+    # IdentityFile ~/.ssh/id_old
+
+Host ghes.corp
+    IdentityFile ~/.ssh/id_shared
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	current := newRepo(t, filepath.Join(home, "current"), "", "old")
+	root := filepath.Join(home, "src")
+	nested := newRepo(t, filepath.Join(root, "a", "nested"), "", "old")
+	other := newRepo(t, filepath.Join(root, "other"), "", "keep")
+	chdir(t, current)
+	deletePurge, deleteYes, deleteRoot = true, true, root
+	t.Cleanup(func() { deletePurge, deleteYes, deleteRoot = false, false, "" })
+
+	var err error
+	stdout, stderr := captureOutput(t, func() { err = runDelete(deleteCmd, []string{"old"}) })
+	if err != nil {
+		t.Fatalf("delete --purge: %v\n%s", err, stderr)
+	}
+	if exists, _ := config.Exists("old"); exists {
+		t.Error("context old still exists")
+	}
+
+	want := "Host github.com\n    IdentityFile ~/.ssh/id_keep\n\nHost ghes.corp\n    IdentityFile ~/.ssh/id_shared\n"
+	if got, _ := os.ReadFile(sshConfig); string(got) != want {
+		t.Errorf("SSH config =\n%s\nwant\n%s", got, want)
+	}
+	if !strings.Contains(stdout, "Keeping IdentityFile ~/.ssh/id_shared in Host ghes.corp: another context uses it") {
+		t.Errorf("kept shared key not reported:\n%s", stdout)
+	}
+
+	for repo, bound := range map[string]bool{current: false, nested: false, other: true} {
+		_, err := os.Stat(filepath.Join(repo, git.MarkerFile))
+		if got := err == nil; got != bound {
+			t.Errorf("%s: binding kept = %v, want %v", repo, got, bound)
+		}
+	}
+}
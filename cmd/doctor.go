@@ -0,0 +1,339 @@
+// ABOUTME: Doctor command for gh-context - checks the whole setup without changing it
+// ABOUTME: --report emits a redacted JSON bundle of versions, paths and per-context results for bug reports
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check every context and the SSH config, optionally as a JSON report",
+	Long: `Check the gh-context setup without changing anything: gh's version, where
+contexts and ~/.ssh/config live, and for each context whether its user is logged
+in, its SSH key exists with safe permissions and is registered on the account,
+a passphrase-protected key is loaded in ssh-agent, and its Host block
+references it.
+
+--report prints the same information as JSON to paste into an issue. It never
+includes tokens or key contents; the home directory is shown as ~, credentials
+in proxy URLs are dropped, git config values are shown only for keys that can't
+hold secrets (user.name, user.email, user.signingkey, core.sshCommand and
+commit.gpgsign), and email addresses are replaced unless you pass --show-emails.`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+var (
+	doctorReport     bool
+	doctorShowEmails bool
+)
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorReport, "report", false, "Print a redacted JSON report for bug reports")
+	doctorCmd.Flags().BoolVar(&doctorShowEmails, "show-emails", false, "Don't redact email addresses in the report")
+}
+
+// doctorReportData is the JSON form of a doctor run.
+type doctorReportData struct {
+	GhVersion   string          `json:"ghVersion"`
+	OS          string          `json:"os"`
+	Paths       doctorPaths     `json:"paths"`
+	Active      string          `json:"active,omitempty"`
+	Contexts    []doctorContext `json:"contexts"`
+	SSHWarnings []string        `json:"sshWarnings,omitempty"`
+}
+
+// doctorPaths lists the files and directories gh-context reads.
+type doctorPaths struct {
+	Contexts  string `json:"contexts"`
+	Settings  string `json:"settings"`
+	SSHConfig string `json:"sshConfig"`
+	BackupDir string `json:"sshBackupDir,omitempty"`
+}
+
+// doctorContext holds one context's settings and check results.
+type doctorContext struct {
+	Name        string            `json:"name"`
+	Hostname    string            `json:"hostname"`
+	User        string            `json:"user"`
+	Transport   string            `json:"transport"`
+	SSHKey      string            `json:"sshKey,omitempty"`
+	SSHHost     string            `json:"sshHost,omitempty"`
+	Proxy       string            `json:"proxy,omitempty"`
+	GitConfig   map[string]string `json:"gitConfig,omitempty"`
+	LoggedIn    string            `json:"loggedIn"`              // yes, no, or unknown
+	TokenSource string            `json:"tokenSource,omitempty"` // Where gh gets the token, never the token
+	KeyStatus   string            `json:"keyStatus,omitempty"`   // ok, missing or insecure
+	Fingerprint string            `json:"keyFingerprint,omitempty"`
+	Encrypted   *bool             `json:"keyEncrypted,omitempty"`  // Key has a passphrase, so needs the agent
+	InAgent     *bool             `json:"keyInAgent,omitempty"`    // With Encrypted: ssh-agent holds the key
+	Registered  *bool             `json:"keyRegistered,omitempty"` // Key is among the account's SSH keys on the host
+	KeyInBlock  *bool             `json:"keyInHostBlock,omitempty"`
+	Problems    []string          `json:"problems,omitempty"`
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	report, err := collectDoctorReport()
+	if err != nil {
+		return err
+	}
+
+	if doctorReport {
+		redactReport(report, !doctorShowEmails)
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	printPlain("gh: %s (%s)", report.GhVersion, report.OS)
+	printPlain("Contexts: %s", report.Paths.Contexts)
+	printPlain("SSH config: %s", report.Paths.SSHConfig)
+	fmt.Println()
+
+	problems := 0
+	for _, c := range report.Contexts {
+		if len(c.Problems) == 0 {
+			printOk("%s (%s@%s)", c.Name, c.User, c.Hostname)
+			continue
+		}
+		printErr("%s (%s@%s)", c.Name, c.User, c.Hostname)
+		for _, p := range c.Problems {
+			printPlain("    %s", p)
+		}
+		problems += len(c.Problems)
+	}
+	for _, w := range report.SSHWarnings {
+		printInfo("SSH config: gh-context can't model this line: %s", w)
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problem(s) found", problems)
+	}
+	return nil
+}
+
+// collectDoctorReport runs every check. It only reads: gh auth status is
+// consulted and each account's SSH keys are listed with its own token, but
+// nothing is switched.
+func collectDoctorReport() (*doctorReportData, error) {
+	contexts, err := config.ListContexts()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &doctorReportData{OS: runtime.GOOS + "/" + runtime.GOARCH, Contexts: []doctorContext{}}
+	report.GhVersion, err = auth.GhVersion()
+	if err != nil {
+		report.GhVersion = fmt.Sprintf("unknown (%v)", err)
+	} else if report.GhVersion == "" {
+		report.GhVersion = "unknown"
+	}
+	report.Active, _ = config.GetActive()
+	report.Paths.Contexts, _ = config.ContextDir()
+	report.Paths.Settings, _ = config.SettingsFile()
+	report.Paths.SSHConfig = ssh.DefaultConfigPath()
+
+	sshCfg, sshErr := loadSSHConfig()
+	if sshErr == nil {
+		report.Paths.BackupDir = sshCfg.BackupDir
+	}
+
+	// The agent is asked once, and only if some key has a passphrase
+	var agentKeys []string
+	var agentErr error
+	agentAsked := false
+
+	warned := make(map[string]bool)
+	for _, ctx := range contexts {
+		c := doctorContext{
+			Name:      ctx.Name,
+			Hostname:  ctx.Hostname,
+			User:      ctx.User,
+			Transport: ctx.Transport,
+			SSHKey:    ctx.SSHKey,
+			SSHHost:   ctx.SSHHost,
+			Proxy:     ctx.Proxy,
+			GitConfig: ctx.GitConfig,
+			LoggedIn:  "unknown",
+		}
+
+		if ctx.Hostname == "" {
+			c.Problems = append(c.Problems, "no host saved (use --host with 'use')")
+		} else if loggedIn, err := auth.CheckLogin(ctx.Hostname, ctx.User); err != nil {
+			c.Problems = append(c.Problems, fmt.Sprintf("gh auth: %v", err))
+		} else if loggedIn {
+			c.LoggedIn = "yes"
+			c.TokenSource, _ = auth.TokenSource(ctx.Hostname)
+		} else {
+			c.LoggedIn = "no"
+			c.Problems = append(c.Problems, fmt.Sprintf("gh auth: %s isn't logged in on %s", ctx.User, ctx.Hostname))
+		}
+
+		if ctx.SSHKey != "" {
+			status, mode := ssh.CheckKey(ctx.SSHKey)
+			c.KeyStatus = string(status)
+			c.Fingerprint, _ = ssh.KeyFingerprint(ctx.SSHKey)
+			if c.Fingerprint != "" && c.LoggedIn == "yes" && ctx.Transport == "ssh" {
+				var keys []auth.KeyInfo
+				err := applyContextProxy(ctx)
+				if err == nil {
+					keys, err = auth.SSHKeys(ctx.Hostname, ctx.User)
+				}
+				if err != nil {
+					c.Problems = append(c.Problems, fmt.Sprintf("registered SSH keys: %v", err))
+				} else {
+					registered := auth.HasSSHKey(keys, c.Fingerprint)
+					c.Registered = &registered
+					if !registered {
+						c.Problems = append(c.Problems, fmt.Sprintf("SSH key %s (%s) not registered on %s's account on %s", ctx.SSHKey, c.Fingerprint, ctx.User, ctx.Hostname))
+					}
+				}
+			}
+			switch status {
+			case ssh.KeyMissing:
+				c.Problems = append(c.Problems, fmt.Sprintf("SSH key %s not found", ctx.SSHKey))
+			case ssh.KeyInsecure:
+				c.Problems = append(c.Problems, fmt.Sprintf("SSH key %s has permissions %04o (run: chmod 600 %s)", ctx.SSHKey, mode, fsutil.ExpandPath(ctx.SSHKey)))
+			}
+
+			if encrypted, err := ssh.IsKeyEncrypted(ctx.SSHKey); err == nil && encrypted {
+				c.Encrypted = &encrypted
+				if !agentAsked {
+					agentKeys, agentErr = ssh.AgentFingerprints()
+					agentAsked = true
+				}
+				held := false
+				for _, fp := range agentKeys {
+					if c.Fingerprint != "" && fp == c.Fingerprint {
+						held = true
+					}
+				}
+				if agentErr == nil && c.Fingerprint != "" {
+					c.InAgent = &held
+				}
+				if !held {
+					c.Problems = append(c.Problems, fmt.Sprintf("SSH key %s is passphrase-protected; ensure ssh-agent holds it (run: ssh-add %s)", ctx.SSHKey, fsutil.ExpandPath(ctx.SSHKey)))
+				}
+			} else if err == nil {
+				c.Encrypted = &encrypted
+			}
+		}
+
+		if sshErr != nil {
+			c.Problems = append(c.Problems, fmt.Sprintf("SSH config: %v", sshErr))
+		} else if ctx.SSHKey != "" && ctx.Transport == "ssh" && ctx.SSHManaged && !ctx.UsesGitCommand() {
+			host := ctx.SSHBlockHost()
+			block := sshCfg.FindHostBlock(host)
+			inBlock := block != nil && block.HasIdentityFile(ctx.SSHKey)
+			c.KeyInBlock = &inBlock
+			if !inBlock {
+				c.Problems = append(c.Problems, fmt.Sprintf("Host %s doesn't reference %s", host, ctx.SSHKey))
+			}
+			for _, w := range sshCfg.Warnings(host) {
+				if !warned[w] {
+					warned[w] = true
+					report.SSHWarnings = append(report.SSHWarnings, w)
+				}
+			}
+		}
+
+		report.Contexts = append(report.Contexts, c)
+	}
+	sort.Strings(report.SSHWarnings)
+
+	return report, nil
+}
+
+// emailPattern matches email addresses for redaction.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// reportGitKeys are the git config keys whose values a report shows; others,
+// such as http.<url>.extraheader or credential.helper, can carry secrets.
+var reportGitKeys = map[string]bool{
+	"user.name":       true,
+	"user.email":      true,
+	"user.signingkey": true,
+	"core.sshcommand": true,
+	"commit.gpgsign":  true,
+}
+
+// redactReport removes anything personal or secret from a report before it
+// is shared: the home directory becomes ~, proxy credentials are dropped, git
+// config values outside reportGitKeys are hidden, and (with emails) email
+// addresses are replaced.
+func redactReport(report *doctorReportData, emails bool) {
+	home, _ := os.UserHomeDir()
+	redact := func(s string) string {
+		if home != "" && home != "/" {
+			s = strings.ReplaceAll(s, home, "~")
+		}
+		if emails {
+			s = emailPattern.ReplaceAllString(s, "[email redacted]")
+		}
+		return s
+	}
+
+	report.GhVersion = redact(report.GhVersion)
+	report.Paths.Contexts = redact(report.Paths.Contexts)
+	report.Paths.Settings = redact(report.Paths.Settings)
+	report.Paths.SSHConfig = redact(report.Paths.SSHConfig)
+	report.Paths.BackupDir = redact(report.Paths.BackupDir)
+	for i, w := range report.SSHWarnings {
+		report.SSHWarnings[i] = redact(w)
+	}
+
+	for i := range report.Contexts {
+		c := &report.Contexts[i]
+		c.SSHKey = redact(c.SSHKey)
+		c.TokenSource = redact(c.TokenSource)
+		c.Proxy = redact(redactProxy(c.Proxy))
+		if len(c.GitConfig) > 0 {
+			gitConfig := make(map[string]string, len(c.GitConfig))
+			for k, v := range c.GitConfig {
+				if reportGitKeys[strings.ToLower(k)] {
+					gitConfig[k] = redact(v)
+				} else {
+					gitConfig[k] = "<redacted>"
+				}
+			}
+			c.GitConfig = gitConfig
+		}
+		for j, p := range c.Problems {
+			c.Problems[j] = redact(p)
+		}
+	}
+}
+
+// redactProxy drops the user and password from a proxy URL, with or without
+// a scheme: everything before the last @ of the host part goes.
+func redactProxy(proxy string) string {
+	scheme, rest, found := strings.Cut(proxy, "://")
+	if !found {
+		scheme, rest = "", proxy
+	}
+	host, path := rest, ""
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		host, path = rest[:i], rest[i:]
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if found {
+		return scheme + "://" + host + path
+	}
+	return host + path
+}
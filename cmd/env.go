@@ -0,0 +1,108 @@
+// ABOUTME: Env command for gh-context - prints shell exports for a context
+// ABOUTME: Exports GIT_SSH_COMMAND so git uses the context's key without any config edits
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env [name]",
+	Short: "Print shell commands that point git at a context's SSH key",
+	Long: `Print a GIT_SSH_COMMAND export ("ssh -i <key> -o IdentitiesOnly=yes") for a
+context (default: the active one), so git in this shell uses its key without
+~/.ssh/config or the repo's git config being touched. This is the shell-wide
+form of the git-command strategy (see 'new --strategy'). A context without an
+SSH key unsets the variable.
+
+Examples:
+  eval "$(gh context env work)"
+  gh context env work --shell fish | source
+  gh context env work --shell powershell | Invoke-Expression`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runEnv,
+}
+
+var envShell string
+
+func init() {
+	envCmd.Flags().StringVar(&envShell, "shell", "sh", "Syntax to print: sh (bash/zsh), fish or powershell")
+}
+
+func runEnv(cmd *cobra.Command, args []string) error {
+	if err := validateEnvShell(envShell); err != nil {
+		return err
+	}
+
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	} else {
+		active, err := config.GetActive()
+		if err != nil {
+			return err
+		}
+		if active == "" {
+			printErr("No active context; pass a context name")
+			return fmt.Errorf("no active context")
+		}
+		name = active
+	}
+
+	ctx, err := config.Load(name)
+	if err != nil {
+		if exists, _ := config.Exists(name); !exists {
+			reportMissingContext(name)
+		}
+		return err
+	}
+
+	printPlain("%s", contextEnv(ctx, envShell))
+	return nil
+}
+
+// validateEnvShell checks a --shell value for env-style output.
+func validateEnvShell(shell string) error {
+	switch shell {
+	case "sh", "fish", "powershell":
+		return nil
+	}
+	return usageErrorf("shell must be 'sh', 'fish' or 'powershell', got: %s", shell)
+}
+
+// contextEnv returns the shell statement pointing git at ctx's SSH key, or
+// clearing GIT_SSH_COMMAND for a context without one.
+func contextEnv(ctx *config.Context, shell string) string {
+	if ctx.SSHKey == "" || ctx.Transport != "ssh" || !ctx.SSHManaged {
+		return unsetEnv(shell, "GIT_SSH_COMMAND")
+	}
+	return exportEnv(shell, "GIT_SSH_COMMAND", ssh.GitSSHCommand(ctx.SSHKey))
+}
+
+// exportEnv returns the statement setting an environment variable in shell.
+func exportEnv(shell, name, value string) string {
+	switch shell {
+	case "fish":
+		return fmt.Sprintf("set -gx %s '%s'", name, strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value))
+	case "powershell":
+		return fmt.Sprintf("$env:%s = '%s'", name, strings.ReplaceAll(value, "'", "''"))
+	}
+	return fmt.Sprintf("export %s='%s'", name, strings.ReplaceAll(value, "'", `'\''`))
+}
+
+// unsetEnv returns the statement removing an environment variable in shell.
+func unsetEnv(shell, name string) string {
+	switch shell {
+	case "fish":
+		return fmt.Sprintf("set -e %s", name)
+	case "powershell":
+		return fmt.Sprintf("Remove-Item Env:%s -ErrorAction SilentlyContinue", name)
+	}
+	return fmt.Sprintf("unset %s", name)
+}
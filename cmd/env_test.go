@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+)
+
+func TestContextEnv(t *testing.T) {
+	work := &config.Context{Name: "work", Hostname: "github.com", User: "me", Transport: "ssh",
+		SSHKey: "~/.ssh/id_o'brien", SSHManaged: true, Strategy: config.StrategyGitCommand}
+	web := &config.Context{Name: "web", Hostname: "github.com", User: "me", Transport: "https"}
+
+	for shell, want := range map[string]string{
+		"sh":         "export GIT_SSH_COMMAND=",
+		"fish":       "set -gx GIT_SSH_COMMAND ",
+		"powershell": "$env:GIT_SSH_COMMAND = ",
+	} {
+		if got := contextEnv(work, shell); !strings.HasPrefix(got, want) {
+			t.Errorf("contextEnv(work, %s) = %q, want it to start %q", shell, got, want)
+		}
+	}
+	for shell, want := range map[string]string{
+		"sh":         "unset GIT_SSH_COMMAND",
+		"fish":       "set -e GIT_SSH_COMMAND",
+		"powershell": "Remove-Item Env:GIT_SSH_COMMAND -ErrorAction SilentlyContinue",
+	} {
+		if got := contextEnv(web, shell); got != want {
+			t.Errorf("contextEnv(web, %s) = %q, want %q", shell, got, want)
+		}
+	}
+
+	// The export survives the quote in the key's path
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not installed")
+	}
+	out, err := exec.Command(sh, "-c", `eval "$1"; printf %s "$GIT_SSH_COMMAND"`, "sh", contextEnv(work, "sh")).Output()
+	if err != nil {
+		t.Fatalf("sh: %v", err)
+	}
+	if want := ssh.GitSSHCommand(work.SSHKey); string(out) != want {
+		t.Errorf("GIT_SSH_COMMAND = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,66 @@
+// ABOUTME: Exit codes for gh-context - lets scripts tell failure classes apart
+// ABOUTME: Maps usage, not-found, auth, SSH config and network errors to distinct process exit statuses
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+// Process exit codes. Anything not classified below exits with ExitError.
+const (
+	ExitOK       = 0
+	ExitError    = 1 // Any other failure
+	ExitUsage    = 2 // Unknown command or flag, bad arguments or flag values
+	ExitNotFound = 3 // Named context or profile doesn't exist
+	ExitAuth     = 4 // gh isn't (or didn't end up) logged in as the context's user
+	ExitSSH      = 5 // ~/.ssh/config couldn't be read, updated or saved
+	ExitNetwork  = 6 // Host unreachable, timed out or rate limited
+)
+
+// exitError attaches an exit code to an error.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExitCode marks err as exiting with code. A nil err stays nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// usageErrorf returns a usage error (exit code 2), for invalid flags and arguments.
+func usageErrorf(format string, args ...interface{}) error {
+	return withExitCode(ExitUsage, fmt.Errorf(format, args...))
+}
+
+// ExitCode returns the process exit code for an error returned by Execute.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	// A network failure explains whatever it interrupted, so it wins over
+	// the code the caller attached
+	if auth.IsRateLimited(err) || auth.IsUnreachable(err) {
+		return ExitNetwork
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	var notFound *config.NotFoundError
+	if errors.As(err, &notFound) {
+		return ExitNotFound
+	}
+	return ExitError
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+func TestExitCode(t *testing.T) {
+	unreachable := fmt.Errorf("%w: ghes.corp did not respond within 1s", auth.ErrUnreachable)
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, ExitOK},
+		{"unclassified", errors.New("boom"), ExitError},
+		{"usage", usageErrorf("bad flag"), ExitUsage},
+		{"not found", fmt.Errorf("loading: %w", &config.NotFoundError{Kind: "context", Name: "x"}), ExitNotFound},
+		{"auth", withExitCode(ExitAuth, errors.New("not logged in")), ExitAuth},
+		{"ssh", withExitCode(ExitSSH, errors.New("no Host block")), ExitSSH},
+		{"unreachable", unreachable, ExitNetwork},
+		{"rate limited", &auth.RateLimitError{Host: "github.com"}, ExitNetwork},
+		{"network wins over attached code", withExitCode(ExitAuth, unreachable), ExitNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUseExitCodes(t *testing.T) {
+	proxy := fakeAPI(t)
+	setupCmd(t,
+		&config.Context{Name: "https", Hostname: "github.localhost", User: "me", Transport: "https", Proxy: proxy},
+		&config.Context{Name: "limited", Hostname: "github.localhost", User: "rate-limited", Transport: "https", Proxy: proxy},
+		&config.Context{Name: "keyed", Hostname: "github.localhost", User: "me", Transport: "ssh", Proxy: proxy,
+			SSHKey: "~/.ssh/id_work", SSHManaged: true},
+	)
+	loginAs(t, "github.localhost/me", "github.localhost/rate-limited")
+
+	// The Host block lacks the context's key, so activating it fails
+	sshConfig := filepath.Join(os.Getenv("HOME"), ".ssh", "config")
+	if err := os.MkdirAll(filepath.Dir(sshConfig), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sshConfig, []byte("Host github.localhost\n  IdentityFile ~/.ssh/id_other\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		context string
+		setup   func()
+		want    int
+	}{
+		{"switched", "https", nil, ExitOK},
+		{"missing context", "nope", nil, ExitNotFound},
+		{"wrong account", "https", func() { os.WriteFile(os.Getenv("GH_PATH")+".as-github.localhost", []byte("someone-else"), 0644) }, ExitAuth},
+		{"key not in SSH config", "keyed", nil, ExitSSH},
+		{"rate limited", "limited", nil, ExitNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(os.Getenv("GH_PATH") + ".as-github.localhost")
+			if tt.setup != nil {
+				tt.setup()
+			}
+			err := useContext(useCmd, []string{tt.context}, switchFlags{})
+			if got := ExitCode(err); got != tt.want {
+				t.Errorf("use %s: exit code %d (%v), want %d", tt.context, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// ABOUTME: Import command for gh-context - creates contexts from a JSON file
+// ABOUTME: Accepts the output of "list -o json" and validates it before saving anything
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Create contexts from a JSON export (list -o json)",
+	Long: `Import contexts from a JSON file such as the output of "gh context list -o json".
+Use - to read from stdin.
+
+The whole file is validated before any context is saved: a record missing name,
+hostname, or user, or with a field of the wrong type, fails the import with the
+line it's on. Unknown fields are reported and ignored.
+
+--merge picks what happens to a context that already exists:
+  skip       leave the existing one alone (the default)
+  overwrite  replace it with the imported one (--force is the same)
+  rename     import it as <name>-imported (or <name>-imported-2, ...)
+Each context's outcome is reported, then a summary.
+
+Example:
+  gh context list -o json > contexts.json     # on the old machine
+  gh context import contexts.json             # on the new one
+  gh context import --merge=rename contexts.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+var (
+	importForce bool
+	importMerge string
+)
+
+// Strategies for --merge.
+const (
+	mergeSkip      = "skip"
+	mergeOverwrite = "overwrite"
+	mergeRename    = "rename"
+)
+
+func init() {
+	importCmd.Flags().BoolVar(&importForce, "force", false, "Overwrite contexts that already exist (same as --merge=overwrite)")
+	importCmd.Flags().StringVar(&importMerge, "merge", mergeSkip, "What to do with contexts that already exist: skip, overwrite or rename")
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	switch importMerge {
+	case mergeSkip, mergeOverwrite, mergeRename:
+	default:
+		return usageErrorf("merge must be 'skip', 'overwrite' or 'rename', got: %s", importMerge)
+	}
+	if importForce {
+		if cmd.Flags().Changed("merge") && importMerge != mergeOverwrite {
+			return usageErrorf("--force can't be combined with --merge=%s", importMerge)
+		}
+		importMerge = mergeOverwrite
+	}
+
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	contexts, warnings, err := config.ParseImport(data)
+	if err != nil {
+		printErr("%s: %v", args[0], err)
+		return err
+	}
+	for _, w := range warnings {
+		printInfo("%s: %s", args[0], w)
+	}
+
+	imported, overwritten, renamed, skipped := 0, 0, 0, 0
+	for _, ctx := range contexts {
+		exists, err := config.Exists(ctx.Name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := ctx.Save(); err != nil {
+				return err
+			}
+			printOk("Imported context '%s' → %s", ctx.Name, ctx)
+			imported++
+			continue
+		}
+
+		switch importMerge {
+		case mergeSkip:
+			printInfo("Skipping '%s': already exists (use --merge=overwrite or --merge=rename)", ctx.Name)
+			skipped++
+		case mergeOverwrite:
+			if err := ctx.Save(); err != nil {
+				return err
+			}
+			printOk("Overwrote context '%s' → %s", ctx.Name, ctx)
+			overwritten++
+		case mergeRename:
+			original := ctx.Name
+			if ctx.Name, err = importedName(original); err != nil {
+				return err
+			}
+			if err := ctx.Save(); err != nil {
+				return err
+			}
+			printOk("Imported context '%s' as '%s' → %s ('%s' already exists)", original, ctx.Name, ctx, original)
+			renamed++
+		}
+	}
+
+	summary := fmt.Sprintf("%d imported", imported)
+	switch importMerge {
+	case mergeOverwrite:
+		summary += fmt.Sprintf(", %d overwritten", overwritten)
+	case mergeRename:
+		summary += fmt.Sprintf(", %d renamed", renamed)
+	}
+	printPlain("%s, %d skipped", summary, skipped)
+	if imported+overwritten+renamed == 0 && skipped > 0 {
+		return fmt.Errorf("no contexts imported")
+	}
+	return nil
+}
+
+// importedName returns the first of <name>-imported, <name>-imported-2, ...
+// that isn't a saved context yet.
+func importedName(name string) (string, error) {
+	candidate := name + "-imported"
+	for n := 2; ; n++ {
+		exists, err := config.Exists(candidate)
+		if err != nil || !exists {
+			return candidate, err
+		}
+		candidate = fmt.Sprintf("%s-imported-%d", name, n)
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+func TestImportMerge(t *testing.T) {
+	const data = `[
+  {"name": "work", "hostname": "github.com", "user": "new-work", "transport": "https"},
+  {"name": "personal", "hostname": "github.com", "user": "new-me", "transport": "https"},
+  {"name": "fresh", "hostname": "ghes.corp", "user": "fresh", "transport": "https"}
+]`
+	tests := []struct {
+		strategy string
+		users    map[string]string // Context name to user after the import
+		summary  string
+	}{
+		{mergeSkip, map[string]string{"work": "work", "personal": "me", "work-imported": "stale", "fresh": "fresh"}, "1 imported, 2 skipped"},
+		{mergeOverwrite, map[string]string{"work": "new-work", "personal": "new-me", "work-imported": "stale", "fresh": "fresh"}, "1 imported, 2 overwritten, 0 skipped"},
+		{mergeRename, map[string]string{
+			"work": "work", "personal": "me", "work-imported": "stale", "fresh": "fresh",
+			"work-imported-2": "new-work", "personal-imported": "new-me",
+		}, "1 imported, 2 renamed, 0 skipped"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			setupCmd(t,
+				&config.Context{Name: "work", Hostname: "github.com", User: "work", Transport: "https"},
+				&config.Context{Name: "personal", Hostname: "github.com", User: "me", Transport: "https"},
+				&config.Context{Name: "work-imported", Hostname: "github.com", User: "stale", Transport: "https"},
+			)
+			file := filepath.Join(t.TempDir(), "contexts.json")
+			if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			importMerge = tt.strategy
+			t.Cleanup(func() { importMerge = mergeSkip })
+
+			var err error
+			stdout, stderr := captureOutput(t, func() { err = runImport(importCmd, []string{file}) })
+			if err != nil {
+				t.Fatalf("import --merge=%s: %v\n%s", tt.strategy, err, stderr)
+			}
+			users := make(map[string]string)
+			contexts, _ := config.ListContexts()
+			for _, ctx := range contexts {
+				users[ctx.Name] = ctx.User
+			}
+			if !reflect.DeepEqual(users, tt.users) {
+				t.Errorf("contexts after import = %v, want %v", users, tt.users)
+			}
+			if !strings.Contains(stdout, tt.summary) {
+				t.Errorf("summary missing %q:\n%s", tt.summary, stdout)
+			}
+			if n := strings.Count(stdout, "\n"); n != 4 {
+				t.Errorf("report has %d lines, want one per context and the summary:\n%s", n, stdout)
+			}
+		})
+	}
+
+	t.Run("nothing imported", func(t *testing.T) {
+		setupCmd(t, &config.Context{Name: "work", Hostname: "github.com", User: "work", Transport: "https"})
+		file := filepath.Join(t.TempDir(), "contexts.json")
+		if err := os.WriteFile(file, []byte(`[{"name": "work", "hostname": "github.com", "user": "x"}]`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		captureOutput(t, func() { err = runImport(importCmd, []string{file}) })
+		if err == nil {
+			t.Error("import skipping every context succeeded")
+		}
+	})
+}
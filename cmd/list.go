@@ -4,9 +4,19 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/cli/go-gh/v2/pkg/term"
+	"github.com/peterjmorgan/gh-context/internal/auth"
 	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
 	"github.com/spf13/cobra"
 )
 
@@ -14,46 +24,525 @@ var listCmd = &cobra.Command{
 	Use:     "list",
 	Aliases: []string{"ls"},
 	Short:   "List all contexts with active indicator",
-	Long:    `List all saved contexts, showing which one is currently active.`,
-	RunE:    runList,
+	Long: `List all saved contexts, showing which one is currently active.
+
+Use -o json for machine-readable records (each with an "active" boolean),
+and --active-only to list just the active context. Use --tree to group
+contexts under the host they belong to.
+
+--check-keys audits key hygiene without any network access: each context's
+SSH key is checked to exist and to be private to you (no group/other
+permissions), and problem rows are marked with ⚠️.
+
+--broken runs the doctor checks and lists only the contexts that fail one,
+with their problems. Add --fix to repair what can be repaired automatically:
+private keys are made private (chmod 600), a key missing from its Host block
+gets an IdentityFile line, and a logged-out user is offered gh auth login.
+A before/after summary for each context follows; problems needing a decision
+(a missing key or Host block, an unregistered key) are left for you.
+
+--since and --older-than filter by when each context was last switched to
+(by use, apply or use-profile), taking ages like 24h, 7d or 90d. --since 30d
+lists the contexts used in the last 30 days; --older-than 30d the stale rest,
+including contexts never switched to, e.g. to review before removing them:
+
+  gh context list --older-than 90d
+
+--verify checks that each listed context's user is logged in and that the API
+answers as that user, without switching accounts (as gh context test does). If
+the API rate limit runs out partway, the remaining checks are skipped and
+reported as such rather than as failures; a limit that resets within a few
+seconds is waited out instead.`,
+	RunE: runList,
+}
+
+var (
+	listOutput     string
+	listActiveOnly bool
+	listTree       bool
+	listCheckKeys  bool
+	listBroken     bool
+	listFix        bool
+	listSince      string
+	listOlderThan  string
+	listVerify     bool
+)
+
+func init() {
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "text", "Output format (text or json)")
+	listCmd.Flags().BoolVar(&listActiveOnly, "active-only", false, "Only list the active context")
+	listCmd.Flags().BoolVar(&listTree, "tree", false, "Group contexts by host")
+	listCmd.Flags().BoolVar(&listCheckKeys, "check-keys", false, "Flag SSH keys that are missing or readable by others")
+	listCmd.Flags().BoolVar(&listBroken, "broken", false, "Only list contexts failing a doctor check, with their problems")
+	listCmd.Flags().BoolVar(&listFix, "fix", false, "With --broken, repair the problems that can be fixed automatically")
+	listCmd.Flags().StringVar(&listSince, "since", "", "Only list contexts used within this age (e.g. 7d, 24h)")
+	listCmd.Flags().StringVar(&listOlderThan, "older-than", "", "Only list contexts not used within this age, or never (e.g. 30d)")
+	listCmd.Flags().BoolVar(&listVerify, "verify", false, "Check each context's gh login against the API (read-only)")
+}
+
+// listRecord is the JSON form of a saved context.
+type listRecord struct {
+	Name       string `json:"name"`
+	Hostname   string `json:"hostname"`
+	User       string `json:"user"`
+	Transport  string `json:"transport"`
+	SSHKey     string `json:"sshKey,omitempty"`
+	SSHHost    string `json:"sshHost,omitempty"`
+	SSHManaged bool   `json:"sshManaged"`
+	Strategy   string `json:"strategy,omitempty"`
+	Proxy      string `json:"proxy,omitempty"`
+	NoProxy    bool   `json:"noProxy,omitempty"`
+	Active     bool   `json:"active"`
+
+	LastUsed *time.Time `json:"lastUsed,omitempty"` // Last switched to; absent if never
+
+	GitConfig map[string]string `json:"gitConfig,omitempty"`
+	Extra     []listExtraHost   `json:"extraHosts,omitempty"`
+	KeyStatus string            `json:"keyStatus,omitempty"` // With --check-keys: ok, missing or insecure
+	Problems  []string          `json:"problems,omitempty"`  // With --broken: the failed doctor checks
+	Auth      string            `json:"auth,omitempty"`      // With --verify: verified, failed, unreachable or skipped
+}
+
+// listExtraHost is the JSON form of a multi-host context's additional host.
+type listExtraHost struct {
+	Hostname string `json:"hostname"`
+	User     string `json:"user"`
+	SSHKey   string `json:"sshKey,omitempty"`
+	SSHHost  string `json:"sshHost,omitempty"`
 }
 
 func runList(cmd *cobra.Command, args []string) error {
+	if listOutput != "text" && listOutput != "json" {
+		return usageErrorf("output must be 'text' or 'json', got: %s", listOutput)
+	}
+	if listTree && listOutput == "json" {
+		return usageErrorf("--tree only applies to text output")
+	}
+	if listFix && !listBroken {
+		return usageErrorf("--fix requires --broken")
+	}
+	if listFix && listOutput == "json" {
+		return usageErrorf("--fix only applies to text output")
+	}
+	if listFix && listVerify {
+		return usageErrorf("--verify can't be combined with --fix")
+	}
+	if listSince != "" && listOlderThan != "" {
+		return usageErrorf("--since can't be combined with --older-than")
+	}
+	var age time.Duration
+	for _, f := range []string{listSince, listOlderThan} {
+		if f == "" {
+			continue
+		}
+		var err error
+		if age, err = parseAge(f); err != nil {
+			return usageErrorf("%v", err)
+		}
+	}
+
 	contexts, err := config.ListContexts()
 	if err != nil {
 		return err
 	}
 
-	if len(contexts) == 0 {
-		printInfo("No contexts found. Create one with: gh context new --from-current --name <name>")
-		return nil
+	active, err := config.GetActive()
+	if err != nil {
+		return err
 	}
 
-	active, err := config.GetActive()
+	used, err := config.LastUsed()
 	if err != nil {
 		return err
 	}
+	if listSince != "" || listOlderThan != "" {
+		contexts = filterByAge(contexts, used, time.Now(), age, listSince != "")
+	}
 
-	printPlain("Available contexts:")
-	for _, ctx := range contexts {
-		indicator := ""
-		if ctx.Name == active {
-			indicator = " *"
+	if listActiveOnly {
+		var filtered []*config.Context
+		for _, ctx := range contexts {
+			if ctx.Name == active {
+				filtered = append(filtered, ctx)
+			}
 		}
+		contexts = filtered
+	}
 
-		sshInfo := ""
-		if ctx.SSHKey != "" {
-			sshInfo = fmt.Sprintf(", key=%s", ctx.SSHKey)
+	var checks map[string]doctorContext
+	if listBroken {
+		if contexts, checks, err = brokenContexts(contexts); err != nil {
+			return err
+		}
+		if listFix {
+			return fixContexts(contexts, checks)
+		}
+	}
+
+	var verified map[string]string
+	var skipped int
+	var limitErr error
+	if listVerify {
+		verified, skipped, limitErr = verifyContexts(contexts)
+	}
+
+	if listOutput == "json" {
+		records := make([]listRecord, 0, len(contexts))
+		for _, ctx := range contexts {
+			var extra []listExtraHost
+			for _, e := range ctx.Extra {
+				extra = append(extra, listExtraHost{Hostname: e.Hostname, User: e.User, SSHKey: e.SSHKey, SSHHost: e.SSHHost})
+			}
+			records = append(records, listRecord{
+				Name:       ctx.Name,
+				Hostname:   ctx.Hostname,
+				User:       ctx.User,
+				Transport:  ctx.Transport,
+				SSHKey:     ctx.SSHKey,
+				SSHHost:    ctx.SSHHost,
+				SSHManaged: ctx.SSHManaged,
+				Strategy:   ctx.Strategy,
+				Proxy:      ctx.Proxy,
+				NoProxy:    ctx.NoProxy,
+				Active:     ctx.Name == active,
+				GitConfig:  ctx.GitConfig,
+				Extra:      extra,
+			})
+			if listCheckKeys && ctx.SSHKey != "" {
+				status, _ := ssh.CheckKey(ctx.SSHKey)
+				records[len(records)-1].KeyStatus = string(status)
+			}
+			records[len(records)-1].Problems = checks[ctx.Name].Problems
+			if t, ok := used[ctx.Name]; ok {
+				records[len(records)-1].LastUsed = &t
+			}
+			records[len(records)-1].Auth = verified[ctx.Name]
+		}
+		if skipped > 0 {
+			printErr("%v, %d of %d checks skipped; try again after the limit resets", limitErr, skipped, len(contexts))
 		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+
+	if len(contexts) == 0 {
+		if listActiveOnly {
+			printInfo("No active context")
+			return nil
+		}
+		if listBroken {
+			printOk("No broken contexts")
+			return nil
+		}
+		if listSince != "" {
+			printInfo("No contexts used in the last %s", listSince)
+			return nil
+		}
+		if listOlderThan != "" {
+			printOk("No contexts unused for %s", listOlderThan)
+			return nil
+		}
+		printInfo("No contexts found. Create one with: gh context new --from-current --name <name>")
+		return nil
+	}
+
+	if listTree {
+		printTree(contexts, active, checks, verified)
+	} else {
+		printPlain("Available contexts:")
+		for _, ctx := range contexts {
+			indicator := ""
+			if ctx.Name == active {
+				indicator = " *"
+			}
 
-		fmt.Printf("  %s%s\t(%s@%s, %s%s)\n",
-			ctx.Name, indicator, ctx.User, ctx.Hostname, ctx.Transport, sshInfo)
+			sshInfo := ""
+			if ctx.SSHKey != "" {
+				sshInfo = fmt.Sprintf(", key=%s", ctx.SSHKey)
+			}
+
+			fmt.Printf("  %s%s\t(%s@%s, %s%s)%s%s\n",
+				ctx.Name, indicator, ctx.User, ctx.Hostname, ctx.Transport, sshInfo, keyWarning(ctx), authNote(verified[ctx.Name]))
+			for _, p := range checks[ctx.Name].Problems {
+				printPlain("      %s", p)
+			}
+		}
 	}
 
 	if active != "" {
 		fmt.Println()
 		printPlain("* = active context")
 	}
+	if skipped > 0 {
+		printErr("%v, %d of %d checks skipped; try again after the limit resets", limitErr, skipped, len(contexts))
+	}
+
+	return nil
+}
+
+// parseAge parses an age like 90d, 36h or 1h30m: a whole number of days, or
+// anything time.ParseDuration accepts.
+func parseAge(s string) (time.Duration, error) {
+	var age time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("age must be like 7d, 24h or 90m, got: %s", s)
+		}
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("age must be like 7d, 24h or 90m, got: %s", s)
+		}
+		age = d
+	}
+	if age <= 0 {
+		return 0, fmt.Errorf("age must be positive, got: %s", s)
+	}
+	return age, nil
+}
+
+// filterByAge keeps the contexts last used (per used) within age of now, or
+// with within false the rest, counting contexts never used as oldest.
+func filterByAge(contexts []*config.Context, used map[string]time.Time, now time.Time, age time.Duration, within bool) []*config.Context {
+	cutoff := now.Add(-age)
+	var filtered []*config.Context
+	for _, ctx := range contexts {
+		t, ok := used[ctx.Name]
+		recent := ok && !t.Before(cutoff)
+		if recent == within {
+			filtered = append(filtered, ctx)
+		}
+	}
+	return filtered
+}
+
+// noHostLabel heads the group of contexts saved without a hostname.
+const noHostLabel = "(no host)"
+
+// printTree prints contexts grouped under their host, hosts sorted by name,
+// each followed by its problems from checks (nil unless --broken). verified
+// holds the --verify results (nil without it).
+func printTree(contexts []*config.Context, active string, checks map[string]doctorContext, verified map[string]string) {
+	groups := make(map[string][]*config.Context)
+	var hosts []string
+	for _, ctx := range contexts {
+		host := ctx.Hostname
+		if host == "" {
+			host = noHostLabel
+		}
+		if _, ok := groups[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		groups[host] = append(groups[host], ctx)
+	}
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		printPlain("%s", host)
+		for _, ctx := range groups[host] {
+			indicator := ""
+			if ctx.Name == active {
+				indicator = " *"
+			}
+
+			sshInfo := ""
+			if ctx.SSHKey != "" {
+				sshInfo = fmt.Sprintf(", key=%s", ctx.SSHKey)
+			}
+
+			fmt.Printf("  %s%s\t(%s, %s%s)%s%s\n", ctx.Name, indicator, ctx.User, ctx.Transport, sshInfo, keyWarning(ctx), authNote(verified[ctx.Name]))
+			for _, p := range checks[ctx.Name].Problems {
+				printPlain("      %s", p)
+			}
+		}
+	}
+}
+
+// keyWarning returns the --check-keys annotation for a context's row, or
+// empty string when the check is off or the key is fine.
+func keyWarning(ctx *config.Context) string {
+	if !listCheckKeys || ctx.SSHKey == "" {
+		return ""
+	}
+	switch status, mode := ssh.CheckKey(ctx.SSHKey); status {
+	case ssh.KeyMissing:
+		return "  ⚠️  key missing"
+	case ssh.KeyInsecure:
+		return fmt.Sprintf("  ⚠️  key permissions %04o (run: chmod 600 %s)", mode, fsutil.ExpandPath(ctx.SSHKey))
+	}
+	return ""
+}
+
+// --verify results for a context
+const (
+	authVerified    = "verified"
+	authFailed      = "failed"
+	authUnreachable = "unreachable"
+	authSkipped     = "skipped" // Rate limited, or after the limit ran out
+)
+
+// verifyContexts runs the read-only gh auth check for each context, through
+// its own proxy settings, returning the result by name. A rate limit stops
+// the batch (see runChecks): the remaining contexts are marked skipped, and
+// their count is returned with the rate-limit error.
+func verifyContexts(contexts []*config.Context) (map[string]string, int, error) {
+	verified := make(map[string]string, len(contexts))
+	skipped, err := runChecks(len(contexts), func(i int) error {
+		ctx := contexts[i]
+		if err := applyContextProxy(ctx); err != nil {
+			verified[ctx.Name] = authUnreachable
+			return nil
+		}
+		ok, err := auth.TestAuth(ctx.Hostname, ctx.User)
+		switch {
+		case auth.IsRateLimited(err):
+			return err
+		case err != nil:
+			verified[ctx.Name] = authUnreachable
+		case ok:
+			verified[ctx.Name] = authVerified
+		default:
+			verified[ctx.Name] = authFailed
+		}
+		return nil
+	})
+	for _, ctx := range contexts[len(contexts)-skipped:] {
+		verified[ctx.Name] = authSkipped
+	}
+	return verified, skipped, err
+}
+
+// authNote returns the --verify annotation for a context's row, or empty
+// string when the check is off.
+func authNote(result string) string {
+	switch result {
+	case authVerified:
+		return "  ✓ verified"
+	case authFailed:
+		return "  ⚠️  not authenticated"
+	case authUnreachable:
+		return "  ⚠️  host unreachable"
+	case authSkipped:
+		return "  (not verified: rate limited)"
+	}
+	return ""
+}
+
+// brokenContexts runs the doctor checks and returns the contexts failing
+// one, along with every context's results by name.
+func brokenContexts(contexts []*config.Context) ([]*config.Context, map[string]doctorContext, error) {
+	report, err := collectDoctorReport()
+	if err != nil {
+		return nil, nil, err
+	}
+	checks := make(map[string]doctorContext, len(report.Contexts))
+	for _, c := range report.Contexts {
+		checks[c.Name] = c
+	}
 
+	var broken []*config.Context
+	for _, ctx := range contexts {
+		if len(checks[ctx.Name].Problems) > 0 {
+			broken = append(broken, ctx)
+		}
+	}
+	return broken, checks, nil
+}
+
+// fixContexts repairs what can be repaired without a decision in each broken
+// context, then runs the checks again and prints each context's before and
+// after. Returns an error if any problem remains.
+func fixContexts(contexts []*config.Context, checks map[string]doctorContext) error {
+	if len(contexts) == 0 {
+		printOk("No broken contexts")
+		return nil
+	}
+
+	active, _ := config.GetActive()
+	sshCfg, sshErr := loadSSHConfig()
+	sshChanged := false
+	prompted := make(map[string]bool)
+	fixes := make(map[string][]string)
+
+	for _, ctx := range contexts {
+		c := checks[ctx.Name]
+		fixed := func(format string, a ...interface{}) {
+			fixes[ctx.Name] = append(fixes[ctx.Name], fmt.Sprintf(format, a...))
+		}
+
+		if c.KeyStatus == string(ssh.KeyInsecure) {
+			if err := os.Chmod(fsutil.ExpandPath(ctx.SSHKey), 0600); err != nil {
+				printErr("%s: %v", ctx.Name, err)
+			} else {
+				fixed("chmod 600 %s", ctx.SSHKey)
+			}
+		}
+
+		if c.KeyInBlock != nil && !*c.KeyInBlock && sshErr == nil {
+			// Added commented out, as use expects, unless this context is
+			// active and the key should be in use now
+			host := ctx.SSHBlockHost()
+			if sshCfg.FindHostBlock(host) != nil {
+				err := sshCfg.AddIdentityFile(host, ctx.SSHKey, false)
+				if err == nil && ctx.Name == active {
+					err = sshCfg.ActivateKey(host, ctx.SSHKey)
+				}
+				if err != nil {
+					printErr("%s: %v", ctx.Name, err)
+				} else {
+					sshChanged = true
+					fixed("added IdentityFile %s to Host %s", ctx.SSHKey, host)
+				}
+			}
+		}
+
+		account := ctx.User + "@" + ctx.Hostname
+		if c.LoggedIn == "no" && !prompted[account] && term.IsTerminal(os.Stdin) {
+			prompted[account] = true
+			if confirm("%s: log in as %s on %s now?", ctx.Name, ctx.User, ctx.Hostname) {
+				if err := auth.Login(ctx.Hostname); err != nil {
+					printErr("%s: gh auth login: %v", ctx.Name, err)
+				} else {
+					fixed("ran gh auth login for %s", ctx.Hostname)
+				}
+			}
+		}
+	}
+
+	if sshChanged {
+		if err := sshCfg.Save(); err != nil {
+			printErr("Failed to save SSH config: %v", err)
+			return withExitCode(ExitSSH, err)
+		}
+	}
+
+	_, after, err := brokenContexts(nil)
+	if err != nil {
+		return err
+	}
+
+	remaining := 0
+	for _, ctx := range contexts {
+		before, now := len(checks[ctx.Name].Problems), after[ctx.Name].Problems
+		if len(now) == 0 {
+			printOk("%s: %d problem(s) → none", ctx.Name, before)
+		} else {
+			printErr("%s: %d problem(s) → %d", ctx.Name, before, len(now))
+		}
+		for _, f := range fixes[ctx.Name] {
+			printPlain("    fixed: %s", f)
+		}
+		for _, p := range now {
+			printPlain("    still: %s", p)
+		}
+		remaining += len(now)
+	}
+
+	if remaining > 0 {
+		return fmt.Errorf("%d problem(s) remain", remaining)
+	}
 	return nil
 }
@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+// throttledAPI stands in for the API like fakeAPI, but answers the calls
+// numbered in limited (from 1) with a rate-limit response carrying headers.
+// It returns its URL and the number of calls it has answered.
+func throttledAPI(t *testing.T, headers map[string]string, limited ...int32) (string, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		for _, l := range limited {
+			if n == l {
+				for k, v := range headers {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "{\"login\": %q}", strings.TrimPrefix(r.Header.Get("Authorization"), "token "))
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { auth.SetProxy(auth.Proxy{}) })
+	return srv.URL, &calls
+}
+
+// resetListFlags restores list's flags to their defaults.
+func resetListFlags() {
+	listOutput, listActiveOnly, listVerify, listSince, listOlderThan = "text", false, false, "", ""
+	listTree, listCheckKeys, listBroken, listFix = false, false, false, false
+}
+
+// runListJSON runs list -o json with only the flags set by set, returning
+// its records and stderr.
+func runListJSON(t *testing.T, set func()) ([]listRecord, string) {
+	t.Helper()
+	resetListFlags()
+	listOutput = "json"
+	set()
+	t.Cleanup(resetListFlags)
+
+	var err error
+	stdout, stderr := captureOutput(t, func() { err = runList(listCmd, nil) })
+	if err != nil {
+		t.Fatalf("list: %v\n%s", err, stderr)
+	}
+	var records []listRecord
+	if err := json.Unmarshal([]byte(stdout), &records); err != nil {
+		t.Fatalf("list output: %v\n%s", err, stdout)
+	}
+	return records, stderr
+}
+
+// verifyContextsOn returns three contexts on github.localhost routed through
+// proxy, and logs their users in.
+func verifyContextsOn(t *testing.T, proxy string) []*config.Context {
+	t.Helper()
+	var contexts []*config.Context
+	var accounts []string
+	for _, name := range []string{"a", "b", "c"} {
+		contexts = append(contexts, &config.Context{Name: name, Hostname: "github.localhost", User: "user-" + name, Transport: "https", Proxy: proxy})
+		accounts = append(accounts, "github.localhost/user-"+name)
+	}
+	setupCmd(t, contexts...)
+	loginAs(t, accounts...)
+	return contexts
+}
+
+func TestListVerifyStopsAtRateLimit(t *testing.T) {
+	proxy, calls := throttledAPI(t, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "4102444800"}, 2)
+	verifyContextsOn(t, proxy)
+
+	records, stderr := runListJSON(t, func() { listVerify = true })
+	want := map[string]string{"a": authVerified, "b": authSkipped, "c": authSkipped}
+	for _, r := range records {
+		if r.Auth != want[r.Name] {
+			t.Errorf("%s: auth = %q, want %q", r.Name, r.Auth, want[r.Name])
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("API called %d times, want 2 (none after the limit)", got)
+	}
+	if !strings.Contains(stderr, "2 of 3 checks skipped") {
+		t.Errorf("stderr doesn't report the skipped checks:\n%s", stderr)
+	}
+}
+
+func TestListVerifyWaitsOutShortRateLimit(t *testing.T) {
+	proxy, calls := throttledAPI(t, map[string]string{"Retry-After": "0"}, 2)
+	verifyContextsOn(t, proxy)
+
+	records, stderr := runListJSON(t, func() { listVerify = true })
+	for _, r := range records {
+		if r.Auth != authVerified {
+			t.Errorf("%s: auth = %q, want %q", r.Name, r.Auth, authVerified)
+		}
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("API called %d times, want 4 (one retry)", got)
+	}
+	if strings.Contains(stderr, "skipped") {
+		t.Errorf("a limit that already reset skipped checks:\n%s", stderr)
+	}
+}
+
+func TestListVerifyReportsFailures(t *testing.T) {
+	verifyContextsOn(t, fakeAPI(t))
+	loginAs(t, "github.localhost/user-a") // b and c are logged out
+
+	records, _ := runListJSON(t, func() { listVerify = true })
+	want := map[string]string{"a": authVerified, "b": authFailed, "c": authFailed}
+	for _, r := range records {
+		if r.Auth != want[r.Name] {
+			t.Errorf("%s: auth = %q, want %q", r.Name, r.Auth, want[r.Name])
+		}
+	}
+}
+
+func TestListVerifyTimesOut(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select { // Never answer
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(done) })
+	t.Cleanup(func() { auth.SetProxy(auth.Proxy{}) })
+	verifyContextsOn(t, srv.URL)
+
+	const timeout = 200 * time.Millisecond
+	if err := auth.SetTimeout(timeout); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { auth.SetTimeout(auth.DefaultTimeout) })
+
+	start := time.Now()
+	records, _ := runListJSON(t, func() { listVerify = true })
+	// Each of the three contexts may take up to the timeout, plus gh calls
+	if elapsed := time.Since(start); elapsed > 3*timeout+2*time.Second {
+		t.Errorf("list --verify took %s with a %s timeout", elapsed, timeout)
+	}
+	for _, r := range records {
+		if r.Auth != authUnreachable {
+			t.Errorf("%s: auth = %q, want %q", r.Name, r.Auth, authUnreachable)
+		}
+	}
+}
+
+func TestListActive(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "personal", Hostname: "github.com", User: "me", Transport: "https"},
+		&config.Context{Name: "work", Hostname: "github.com", User: "work", Transport: "https"},
+		&config.Context{Name: "corp", Hostname: "ghes.corp", User: "corp", Transport: "https"},
+	)
+	if err := config.SetActive("work"); err != nil {
+		t.Fatal(err)
+	}
+
+	records, _ := runListJSON(t, func() {})
+	if len(records) != 3 {
+		t.Fatalf("list returned %d records, want 3", len(records))
+	}
+	for _, r := range records {
+		if r.Active != (r.Name == "work") {
+			t.Errorf("%s: active = %v", r.Name, r.Active)
+		}
+	}
+
+	records, _ = runListJSON(t, func() { listActiveOnly = true })
+	if len(records) != 1 || records[0].Name != "work" || !records[0].Active {
+		t.Errorf("--active-only returned %+v, want only work", records)
+	}
+
+	// Composes with other filters: the active context was never used
+	records, _ = runListJSON(t, func() { listActiveOnly, listSince = true, "7d" })
+	if len(records) != 0 {
+		t.Errorf("--active-only --since 7d returned %+v, want none", records)
+	}
+}
+
+func TestListTree(t *testing.T) {
+	contexts := []*config.Context{
+		{Name: "personal", Hostname: "github.com", User: "me", Transport: "https"},
+		{Name: "admin", Hostname: "ghes.corp", User: "admin", Transport: "ssh", SSHKey: "~/.ssh/id_corp"},
+		{Name: "work", Hostname: "github.com", User: "work", Transport: "https"},
+		{Name: "legacy", User: "old", Transport: "https"},
+	}
+	stdout, _ := captureOutput(t, func() { printTree(contexts, "work", nil, nil) })
+
+	want := noHostLabel + "\n" +
+		"  legacy\t(old, https)\n" +
+		"ghes.corp\n" +
+		"  admin\t(admin, ssh, key=~/.ssh/id_corp)\n" +
+		"github.com\n" +
+		"  personal\t(me, https)\n" +
+		"  work *\t(work, https)\n"
+	if stdout != want {
+		t.Errorf("tree =\n%s\nwant\n%s", stdout, want)
+	}
+}
+
+func TestListCheckKeys(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "safe", Hostname: "github.com", User: "a", Transport: "ssh", SSHKey: "~/.ssh/id_safe"},
+		&config.Context{Name: "open", Hostname: "github.com", User: "b", Transport: "ssh", SSHKey: "~/.ssh/id_open"},
+		&config.Context{Name: "missing", Hostname: "github.com", User: "c", Transport: "ssh", SSHKey: "~/.ssh/id_missing"},
+		&config.Context{Name: "web", Hostname: "github.com", User: "d", Transport: "https"},
+	)
+	sshDir := filepath.Join(os.Getenv("HOME"), ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, mode := range map[string]os.FileMode{"id_safe": 0600, "id_open": 0644} {
+		path := filepath.Join(sshDir, name)
+		if err := os.WriteFile(path, nil, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil { // Past the umask
+			t.Fatal(err)
+		}
+	}
+	resetListFlags()
+	listCheckKeys = true
+	t.Cleanup(resetListFlags)
+
+	var err error
+	stdout, _ := captureOutput(t, func() { err = runList(listCmd, nil) })
+	if err != nil {
+		t.Fatalf("list --check-keys: %v", err)
+	}
+	rows := make(map[string]string)
+	for _, line := range strings.Split(stdout, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			rows[fields[0]] = line
+		}
+	}
+	for name, want := range map[string]string{
+		"safe":    "",
+		"open":    "key permissions 0644 (run: chmod 600 " + filepath.Join(sshDir, "id_open") + ")",
+		"missing": "key missing",
+		"web":     "",
+	} {
+		row, ok := rows[name]
+		if !ok {
+			t.Errorf("no row for %s:\n%s", name, stdout)
+			continue
+		}
+		if got := strings.Contains(row, "⚠️"); got != (want != "") || !strings.Contains(row, want) {
+			t.Errorf("%s: row %q, want warning %q", name, row, want)
+		}
+	}
+}
+
+func TestListBrokenFix(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "fine", Hostname: "github.com", User: "a", Transport: "https"},
+		&config.Context{Name: "open", Hostname: "github.com", User: "b", Transport: "https", SSHKey: "~/.ssh/id_open"},
+		&config.Context{Name: "unlisted", Hostname: "ghes.corp", User: "c", Transport: "ssh", SSHKey: "~/.ssh/id_unlisted", SSHManaged: true},
+		&config.Context{Name: "gone", Hostname: "github.com", User: "d", Transport: "https", SSHKey: "~/.ssh/id_gone"},
+		&config.Context{Name: "out", Hostname: "github.com", User: "e", Transport: "https"},
+	)
+	loginAs(t, "github.com/a", "github.com/b", "ghes.corp/c", "github.com/d") // e is logged out
+	sshDir := filepath.Join(os.Getenv("HOME"), ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	sshConfig := filepath.Join(sshDir, "config")
+	if err := os.WriteFile(sshConfig, []byte("Host ghes.corp\n    User git\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for name, mode := range map[string]os.FileMode{"id_open": 0644, "id_unlisted": 0600} {
+		path := filepath.Join(sshDir, name)
+		if err := os.WriteFile(path, nil, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil { // Past the umask
+			t.Fatal(err)
+		}
+	}
+	resetListFlags()
+	listBroken, listFix = true, true
+	t.Cleanup(resetListFlags)
+
+	var err error
+	stdout, stderr := captureOutput(t, func() { err = runList(listCmd, nil) })
+	if err == nil || !strings.Contains(err.Error(), "2 problem(s) remain") {
+		t.Errorf("list --broken --fix = %v, want 2 problems remaining", err)
+	}
+
+	if info, err := os.Stat(filepath.Join(sshDir, "id_open")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("id_open not made private: %v %v", info.Mode(), err)
+	}
+	if data, _ := os.ReadFile(sshConfig); !strings.Contains(string(data), "IdentityFile ~/.ssh/id_unlisted") {
+		t.Errorf("IdentityFile not added to Host ghes.corp:\n%s", data)
+	}
+
+	output := stdout + stderr
+	for _, want := range []string{
+		"open: 1 problem(s) → none",
+		"fixed: chmod 600 ~/.ssh/id_open",
+		"unlisted: 1 problem(s) → none",
+		"fixed: added IdentityFile ~/.ssh/id_unlisted to Host ghes.corp",
+		"gone: 1 problem(s) → 1",
+		"still: SSH key ~/.ssh/id_gone not found",
+		"out: 1 problem(s) → 1",
+		"still: gh auth: e isn't logged in on github.com",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("summary missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "fine:") {
+		t.Errorf("summary lists a healthy context:\n%s", output)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"7d":  7 * 24 * time.Hour,
+		"24h": 24 * time.Hour,
+		"90m": 90 * time.Minute,
+	} {
+		if got, err := parseAge(in); err != nil || got != want {
+			t.Errorf("parseAge(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "7", "d", "1w", "-3d", "0d", "0s"} {
+		if _, err := parseAge(in); err == nil {
+			t.Errorf("parseAge(%q) succeeded", in)
+		}
+	}
+}
+
+func TestFilterByAge(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	contexts := []*config.Context{{Name: "today"}, {Name: "last-week"}, {Name: "cutoff"}, {Name: "never"}}
+	used := map[string]time.Time{
+		"today":     now.Add(-time.Hour),
+		"last-week": now.Add(-8 * 24 * time.Hour),
+		"cutoff":    now.Add(-7 * 24 * time.Hour), // Exactly the age counts as within
+	}
+	names := func(contexts []*config.Context) []string {
+		var n []string
+		for _, ctx := range contexts {
+			n = append(n, ctx.Name)
+		}
+		return n
+	}
+
+	if got, want := names(filterByAge(contexts, used, now, 7*24*time.Hour, true)), []string{"today", "cutoff"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("--since 7d = %v, want %v", got, want)
+	}
+	if got, want := names(filterByAge(contexts, used, now, 7*24*time.Hour, false)), []string{"last-week", "never"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("--older-than 7d = %v, want %v", got, want)
+	}
+}
+
+func TestListAgeFlags(t *testing.T) {
+	setupCmd(t, &config.Context{Name: "work", Hostname: "github.com", User: "me", Transport: "https"})
+	for _, set := range []func(){
+		func() { listSince = "soon" },
+		func() { listOlderThan = "-1d" },
+		func() { listSince, listOlderThan = "7d", "30d" },
+	} {
+		resetListFlags()
+		set()
+		if err := runList(listCmd, nil); ExitCode(err) != ExitUsage {
+			t.Errorf("list --since %q --older-than %q = %v, want a usage error", listSince, listOlderThan, err)
+		}
+	}
+	resetListFlags()
+
+	// A context never switched to is older than any age
+	records, _ := runListJSON(t, func() { listOlderThan = "1h" })
+	if len(records) != 1 || records[0].Name != "work" || records[0].LastUsed != nil {
+		t.Errorf("--older-than 1h returned %+v, want the never-used work", records)
+	}
+	records, _ = runListJSON(t, func() { listSince = "1h" })
+	if len(records) != 0 {
+		t.Errorf("--since 1h returned %+v, want none", records)
+	}
+
+	if err := config.RecordUse("work"); err != nil {
+		t.Fatal(err)
+	}
+	records, _ = runListJSON(t, func() { listSince = "1h" })
+	if len(records) != 1 || records[0].LastUsed == nil {
+		t.Errorf("--since 1h after using work returned %+v, want work", records)
+	}
+}
@@ -0,0 +1,71 @@
+// ABOUTME: Migrate command for gh-context - upgrades contexts saved by the original bash script
+// ABOUTME: Backs up the contexts directory, then rewrites legacy files as current .ctx files; safe to re-run
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade contexts saved by the original bash script to the current format",
+	Long: `Upgrade context files left by the bash version of gh-context: flat files
+named after the context (no .ctx extension), shell-style lines (export, quoted
+values), the old SSH_HOST_ALIAS field, or a missing TRANSPORT.
+
+The contexts directory is copied to a timestamped contexts-backup-* directory
+beside it before anything is changed. Files already in the current format are
+left alone, so running it again does nothing.`,
+	Args: cobra.NoArgs,
+	RunE: runMigrate,
+}
+
+var migrateDryRun bool
+
+func init() {
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "List what would be migrated without changing anything")
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	migrations, err := config.FindMigrations()
+	if err != nil {
+		printErr("%v", err)
+		return err
+	}
+	if len(migrations) == 0 {
+		printOk("All contexts are in the current format; nothing to migrate")
+		return nil
+	}
+
+	pending := 0
+	for _, m := range migrations {
+		if m.Skip != "" {
+			printErr("%s: skipped, %s (merge it by hand)", m.Path, m.Skip)
+			continue
+		}
+		printPlain("  %s → %s.ctx (%s)", m.Path, m.Name, strings.Join(m.Reasons, ", "))
+		pending++
+	}
+	if pending == 0 {
+		return nil
+	}
+	if migrateDryRun {
+		printInfo("Dry run: %d context(s) would be migrated", pending)
+		return nil
+	}
+
+	backup, err := config.Migrate(migrations)
+	if backup != "" {
+		printInfo("Backed up the previous state to %s", backup)
+	}
+	if err != nil {
+		printErr("Migration failed: %v", err)
+		return err
+	}
+	printOk("Migrated %d context(s)", pending)
+	return nil
+}
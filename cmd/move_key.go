@@ -0,0 +1,81 @@
+// ABOUTME: Move-key command for gh-context - rotates an SSH key path everywhere
+// ABOUTME: Rewrites IdentityFile lines in ~/.ssh/config and SSH_KEY in every context
+
+package cmd
+
+import (
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var moveKeyCmd = &cobra.Command{
+	Use:   "move-key <old-path> <new-path>",
+	Short: "Replace an SSH key path in ~/.ssh/config and all contexts",
+	Long: `Rotate an SSH key by replacing every reference to its old path with the new one:
+- IdentityFile lines (commented or not) in every Host block, including included files
+- SSH_KEY in every saved context
+
+Use --activate to also make the new key the active one in each changed Host block.
+
+Example:
+  gh context move-key ~/.ssh/id_deploy ~/.ssh/id_deploy_2025 --activate`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMoveKey,
+}
+
+var moveKeyActivate bool
+
+func init() {
+	moveKeyCmd.Flags().BoolVar(&moveKeyActivate, "activate", false, "Activate the new key in each Host block that referenced the old one")
+}
+
+func runMoveKey(cmd *cobra.Command, args []string) error {
+	oldPath, newPath := args[0], args[1]
+
+	if !ssh.KeyExists(newPath) {
+		printErr("SSH key file not found: %s", fsutil.ExpandPath(newPath))
+		printInfo("Continuing anyway; create the key before using these contexts")
+	}
+
+	contexts, err := config.ListContexts()
+	if err != nil {
+		return err
+	}
+
+	sshCfg, err := loadSSHConfig()
+	if err != nil {
+		return err
+	}
+
+	lines := sshCfg.ReplaceIdentityFile(oldPath, newPath, moveKeyActivate)
+	if lines > 0 {
+		if err := sshCfg.Save(); err != nil {
+			return withExitCode(ExitSSH, err)
+		}
+		printSSHSaved(sshCfg)
+		printOk("Updated %d IdentityFile line(s)", lines)
+	} else {
+		printInfo("No IdentityFile lines reference %s", oldPath)
+	}
+
+	updated := 0
+	for _, ctx := range contexts {
+		if ctx.SSHKey == "" || !ssh.SamePath(ctx.SSHKey, oldPath) {
+			continue
+		}
+		ctx.SSHKey = newPath
+		if err := ctx.Save(); err != nil {
+			printErr("Failed to update context '%s': %v", ctx.Name, err)
+			continue
+		}
+		printOk("Updated context '%s'", ctx.Name)
+		updated++
+	}
+	if updated == 0 {
+		printInfo("No contexts reference %s", oldPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+)
+
+func TestMoveKey(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "deploy", Hostname: "github.com", User: "bot", Transport: "ssh", SSHKey: "~/.ssh/id_old"},
+		&config.Context{Name: "deploy-ghes", Hostname: "ghes.corp", User: "bot", Transport: "ssh", SSHKey: "~/.ssh/id_old"},
+		&config.Context{Name: "personal", Hostname: "github.com", User: "me", Transport: "ssh", SSHKey: "~/.ssh/id_personal"},
+	)
+	sshDir := filepath.Join(os.Getenv("HOME"), ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	sshConfig := filepath.Join(sshDir, "config")
+	if err := os.WriteFile(sshConfig, []byte(`Host github.com
+    IdentityFile ~/.ssh/id_personal
+    # IdentityFile ~/.ssh/id_old
+
+Host ghes.corp
+    IdentityFile ~/.ssh/id_old
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "id_new"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	moveKeyActivate = true
+	t.Cleanup(func() { moveKeyActivate = false })
+	if err := runMoveKey(moveKeyCmd, []string{"~/.ssh/id_old", "~/.ssh/id_new"}); err != nil {
+		t.Fatalf("move-key: %v", err)
+	}
+
+	data, err := os.ReadFile(sshConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "id_old") {
+		t.Errorf("SSH config still references the old key:\n%s", data)
+	}
+	cfg, err := ssh.ParseConfig(sshConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"github.com", "ghes.corp"} {
+		if got := cfg.GetActiveIdentityFile(host); got != "~/.ssh/id_new" {
+			t.Errorf("%s: active key = %q, want ~/.ssh/id_new", host, got)
+		}
+	}
+
+	for name, want := range map[string]string{"deploy": "~/.ssh/id_new", "deploy-ghes": "~/.ssh/id_new", "personal": "~/.ssh/id_personal"} {
+		ctx, err := config.Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ctx.SSHKey != want {
+			t.Errorf("%s: SSH key = %q, want %q", name, ctx.SSHKey, want)
+		}
+	}
+}
@@ -6,25 +6,58 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/cli/go-gh/v2/pkg/term"
 	"github.com/peterjmorgan/gh-context/internal/auth"
 	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+	"github.com/peterjmorgan/gh-context/internal/git"
 	"github.com/peterjmorgan/gh-context/internal/ssh"
 	"github.com/spf13/cobra"
 )
 
 var newCmd = &cobra.Command{
-	Use:   "new",
+	Use:   "new [name]",
 	Short: "Create a new context",
 	Long: `Create a new context from the current session or with explicit parameters.
 
-For SSH transport, the SSH key is required. When using --from-current, it will
-detect the currently active SSH key from your ~/.ssh/config file.
+The name may be given as an argument or with --name.
+
+For SSH transport, the SSH key is required. When using --from-current, it
+snapshots the active gh session: the host gh would use by default (or the only
+host you're logged in to), its active user, and the active IdentityFile for
+that host in your ~/.ssh/config.
+
+If --hostname names an SSH Host alias (e.g. "Host github-work" with
+"HostName github.com"), gh auth uses the real host and key switching
+edits the alias block. Use --ssh-host to name the alias explicitly; with
+--hostname as well, the two can be any names, such as two DNS names for the
+same GitHub Enterprise Server (gh authenticated under one, SSH using the other).
+
+--clone-url takes the host from a repository's clone URL instead (SSH or
+HTTPS form; an https:// URL also implies --transport https), and fills in the
+key already active for that host in ~/.ssh/config when --ssh-key is omitted.
+
+--also adds further hosts the same person uses (e.g. github.com plus a GHEC
+host): 'use' switches every host, and restores the previous accounts if any of
+them fails.
+
+--strategy git-command leaves ~/.ssh/config alone: 'use' writes the repo's
+core.sshCommand ("ssh -i <key> -o IdentitiesOnly=yes") instead, and
+'gh context env' exports the same command as GIT_SSH_COMMAND.
 
 Examples:
+  gh context new work --from-current
   gh context new --from-current --name work
   gh context new --from-current --name personal --ssh-key ~/.ssh/id_personal
-  gh context new --hostname github.com --user myuser --ssh-key ~/.ssh/id_mykey --name mycontext`,
+  gh context new --hostname github.com --user myuser --ssh-key ~/.ssh/id_mykey --name mycontext
+  gh context new --ssh-host github-work --user workuser --ssh-key ~/.ssh/id_work --name work
+  gh context new corp --hostname ghes.corp --ssh-host git.corp --user me --ssh-key ~/.ssh/id_corp
+  gh context new work --clone-url git@ghes.corp:org/repo.git --user workuser
+  gh context new work --from-current --also workuser@ghec.corp=~/.ssh/id_ghec
+  gh context new work --from-current --git-config core.sshCommand="ssh -i ~/.ssh/id_work"`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runNew,
 }
 
@@ -35,20 +68,48 @@ var (
 	newUser        string
 	newTransport   string
 	newSSHKey      string
+	newNoSSH       bool
+	newSSHHost     string
+	newProxy       string
+	newNoProxy     bool
+	newGitConfig   []string
+	newCloneURL    string
+	newAlso        []string
+	newStrategy    string
+	newSSHBanner   string
 )
 
 func init() {
-	newCmd.Flags().StringVar(&newName, "name", "", "Context name (required)")
+	newCmd.Flags().StringVar(&newName, "name", "", "Context name (or pass it as an argument)")
 	newCmd.Flags().BoolVar(&newFromCurrent, "from-current", false, "Create context from current gh session")
 	newCmd.Flags().StringVar(&newHostname, "hostname", "", "GitHub hostname (default: github.com)")
 	newCmd.Flags().StringVar(&newUser, "user", "", "GitHub username")
 	newCmd.Flags().StringVar(&newTransport, "transport", "ssh", "Transport protocol (ssh or https)")
 	newCmd.Flags().StringVar(&newSSHKey, "ssh-key", "", "Path to SSH key (e.g., ~/.ssh/id_personal)")
+	newCmd.Flags().StringVar(&newCloneURL, "clone-url", "", "Take the host (and key) from a repository clone URL")
+	newCmd.Flags().StringVar(&newSSHHost, "ssh-host", "", "SSH Host alias whose block holds the key (e.g., github-work)")
+	newCmd.Flags().BoolVar(&newNoSSH, "no-ssh", false, "Never modify ~/.ssh/config when switching to this context")
+	newCmd.Flags().StringVar(&newSSHBanner, "ssh-banner", "", "Regexp for a customized ssh -T greeting (GHES), capturing the user in its first group")
+	newCmd.Flags().StringVar(&newStrategy, "strategy", config.StrategySSHConfig, "How use applies the key: ssh-config (edit ~/.ssh/config) or git-command (write the repo's core.sshCommand)")
 
-	newCmd.MarkFlagRequired("name")
+	newCmd.Flags().StringVar(&newProxy, "proxy", "", "HTTP(S) proxy URL for API calls in this context")
+	newCmd.Flags().BoolVar(&newNoProxy, "no-proxy", false, "Bypass any proxy environment for this context")
+
+	newCmd.Flags().StringArrayVar(&newAlso, "also", nil, "Another host this identity uses, as USER@HOST[=SSH_KEY], switched together on use (repeatable)")
+	newCmd.Flags().StringArrayVar(&newGitConfig, "git-config", nil, "Local git config KEY=VALUE written to repos on use (repeatable)")
 }
 
 func runNew(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		if newName != "" && newName != args[0] {
+			return usageErrorf("context name given twice: '%s' and --name '%s'", args[0], newName)
+		}
+		newName = args[0]
+	}
+	if newName == "" {
+		return usageErrorf("context name is required: gh context new <name> or --name <name>")
+	}
+
 	// Validate context name
 	if err := config.ValidateName(newName); err != nil {
 		return err
@@ -63,18 +124,88 @@ func runNew(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("context '%s' already exists", newName)
 	}
 
-	var hostname, user, sshKey string
+	if err := config.ValidateStrategy(newStrategy); err != nil {
+		return withExitCode(ExitUsage, err)
+	}
 
-	if newFromCurrent {
-		// Get from current session
-		hostname = newHostname
-		if hostname == "" {
-			hostname = os.Getenv("GH_HOST")
+	if _, err := ssh.CompileBanner(newSSHBanner); err != nil {
+		return withExitCode(ExitUsage, err)
+	}
+
+	if newProxy != "" && newNoProxy {
+		return usageErrorf("--proxy and --no-proxy are mutually exclusive")
+	}
+	if err := auth.SetProxy(auth.Proxy{URL: newProxy, Disabled: newNoProxy}); err != nil {
+		return err
+	}
+
+	gitConfig, err := parseGitConfigFlags(newGitConfig)
+	if err != nil {
+		return err
+	}
+
+	extra, err := parseAlsoFlags(newAlso)
+	if err != nil {
+		return err
+	}
+
+	if newCloneURL != "" {
+		if newHostname != "" || newSSHHost != "" {
+			return usageErrorf("--clone-url can't be combined with --hostname or --ssh-host")
+		}
+		newHostname = git.ParseRemoteHost(newCloneURL)
+		if newHostname == "" {
+			return usageErrorf("no host in clone URL: %s", newCloneURL)
 		}
-		if hostname == "" {
-			hostname = "github.com"
+		if strings.HasPrefix(newCloneURL, "https://") && !cmd.Flags().Changed("transport") {
+			newTransport = "https"
+		}
+		printInfo("Using host %s from clone URL", newHostname)
+	}
+
+	var hostname, user, sshKey string
+
+	if !newFromCurrent && ((newHostname == "" && newSSHHost == "") || newUser == "") {
+		return usageErrorf("provide either --from-current or both --hostname (or --clone-url) and --user")
+	}
+
+	hostname = newHostname
+	if hostname == "" && !newFromCurrent {
+		hostname = newSSHHost
+	}
+	if hostname == "" {
+		hostname = os.Getenv("GH_HOST")
+	}
+	if hostname == "" && newSSHHost == "" {
+		hostname = discoverHost()
+	}
+	if hostname == "" {
+		hostname = auth.DefaultHost()
+	}
+
+	// Resolve SSH Host aliases (e.g. github-work → github.com): auth uses the
+	// canonical host, key switching uses the alias block
+	sshHost := newSSHHost
+	sshCfg, sshErr := loadSSHConfig()
+	if sshErr == nil {
+		if sshHost == "" {
+			if canonical := sshCfg.CanonicalHost(hostname); canonical != hostname {
+				sshHost = hostname
+				hostname = canonical
+			}
+		} else if newHostname == "" {
+			hostname = sshCfg.CanonicalHost(sshHost)
 		}
+	}
+	if sshHost != "" {
+		printInfo("Using SSH Host alias '%s' for %s", sshHost, hostname)
+	}
+	keyHost := hostname
+	if sshHost != "" {
+		keyHost = sshHost
+	}
 
+	if newFromCurrent {
 		// Get current user from API
 		currentUser, authErr := auth.GetCurrentUserFromSession(hostname)
 		if authErr != nil {
@@ -90,25 +221,25 @@ func runNew(cmd *cobra.Command, args []string) error {
 
 		// Get SSH key - from flag or detect from current config
 		sshKey = newSSHKey
-		if sshKey == "" && newTransport == "ssh" {
-			// Try to detect from SSH config
-			sshCfg, err := ssh.ParseConfig("")
-			if err == nil {
-				activeKey := sshCfg.GetActiveIdentityFile(hostname)
-				if activeKey != "" {
-					sshKey = activeKey
-					printInfo("Detected SSH key from config: %s", sshKey)
-				}
+		if sshKey == "" && newTransport == "ssh" && !newNoSSH && sshErr == nil {
+			activeKey := sshCfg.GetActiveIdentityFile(keyHost)
+			if activeKey != "" {
+				sshKey = activeKey
+				printInfo("Detected SSH key from config: %s", sshKey)
+			} else if agent := sshCfg.GetIdentityAgent(keyHost); agent != "" {
+				printInfo("Host %s is authenticating via agent: %s", keyHost, agent)
+				printInfo("Pass --ssh-key for the key to switch, or --no-ssh to leave SSH config alone")
 			}
 		}
 	} else {
-		// Explicit parameters required
-		if newHostname == "" || newUser == "" {
-			return fmt.Errorf("provide either --from-current or both --hostname and --user")
-		}
-		hostname = newHostname
 		user = newUser
 		sshKey = newSSHKey
+		if sshKey == "" && newCloneURL != "" && newTransport == "ssh" && !newNoSSH && sshErr == nil {
+			if activeKey := sshCfg.GetActiveIdentityFile(keyHost); activeKey != "" {
+				sshKey = activeKey
+				printInfo("Using SSH key active for %s: %s", keyHost, sshKey)
+			}
+		}
 	}
 
 	// Validate transport
@@ -116,29 +247,61 @@ func runNew(cmd *cobra.Command, args []string) error {
 	case "ssh", "https":
 		// Valid
 	default:
-		return fmt.Errorf("transport must be 'ssh' or 'https', got: %s", newTransport)
+		return usageErrorf("transport must be 'ssh' or 'https', got: %s", newTransport)
 	}
 
 	// For SSH transport, require SSH key
-	if newTransport == "ssh" && sshKey == "" {
+	if newTransport == "ssh" && sshKey == "" && !newNoSSH {
 		printErr("SSH key is required for SSH transport")
-		printInfo("Provide --ssh-key PATH or ensure your ~/.ssh/config has an active IdentityFile for %s", hostname)
+		printInfo("Provide --ssh-key PATH or ensure your ~/.ssh/config has an active IdentityFile for %s", keyHost)
+		if sshErr == nil {
+			if block := sshCfg.FindHostBlock(keyHost); block != nil {
+				for _, ifl := range block.IdentityFiles {
+					printInfo("  Candidate: --ssh-key %s", ifl.Path)
+				}
+			}
+		}
 		return fmt.Errorf("SSH key required")
 	}
 
 	// Validate SSH key exists if provided
 	if sshKey != "" && !ssh.KeyExists(sshKey) {
-		printErr("SSH key file not found: %s", ssh.ExpandPath(sshKey))
+		printErr("SSH key file not found: %s", fsutil.ExpandPath(sshKey))
 		return fmt.Errorf("SSH key not found")
 	}
 
 	// Create and save context
 	ctx := &config.Context{
-		Name:      newName,
-		Hostname:  hostname,
-		User:      user,
-		Transport: newTransport,
-		SSHKey:    sshKey,
+		Name:       newName,
+		Hostname:   hostname,
+		User:       user,
+		Transport:  newTransport,
+		SSHKey:     sshKey,
+		SSHHost:    sshHost,
+		SSHManaged: !newNoSSH,
+		Strategy:   newStrategy,
+		Proxy:      newProxy,
+		NoProxy:    newNoProxy,
+		SSHBanner:  newSSHBanner,
+		GitConfig:  gitConfig,
+		Extra:      extra,
+	}
+
+	for i, e := range ctx.Extra {
+		// An SSH Host alias authenticates against its real host, as for --hostname
+		if sshErr == nil {
+			if canonical := sshCfg.CanonicalHost(e.Hostname); canonical != e.Hostname {
+				ctx.Extra[i].SSHHost = e.Hostname
+				ctx.Extra[i].Hostname = canonical
+			}
+		}
+		if ctx.Extra[i].Hostname == hostname {
+			return usageErrorf("--also host %s is the context's own host", ctx.Extra[i].Hostname)
+		}
+		if e.SSHKey != "" && !ssh.KeyExists(e.SSHKey) {
+			printErr("SSH key file not found: %s", fsutil.ExpandPath(e.SSHKey))
+			return fmt.Errorf("SSH key not found")
+		}
 	}
 
 	if err := ctx.Save(); err != nil {
@@ -151,5 +314,62 @@ func runNew(cmd *cobra.Command, args []string) error {
 	}
 
 	printOk("Created context '%s' → %s@%s (%s%s)", newName, user, hostname, newTransport, sshInfo)
+	for _, e := range ctx.Extra {
+		printPlain("  also %s@%s", e.User, e.Hostname)
+	}
 	return nil
 }
+
+// parseAlsoFlags turns --also USER@HOST[=SSH_KEY] flags into host entries.
+func parseAlsoFlags(flags []string) ([]config.HostEntry, error) {
+	var entries []config.HostEntry
+	seen := make(map[string]bool)
+	for _, f := range flags {
+		account, key, _ := strings.Cut(f, "=")
+		user, host, ok := strings.Cut(strings.TrimSpace(account), "@")
+		if !ok || user == "" || host == "" {
+			return nil, usageErrorf("--also must be USER@HOST[=SSH_KEY], got: %s", f)
+		}
+		host = strings.ToLower(host)
+		if seen[host] {
+			return nil, usageErrorf("--also lists %s more than once", host)
+		}
+		seen[host] = true
+		entries = append(entries, config.HostEntry{Hostname: host, User: user, SSHKey: strings.TrimSpace(key)})
+	}
+	return entries, nil
+}
+
+// parseGitConfigFlags turns --git-config KEY=VALUE flags into a map.
+func parseGitConfigFlags(flags []string) (map[string]string, error) {
+	if len(flags) == 0 {
+		return nil, nil
+	}
+	entries := make(map[string]string)
+	for _, f := range flags {
+		key, value, ok := strings.Cut(f, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || !strings.Contains(key, ".") {
+			return nil, usageErrorf("--git-config must be KEY=VALUE with a section, e.g. core.sshCommand=..., got: %s", f)
+		}
+		entries[key] = strings.TrimSpace(value)
+	}
+	return entries, nil
+}
+
+// discoverHost picks a host from those gh is already logged in to: the only
+// one if there's just one, or the user's choice when stdin is a terminal.
+// Returns empty string to fall back to the default.
+func discoverHost() string {
+	hosts, err := auth.ListHosts()
+	if err != nil || len(hosts) == 0 {
+		return ""
+	}
+	if len(hosts) == 1 {
+		return hosts[0]
+	}
+	if !term.IsTerminal(os.Stdin) {
+		return ""
+	}
+	return choose("Which host is this context for?", hosts)
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+func TestNewFromCurrent(t *testing.T) {
+	proxy := fakeAPI(t)
+	setupCmd(t)
+	t.Setenv("GH_HOST", "github.localhost")
+	setGhUser(t, "github.localhost", "me")
+	sshConfig := filepath.Join(os.Getenv("HOME"), ".ssh", "config")
+	if err := os.MkdirAll(filepath.Dir(sshConfig), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sshConfig, []byte("Host github.localhost\n    # IdentityFile ~/.ssh/id_personal\n    IdentityFile ~/.ssh/id_work\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(sshConfig), "id_work"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	newFromCurrent, newProxy = true, proxy
+	t.Cleanup(func() { newName, newFromCurrent, newProxy = "", false, "" })
+	if err := runNew(newCmd, []string{"snap"}); err != nil {
+		t.Fatalf("new --from-current: %v", err)
+	}
+
+	ctx, err := config.Load("snap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Hostname != "github.localhost" || ctx.User != "me" {
+		t.Errorf("context = %s@%s, want the session's me@github.localhost", ctx.User, ctx.Hostname)
+	}
+	if ctx.SSHKey != "~/.ssh/id_work" {
+		t.Errorf("SSH key = %q, want the active ~/.ssh/id_work", ctx.SSHKey)
+	}
+}
+
+func TestNewFromCurrentLoggedOut(t *testing.T) {
+	proxy := fakeAPI(t)
+	setupCmd(t)
+	t.Setenv("GH_HOST", "github.localhost")
+
+	newFromCurrent, newProxy = true, proxy
+	t.Cleanup(func() { newName, newFromCurrent, newProxy = "", false, "" })
+	if err := runNew(newCmd, []string{"snap"}); err == nil {
+		t.Fatal("new --from-current succeeded without a gh session")
+	}
+	if exists, _ := config.Exists("snap"); exists {
+		t.Error("context saved without a gh session")
+	}
+}
+
+func TestNewCloneURL(t *testing.T) {
+	tests := []struct {
+		url           string
+		wantTransport string
+		wantKey       string
+	}{
+		{"git@ghes.corp:org/repo.git", "ssh", "~/.ssh/id_corp"},
+		{"https://ghes.corp/org/repo.git", "https", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			setupCmd(t)
+			sshDir := filepath.Join(os.Getenv("HOME"), ".ssh")
+			if err := os.MkdirAll(sshDir, 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(sshDir, "config"), []byte("Host ghes.corp\n    IdentityFile ~/.ssh/id_corp\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(sshDir, "id_corp"), nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			newCloneURL, newUser = tt.url, "me"
+			t.Cleanup(func() { newName, newCloneURL, newUser, newHostname, newTransport = "", "", "", "", "ssh" })
+			if err := runNew(newCmd, []string{"corp"}); err != nil {
+				t.Fatalf("new --clone-url: %v", err)
+			}
+
+			ctx, err := config.Load("corp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ctx.Hostname != "ghes.corp" || ctx.Transport != tt.wantTransport || ctx.SSHKey != tt.wantKey {
+				t.Errorf("context = host %q, transport %q, key %q; want ghes.corp, %q, %q",
+					ctx.Hostname, ctx.Transport, ctx.SSHKey, tt.wantTransport, tt.wantKey)
+			}
+		})
+	}
+}
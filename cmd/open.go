@@ -0,0 +1,81 @@
+// ABOUTME: Open command for gh-context - opens a context's account page in the browser
+// ABOUTME: Builds https://<host>/<user> and hands it to gh's browser launcher, or prints it with --print
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/cli/go-gh/v2/pkg/browser"
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var openCmd = &cobra.Command{
+	Use:   "open [name]",
+	Short: "Open a context's account page in the browser",
+	Long: `Open https://<host>/<user> for a context (default: the active one) in the
+browser gh is configured to use (GH_BROWSER, gh's browser setting, or BROWSER).
+
+Use --print to write the URL to stdout instead, for piping.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runOpen,
+}
+
+var openPrint bool
+
+func init() {
+	openCmd.Flags().BoolVar(&openPrint, "print", false, "Print the URL instead of opening it")
+}
+
+func runOpen(cmd *cobra.Command, args []string) error {
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	} else {
+		active, err := config.GetActive()
+		if err != nil {
+			return err
+		}
+		if active == "" {
+			printErr("No active context; pass a context name")
+			return fmt.Errorf("no active context")
+		}
+		name = active
+	}
+
+	ctx, err := config.Load(name)
+	if err != nil {
+		if exists, _ := config.Exists(name); !exists {
+			reportMissingContext(name)
+		}
+		return err
+	}
+
+	profile, err := profileURL(ctx)
+	if err != nil {
+		printErr("%v", err)
+		return err
+	}
+	if openPrint {
+		fmt.Println(profile)
+		return nil
+	}
+
+	printInfo("Opening %s in your browser", profile)
+	return browser.New("", os.Stdout, os.Stderr).Browse(profile)
+}
+
+// profileURL returns the web page of a context's account on its host.
+func profileURL(ctx *config.Context) (string, error) {
+	if ctx.Hostname == "" {
+		return "", fmt.Errorf("context '%s' has no host", ctx.Name)
+	}
+	if ctx.User == "" {
+		return "", fmt.Errorf("context '%s' has no user", ctx.Name)
+	}
+	u := url.URL{Scheme: "https", Host: ctx.Hostname, Path: "/" + ctx.User}
+	return u.String(), nil
+}
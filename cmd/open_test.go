@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+// stubBrowser points GH_BROWSER at a script that records the URLs it's asked
+// to open, and returns the file it records them in.
+func stubBrowser(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "opened")
+	script := filepath.Join(dir, "browser")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$1\" >> "+log+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GH_BROWSER", script)
+	return log
+}
+
+func TestOpen(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "personal", Hostname: "github.com", User: "me", Transport: "https"},
+		&config.Context{Name: "corp", Hostname: "ghes.corp:8443", User: "jdoe", Transport: "https"},
+	)
+	if err := config.SetActive("personal"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"active context", nil, "https://github.com/me"},
+		{"named GHES context", []string{"corp"}, "https://ghes.corp:8443/jdoe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := stubBrowser(t)
+			var err error
+			_, stderr := captureOutput(t, func() { err = runOpen(openCmd, tt.args) })
+			if err != nil {
+				t.Fatalf("open: %v\n%s", err, stderr)
+			}
+			if got, _ := os.ReadFile(log); string(got) != tt.want+"\n" {
+				t.Errorf("opened %q, want %q", got, tt.want)
+			}
+
+			openPrint = true
+			t.Cleanup(func() { openPrint = false })
+			log = stubBrowser(t)
+			stdout, _ := captureOutput(t, func() { err = runOpen(openCmd, tt.args) })
+			if err != nil {
+				t.Fatalf("open --print: %v", err)
+			}
+			if strings.TrimSpace(stdout) != tt.want {
+				t.Errorf("open --print = %q, want %q", stdout, tt.want)
+			}
+			if _, err := os.Stat(log); !os.IsNotExist(err) {
+				t.Errorf("open --print launched the browser")
+			}
+		})
+	}
+
+	t.Run("no host", func(t *testing.T) {
+		if _, err := profileURL(&config.Context{Name: "legacy", User: "old"}); err == nil {
+			t.Error("profileURL of a context without a host succeeded")
+		}
+	})
+}
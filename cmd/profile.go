@@ -0,0 +1,215 @@
+// ABOUTME: Profile commands for gh-context - switch several hosts at once
+// ABOUTME: new-profile saves host→context sets, use-profile applies them with rollback, delete-profile removes them
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/logging"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var newProfileCmd = &cobra.Command{
+	Use:   "new-profile <name> <context>...",
+	Short: "Create a profile grouping contexts for different hosts",
+	Long: `Create a profile: a named set of contexts, one per host, that can be applied together.
+
+Example:
+  gh context new-profile daytime personal work-ghes work-ghec`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runNewProfile,
+}
+
+var useProfileCmd = &cobra.Command{
+	Use:   "use-profile <name>",
+	Short: "Switch every host in a profile to its context",
+	Long: `Apply a profile. For each host in the profile this will:
+1. Switch gh CLI authentication to the context's user
+2. Activate the context's SSH key in ~/.ssh/config
+
+If any host fails to switch, hosts already switched are restored to their
+previous account and ~/.ssh/config is left untouched.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runUseProfile,
+}
+
+var deleteProfileCmd = &cobra.Command{
+	Use:   "delete-profile <name>",
+	Short: "Remove a saved profile",
+	Long: `Delete a profile. The contexts it groups are left alone.
+
+Example:
+  gh context delete-profile daytime`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDeleteProfile,
+}
+
+func runNewProfile(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if err := config.ValidateName(name); err != nil {
+		return err
+	}
+
+	exists, err := config.ProfileExists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("profile '%s' already exists", name)
+	}
+
+	profile, err := config.NewProfile(name, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if err := profile.Save(); err != nil {
+		return err
+	}
+
+	printOk("Created profile '%s'", name)
+	for _, e := range profile.Entries {
+		printPlain("  %s → %s", e.Hostname, e.Context)
+	}
+	return nil
+}
+
+// switchedHost records a host whose gh account was changed, for rollback.
+type switchedHost struct {
+	hostname string
+	prevUser string
+}
+
+// restoreHosts switches each host back to its previous account, newest first.
+func restoreHosts(switched []switchedHost) {
+	for i := len(switched) - 1; i >= 0; i-- {
+		s := switched[i]
+		if s.prevUser == "" {
+			continue
+		}
+		if err := auth.SwitchUser(s.hostname, s.prevUser); err != nil {
+			printErr("Failed to restore %s to %s: %v", s.hostname, s.prevUser, err)
+		} else {
+			printInfo("Restored %s to %s", s.hostname, s.prevUser)
+		}
+	}
+}
+
+func runDeleteProfile(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if err := config.DeleteProfile(name); err != nil {
+		var notFound *config.NotFoundError
+		if errors.As(err, &notFound) {
+			printErr("Profile '%s' not found", name)
+			if profiles, listErr := config.ListProfiles(); listErr == nil && len(profiles) > 0 {
+				printInfo("Available profiles: %v", profiles)
+			}
+		}
+		return err
+	}
+
+	printOk("Deleted profile '%s'", name)
+	return nil
+}
+
+func runUseProfile(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	profile, err := config.LoadProfile(name)
+	if err != nil {
+		profiles, listErr := config.ListProfiles()
+		if listErr == nil && len(profiles) > 0 {
+			printErr("Profile '%s' not found", name)
+			printInfo("Available profiles: %v", profiles)
+		}
+		return err
+	}
+	if len(profile.Entries) == 0 {
+		return fmt.Errorf("profile '%s' has no contexts", name)
+	}
+
+	var sshCfg *ssh.ConfigFile
+	sshChanged := false
+	var switched []switchedHost
+
+	rollback := func() { restoreHosts(switched) }
+
+	for _, e := range profile.Entries {
+		ctx, err := config.Load(e.Context)
+		if err != nil {
+			rollback()
+			return err
+		}
+		if ctx.Hostname != e.Hostname {
+			rollback()
+			return fmt.Errorf("context '%s' targets %s, but profile lists it for %s", ctx.Name, ctx.Hostname, e.Hostname)
+		}
+
+		if err := applyContextProxy(ctx); err != nil {
+			rollback()
+			return err
+		}
+
+		prevUser, _ := auth.GetCurrentUserFromSession(ctx.Hostname)
+		if err := auth.SwitchUser(ctx.Hostname, ctx.User); err != nil {
+			printErr("Failed to switch %s to %s", ctx.Hostname, ctx.User)
+			rollback()
+			return withExitCode(ExitAuth, err)
+		}
+		switched = append(switched, switchedHost{hostname: ctx.Hostname, prevUser: prevUser})
+
+		if actual, err := auth.GetCurrentUserFromSession(ctx.Hostname); err == nil && actual != ctx.User {
+			printErr("gh switched to %s on %s, not %s", actual, ctx.Hostname, ctx.User)
+			rollback()
+			return withExitCode(ExitAuth, fmt.Errorf("authenticated as %s, expected %s", actual, ctx.User))
+		}
+
+		if ctx.SSHManaged && ctx.SSHKey != "" && ctx.Transport == "ssh" {
+			if sshCfg == nil {
+				sshCfg, err = loadSSHConfig()
+				if err != nil {
+					printErr("Failed to read SSH config: %v", err)
+					rollback()
+					return err
+				}
+			}
+			if err := sshCfg.ActivateKey(ctx.SSHBlockHost(), ctx.SSHKey); err != nil {
+				printErr("Failed to activate SSH key for %s: %v", ctx.Hostname, err)
+				rollback()
+				return withExitCode(ExitSSH, err)
+			}
+			sshChanged = true
+		}
+
+		printOk("%s → %s (%s@%s)", e.Hostname, ctx.Name, ctx.User, ctx.Hostname)
+	}
+
+	if sshChanged {
+		if err := sshCfg.Save(); err != nil {
+			printErr("Failed to save SSH config: %v", err)
+			rollback()
+			return withExitCode(ExitSSH, err)
+		}
+		printSSHSaved(sshCfg)
+	}
+
+	// The first context in the profile becomes the active pointer
+	if err := config.SetActive(profile.Entries[0].Context); err != nil {
+		return err
+	}
+	for _, e := range profile.Entries {
+		if err := config.RecordUse(e.Context); err != nil {
+			logging.Debug("recording last use failed", "context", e.Context, "err", err)
+		}
+	}
+
+	printOk("Applied profile '%s'", name)
+	return nil
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+// setupProfile saves a two-host profile "daytime" (personal on
+// github.localhost, corp on api.github.localhost), with gh on other accounts
+// on both hosts.
+func setupProfile(t *testing.T) {
+	t.Helper()
+	proxy := fakeAPI(t)
+	setupCmd(t,
+		&config.Context{Name: "personal", Hostname: "github.localhost", User: "me", Transport: "https", Proxy: proxy},
+		&config.Context{Name: "corp", Hostname: "api.github.localhost", User: "me-corp", Transport: "https", Proxy: proxy},
+	)
+	loginAs(t, "github.localhost/me", "github.localhost/old", "api.github.localhost/me-corp", "api.github.localhost/old-corp")
+	setGhUser(t, "github.localhost", "old")
+	setGhUser(t, "api.github.localhost", "old-corp")
+
+	profile, err := config.NewProfile("daytime", []string{"personal", "corp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := profile.Save(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUseProfile(t *testing.T) {
+	setupProfile(t)
+
+	if err := runUseProfile(useProfileCmd, []string{"daytime"}); err != nil {
+		t.Fatalf("use-profile: %v", err)
+	}
+	if got := ghUser(t, "github.localhost"); got != "me" {
+		t.Errorf("github.localhost account = %q, want me", got)
+	}
+	if got := ghUser(t, "api.github.localhost"); got != "me-corp" {
+		t.Errorf("api.github.localhost account = %q, want me-corp", got)
+	}
+	if active, _ := config.GetActive(); active != "personal" {
+		t.Errorf("active context = %q, want the profile's first, personal", active)
+	}
+}
+
+func TestUseProfileRollsBack(t *testing.T) {
+	setupProfile(t)
+	if err := os.WriteFile(os.Getenv("GH_PATH")+".fail-api.github.localhost", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runUseProfile(useProfileCmd, []string{"daytime"})
+	if err == nil {
+		t.Fatal("use-profile succeeded with a host that can't switch")
+	}
+	if ExitCode(err) != ExitAuth {
+		t.Errorf("exit code %d (%v), want %d", ExitCode(err), err, ExitAuth)
+	}
+	if got := ghUser(t, "github.localhost"); got != "old" {
+		t.Errorf("github.localhost account = %q, want old restored", got)
+	}
+	if got := ghUser(t, "api.github.localhost"); got != "old-corp" {
+		t.Errorf("api.github.localhost account = %q, want old-corp untouched", got)
+	}
+	if active, _ := config.GetActive(); active != "" {
+		t.Errorf("active context = %q, want none set", active)
+	}
+}
+
+func TestUseProfileMissing(t *testing.T) {
+	setupCmd(t)
+	if err := runUseProfile(useProfileCmd, []string{"nope"}); ExitCode(err) != ExitNotFound {
+		t.Errorf("use-profile nope = %v (exit %d), want %d", err, ExitCode(err), ExitNotFound)
+	}
+}
+
+func TestDeleteProfile(t *testing.T) {
+	setupProfile(t)
+
+	if err := runDeleteProfile(deleteProfileCmd, []string{"daytime"}); err != nil {
+		t.Fatalf("delete-profile: %v", err)
+	}
+	if exists, _ := config.ProfileExists("daytime"); exists {
+		t.Error("profile daytime still exists")
+	}
+	if names, _ := config.List(); len(names) != 2 {
+		t.Errorf("contexts after delete-profile = %v, want personal and corp kept", names)
+	}
+
+	var err error
+	_, stderr := captureOutput(t, func() { err = runDeleteProfile(deleteProfileCmd, []string{"daytime"}) })
+	if ExitCode(err) != ExitNotFound {
+		t.Errorf("deleting a missing profile = %v, want exit %d", err, ExitNotFound)
+	}
+	if !strings.Contains(stderr, "Profile 'daytime' not found") {
+		t.Errorf("stderr = %q, want a not-found message", stderr)
+	}
+}
@@ -0,0 +1,111 @@
+// ABOUTME: Prune command for gh-context - removes contexts for dead accounts
+// ABOUTME: Deletes contexts that are neither logged in nor have an SSH key on disk
+
+package cmd
+
+import (
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove contexts whose accounts no longer exist",
+	Long: `Find contexts whose user is no longer logged in to gh AND whose SSH key
+no longer exists on disk, and delete them. Contexts without an SSH key (HTTPS
+only) are never pruned, and neither is any context whose login gh couldn't
+check (the host didn't answer, or gh failed): those are reported and kept.
+
+If the current repository is bound to a pruned context, its .ghcontext is removed too.
+
+Examples:
+  gh context prune --dry-run
+  gh context prune --yes`,
+	Args: cobra.NoArgs,
+	RunE: runPrune,
+}
+
+var (
+	pruneDryRun bool
+	pruneYes    bool
+)
+
+func init() {
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Show what would be removed without deleting anything")
+	pruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "Delete without asking for confirmation")
+}
+
+// isDeadContext reports whether a context has lost both its gh login and its
+// SSH key. A context without a key is never dead, and an error checking the
+// login is returned rather than taken as logged out.
+func isDeadContext(ctx *config.Context) (bool, error) {
+	if ctx.SSHKey == "" || ssh.KeyExists(ctx.SSHKey) {
+		return false, nil
+	}
+	loggedIn, err := auth.CheckLogin(ctx.Hostname, ctx.User)
+	if err != nil {
+		return false, err
+	}
+	return !loggedIn, nil
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	contexts, err := config.ListContexts()
+	if err != nil {
+		return err
+	}
+
+	var dead []*config.Context
+	for _, ctx := range contexts {
+		isDead, err := isDeadContext(ctx)
+		if err != nil {
+			printErr("Keeping '%s': couldn't check its login: %v", ctx.Name, err)
+			continue
+		}
+		if isDead {
+			dead = append(dead, ctx)
+		}
+	}
+
+	if len(dead) == 0 {
+		printOk("No stale contexts found")
+		return nil
+	}
+
+	printPlain("Stale contexts (not logged in, SSH key missing):")
+	for _, ctx := range dead {
+		printPlain("  %s\t(%s)", ctx.Name, ctx)
+	}
+
+	if pruneDryRun {
+		printInfo("Dry run: nothing deleted")
+		return nil
+	}
+
+	if !pruneYes && !confirm("Delete %d context(s)?", len(dead)) {
+		printInfo("Aborted")
+		return nil
+	}
+
+	binding, _ := git.GetBinding()
+	for _, ctx := range dead {
+		if err := config.Delete(ctx.Name); err != nil {
+			printErr("Failed to delete '%s': %v", ctx.Name, err)
+			continue
+		}
+		printOk("Deleted context '%s'", ctx.Name)
+
+		if binding == ctx.Name {
+			if err := git.RemoveBinding(); err != nil {
+				printErr("Failed to remove repo binding: %v", err)
+			} else {
+				printOk("Removed repo binding")
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/git"
+)
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name   string
+		dryRun bool
+		want   []string // Contexts left
+	}{
+		{"dry run", true, []string{"dead", "https", "keyed", "logged-in"}},
+		{"delete", false, []string{"https", "keyed", "logged-in"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCmd(t,
+				&config.Context{Name: "dead", Hostname: "github.com", User: "gone", Transport: "ssh", SSHKey: "~/.ssh/id_gone"},
+				&config.Context{Name: "https", Hostname: "github.com", User: "web", Transport: "https"},
+				&config.Context{Name: "keyed", Hostname: "github.com", User: "keyed", Transport: "ssh", SSHKey: "~/.ssh/id_keyed"},
+				&config.Context{Name: "logged-in", Hostname: "github.com", User: "me", Transport: "ssh", SSHKey: "~/.ssh/id_lost"},
+			)
+			loginAs(t, "github.com/me") // Everyone else is logged out
+			sshDir := filepath.Join(os.Getenv("HOME"), ".ssh")
+			if err := os.MkdirAll(sshDir, 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(sshDir, "id_keyed"), nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+			repo := newRepo(t, filepath.Join(os.Getenv("HOME"), "repo"), "", "dead")
+			chdir(t, repo)
+			pruneDryRun, pruneYes = tt.dryRun, true
+			t.Cleanup(func() { pruneDryRun, pruneYes = false, false })
+
+			var err error
+			stdout, stderr := captureOutput(t, func() { err = runPrune(pruneCmd, nil) })
+			if err != nil {
+				t.Fatalf("prune: %v\n%s", err, stderr)
+			}
+			if got, _ := config.List(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("contexts left = %v, want %v", got, tt.want)
+			}
+			if !strings.Contains(stdout, "  dead\t") {
+				t.Errorf("dead context not listed:\n%s", stdout)
+			}
+			_, statErr := os.Stat(filepath.Join(repo, git.MarkerFile))
+			if removed := os.IsNotExist(statErr); removed == tt.dryRun {
+				t.Errorf("binding to the pruned context removed: %v, want %v", removed, !tt.dryRun)
+			}
+		})
+	}
+}
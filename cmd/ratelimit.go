@@ -0,0 +1,50 @@
+// ABOUTME: Rate-limit handling for batches of API checks in gh-context
+// ABOUTME: Waits out a limit that resets soon, else stops the batch and counts the skipped checks
+
+package cmd
+
+import (
+	"errors"
+	"time"
+
+	"github.com/peterjmorgan/gh-context/internal/auth"
+)
+
+// rateLimitWait is the longest a batch of API checks waits for a rate limit
+// to reset before giving up on the rest of the batch.
+var rateLimitWait = 10 * time.Second
+
+// runChecks runs n API checks in order. A rate-limited check is retried once
+// if the limit resets within rateLimitWait; otherwise the batch stops there,
+// since every later check would be throttled too. Returns how many checks
+// were skipped (counting the throttled one) and the rate-limit error.
+func runChecks(n int, check func(i int) error) (int, error) {
+	for i := 0; i < n; i++ {
+		err := check(i)
+		if waitRateLimit(err) {
+			err = check(i)
+		}
+		if auth.IsRateLimited(err) {
+			return n - i, err
+		}
+	}
+	return 0, nil
+}
+
+// waitRateLimit reports whether err is a rate limit that resets within
+// rateLimitWait, after sleeping until it does.
+func waitRateLimit(err error) bool {
+	var rl *auth.RateLimitError
+	if !errors.As(err, &rl) || rl.Reset.IsZero() {
+		return false
+	}
+	wait := time.Until(rl.Reset)
+	if wait > rateLimitWait {
+		return false
+	}
+	if wait > 0 {
+		printInfo("Rate limited by %s, waiting %s for the limit to reset", rl.Host, wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+// ABOUTME: Rename command for gh-context - gives a saved context a new name
+// ABOUTME: Moves the active pointer, profile entries and repo bindings along with it
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var renameCmd = &cobra.Command{
+	Use:     "rename <old> <new>",
+	Aliases: []string{"mv"},
+	Short:   "Rename a saved context",
+	Long: `Rename a saved context. If it is the active context the active pointer
+follows it, and profiles that include it are updated.
+
+The active file is switched to the new name in one atomic write, and only
+after the renamed context exists, so shell hooks in other terminals never see
+a name that doesn't resolve.
+
+Repository bindings naming the old context are rewritten too: the current
+repository's, and those of every repository under --root if given.
+
+Example:
+  gh context rename work acme --root ~/src`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRename,
+}
+
+var renameRoot string
+
+func init() {
+	renameCmd.Flags().StringVar(&renameRoot, "root", "", "Also rewrite bindings in repositories under this directory")
+}
+
+func runRename(cmd *cobra.Command, args []string) error {
+	oldName, newName := args[0], args[1]
+
+	if exists, _ := config.Exists(oldName); !exists {
+		reportMissingContext(oldName)
+		return &config.NotFoundError{Kind: "context", Name: oldName}
+	}
+
+	// Find bindings first: once renamed, the old name no longer resolves
+	markers, err := boundMarkers(oldName, renameRoot)
+	if err != nil {
+		return err
+	}
+
+	active, _ := config.GetActive()
+	if err := config.Rename(oldName, newName); err != nil {
+		printErr("%v", err)
+		return err
+	}
+	printOk("Renamed context '%s' to '%s'", oldName, newName)
+	if active == oldName {
+		printInfo("Active context is now '%s'", newName)
+	}
+
+	failed := 0
+	for _, m := range markers {
+		if err := git.WriteMarker(m, newName); err != nil {
+			printErr("Failed to rebind %s: %v", m, err)
+			failed++
+			continue
+		}
+		printOk("Rebound %s", m)
+	}
+	if renameRoot == "" {
+		printInfo("Other repositories may still be bound to '%s'; pass --root to rewrite them", oldName)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d binding(s) could not be rewritten", failed)
+	}
+	return nil
+}
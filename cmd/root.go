@@ -4,9 +4,19 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+	"github.com/peterjmorgan/gh-context/internal/logging"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
 	"github.com/spf13/cobra"
 )
 
@@ -18,17 +28,84 @@ var rootCmd = &cobra.Command{
 Switch between personal, work, and enterprise GitHub accounts
 without manually managing authentication each time.
 
-Contexts are stored in: ~/.config/gh/contexts/ (or %APPDATA%\gh\contexts on Windows)`,
+Contexts are stored in: ~/.config/gh/contexts/ (or %APPDATA%\gh\contexts on Windows),
+unless --config-dir or GH_CONTEXT_DIR points elsewhere.`,
 	SilenceUsage:  true,
 	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Before the settings file is read, since it lives there too
+		if configDir != "" {
+			config.SetDir(fsutil.ExpandPath(configDir))
+		}
+		if err := applySettings(cmd); err != nil {
+			printErr("%v", err)
+			return err
+		}
+		logging.Configure(logVerbose, logDebug)
+		return auth.SetTimeout(netTimeout)
+	},
 }
 
-// Execute runs the root command.
+// Execute runs the root command. Usage errors (see ExitCode) are printed
+// here, since cobra's own error output is silenced.
 func Execute() error {
-	return rootCmd.Execute()
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return withExitCode(ExitUsage, err)
+	})
+	markArgsUsage(rootCmd)
+
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil && strings.HasPrefix(err.Error(), "unknown command ") {
+		err = withExitCode(ExitUsage, err)
+	}
+	if ExitCode(err) == ExitUsage {
+		printErr("%v", err)
+		printInfo("Run 'gh context%s --help' for usage", strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()))
+	}
+	return err
+}
+
+// markArgsUsage makes argument count errors of cmd and its subcommands usage errors.
+func markArgsUsage(cmd *cobra.Command) {
+	if validate := cmd.Args; validate != nil {
+		cmd.Args = func(cmd *cobra.Command, args []string) error {
+			return withExitCode(ExitUsage, validate(cmd, args))
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		markArgsUsage(sub)
+	}
 }
 
+// Global flags
+var (
+	configDir    string // Relocates all gh-context state (overrides GH_CONTEXT_DIR)
+	sshBackupDir string // Relocates SSH config backups (overrides GH_CONTEXT_SSH_BACKUP_DIR)
+	sshNoBackup  bool   // Skips SSH config backups (same as GH_CONTEXT_NO_BACKUP=1)
+	logVerbose   bool
+	logDebug     bool
+	netTimeout   time.Duration // Bounds each gh call and API request
+
+	sshNewBlock ssh.BlockOptions // Lines for Host blocks gh-context creates (settings file only)
+
+	applyAllow []string // Directory globs apply is limited to (settings file only)
+	applyDeny  []string // Directory globs apply skips (settings file only)
+	workspaces []string // Workspace roots whose .ghcontext nested repos inherit (settings file only)
+
+	sshBanner string // ssh -T greeting pattern for contexts without their own (settings file only)
+
+	quietOutput bool      // Silences printInfo, printOk and printPlain (see apply's ANNOUNCE handling)
+	progressOut io.Writer // Where printInfo, printOk and printPlain write; nil = stdout (see use --print-env)
+)
+
 func init() {
+	rootCmd.PersistentFlags().BoolVarP(&logVerbose, "verbose", "v", false, "Log the steps taken to stderr")
+	rootCmd.PersistentFlags().BoolVar(&logDebug, "debug", false, "Log detailed diagnostics to stderr")
+	rootCmd.PersistentFlags().DurationVar(&netTimeout, "timeout", auth.DefaultTimeout, "Limit for each network operation (0 disables) (env: "+auth.TimeoutEnv+")")
+	rootCmd.PersistentFlags().StringVar(&configDir, "config-dir", "", "Directory holding contexts, profiles, the active pointer and settings (env: "+config.DirEnv+")")
+	rootCmd.PersistentFlags().StringVar(&sshBackupDir, "backup-dir", "", "Directory for ~/.ssh/config backups (env: "+ssh.BackupDirEnv+")")
+	rootCmd.PersistentFlags().BoolVar(&sshNoBackup, "no-backup", false, "Don't back up ~/.ssh/config before editing (env: "+ssh.NoBackupEnv+")")
+
 	// Add all subcommands
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(currentCmd)
@@ -40,6 +117,145 @@ func init() {
 	rootCmd.AddCommand(applyCmd)
 	rootCmd.AddCommand(shellHookCmd)
 	rootCmd.AddCommand(authStatusCmd)
+	rootCmd.AddCommand(newProfileCmd)
+	rootCmd.AddCommand(useProfileCmd)
+	rootCmd.AddCommand(deleteProfileCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(whichCmd)
+	rootCmd.AddCommand(moveKeyCmd)
+	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(renameCmd)
+	rootCmd.AddCommand(switchAccountCmd)
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(openCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(showCmd)
+}
+
+// applySettings fills in global flags the user didn't pass, from the
+// environment and then the settings file, so precedence is
+// flag > env > settings file > built-in default.
+func applySettings(cmd *cobra.Command) error {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+	changed := func(name string) bool {
+		f := cmd.Flag(name)
+		return f != nil && f.Changed
+	}
+
+	if !changed("timeout") {
+		if raw := os.Getenv(auth.TimeoutEnv); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return fmt.Errorf("%s must be a duration like 5s, got: %s", auth.TimeoutEnv, raw)
+			}
+			netTimeout = d
+		} else if d, ok, err := settings.Duration(config.SettingTimeout); err != nil {
+			return err
+		} else if ok {
+			netTimeout = d
+		}
+	}
+
+	// The ssh package reads its environment variables itself; only a
+	// settings value with neither flag nor env set needs applying here
+	if !changed("backup-dir") && os.Getenv(ssh.BackupDirEnv) == "" {
+		if dir, ok := settings.String(config.SettingBackupDir); ok {
+			sshBackupDir = fsutil.ExpandPath(dir)
+		}
+	}
+	if !changed("no-backup") && os.Getenv(ssh.NoBackupEnv) == "" {
+		noBackup, ok, err := settings.Bool(config.SettingNoBackup)
+		if err != nil {
+			return err
+		}
+		if ok {
+			sshNoBackup = noBackup
+		}
+	}
+
+	if user, ok, err := settings.Bool(config.SettingBlockUser); err != nil {
+		return err
+	} else if ok {
+		sshNewBlock.OmitUser = !user
+	}
+	if only, ok, err := settings.Bool(config.SettingBlockIdentitiesOnly); err != nil {
+		return err
+	} else if ok {
+		sshNewBlock.OmitIdentitiesOnly = !only
+	}
+
+	applyAllow = settings.List(config.SettingApplyAllow)
+	applyDeny = settings.List(config.SettingApplyDeny)
+	workspaces = settings.List(config.SettingWorkspaces)
+	if cmd == applyCmd && !changed("quiet") {
+		quiet, ok, err := settings.Bool(config.SettingApplyQuiet)
+		if err != nil {
+			return err
+		}
+		if ok {
+			applyQuiet = quiet
+		}
+	}
+
+	if pattern, ok := settings.String(config.SettingSSHBanner); ok {
+		if _, err := ssh.CompileBanner(pattern); err != nil {
+			return fmt.Errorf("%s: %s: %w", settings.Path, config.SettingSSHBanner, err)
+		}
+		sshBanner = pattern
+	}
+
+	if !changed("verbose") {
+		verbose, ok, err := settings.Bool(config.SettingVerbose)
+		if err != nil {
+			return err
+		}
+		if ok {
+			logVerbose = verbose
+		}
+	}
+
+	return nil
+}
+
+// loadSSHConfig parses the default SSH config with command-line overrides applied.
+func loadSSHConfig() (*ssh.ConfigFile, error) {
+	cfg, err := ssh.ParseConfigWith("", ssh.ParseOptions{BackupDir: sshBackupDir, NoBackup: sshNoBackup})
+	if err != nil {
+		return nil, withExitCode(ExitSSH, err)
+	}
+	cfg.NewBlock = sshNewBlock
+	return cfg, nil
+}
+
+// reportMissingContext prints a not-found error for a context name, suggesting
+// saved contexts that look like what was meant.
+func reportMissingContext(name string) {
+	printErr("Context '%s' not found", name)
+
+	names, err := config.List()
+	if err != nil || len(names) == 0 {
+		return
+	}
+	if suggestions := config.Suggest(name, names); len(suggestions) > 0 {
+		printInfo("Did you mean: %s?", strings.Join(suggestions, ", "))
+		return
+	}
+	printInfo("Available contexts: %v", names)
+}
+
+// printSSHSaved reports a successful SSH config save and where its backup went.
+func printSSHSaved(cfg *ssh.ConfigFile) {
+	if cfg.NoBackup {
+		printOk("SSH config updated (no backup)")
+		return
+	}
+	printOk("SSH config updated (backup saved to %s)", cfg.BackupPath())
 }
 
 // Output helpers that match the bash script style
@@ -49,17 +265,60 @@ func printErr(format string, a ...interface{}) {
 	fmt.Fprintf(os.Stderr, "✗ "+format+"\n", a...)
 }
 
+// progress returns the writer for printInfo, printOk and printPlain.
+func progress() io.Writer {
+	if progressOut != nil {
+		return progressOut
+	}
+	return os.Stdout
+}
+
 // printInfo prints an informational message with • prefix.
 func printInfo(format string, a ...interface{}) {
-	fmt.Printf("• "+format+"\n", a...)
+	if quietOutput {
+		return
+	}
+	fmt.Fprintf(progress(), "• "+format+"\n", a...)
 }
 
 // printOk prints a success message with ✓ prefix.
 func printOk(format string, a ...interface{}) {
-	fmt.Printf("✓ "+format+"\n", a...)
+	if quietOutput {
+		return
+	}
+	fmt.Fprintf(progress(), "✓ "+format+"\n", a...)
 }
 
 // printPlain prints a message without prefix.
 func printPlain(format string, a ...interface{}) {
-	fmt.Printf(format+"\n", a...)
+	if quietOutput {
+		return
+	}
+	fmt.Fprintf(progress(), format+"\n", a...)
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from stdin.
+// Anything other than "y" or "yes" counts as no.
+func confirm(format string, a ...interface{}) bool {
+	fmt.Fprintf(os.Stderr, "? "+format+" [y/N] ", a...)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// choose lists options on stderr and reads a 1-based selection from stdin.
+// Returns the first option if the answer is empty or invalid.
+func choose(prompt string, options []string) string {
+	fmt.Fprintf(os.Stderr, "? %s\n", prompt)
+	for i, opt := range options {
+		fmt.Fprintf(os.Stderr, "  %d) %s\n", i+1, opt)
+	}
+	fmt.Fprintf(os.Stderr, "Choice [1]: ")
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	n, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || n < 1 || n > len(options) {
+		return options[0]
+	}
+	return options[n-1]
 }
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+)
+
+func TestLoadSSHConfigBackupFlags(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(ssh.BackupDirEnv, "")
+	t.Setenv(ssh.NoBackupEnv, "")
+	sshDir := filepath.Join(home, ".ssh")
+	if err := os.MkdirAll(filepath.Join(sshDir, "config.d"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "config"), []byte("Include config.d/*\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "config.d", "work"), []byte("Host github.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sshBackupDir, sshNoBackup = filepath.Join(home, "backups"), true
+	t.Cleanup(func() { sshBackupDir, sshNoBackup = "", false })
+
+	cfg, err := loadSSHConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Includes) != 1 {
+		t.Fatalf("parsed %d included files, want 1", len(cfg.Includes))
+	}
+	for _, f := range []*ssh.ConfigFile{cfg, cfg.Includes[0]} {
+		if !f.NoBackup || f.BackupDir != sshBackupDir {
+			t.Errorf("%s: NoBackup %v, BackupDir %q; want the flags' true, %q", f.Path, f.NoBackup, f.BackupDir, sshBackupDir)
+		}
+	}
+}
+
+func TestApplySettingsPrecedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	config.SetDir(filepath.Join(home, "contexts"))
+	t.Cleanup(func() { config.SetDir("") })
+	settings, err := config.SettingsFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(settings), 0700); err != nil {
+		t.Fatal(err)
+	}
+	timeoutFlag := rootCmd.Flag("timeout")
+	t.Cleanup(func() { netTimeout, timeoutFlag.Changed = auth.DefaultTimeout, false })
+
+	tests := []struct {
+		name string
+		file string // Settings file TIMEOUT; empty for none
+		env  string
+		flag string
+		want time.Duration
+	}{
+		{"built-in default", "", "", "", auth.DefaultTimeout},
+		{"settings file", "7s", "", "", 7 * time.Second},
+		{"env over file", "7s", "5s", "", 5 * time.Second},
+		{"flag over env", "7s", "5s", "3s", 3 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := ""
+			if tt.file != "" {
+				content = config.SettingTimeout + "=" + tt.file + "\n"
+			}
+			if err := os.WriteFile(settings, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv(auth.TimeoutEnv, tt.env)
+			netTimeout, timeoutFlag.Changed = auth.DefaultTimeout, false
+			if tt.flag != "" {
+				if err := timeoutFlag.Value.Set(tt.flag); err != nil {
+					t.Fatal(err)
+				}
+				timeoutFlag.Changed = true
+			}
+
+			if err := applySettings(rootCmd); err != nil {
+				t.Fatalf("applySettings: %v", err)
+			}
+			if netTimeout != tt.want {
+				t.Errorf("timeout = %s, want %s", netTimeout, tt.want)
+			}
+		})
+	}
+
+	t.Run("bad value in file", func(t *testing.T) {
+		if err := os.WriteFile(settings, []byte(config.SettingTimeout+"=soon\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(auth.TimeoutEnv, "")
+		timeoutFlag.Changed = false
+		if err := applySettings(rootCmd); err == nil {
+			t.Error("applySettings accepted TIMEOUT=soon")
+		}
+	})
+}
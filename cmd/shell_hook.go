@@ -1,11 +1,17 @@
 // ABOUTME: Shell-hook command for gh-context - generates shell integration code
-// ABOUTME: Supports bash, zsh, PowerShell, and fish shells for auto-apply on cd
+// ABOUTME: Supports bash, zsh, PowerShell, fish, and direnv for auto-apply on cd
 
 package cmd
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/peterjmorgan/gh-context/internal/shellrc"
 	"github.com/spf13/cobra"
 )
 
@@ -14,41 +20,261 @@ var shellHookCmd = &cobra.Command{
 	Short: "Print shell snippet for auto-apply on cd",
 	Long: `Print shell integration code that automatically applies context when entering a repo with .ghcontext.
 
-Supported shells: bash, zsh, powershell, pwsh, fish
+Supported shells: bash, zsh, powershell, pwsh, fish, direnv
 
 Examples:
   gh context shell-hook bash >> ~/.bashrc
   gh context shell-hook zsh >> ~/.zshrc
   gh context shell-hook powershell >> $PROFILE
   gh context shell-hook fish >> ~/.config/fish/config.fish
-
-If no shell is specified, outputs bash/zsh compatible code.`,
+  gh context shell-hook direnv >> .envrc && direnv allow
+
+If no shell is specified, it is detected from $ZSH_VERSION, $FISH_VERSION,
+$SHELL, or $PSModulePath (in that order), falling back to bash; a comment at
+the top of the output says which shell was picked and why.
+
+The hooks only run apply in a repository with a binding: its .ghcontext, a
+local binding (gh context bind --local) or a workspace .ghcontext above it.
+They run it with --infer=false, so they never infer a context from the origin
+remote; run gh context apply in an unbound repository to do that.
+
+Each hook is bracketed by "# >>> gh-context >>>" and "# <<< gh-context <<<".
+Use --uninstall to remove that block from the shell's rc file (~/.bashrc,
+~/.zshrc, fish's config.fish, or .envrc for direnv), or name the file with --file:
+  gh context shell-hook --uninstall zsh
+  gh context shell-hook --uninstall pwsh --file $PROFILE
+
+To install without appending duplicate copies, use the install subcommand; it
+replaces an existing block in place:
+  gh context shell-hook install bash
+
+The direnv snippet goes in a repository's .envrc instead of your shell rc file.
+direnv re-runs it whenever .ghcontext changes. Switching a context is global to gh,
+so leaving the directory doesn't switch back; the next bound repo applies its own.
+
+The generated hook embeds the active-context path gh-context resolves right now
+(honoring GH_CONFIG_DIR, XDG_CONFIG_HOME, and APPDATA) and the WORKSPACE_ROOTS
+setting: a .ghcontext above a repository is only looked for up to the root the
+repository lies under, so one in, say, your home directory is ignored unless
+home is a workspace root. Use --print-paths to see the paths without generating
+a hook; re-generate the hook if they change.`,
 	Args:      cobra.MaximumNArgs(1),
-	ValidArgs: []string{"bash", "zsh", "powershell", "pwsh", "fish"},
+	ValidArgs: []string{"bash", "zsh", "powershell", "pwsh", "fish", "direnv"},
 	RunE:      runShellHook,
 }
 
+var shellHookInstallCmd = &cobra.Command{
+	Use:   "install [shell]",
+	Short: "Install the hook into the shell's rc file, replacing any previous copy",
+	Long: `Write the hook into the shell's rc file (~/.bashrc, ~/.zshrc, fish's config.fish,
+or .envrc for direnv). An existing gh-context block is replaced in place, so
+running this again after upgrading leaves exactly one copy.
+
+Examples:
+  gh context shell-hook install bash
+  gh context shell-hook install pwsh --file $PROFILE`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"bash", "zsh", "powershell", "pwsh", "fish", "direnv"},
+	RunE:      runShellHookInstall,
+}
+
+var (
+	shellHookPrintPaths bool
+	shellHookUninstall  bool
+	shellHookFile       string
+)
+
+func init() {
+	shellHookCmd.Flags().BoolVar(&shellHookPrintPaths, "print-paths", false, "Print the active-file path and marker names the hook uses")
+	shellHookCmd.Flags().BoolVar(&shellHookUninstall, "uninstall", false, "Remove the gh-context block from the shell's rc file")
+	shellHookCmd.Flags().StringVar(&shellHookFile, "file", "", "rc file to edit with --uninstall (default depends on the shell)")
+
+	shellHookInstallCmd.Flags().StringVar(&shellHookFile, "file", "", "rc file to install into (default depends on the shell)")
+	shellHookCmd.AddCommand(shellHookInstallCmd)
+}
+
+// activeFilePlaceholder is replaced with the resolved active-file path, as a
+// quoted word, in hooks.
+const activeFilePlaceholder = "__GH_CONTEXT_ACTIVE_FILE__"
+
+// workspacesPlaceholder is replaced with the WORKSPACE_ROOTS directories, as
+// a list of quoted words, in hooks that look for workspace markers.
+const workspacesPlaceholder = "__GH_CONTEXT_WORKSPACES__"
+
 func runShellHook(cmd *cobra.Command, args []string) error {
-	shell := "bash" // Default
+	activeFile, err := config.ActiveFile()
+	if err != nil {
+		return err
+	}
+
+	if shellHookPrintPaths {
+		printPlain("active file:   %s", activeFile)
+		printPlain("marker file:   %s (in the repository root)", git.MarkerFile)
+		printPlain("local marker:  $GIT_DIR/%s", git.LocalMarkerFile)
+		printPlain("workspaces:    %s", strings.Join(hookWorkspaces(), ", "))
+		return nil
+	}
+
+	shell, detected := hookShell(args)
+
+	if shellHookUninstall {
+		if detected != "" {
+			printInfo("%s", detected)
+		}
+		return uninstallHook(shell)
+	}
+
+	hook, err := renderHook(shell, detected)
+	if err != nil {
+		return err
+	}
+	fmt.Print(hook)
+	return nil
+}
+
+// hookShell returns the shell named in args, or the detected one along with
+// a note on how it was detected.
+func hookShell(args []string) (shell, detected string) {
 	if len(args) > 0 {
-		shell = args[0]
+		return args[0], ""
+	}
+	shell, source := shellrc.DetectShell()
+	if source == "" {
+		return shell, fmt.Sprintf("Couldn't detect the shell, using %s; name it to choose another", shell)
+	}
+	return shell, fmt.Sprintf("Detected %s from %s; name the shell to choose another", shell, source)
+}
+
+func runShellHookInstall(cmd *cobra.Command, args []string) error {
+	shell, detected := hookShell(args)
+	if detected != "" {
+		printInfo("%s", detected)
+	}
+
+	path, err := hookFile(shell)
+	if err != nil {
+		return err
+	}
+
+	hook, err := renderHook(shell, "")
+	if err != nil {
+		return err
+	}
+
+	replaced, err := shellrc.InstallBlock(path, hook)
+	if err != nil {
+		return err
+	}
+	if replaced {
+		printOk("Updated gh-context hook in %s", path)
+	} else {
+		printOk("Installed gh-context hook in %s", path)
+	}
+	if shell == "direnv" {
+		printInfo("Run 'direnv allow' to approve the updated .envrc")
+	} else {
+		printInfo("Restart your shell (or open a new one) for the change to take effect")
+	}
+	return nil
+}
+
+// renderHook returns the marker-bracketed hook for a shell with paths filled
+// in, starting with note as a comment if given, so it stays inside the markers.
+func renderHook(shell, note string) (string, error) {
+	activeFile, err := config.ActiveFile()
+	if err != nil {
+		return "", err
 	}
 
 	var hook string
+	quote, sep := shQuote, " "
 	switch shell {
 	case "bash":
 		hook = bashHook()
 	case "zsh":
 		hook = zshHook()
 	case "powershell", "pwsh":
-		hook = powershellHook()
+		hook, quote, sep = powershellHook(), psQuote, ", "
 	case "fish":
-		hook = fishHook()
+		hook, quote = fishHook(), fishQuote
+	case "direnv":
+		hook = direnvHook()
 	default:
-		return fmt.Errorf("unsupported shell: %s (supported: bash, zsh, powershell, pwsh, fish)", shell)
+		return "", fmt.Errorf("unsupported shell: %s (supported: bash, zsh, powershell, pwsh, fish, direnv)", shell)
 	}
 
-	fmt.Print(hook)
+	var roots []string
+	for _, root := range hookWorkspaces() {
+		roots = append(roots, quote(root))
+	}
+	hook = strings.ReplaceAll(hook, workspacesPlaceholder, strings.Join(roots, sep))
+	hook = strings.ReplaceAll(hook, activeFilePlaceholder, quote(activeFile))
+	if note != "" {
+		// A # comment is valid in every supported shell
+		hook = "# gh-context: " + note + "\n" + hook
+	}
+	return shellrc.Wrap(hook), nil
+}
+
+// hookWorkspaces returns the WORKSPACE_ROOTS directories the way git reports
+// repository roots: absolute, symlinks resolved, with forward slashes and no
+// trailing slash.
+func hookWorkspaces() []string {
+	var roots []string
+	for _, root := range workspaces {
+		abs, err := filepath.Abs(fsutil.ExpandPath(root))
+		if err != nil {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = real
+		}
+		roots = append(roots, strings.TrimSuffix(filepath.ToSlash(abs), "/"))
+	}
+	return roots
+}
+
+// shQuote quotes s as a single bash or zsh word.
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes s as a single fish word.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// psQuote quotes s as a PowerShell string literal.
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// hookFile returns the rc file to edit: --file if given, else the shell's default.
+func hookFile(shell string) (string, error) {
+	if shellHookFile != "" {
+		return shellHookFile, nil
+	}
+	return shellrc.DefaultFile(shell)
+}
+
+// uninstallHook strips the marked gh-context block from the shell's rc file.
+func uninstallHook(shell string) error {
+	path, err := hookFile(shell)
+	if err != nil {
+		return err
+	}
+
+	removed, err := shellrc.RemoveBlock(path)
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		printInfo("No gh-context hook found in %s", path)
+		return nil
+	}
+
+	printOk("Removed gh-context hook from %s", path)
+	printInfo("Restart your shell (or open a new one) for the change to take effect")
 	return nil
 }
 
@@ -60,16 +286,42 @@ __gh_context_auto_apply() {
   local root
   root="$(git rev-parse --show-toplevel 2>/dev/null)" || return 0
 
-  if [[ -f "$root/.ghcontext" ]]; then
+  local marker="$root/.ghcontext"
+  [[ -f "$marker" ]] || marker="$(git rev-parse --git-path info/ghcontext 2>/dev/null)"
+  if [[ ! -f "$marker" ]]; then
+    # No marker of its own: the nearest .ghcontext above the repo applies,
+    # searching no higher than the WORKSPACE_ROOTS root the repo lies under
+    local ws dir
+    for ws in __GH_CONTEXT_WORKSPACES__; do
+      dir="${root%/*}"
+      while [[ -n "$dir" && "$dir/" == "$ws"/* ]]; do
+        [[ -f "$dir/.ghcontext" ]] && { marker="$dir/.ghcontext"; break 2; }
+        dir="${dir%/*}"
+      done
+    done
+  fi
+
+  if [[ -f "$marker" ]]; then
     local name current
-    name="$(cat "$root/.ghcontext")"
+    read -r name < "$marker"  # The first line; overrides may follow
     current=""
-    [[ -f "${XDG_CONFIG_HOME:-$HOME/.config}/gh/contexts/active" ]] && \
-      current="$(cat "${XDG_CONFIG_HOME:-$HOME/.config}/gh/contexts/active")"
-
-    if [[ "$current" != "$name" ]]; then
-      echo "• Auto-applying gh context: $name"
-      gh context use "$name" 2>/dev/null || true
+    [[ -f __GH_CONTEXT_ACTIVE_FILE__ ]] && \
+      current="$(cat __GH_CONTEXT_ACTIVE_FILE__)"
+
+    # A failed apply (e.g. the bound context was deleted) is reported once,
+    # not retried on every prompt until the binding changes. apply also
+    # decides whether this repo is excluded by APPLY_ALLOW/APPLY_DENY; if so
+    # it succeeds without switching, and that too isn't retried.
+    if [[ "$current" != "$name" && "$__gh_context_failed" != "$marker:$name" ]]; then
+      if gh context apply --infer=false 2>/dev/null; then
+        current=""
+        [[ -f __GH_CONTEXT_ACTIVE_FILE__ ]] && \
+          current="$(cat __GH_CONTEXT_ACTIVE_FILE__)"
+        [[ "$current" == "$name" ]] && __gh_context_failed="" || __gh_context_failed="$marker:$name"
+      else
+        __gh_context_failed="$marker:$name"
+        echo "⚠️  Could not apply gh context '$name' bound in $root (check: gh context which)"
+      fi
     fi
   fi
 }
@@ -86,16 +338,42 @@ __gh_context_auto_apply() {
   local root
   root="$(git rev-parse --show-toplevel 2>/dev/null)" || return 0
 
-  if [[ -f "$root/.ghcontext" ]]; then
+  local marker="$root/.ghcontext"
+  [[ -f "$marker" ]] || marker="$(git rev-parse --git-path info/ghcontext 2>/dev/null)"
+  if [[ ! -f "$marker" ]]; then
+    # No marker of its own: the nearest .ghcontext above the repo applies,
+    # searching no higher than the WORKSPACE_ROOTS root the repo lies under
+    local ws dir
+    for ws in __GH_CONTEXT_WORKSPACES__; do
+      dir="${root%/*}"
+      while [[ -n "$dir" && "$dir/" == "$ws"/* ]]; do
+        [[ -f "$dir/.ghcontext" ]] && { marker="$dir/.ghcontext"; break 2; }
+        dir="${dir%/*}"
+      done
+    done
+  fi
+
+  if [[ -f "$marker" ]]; then
     local name current
-    name="$(cat "$root/.ghcontext")"
+    read -r name < "$marker"  # The first line; overrides may follow
     current=""
-    [[ -f "${XDG_CONFIG_HOME:-$HOME/.config}/gh/contexts/active" ]] && \
-      current="$(cat "${XDG_CONFIG_HOME:-$HOME/.config}/gh/contexts/active")"
-
-    if [[ "$current" != "$name" ]]; then
-      echo "• Auto-applying gh context: $name"
-      gh context use "$name" 2>/dev/null || true
+    [[ -f __GH_CONTEXT_ACTIVE_FILE__ ]] && \
+      current="$(cat __GH_CONTEXT_ACTIVE_FILE__)"
+
+    # A failed apply (e.g. the bound context was deleted) is reported once,
+    # not retried on every prompt until the binding changes. apply also
+    # decides whether this repo is excluded by APPLY_ALLOW/APPLY_DENY; if so
+    # it succeeds without switching, and that too isn't retried.
+    if [[ "$current" != "$name" && "$__gh_context_failed" != "$marker:$name" ]]; then
+      if gh context apply --infer=false 2>/dev/null; then
+        current=""
+        [[ -f __GH_CONTEXT_ACTIVE_FILE__ ]] && \
+          current="$(cat __GH_CONTEXT_ACTIVE_FILE__)"
+        [[ "$current" == "$name" ]] && __gh_context_failed="" || __gh_context_failed="$marker:$name"
+      else
+        __gh_context_failed="$marker:$name"
+        echo "⚠️  Could not apply gh context '$name' bound in $root (check: gh context which)"
+      fi
     fi
   fi
 }
@@ -114,20 +392,54 @@ function Invoke-GhContextAutoApply {
     if (-not $root) { return }
 
     $ghContextFile = Join-Path $root ".ghcontext"
-    if (Test-Path $ghContextFile) {
-        $name = (Get-Content $ghContextFile -Raw).Trim()
+    if (-not (Test-Path $ghContextFile)) {
+        $ghContextFile = git rev-parse --git-path info/ghcontext 2>$null
+    }
+    if (-not ($ghContextFile -and (Test-Path $ghContextFile))) {
+        # No marker of its own: the nearest .ghcontext above the repo applies,
+        # searching no higher than the WORKSPACE_ROOTS root the repo lies under
+        $ghContextFile = $null
+        foreach ($ws in @(__GH_CONTEXT_WORKSPACES__)) {
+            $dir = Split-Path $root -Parent
+            while ($dir -and -not $ghContextFile) {
+                $path = ($dir -replace '\\', '/').TrimEnd('/')
+                if ($path -ne $ws -and -not $path.StartsWith("$ws/", [StringComparison]::OrdinalIgnoreCase)) { break }
+                if (Test-Path (Join-Path $dir ".ghcontext")) { $ghContextFile = Join-Path $dir ".ghcontext" }
+                $dir = Split-Path $dir -Parent
+            }
+            if ($ghContextFile) { break }
+        }
+    }
+    if ($ghContextFile -and (Test-Path $ghContextFile)) {
+        $name = "$(Get-Content $ghContextFile -TotalCount 1)".Trim()
 
         # Get current active context
-        $configDir = if ($env:XDG_CONFIG_HOME) { $env:XDG_CONFIG_HOME } else { "$env:APPDATA" }
-        $activeFile = Join-Path $configDir "gh\contexts\active"
+        $activeFile = __GH_CONTEXT_ACTIVE_FILE__
         $current = ""
         if (Test-Path $activeFile) {
             $current = (Get-Content $activeFile -Raw).Trim()
         }
 
-        if ($current -ne $name) {
-            Write-Host "• Auto-applying gh context: $name"
-            gh context use $name 2>$null
+        # A failed apply (e.g. the bound context was deleted) is reported once,
+        # not retried on every prompt until the binding changes. apply also
+        # decides whether this repo is excluded by APPLY_ALLOW/APPLY_DENY; if so
+        # it succeeds without switching, and that too isn't retried.
+        if ($current -ne $name -and $global:__ghContextFailed -ne "${ghContextFile}:$name") {
+            gh context apply --infer=false 2>$null
+            if ($LASTEXITCODE -eq 0) {
+                $current = ""
+                if (Test-Path $activeFile) {
+                    $current = (Get-Content $activeFile -Raw).Trim()
+                }
+                if ($current -eq $name) {
+                    $global:__ghContextFailed = ""
+                } else {
+                    $global:__ghContextFailed = "${ghContextFile}:$name"
+                }
+            } else {
+                $global:__ghContextFailed = "${ghContextFile}:$name"
+                Write-Host "⚠️  Could not apply gh context '$name' bound in $root (check: gh context which)"
+            }
         }
     }
 }
@@ -152,28 +464,80 @@ function __gh_context_auto_apply --on-variable PWD
     end
 
     set -l ghcontext_file "$root/.ghcontext"
-    if test -f $ghcontext_file
-        set -l name (cat $ghcontext_file | string trim)
-
-        # Get current active context
-        set -l config_dir
-        if test -n "$XDG_CONFIG_HOME"
-            set config_dir $XDG_CONFIG_HOME
-        else
-            set config_dir ~/.config
+    if not test -f $ghcontext_file
+        set ghcontext_file (git rev-parse --git-path info/ghcontext 2>/dev/null)
+    end
+    if test -z "$ghcontext_file"; or not test -f $ghcontext_file
+        # No marker of its own: the nearest .ghcontext above the repo applies,
+        # searching no higher than the WORKSPACE_ROOTS root the repo lies under
+        set -l ws_marker
+        for ws in __GH_CONTEXT_WORKSPACES__
+            set -l dir (dirname $root)
+            while test -z "$ws_marker"; and test "$dir" != /; and string match -q -- "$ws/*" "$dir/"
+                test -f "$dir/.ghcontext"; and set ws_marker "$dir/.ghcontext"
+                set dir (dirname $dir)
+            end
+            test -n "$ws_marker"; and break
         end
+        set ghcontext_file $ws_marker
+    end
+    if test -n "$ghcontext_file"; and test -f $ghcontext_file
+        set -l name (head -n 1 $ghcontext_file | string trim)
 
-        set -l active_file "$config_dir/gh/contexts/active"
+        # Get current active context
+        set -l active_file __GH_CONTEXT_ACTIVE_FILE__
         set -l current ""
         if test -f $active_file
             set current (cat $active_file | string trim)
         end
 
-        if test "$current" != "$name"
-            echo "• Auto-applying gh context: $name"
-            gh context use $name 2>/dev/null
+        # A failed apply (e.g. the bound context was deleted) is reported once,
+        # not retried on every directory change until the binding changes. apply
+        # also decides whether this repo is excluded by APPLY_ALLOW/APPLY_DENY;
+        # if so it succeeds without switching, and that too isn't retried.
+        if test "$current" != "$name"; and test "$__gh_context_failed" != "$ghcontext_file:$name"
+            if gh context apply --infer=false 2>/dev/null
+                set current ""
+                if test -f $active_file
+                    set current (cat $active_file | string trim)
+                end
+                if test "$current" = "$name"
+                    set -g __gh_context_failed ""
+                else
+                    set -g __gh_context_failed "$ghcontext_file:$name"
+                end
+            else
+                set -g __gh_context_failed "$ghcontext_file:$name"
+                echo "⚠️  Could not apply gh context '$name' bound in $root (check: gh context which)"
+            end
         end
     end
 end
 `
 }
+
+func direnvHook() string {
+	return `# gh-context: Apply this repo's context when direnv loads the directory
+# Add this to the repository's .envrc, then run: direnv allow
+
+__gh_context_local_marker="$(git rev-parse --git-path info/ghcontext 2>/dev/null)"
+watch_file .ghcontext
+[[ -n "$__gh_context_local_marker" ]] && watch_file "$__gh_context_local_marker"
+
+__gh_context_marker=".ghcontext"
+[[ -f "$__gh_context_marker" ]] || __gh_context_marker="$__gh_context_local_marker"
+
+if [[ -f "$__gh_context_marker" ]]; then
+  read -r __gh_context_name < "$__gh_context_marker"
+  __gh_context_current=""
+  [[ -f __GH_CONTEXT_ACTIVE_FILE__ ]] && \
+    __gh_context_current="$(cat __GH_CONTEXT_ACTIVE_FILE__)"
+
+  if [[ "$__gh_context_current" != "$__gh_context_name" ]]; then
+    log_status "applying gh context: $__gh_context_name"
+    gh context apply --infer=false >&2 || true
+  fi
+fi
+unset __gh_context_marker __gh_context_local_marker __gh_context_name __gh_context_current
+`
+}
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/peterjmorgan/gh-context/internal/shellrc"
+)
+
+func TestRenderHookKeepsNoteInsideMarkers(t *testing.T) {
+	config.SetDir(t.TempDir())
+	t.Cleanup(func() { config.SetDir("") })
+
+	for _, shell := range []string{"bash", "zsh", "fish", "pwsh", "direnv"} {
+		hook, err := renderHook(shell, "Detected bash from $SHELL")
+		if err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		if !strings.HasPrefix(hook, shellrc.BeginMarker+"\n# gh-context: Detected bash from $SHELL\n") {
+			t.Errorf("%s: note isn't the first line inside the markers:\n%s", shell, hook)
+		}
+		if rest, removed, err := shellrc.Strip(hook); err != nil || removed != 1 || rest != "" {
+			t.Errorf("%s: Strip left %q (%d blocks, %v)", shell, rest, removed, err)
+		}
+	}
+}
+
+func TestBashHookSearchesOnlyWorkspaceRoots(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	setupCmd(t)
+	home := os.Getenv("HOME")
+	ws := filepath.Join(home, "work")
+	workspaces = []string{ws}
+	t.Cleanup(func() { workspaces = nil })
+
+	// A stray marker in home must not reach repos outside the workspace
+	writeMarker := func(dir, name string) {
+		if err := os.WriteFile(filepath.Join(dir, git.MarkerFile), []byte(name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeMarker(home, "stray")
+	inWS := newRepo(t, filepath.Join(ws, "group", "repo"), "", "")
+	writeMarker(ws, "work")
+	outside := newRepo(t, filepath.Join(home, "src", "repo"), "", "")
+
+	hook, err := renderHook("bash", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hookFile := filepath.Join(home, "hook.bash")
+	if err := os.WriteFile(hookFile, []byte(hook), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ghDir := filepath.Dir(os.Getenv("GH_PATH"))
+
+	for _, tt := range []struct {
+		dir  string
+		want string
+	}{
+		{outside, ""},
+		{inWS, "context apply --infer=false\n"},
+	} {
+		os.Remove(os.Getenv("GH_PATH") + ".log")
+		cmd := exec.Command(bash, "--norc", "-c", `source "$1"; __gh_context_auto_apply`, "bash", hookFile)
+		cmd.Dir = tt.dir
+		cmd.Env = append(os.Environ(), "PATH="+ghDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("hook in %s: %v\n%s", tt.dir, err, out)
+		}
+		log, _ := os.ReadFile(os.Getenv("GH_PATH") + ".log")
+		if string(log) != tt.want {
+			t.Errorf("hook in %s ran gh %q, want %q", tt.dir, log, tt.want)
+		}
+	}
+}
+
+func TestHookQuotesActiveFile(t *testing.T) {
+	setupCmd(t)
+	home := os.Getenv("HOME")
+	// Command substitution left unquoted in the hook would create pwned
+	dir := filepath.Join(home, `we"ird $(touch pwned) 'dir'`)
+	config.SetDir(dir)
+	if err := config.SetActive("work"); err != nil {
+		t.Fatal(err)
+	}
+	activeFile, err := config.ActiveFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for shell, quote := range map[string]func(string) string{
+		"bash":   shQuote,
+		"zsh":    shQuote,
+		"direnv": shQuote,
+		"fish":   fishQuote,
+		"pwsh":   psQuote,
+	} {
+		hook, err := renderHook(shell, "")
+		if err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		if !strings.Contains(hook, quote(activeFile)) {
+			t.Errorf("%s: hook doesn't quote the active file as %s", shell, quote(activeFile))
+		}
+	}
+
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	hook, err := renderHook("bash", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hookFile := filepath.Join(home, "hook.bash")
+	if err := os.WriteFile(hookFile, []byte(hook), 0644); err != nil {
+		t.Fatal(err)
+	}
+	repo := newRepo(t, filepath.Join(home, "repo"), "", "work")
+
+	// The hook reads "work" from the active file, so it has nothing to apply
+	cmd := exec.Command(bash, "--norc", "-c", `source "$1"; __gh_context_auto_apply`, "bash", hookFile)
+	cmd.Dir = repo
+	cmd.Env = append(os.Environ(), "PATH="+filepath.Dir(os.Getenv("GH_PATH"))+string(os.PathListSeparator)+os.Getenv("PATH"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("hook: %v\n%s", err, out)
+	}
+	if log, _ := os.ReadFile(os.Getenv("GH_PATH") + ".log"); len(log) > 0 {
+		t.Errorf("hook ran gh %q though the bound context is active", log)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "pwned")); !os.IsNotExist(err) {
+		t.Error("hook ran the command substitution in the active file's path")
+	}
+}
+
+func TestDirenvHook(t *testing.T) {
+	setupCmd(t)
+	hook, err := renderHook("direnv", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"watch_file .ghcontext", "gh context apply"} {
+		if !strings.Contains(hook, want) {
+			t.Errorf("direnv hook doesn't contain %q:\n%s", want, hook)
+		}
+	}
+
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	home := os.Getenv("HOME")
+	hookFile := filepath.Join(home, "envrc")
+	if err := os.WriteFile(hookFile, []byte(hook), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SetActive("old"); err != nil {
+		t.Fatal(err)
+	}
+	repo := newRepo(t, filepath.Join(home, "repo"), "", "work")
+
+	// Stand in for direnv's stdlib, recording what the .envrc watches
+	const stdlib = `watch_file() { echo "watch $*"; }; log_status() { :; }`
+	cmd := exec.Command(bash, "--norc", "-c", stdlib+`; source "$1"`, "bash", hookFile)
+	cmd.Dir = repo
+	cmd.Env = append(os.Environ(), "PATH="+filepath.Dir(os.Getenv("GH_PATH"))+string(os.PathListSeparator)+os.Getenv("PATH"))
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("envrc: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "watch .ghcontext\n") {
+		t.Errorf("envrc doesn't watch .ghcontext:\n%s", out)
+	}
+	if log, _ := os.ReadFile(os.Getenv("GH_PATH") + ".log"); string(log) != "context apply --infer=false\n" {
+		t.Errorf("envrc ran gh %q, want it to apply the bound context", log)
+	}
+}
+
+func TestRenderHookShells(t *testing.T) {
+	setupCmd(t)
+	home := os.Getenv("HOME")
+	workspaces = []string{filepath.Join(home, "work"), filepath.Join(home, "client's")}
+	t.Cleanup(func() { workspaces = nil })
+	activeFile, err := config.ActiveFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		shell string
+		roots string   // The WORKSPACE_ROOTS list as rendered
+		want  []string // Lines the hook must have
+		check []string // Syntax check, if the shell is installed
+	}{
+		{"zsh", shQuote(home+"/work") + " " + shQuote(home+"/client's"),
+			[]string{"add-zsh-hook precmd __gh_context_auto_apply", "current=\"$(cat " + shQuote(activeFile) + ")\""},
+			[]string{"zsh", "-n"}},
+		{"fish", fishQuote(home+"/work") + " " + fishQuote(home+"/client's"),
+			[]string{"function __gh_context_auto_apply --on-variable PWD", "set -l active_file " + fishQuote(activeFile)},
+			[]string{"fish", "--no-execute"}},
+		{"pwsh", psQuote(home+"/work") + ", " + psQuote(home+"/client's"),
+			[]string{"function prompt {", "$activeFile = " + psQuote(activeFile)},
+			nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			hook, err := renderHook(tt.shell, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, placeholder := range []string{activeFilePlaceholder, workspacesPlaceholder} {
+				if strings.Contains(hook, placeholder) {
+					t.Errorf("hook still has %s:\n%s", placeholder, hook)
+				}
+			}
+			want := append([]string{"gh context apply --infer=false", tt.roots}, tt.want...)
+			for _, w := range want {
+				if !strings.Contains(hook, w) {
+					t.Errorf("hook missing %q:\n%s", w, hook)
+				}
+			}
+
+			if tt.check == nil {
+				return
+			}
+			bin, err := exec.LookPath(tt.check[0])
+			if err != nil {
+				return // Rendering is still checked above
+			}
+			file := filepath.Join(t.TempDir(), "hook")
+			if err := os.WriteFile(file, []byte(hook), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if out, err := exec.Command(bin, append(tt.check[1:], file)...).CombinedOutput(); err != nil {
+				t.Errorf("%s rejects the hook: %v\n%s", tt.shell, err, out)
+			}
+		})
+	}
+}
+
+func TestShellHookInstallUninstall(t *testing.T) {
+	setupCmd(t)
+	for _, shell := range []string{"zsh", "fish", "pwsh"} {
+		t.Run(shell, func(t *testing.T) {
+			rc := filepath.Join(t.TempDir(), "rc")
+			const original = "# my settings\nexport EDITOR=vi\n"
+			if err := os.WriteFile(rc, []byte(original), 0644); err != nil {
+				t.Fatal(err)
+			}
+			shellHookFile = rc
+			t.Cleanup(func() { shellHookFile, shellHookUninstall = "", false })
+
+			var err error
+			// Installing twice leaves one block after the existing settings
+			for i := 0; i < 2; i++ {
+				captureOutput(t, func() { err = runShellHookInstall(shellHookInstallCmd, []string{shell}) })
+				if err != nil {
+					t.Fatalf("install: %v", err)
+				}
+			}
+			data, _ := os.ReadFile(rc)
+			if n := strings.Count(string(data), shellrc.BeginMarker); n != 1 || !strings.HasPrefix(string(data), original) {
+				t.Errorf("rc after two installs has %d blocks:\n%s", n, data)
+			}
+			hook, _ := renderHook(shell, "")
+			if !strings.Contains(string(data), hook) {
+				t.Errorf("installed block differs from the rendered hook:\n%s", data)
+			}
+
+			shellHookUninstall = true
+			captureOutput(t, func() { err = runShellHook(shellHookCmd, []string{shell}) })
+			if err != nil {
+				t.Fatalf("uninstall: %v", err)
+			}
+			if data, _ := os.ReadFile(rc); string(data) != original {
+				t.Errorf("rc after uninstall =\n%q\nwant\n%q", data, original)
+			}
+		})
+	}
+}
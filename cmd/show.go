@@ -0,0 +1,237 @@
+// ABOUTME: Show command for gh-context - describes one context in full
+// ABOUTME: With --ssh-cmd, adds the ssh command git would effectively run for the context's host, per ssh -G
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+	"github.com/peterjmorgan/gh-context/internal/switcher"
+	"github.com/spf13/cobra"
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Describe a context, optionally with the ssh command git will use",
+	Long: `Describe a context (default: the active one): its account, key, SSH Host
+alias, strategy, proxy, git config entries and extra hosts.
+
+--ssh-cmd adds what ssh resolves for the context's SSH host (ssh -G): the
+host and port connected to, the identity files offered, IdentitiesOnly and any
+ProxyJump or ProxyCommand. For git-command contexts the context's
+core.sshCommand options are included, as git would pass them. It ends with an
+ssh -T command spelling all of that out, to paste into a shell when a
+connection fails.
+
+Example:
+  gh context show work --ssh-cmd`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runShow,
+}
+
+var showSSHCmd bool
+
+func init() {
+	showCmd.Flags().BoolVar(&showSSHCmd, "ssh-cmd", false, "Also print the effective ssh command for the context's host")
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	} else {
+		active, err := config.GetActive()
+		if err != nil {
+			return err
+		}
+		if active == "" {
+			printErr("No active context; pass a context name")
+			return fmt.Errorf("no active context")
+		}
+		name = active
+	}
+
+	ctx, err := config.Load(name)
+	if err != nil {
+		if exists, _ := config.Exists(name); !exists {
+			reportMissingContext(name)
+		}
+		return err
+	}
+	active, err := config.GetActive()
+	if err != nil {
+		return err
+	}
+	used, err := config.LastUsed()
+	if err != nil {
+		return err
+	}
+
+	title := ctx.Name
+	if ctx.Name == active {
+		title += " (active)"
+	}
+	printPlain("%s", title)
+	field := func(label, value string) {
+		if value != "" {
+			printPlain("  %-11s %s", label+":", value)
+		}
+	}
+	field("Host", ctx.Hostname)
+	field("User", ctx.User)
+	field("Transport", ctx.Transport)
+	field("SSH key", ctx.SSHKey)
+	field("SSH host", ctx.SSHHost)
+	if !ctx.SSHManaged {
+		field("SSH config", "not managed")
+	}
+	if ctx.UsesGitCommand() {
+		field("Strategy", ctx.Strategy)
+	}
+	field("Proxy", ctx.Proxy)
+	if ctx.NoProxy {
+		field("Proxy", "none (direct)")
+	}
+	for _, e := range ctx.Extra {
+		field("Also", fmt.Sprintf("%s@%s", e.User, e.Hostname))
+	}
+	keys := make([]string, 0, len(ctx.GitConfig))
+	for k := range ctx.GitConfig {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		field("Git config", fmt.Sprintf("%s=%s", k, ctx.GitConfig[k]))
+	}
+	if t, ok := used[ctx.Name]; ok {
+		field("Last used", t.Local().Format(time.RFC1123))
+	}
+
+	if !showSSHCmd {
+		return nil
+	}
+	return showEffectiveSSH(ctx)
+}
+
+// showEffectiveSSH prints what ssh -G resolves for the context's SSH host and
+// a command reproducing the connection.
+func showEffectiveSSH(ctx *config.Context) error {
+	host := ctx.SSHHost
+	if host == "" {
+		host = ctx.Hostname
+	}
+	if ctx.Transport != "ssh" {
+		printInfo("Context uses %s, so git won't run ssh for it; showing the SSH settings anyway", ctx.Transport)
+	}
+
+	opts, err := sshCommandOptions(ctx)
+	if err != nil {
+		printInfo("%v", err)
+	}
+	// git connects as the user in the remote URL, git@, whatever User says
+	eff, err := ssh.ResolveEffective("git@"+host, opts...)
+	if err != nil {
+		printErr("%v", err)
+		return err
+	}
+
+	fmt.Println()
+	printPlain("SSH (ssh -G %s):", strings.Join(append(opts, eff.Host), " "))
+	printPlain("  HostName:       %s", eff.HostName)
+	printPlain("  Port:           %s", eff.Port)
+	printPlain("  User:           %s", eff.User)
+	for _, f := range eff.IdentityFiles {
+		status := ""
+		if !ssh.KeyExists(f) {
+			status = " (not on disk; ssh skips it)"
+		}
+		printPlain("  IdentityFile:   %s%s", f, status)
+	}
+	only := "no (agent keys are offered too)"
+	if eff.IdentitiesOnly {
+		only = "yes"
+	}
+	printPlain("  IdentitiesOnly: %s", only)
+	if eff.ProxyJump != "" {
+		printPlain("  ProxyJump:      %s", eff.ProxyJump)
+	}
+	if eff.ProxyCommand != "" {
+		printPlain("  ProxyCommand:   %s", eff.ProxyCommand)
+	}
+	fmt.Println()
+	printPlain("%s", eff.Command())
+	return nil
+}
+
+// sshCommandOptions returns the options git passes ssh for ctx beyond the
+// user's SSH config: those of the core.sshCommand the context writes to a
+// repo (its git-command key, or its own GIT_CONFIG entry), if any. A command
+// that isn't a plain ssh invocation is reported and its options left out.
+func sshCommandOptions(ctx *config.Context) ([]string, error) {
+	command := ""
+	for k, v := range switcher.RepoGitConfig(ctx, switcher.Options{}) {
+		if strings.EqualFold(k, "core.sshCommand") {
+			command = v
+		}
+	}
+	if command == "" {
+		return nil, nil
+	}
+	fields, ok := shellFields(command)
+	if !ok || len(fields) == 0 || strings.TrimSuffix(filepath.Base(fields[0]), ".exe") != "ssh" {
+		return nil, fmt.Errorf("core.sshCommand isn't a plain ssh command, so its options aren't included: %s", command)
+	}
+	for i, f := range fields[1:] {
+		fields[i+1] = fsutil.ExpandPath(f)
+	}
+	return fields[1:], nil
+}
+
+// shellFields splits a simple shell command line into words, honoring single
+// and double quotes. Returns false for anything else the shell would
+// interpret (variables, backslashes, pipes), or an unclosed quote.
+func shellFields(s string) ([]string, bool) {
+	var fields []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if quote == '"' && strings.ContainsRune("$`\\", r) {
+				return nil, false
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				fields = append(fields, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case strings.ContainsRune("$`\\|&;<>()", r):
+			return nil, false
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, false
+	}
+	if inWord {
+		fields = append(fields, word.String())
+	}
+	return fields, true
+}
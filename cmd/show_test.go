@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+func TestShowSSHCmd(t *testing.T) {
+	if _, err := exec.LookPath("ssh"); err != nil {
+		t.Skip("ssh not installed")
+	}
+	setupCmd(t, &config.Context{Name: "corp", Hostname: "ghes.corp", User: "me", Transport: "ssh", SSHKey: "~/.ssh/id_corp", SSHManaged: true})
+	sshDir := filepath.Join(os.Getenv("HOME"), ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "config"), []byte(`Host ghes.corp
+    HostName ghes.internal
+    Port 2222
+    IdentityFile ~/.ssh/id_corp
+    IdentitiesOnly yes
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	showSSHCmd = true
+	t.Cleanup(func() { showSSHCmd = false })
+
+	var err error
+	stdout, stderr := captureOutput(t, func() { err = runShow(showCmd, []string{"corp"}) })
+	if err != nil {
+		t.Fatalf("show --ssh-cmd: %v\n%s", err, stderr)
+	}
+	for _, want := range []string{
+		"HostName:       ghes.internal",
+		"Port:           2222",
+		"IdentityFile:   ~/.ssh/id_corp (not on disk; ssh skips it)",
+		"IdentitiesOnly: yes",
+		"ssh -T -i ~/.ssh/id_corp -o IdentitiesOnly=yes -p 2222 git@ghes.internal",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output missing %q:\n%s", want, stdout)
+		}
+	}
+}
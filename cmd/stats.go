@@ -0,0 +1,185 @@
+// ABOUTME: Stats command for gh-context - a summary of saved contexts and how they're used
+// ABOUTME: Counts contexts per host, broken ones and bound repos, and shows the most and least recently used
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize contexts: counts per host, broken ones, bound repos and recent use",
+	Long: `Print a summary of the saved contexts: how many there are per host, how
+many fail a doctor check (see 'gh context list --broken'), how many
+repositories under --root (default: the current directory) are bound to each,
+and which contexts were switched to most and least recently.
+
+Last-used times are recorded by 'use', 'apply' and 'use-profile'; a context
+never switched to since then counts as least recently used.`,
+	Args: cobra.NoArgs,
+	RunE: runStats,
+}
+
+var (
+	statsOutput string
+	statsRoot   string
+)
+
+func init() {
+	statsCmd.Flags().StringVarP(&statsOutput, "output", "o", "text", "Output format (text or json)")
+	statsCmd.Flags().StringVar(&statsRoot, "root", ".", "Directory to search for bound repositories")
+}
+
+// statsData is the JSON form of a stats run.
+type statsData struct {
+	Total      int            `json:"total"`
+	PerHost    map[string]int `json:"perHost"`
+	Broken     []string       `json:"broken"`
+	Root       string         `json:"root"`
+	BoundRepos int            `json:"boundRepos"`
+	PerContext map[string]int `json:"boundPerContext"`
+	Recent     []statsUse     `json:"recentlyUsed"` // Most recent first; never-used last
+}
+
+// statsUse is one context's last use.
+type statsUse struct {
+	Context  string     `json:"context"`
+	LastUsed *time.Time `json:"lastUsed"` // nil = never recorded
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	if statsOutput != "text" && statsOutput != "json" {
+		return usageErrorf("output must be 'text' or 'json', got: %s", statsOutput)
+	}
+
+	stats, err := collectStats()
+	if err != nil {
+		return err
+	}
+
+	if statsOutput == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	if stats.Total == 0 {
+		printInfo("No contexts found. Create one with: gh context new --from-current --name <name>")
+		return nil
+	}
+
+	printPlain("Contexts: %d", stats.Total)
+	for _, host := range sortedKeys(stats.PerHost) {
+		printPlain("  %s: %d", host, stats.PerHost[host])
+	}
+	if len(stats.Broken) > 0 {
+		printPlain("Broken: %d (see: gh context list --broken)", len(stats.Broken))
+	} else {
+		printPlain("Broken: 0")
+	}
+	printPlain("Bound repositories under %s: %d", stats.Root, stats.BoundRepos)
+	for _, name := range sortedKeys(stats.PerContext) {
+		printPlain("  %s: %d", name, stats.PerContext[name])
+	}
+
+	most, least := stats.Recent[0], stats.Recent[len(stats.Recent)-1]
+	printPlain("Most recently used: %s", describeUse(most))
+	printPlain("Least recently used: %s", describeUse(least))
+	return nil
+}
+
+// collectStats gathers the counts. Finding broken contexts runs the doctor
+// checks, which only read.
+func collectStats() (*statsData, error) {
+	contexts, err := config.ListContexts()
+	if err != nil {
+		return nil, err
+	}
+	stats := &statsData{
+		Total:      len(contexts),
+		PerHost:    make(map[string]int),
+		Broken:     []string{},
+		PerContext: make(map[string]int),
+	}
+	for _, ctx := range contexts {
+		host := ctx.Hostname
+		if host == "" {
+			host = noHostLabel
+		}
+		stats.PerHost[host]++
+	}
+
+	if len(contexts) > 0 {
+		broken, _, err := brokenContexts(contexts)
+		if err != nil {
+			return nil, err
+		}
+		for _, ctx := range broken {
+			stats.Broken = append(stats.Broken, ctx.Name)
+		}
+	}
+
+	if stats.Root, err = filepath.Abs(statsRoot); err != nil {
+		return nil, err
+	}
+	repos, err := git.FindRepos(stats.Root)
+	if err != nil {
+		return nil, err
+	}
+	for _, repo := range repos {
+		b, err := git.ReadBindingIn(repo)
+		if err != nil || b == nil || b.Context == "" {
+			continue
+		}
+		stats.BoundRepos++
+		stats.PerContext[b.Context]++
+	}
+
+	used, err := config.LastUsed()
+	if err != nil {
+		return nil, err
+	}
+	for _, ctx := range contexts {
+		u := statsUse{Context: ctx.Name}
+		if t, ok := used[ctx.Name]; ok {
+			u.LastUsed = &t
+		}
+		stats.Recent = append(stats.Recent, u)
+	}
+	sort.SliceStable(stats.Recent, func(i, j int) bool {
+		a, b := stats.Recent[i].LastUsed, stats.Recent[j].LastUsed
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.After(*b)
+	})
+	return stats, nil
+}
+
+// describeUse formats a context's last use for text output.
+func describeUse(u statsUse) string {
+	if u.LastUsed == nil {
+		return fmt.Sprintf("%s (never recorded)", u.Context)
+	}
+	return fmt.Sprintf("%s (%s)", u.Context, u.LastUsed.Local().Format("2006-01-02 15:04"))
+}
+
+// sortedKeys returns a count map's keys in order.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
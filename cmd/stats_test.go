@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+func TestStats(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "a", Hostname: "github.com", User: "a", Transport: "https"},
+		&config.Context{Name: "b", Hostname: "github.com", User: "b", Transport: "https"},
+		&config.Context{Name: "c", Hostname: "ghes.corp", User: "c", Transport: "https"},
+		&config.Context{Name: "d", User: "d", Transport: "https"},
+	)
+	loginAs(t, "github.com/a", "ghes.corp/c") // b is logged out, d has no host
+	root := filepath.Join(os.Getenv("HOME"), "src")
+	newRepo(t, filepath.Join(root, "one"), "", "a")
+	newRepo(t, filepath.Join(root, "two"), "", "a")
+	newRepo(t, filepath.Join(root, "nested", "three"), "", "c")
+	newRepo(t, filepath.Join(root, "unbound"), "", "")
+	usage, err := config.UsageFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(usage, []byte("a=2026-01-02T10:00:00Z\nc=2026-03-01T10:00:00Z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	statsRoot = root
+	t.Cleanup(func() { statsOutput, statsRoot = "text", "." })
+
+	statsOutput = "json"
+	var runErr error
+	stdout, stderr := captureOutput(t, func() { runErr = runStats(statsCmd, nil) })
+	if runErr != nil {
+		t.Fatalf("stats: %v\n%s", runErr, stderr)
+	}
+	var stats statsData
+	if err := json.Unmarshal([]byte(stdout), &stats); err != nil {
+		t.Fatalf("stats output: %v\n%s", err, stdout)
+	}
+	if stats.Total != 4 {
+		t.Errorf("total = %d, want 4", stats.Total)
+	}
+	if want := map[string]int{"github.com": 2, "ghes.corp": 1, noHostLabel: 1}; !reflect.DeepEqual(stats.PerHost, want) {
+		t.Errorf("perHost = %v, want %v", stats.PerHost, want)
+	}
+	if want := []string{"b", "d"}; !reflect.DeepEqual(stats.Broken, want) {
+		t.Errorf("broken = %v, want %v", stats.Broken, want)
+	}
+	if stats.BoundRepos != 3 {
+		t.Errorf("boundRepos = %d, want 3", stats.BoundRepos)
+	}
+	if want := map[string]int{"a": 2, "c": 1}; !reflect.DeepEqual(stats.PerContext, want) {
+		t.Errorf("boundPerContext = %v, want %v", stats.PerContext, want)
+	}
+	var order []string
+	for _, u := range stats.Recent {
+		order = append(order, u.Context)
+	}
+	if want := []string{"c", "a", "b", "d"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("recentlyUsed = %v, want %v", order, want)
+	}
+
+	statsOutput = "text"
+	stdout, _ = captureOutput(t, func() { runErr = runStats(statsCmd, nil) })
+	if runErr != nil {
+		t.Fatalf("stats: %v", runErr)
+	}
+	for _, want := range []string{
+		"Contexts: 4",
+		"Broken: 2",
+		"Bound repositories under " + root + ": 3",
+		"Most recently used: c (",
+		"Least recently used: d (never recorded)",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("text output missing %q:\n%s", want, stdout)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// ABOUTME: Switch-account command for gh-context - flips a host's gh account without a saved context
+// ABOUTME: Switches gh auth to one of the host's logged-in users and activates that user's SSH key if it can tell which
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cli/go-gh/v2/pkg/term"
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/switcher"
+	"github.com/spf13/cobra"
+)
+
+var switchAccountCmd = &cobra.Command{
+	Use:   "switch-account",
+	Short: "Switch a host's gh account directly, without a saved context",
+	Long: `Switch gh auth on a host to another of its logged-in accounts, for when you
+just want to flip between accounts gh already knows about.
+
+Without --user, the host's accounts are listed to pick from (stdin must be a
+terminal). The matching SSH key is activated when one can be inferred: the key
+of a saved context for that host and user, or else the only IdentityFile in the
+host's Host block whose file name contains the user name (e.g. ~/.ssh/id_alice).
+
+The active context is left as it is.
+
+Example:
+  gh context switch-account --host github.com --user alice`,
+	Args: cobra.NoArgs,
+	RunE: runSwitchAccount,
+}
+
+var (
+	switchAccountHost   string
+	switchAccountUser   string
+	switchAccountNoSSH  bool
+	switchAccountDryRun bool
+)
+
+func init() {
+	switchAccountCmd.Flags().StringVar(&switchAccountHost, "host", "", "GitHub host (default: the one gh is logged in to, or gh's default)")
+	switchAccountCmd.Flags().StringVar(&switchAccountUser, "user", "", "Account to switch to (default: pick from the host's accounts)")
+	switchAccountCmd.Flags().BoolVar(&switchAccountNoSSH, "no-ssh", false, "Don't modify ~/.ssh/config, only switch gh auth")
+	switchAccountCmd.Flags().BoolVar(&switchAccountDryRun, "dry-run", false, "Show what would change without changing anything")
+}
+
+func runSwitchAccount(cmd *cobra.Command, args []string) error {
+	host := switchAccountHost
+	if host == "" {
+		host = discoverHost()
+	}
+	if host == "" {
+		host = auth.DefaultHost()
+	}
+
+	accounts, err := auth.GetStatus(host)
+	if err != nil {
+		printErr("Failed to read gh auth status for %s: %v", host, err)
+		return err
+	}
+	var users []string
+	active := ""
+	for _, acct := range accounts {
+		if acct.Hostname != host || !acct.LoggedIn {
+			continue
+		}
+		users = append(users, acct.User)
+		if acct.Active {
+			active = acct.User
+		}
+	}
+	if len(users) == 0 {
+		printErr("No accounts logged in on %s", host)
+		printInfo("Log in with: gh auth login --hostname %s", host)
+		return withExitCode(ExitAuth, fmt.Errorf("no accounts on %s", host))
+	}
+
+	user := switchAccountUser
+	if user == "" {
+		if !term.IsTerminal(os.Stdin) {
+			printInfo("Accounts on %s: %s", host, strings.Join(users, ", "))
+			return usageErrorf("--user required when stdin isn't a terminal")
+		}
+		options := make([]string, len(users))
+		for i, u := range users {
+			options[i] = u
+			if u == active {
+				options[i] += " (active)"
+			}
+		}
+		user = strings.TrimSuffix(choose(fmt.Sprintf("Which account on %s?", host), options), " (active)")
+	}
+	if !contains(users, user) {
+		printErr("%s isn't logged in on %s (accounts: %s)", user, host, strings.Join(users, ", "))
+		printInfo("Log in with: gh auth login --hostname %s --username %s", host, user)
+		return withExitCode(ExitAuth, fmt.Errorf("%s not logged in on %s", user, host))
+	}
+
+	var keyAction *switcher.Action
+	if !switchAccountNoSSH {
+		keyAction = inferAccountKey(host, user)
+	}
+
+	if switchAccountDryRun {
+		printPlain("Plan for %s@%s (dry run, nothing changed):", user, host)
+		printPlain("  1. Switch gh auth on %s to %s", host, user)
+		if keyAction != nil {
+			printPlain("  2. %s", keyAction.Description)
+		}
+		return nil
+	}
+
+	if user == active {
+		printInfo("%s is already the active account on %s", user, host)
+	} else if err := auth.SwitchUser(host, user); err != nil {
+		printErr("Failed to switch %s to %s: %v", host, user, err)
+		return withExitCode(ExitAuth, err)
+	}
+	printOk("Switched gh auth on %s to %s", host, user)
+
+	if keyAction != nil {
+		return activateSSHKey(*keyAction)
+	}
+	if !switchAccountNoSSH {
+		printInfo("No SSH key found for %s; ~/.ssh/config left unchanged", user)
+	}
+	return nil
+}
+
+// inferAccountKey returns the key activation for user on host: a saved
+// context's key for that account, else a key in the host's Host block named
+// after the user. Returns nil when neither is found.
+func inferAccountKey(host, user string) *switcher.Action {
+	if contexts, err := config.ListContexts(); err == nil {
+		for _, ctx := range contexts {
+			if ctx.Hostname == host && ctx.User == user && ctx.SSHKey != "" && ctx.SSHManaged {
+				return &switcher.Action{
+					Kind:        switcher.ActionActivateKey,
+					Description: fmt.Sprintf("Activate SSH key %s in Host %s (from context '%s')", ctx.SSHKey, ctx.SSHBlockHost(), ctx.Name),
+					Host:        ctx.SSHBlockHost(),
+					SSHKey:      ctx.SSHKey,
+					HostName:    host,
+				}
+			}
+		}
+	}
+
+	sshCfg, err := loadSSHConfig()
+	if err != nil {
+		return nil
+	}
+	key := sshCfg.KeyForUser(host, user)
+	if key == "" {
+		return nil
+	}
+	return &switcher.Action{
+		Kind:        switcher.ActionActivateKey,
+		Description: fmt.Sprintf("Activate SSH key %s in Host %s", key, host),
+		Host:        host,
+		SSHKey:      key,
+		HostName:    host,
+	}
+}
+
+// contains reports whether list includes s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+)
+
+func TestSwitchAccount(t *testing.T) {
+	const sshContent = "Host github.localhost\n    IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_alice\n    # IdentityFile ~/.ssh/id_work\n"
+	tests := []struct {
+		name    string
+		user    string
+		wantKey string // Active key afterwards
+		wantErr int    // Exit code
+	}{
+		{"key named after the user", "alice", "~/.ssh/id_alice", ExitOK},
+		{"key from a saved context", "bob", "~/.ssh/id_work", ExitOK},
+		{"no inferable key", "carol", "~/.ssh/id_personal", ExitOK},
+		{"not logged in", "mallory", "~/.ssh/id_personal", ExitAuth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCmd(t, &config.Context{Name: "work", Hostname: "github.localhost", User: "bob", Transport: "ssh",
+				SSHKey: "~/.ssh/id_work", SSHManaged: true})
+			loginAs(t, "github.localhost/me", "github.localhost/alice", "github.localhost/bob", "github.localhost/carol")
+			setGhUser(t, "github.localhost", "me")
+			sshConfig := filepath.Join(os.Getenv("HOME"), ".ssh", "config")
+			if err := os.MkdirAll(filepath.Dir(sshConfig), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(sshConfig, []byte(sshContent), 0600); err != nil {
+				t.Fatal(err)
+			}
+			switchAccountHost, switchAccountUser = "github.localhost", tt.user
+			t.Cleanup(func() { switchAccountHost, switchAccountUser = "", "" })
+
+			var err error
+			captureOutput(t, func() { err = runSwitchAccount(switchAccountCmd, nil) })
+			if got := ExitCode(err); got != tt.wantErr {
+				t.Fatalf("exit code %d (%v), want %d", got, err, tt.wantErr)
+			}
+			wantUser := tt.user
+			if tt.wantErr != ExitOK {
+				wantUser = "me"
+			}
+			if got := ghUser(t, "github.localhost"); got != wantUser {
+				t.Errorf("github.localhost account = %q, want %q", got, wantUser)
+			}
+			cfg, err := ssh.ParseConfig(sshConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.GetActiveIdentityFile("github.localhost"); got != tt.wantKey {
+				t.Errorf("active key = %q, want %q", got, tt.wantKey)
+			}
+			if active, _ := config.GetActive(); active != "" {
+				t.Errorf("active context = %q, want none set", active)
+			}
+		})
+	}
+}
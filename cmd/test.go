@@ -0,0 +1,137 @@
+// ABOUTME: Test command for gh-context - one-shot health check of a single context
+// ABOUTME: Verifies gh auth, the SSH key and its config entry, and SSH authentication
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/peterjmorgan/gh-context/internal/auth"
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test <name>",
+	Short: "Check that a context works end to end without switching to it",
+	Long: `Run a health check on one context:
+1. gh auth: the context's user is logged in and the API answers as that user
+2. SSH key: the key file exists and is referenced in its Host block in ~/.ssh/config
+3. SSH auth: the key alone ("ssh -i <key> -o IdentitiesOnly=yes -T git@<host>")
+   authenticates as the context's user, not some other account
+
+The user is read from the server's greeting ("Hi <user>! You've successfully
+authenticated"). For a GitHub Enterprise Server instance with a customized
+greeting, set SSH_BANNER in the context file (or the settings file) to a
+regular expression whose first group captures the user.
+
+Nothing is changed: the API check uses the account's own token, so neither the
+active context nor gh's active account on the host is switched.
+
+Exits non-zero if any check fails.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTest,
+}
+
+func runTest(cmd *cobra.Command, args []string) error {
+	ctx, err := config.Load(args[0])
+	if err != nil {
+		if exists, _ := config.Exists(args[0]); !exists {
+			reportMissingContext(args[0])
+		}
+		return err
+	}
+
+	printPlain("Testing context '%s' (%s)", ctx.Name, ctx)
+	failed := 0
+	check := func(ok bool, passMsg, failMsg string, a ...interface{}) {
+		if ok {
+			printOk(passMsg, a...)
+		} else {
+			printErr(failMsg, a...)
+			failed++
+		}
+	}
+
+	// 1. gh auth
+	if err := applyContextProxy(ctx); err != nil {
+		return err
+	}
+	ok, authErr := auth.TestAuth(ctx.Hostname, ctx.User)
+	if authErr != nil {
+		printErr("gh auth: %v", authErr)
+		failed++
+	} else {
+		check(ok, "gh auth: %s@%s", "gh auth: %s isn't authenticated on %s", ctx.User, ctx.Hostname)
+	}
+	authFailed := failed > 0
+
+	if ctx.Transport == "ssh" && ctx.SSHKey != "" {
+		// 2. SSH key file and config entry
+		check(ssh.KeyExists(ctx.SSHKey), "SSH key: %s exists", "SSH key: %s not found", fsutil.ExpandPath(ctx.SSHKey))
+
+		host := ctx.SSHBlockHost()
+		sshCfg, err := loadSSHConfig()
+		if ctx.UsesGitCommand() {
+			printInfo("SSH config: not checked (context uses core.sshCommand)")
+		} else if err != nil {
+			printErr("SSH config: %v", err)
+			failed++
+		} else {
+			block := sshCfg.FindHostBlock(host)
+			check(block != nil && block.HasIdentityFile(ctx.SSHKey),
+				"SSH config: Host %s references %s", "SSH config: Host %s doesn't reference %s", host, ctx.SSHKey)
+			for _, w := range sshCfg.Warnings(host) {
+				printInfo("SSH config: gh-context can't model this line, so edits here may be imperfect: %s", w)
+			}
+		}
+
+		// 3. SSH authentication with this key alone, so a key registered to a
+		// different GitHub account is caught even if another key would work
+		var greeted string
+		banner, err := contextBanner(ctx)
+		if err == nil {
+			greeted, err = ssh.TestConnection(host, ctx.SSHKey, banner)
+		}
+		switch {
+		case err != nil:
+			printErr("SSH auth: %v", err)
+			failed++
+		case greeted != ctx.User:
+			printErr("SSH auth: key belongs to %s, not %s", greeted, ctx.User)
+			failed++
+		default:
+			printOk("SSH auth: %s accepts the key as %s", host, greeted)
+		}
+	} else {
+		printInfo("SSH checks skipped (transport %s, no SSH key)", ctx.Transport)
+	}
+
+	if failed > 0 {
+		// Exit with the class of the first failing check: auth (or the
+		// network error that stopped it), else SSH
+		code := ExitSSH
+		if authFailed {
+			code = ExitAuth
+			if ExitCode(authErr) == ExitNetwork {
+				code = ExitNetwork
+			}
+		}
+		return withExitCode(code, fmt.Errorf("context '%s' failed %d check(s)", ctx.Name, failed))
+	}
+	printOk("Context '%s' is healthy", ctx.Name)
+	return nil
+}
+
+// contextBanner compiles the ssh -T greeting pattern for a context: its own
+// SSH_BANNER, else the settings file's, else GitHub's.
+func contextBanner(ctx *config.Context) (*regexp.Regexp, error) {
+	pattern := ctx.SSHBanner
+	if pattern == "" {
+		pattern = sshBanner
+	}
+	return ssh.CompileBanner(pattern)
+}
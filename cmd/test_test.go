@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+// A context reaching one GHES under two DNS names checks gh auth on its
+// HOSTNAME and the key and SSH login on its SSH_HOST.
+func TestTestSplitHosts(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "corp", Hostname: "github.localhost", SSHHost: "git.corp", User: "me", Transport: "ssh", Proxy: fakeAPI(t),
+			SSHKey: "~/.ssh/id_corp", SSHManaged: true},
+	)
+	loginAs(t, "github.localhost/me")
+	home := os.Getenv("HOME")
+	sshDir := filepath.Join(home, ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "config"), []byte("Host git.corp\n    IdentityFile ~/.ssh/id_corp\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "id_corp"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// ssh answers as GitHub's does, logging where it was sent
+	bin := t.TempDir()
+	sshLog := filepath.Join(bin, "ssh.log")
+	script := "#!/bin/sh\necho \"$@\" >> '" + sshLog + "'\necho \"Hi me! You've successfully authenticated, but GitHub does not provide shell access.\" >&2\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(bin, "ssh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	var err error
+	stdout, stderr := captureOutput(t, func() { err = runTest(testCmd, []string{"corp"}) })
+	if err != nil {
+		t.Fatalf("test: %v\n%s%s", err, stdout, stderr)
+	}
+	for _, want := range []string{
+		"gh auth: me@github.localhost",
+		"SSH config: Host git.corp references ~/.ssh/id_corp",
+		"SSH auth: git.corp accepts the key as me",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output missing %q:\n%s", want, stdout)
+		}
+	}
+
+	ghLog, _ := os.ReadFile(os.Getenv("GH_PATH") + ".log")
+	if strings.Contains(string(ghLog), "git.corp") {
+		t.Errorf("gh was asked about the SSH name:\n%s", ghLog)
+	}
+	probes, _ := os.ReadFile(sshLog)
+	if !strings.Contains(string(probes), "git@git.corp") || strings.Contains(string(probes), "github.localhost") {
+		t.Errorf("ssh wasn't sent to git.corp alone:\n%s", probes)
+	}
+}
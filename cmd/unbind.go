@@ -11,9 +11,17 @@ import (
 var unbindCmd = &cobra.Command{
 	Use:   "unbind",
 	Short: "Remove .ghcontext from repo root",
-	Long:  `Remove the repository's context binding by deleting the .ghcontext file.`,
-	Args:  cobra.NoArgs,
-	RunE:  runUnbind,
+	Long: `Remove the repository's context binding by deleting the .ghcontext file.
+
+Use --local to remove a binding created with "bind --local" (.git/info/ghcontext).`,
+	Args: cobra.NoArgs,
+	RunE: runUnbind,
+}
+
+var unbindLocal bool
+
+func init() {
+	unbindCmd.Flags().BoolVar(&unbindLocal, "local", false, "Remove the local binding in .git/info/ghcontext")
 }
 
 func runUnbind(cmd *cobra.Command, args []string) error {
@@ -27,18 +35,23 @@ func runUnbind(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	hasBinding, removeBinding := git.HasBinding, git.RemoveBinding
+	if unbindLocal {
+		hasBinding, removeBinding = git.HasLocalBinding, git.RemoveLocalBinding
+	}
+
 	// Check if binding exists
-	hasBinding, bindErr := git.HasBinding()
+	found, bindErr := hasBinding()
 	if bindErr != nil {
 		return bindErr
 	}
 
-	if !hasBinding {
+	if !found {
 		printInfo("No repo binding found")
 		return nil
 	}
 
-	if removeErr := git.RemoveBinding(); removeErr != nil {
+	if removeErr := removeBinding(); removeErr != nil {
 		return removeErr
 	}
 
@@ -4,88 +4,524 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/peterjmorgan/gh-context/internal/auth"
 	"github.com/peterjmorgan/gh-context/internal/config"
-	"github.com/peterjmorgan/gh-context/internal/ssh"
+	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/peterjmorgan/gh-context/internal/logging"
+	"github.com/peterjmorgan/gh-context/internal/resolve"
+	"github.com/peterjmorgan/gh-context/internal/switcher"
 	"github.com/spf13/cobra"
 )
 
 var useCmd = &cobra.Command{
-	Use:   "use <name>",
+	Use:   "use <name> | --stdin",
 	Short: "Switch to context (updates SSH config and gh auth)",
 	Long: `Switch to a saved context. This will:
 1. Set the active context
 2. Update ~/.ssh/config to use the correct SSH key
 3. Switch gh CLI authentication to the correct user
 
+Use --no-ssh (or set SSH_MANAGED=false in the context) to skip step 2 for
+HTTPS/token-only workflows.
+
+Inside a repository, --bind also writes .ghcontext (or .git/info/ghcontext with
+--local) so the context is applied automatically next time.
+
+For provisioning scripts, --force makes the end state deterministic: the Host
+block is created if it doesn't exist, the IdentityFile line added if missing,
+and the context's key left as the only active one.
+
+Inside a git repository, the context's git config entries (see 'new --git-config')
+are written to the repo's local config, and entries a previous context wrote
+that this one doesn't define are unset.
+
+Contexts saved by older versions may have no host. --host supplies it for this
+switch, and --save-host also writes it back into the context.
+
+--stdin reads the context name from standard input instead of the argument,
+for pipelines like: gh context list -o json | jq -r '.[0].name' | gh context use --stdin
+
+--print-env switches and then prints the exports 'env' would for the context
+(--shell sh, fish or powershell), with all progress on stderr, so one command
+both switches and sets up the current shell: eval "$(gh context use work --print-env)"
+
 If authentication is not configured, provides instructions to set it up.`,
-	Args: cobra.ExactArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if useStdin {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	RunE: runUse,
 }
 
+// switchFlags are the flags use and apply share, deciding how a switch is made.
+type switchFlags struct {
+	NoSSH      bool
+	AddKey     bool
+	AllAliases bool
+	DryRun     bool
+	Force      bool
+}
+
+// register adds the switch flags to cmd, bound to f.
+func (f *switchFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.NoSSH, "no-ssh", false, "Don't modify ~/.ssh/config, only switch gh auth")
+	cmd.Flags().BoolVar(&f.AddKey, "add-key", false, "Add the context's IdentityFile to the Host block if missing")
+	cmd.Flags().BoolVar(&f.AllAliases, "all-aliases", false, "Activate the key in every Host block whose HostName is the context's host")
+	cmd.Flags().BoolVar(&f.DryRun, "dry-run", false, "Show what would change without changing anything")
+	cmd.Flags().BoolVar(&f.Force, "force", false, "Make the context's key active no matter what: create the Host block or IdentityFile line if missing")
+}
+
+var (
+	useFlags     switchFlags
+	useBind      bool
+	useBindLocal bool
+	useHost      string
+	useSaveHost  bool
+	useStdin     bool
+	usePrintEnv  bool
+	useShell     string
+)
+
+func init() {
+	useFlags.register(useCmd)
+	useCmd.Flags().BoolVar(&useBind, "bind", false, "Also bind the current repository to this context (like 'bind')")
+	useCmd.Flags().BoolVar(&useBindLocal, "local", false, "With --bind, store the binding in .git/info/ghcontext")
+	useCmd.Flags().BoolVar(&useStdin, "stdin", false, "Read the context name from standard input")
+	useCmd.Flags().StringVar(&useHost, "host", "", "Host to use for a context saved without one")
+	useCmd.Flags().BoolVar(&useSaveHost, "save-host", false, "With --host, store the host in the context")
+	useCmd.Flags().BoolVar(&usePrintEnv, "print-env", false, "After switching, print the context's env exports (as 'env' does); progress goes to stderr")
+	useCmd.Flags().StringVar(&useShell, "shell", "sh", "With --print-env, syntax to print: sh (bash/zsh), fish or powershell")
+}
+
 func runUse(cmd *cobra.Command, args []string) error {
-	name := args[0]
+	return useWith(cmd, args, useFlags)
+}
+
+// useWith runs use with the given switch flags; apply shares it with its own.
+func useWith(cmd *cobra.Command, args []string, flags switchFlags) error {
+	if !usePrintEnv {
+		if cmd.Flags().Changed("shell") {
+			return usageErrorf("--shell only applies with --print-env")
+		}
+		return useContext(cmd, args, flags)
+	}
+	if err := validateEnvShell(useShell); err != nil {
+		return err
+	}
+
+	// Only the exports go to stdout, so a wrapper can eval it
+	progressOut = os.Stderr
+	err := useContext(cmd, args, flags)
+	progressOut = nil
+	if err != nil || flags.DryRun {
+		return err
+	}
+
+	active, err := config.GetActive()
+	if err != nil {
+		return err
+	}
+	ctx, err := config.Load(active)
+	if err != nil {
+		return err
+	}
+	fmt.Println(contextEnv(ctx, useShell))
+	return nil
+}
+
+// useContext switches to the context named in args (or on stdin with --stdin).
+func useContext(cmd *cobra.Command, args []string, flags switchFlags) error {
+	var name string
+	if useStdin {
+		var err error
+		if name, err = readStdinName(os.Stdin); err != nil {
+			printErr("%v", err)
+			return err
+		}
+	} else {
+		name = args[0]
+	}
 
 	// Load context to verify it exists
 	ctx, loadErr := config.Load(name)
 	if loadErr != nil {
-		// Context not found - show available contexts
-		contexts, listErr := config.List()
-		if listErr == nil && len(contexts) > 0 {
-			printErr("Context '%s' not found", name)
-			printInfo("Available contexts: %v", contexts)
+		// Context not found - suggest close matches
+		if exists, _ := config.Exists(name); !exists {
+			reportMissingContext(name)
 		}
 		return loadErr
 	}
 
-	// Set context immediately (fast by default)
-	if err := config.SetActive(name); err != nil {
+	if flags.Force && (flags.NoSSH || flags.AllAliases) {
+		return usageErrorf("--force can't be combined with --no-ssh or --all-aliases")
+	}
+
+	if useBindLocal && !useBind {
+		return usageErrorf("--local only applies with --bind")
+	}
+
+	if err := fillMissingHost(ctx, flags.DryRun); err != nil {
+		printErr("%v", err)
 		return err
 	}
 
-	printOk("Switched to context '%s' (%s@%s)", name, ctx.User, ctx.Hostname)
-
-	// Activate SSH key if configured
-	if ctx.SSHKey != "" && ctx.Transport == "ssh" {
-		printInfo("Activating SSH key: %s", ctx.SSHKey)
-
-		sshCfg, err := ssh.ParseConfig("")
-		if err != nil {
-			printErr("Failed to read SSH config: %v", err)
-		} else {
-			err = sshCfg.ActivateKey(ctx.Hostname, ctx.SSHKey)
-			if err != nil {
-				printErr("Failed to activate SSH key: %v", err)
-				printInfo("You may need to manually update your ~/.ssh/config")
-			} else {
-				if err := sshCfg.Save(); err != nil {
-					printErr("Failed to save SSH config: %v", err)
-				} else {
-					printOk("SSH config updated (backup saved to ~/.ssh/config.bak)")
-				}
+	opts := switcher.Options{NoSSH: flags.NoSSH, AddKey: flags.AddKey, AllAliases: flags.AllAliases, Force: flags.Force}
+	opts.Repo, _ = git.RepoRoot()
+	if opts.Repo != "" {
+		managed, _ := git.ManagedKeys()
+		opts.RepoManaged = len(managed) > 0
+		opts.RepoGit = bindingOverrides("", name)
+		opts.Bind, opts.BindLocal = useBind, useBindLocal
+	} else if useBind {
+		printErr("Not inside a Git repository; --bind ignored")
+	}
+	plan := switcher.NewPlan(ctx, opts)
+
+	if flags.DryRun {
+		printPlan(plan)
+		return nil
+	}
+
+	if err := applyContextProxy(ctx); err != nil {
+		return err
+	}
+
+	var switched []switchedHost
+	var sshErr error // Reported when the switch is done; the rest still applies
+	for _, action := range plan.Actions {
+		switch action.Kind {
+		case switcher.ActionSetActive:
+			// Set context immediately (fast by default)
+			if err := config.SetActive(action.Context); err != nil {
+				return err
+			}
+			if err := config.RecordUse(action.Context); err != nil {
+				logging.Debug("recording last use failed", "context", action.Context, "err", err)
+			}
+			printOk("Switched to context '%s' (%s@%s)", name, ctx.User, ctx.Hostname)
+			if flags.NoSSH || !ctx.SSHManaged {
+				printInfo("Skipping SSH config (context is not SSH-managed)")
+			} else if switcher.GitCommandKey(ctx, opts) && opts.Repo == "" {
+				printInfo("Not inside a Git repository; core.sshCommand not written (for this shell: eval \"$(gh context env %s)\")", name)
 			}
+
+		case switcher.ActionActivateKey:
+			if err := activateSSHKey(action); err != nil && sshErr == nil {
+				sshErr = err
+			}
+
+		case switcher.ActionGitConfig:
+			if err := applyGitConfig(action.GitConfig); err != nil {
+				printErr("Failed to update git config: %v", err)
+			}
+
+		case switcher.ActionBindRepo:
+			if err := bindRepo(action); err != nil {
+				printErr("Failed to bind repository: %v", err)
+			}
+
+		case switcher.ActionSwitchAuth:
+			// Multi-host contexts roll every host back if a later one fails,
+			// or if the primary host switches but can't be verified
+			prevUser := ""
+			if len(ctx.Extra) > 0 {
+				prevUser, _ = auth.GetCurrentUserFromSession(ctx.Hostname)
+			}
+			switched = append(switched, switchedHost{hostname: ctx.Hostname, prevUser: prevUser})
+			if err := switchAuth(ctx); err != nil {
+				restoreHosts(switched)
+				return err
+			}
+
+		case switcher.ActionSwitchHost:
+			prevUser, _ := auth.GetCurrentUserFromSession(action.Host)
+			if err := switchExtraHost(action); err != nil {
+				printErr("Failed to switch %s to %s: %v", action.Host, action.User, err)
+				restoreHosts(switched)
+				return err
+			}
+			switched = append(switched, switchedHost{hostname: action.Host, prevUser: prevUser})
+
+		case switcher.ActionGitProtocol:
+			if err := setGitProtocol(action); err != nil {
+				printErr("Failed to set gh git_protocol: %v", err)
+			}
+		}
+	}
+
+	return sshErr
+}
+
+// readStdinName reads a single context name from r, ignoring surrounding
+// whitespace and blank lines.
+func readStdinName(r io.Reader) (string, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			names = append(names, line)
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read context name from stdin: %w", err)
+	}
 
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("no context name on stdin")
+	case 1:
+		if err := config.ValidateName(names[0]); err != nil {
+			return "", err
+		}
+		return names[0], nil
+	}
+	return "", fmt.Errorf("expected one context name on stdin, got %d: %s", len(names), strings.Join(names, ", "))
+}
+
+// fillMissingHost applies --host to a context saved without a hostname,
+// persisting it with --save-host unless dryRun. SSH Host aliases resolve like 'new'.
+func fillMissingHost(ctx *config.Context, dryRun bool) error {
+	if useSaveHost && useHost == "" {
+		return usageErrorf("--save-host only applies with --host")
+	}
+	if useHost == "" {
+		if ctx.Hostname == "" {
+			return fmt.Errorf("context '%s' has no host; pass --host HOST (and --save-host to store it)", ctx.Name)
+		}
+		return nil
+	}
+	if ctx.Hostname != "" {
+		if strings.EqualFold(ctx.Hostname, useHost) {
+			return nil
+		}
+		return usageErrorf("context '%s' already has host %s; --host only applies to contexts without one", ctx.Name, ctx.Hostname)
+	}
+
+	ctx.Hostname = useHost
+	if sshCfg, err := loadSSHConfig(); err == nil && ctx.SSHHost == "" {
+		if canonical := sshCfg.CanonicalHost(useHost); canonical != useHost {
+			ctx.SSHHost = useHost
+			ctx.Hostname = canonical
+		}
+	}
+
+	if useSaveHost && !dryRun {
+		if err := ctx.Save(); err != nil {
+			return fmt.Errorf("failed to save host to context '%s': %w", ctx.Name, err)
+		}
+		printOk("Saved host %s in context '%s'", ctx.Hostname, ctx.Name)
+	}
+	return nil
+}
+
+// printPlan renders a plan without executing it.
+func printPlan(plan *switcher.Plan) {
+	printPlain("Plan for context '%s' (dry run, nothing changed):", plan.Name)
+	for i, action := range plan.Actions {
+		printPlain("  %d. %s", i+1, action.Description)
+		if action.Kind == switcher.ActionActivateKey && (action.AddKey || action.Force) && !action.AllAliases {
+			previewIdentityFile(action)
+		}
+	}
+}
+
+// previewIdentityFile shows the IdentityFile line an add-key action would
+// insert, if its Host block doesn't list the key yet.
+func previewIdentityFile(action switcher.Action) {
+	sshCfg, err := loadSSHConfig()
+	if err != nil || sshCfg.FindHostBlock(action.Host) == nil {
+		return // Force creates the block; nothing to preview
+	}
+	at, line, err := sshCfg.AddIdentityFilePreview(action.Host, action.SSHKey, true)
+	if err == nil && line != "" {
+		printPlain("       + %s (at line %d)", strings.TrimSpace(line), at+1)
+	}
+}
+
+// activateSSHKey executes an activate-ssh-key action, reporting a failure
+// and returning it as an ExitSSH error.
+func activateSSHKey(action switcher.Action) error {
+	printInfo("Activating SSH key: %s", action.SSHKey)
+
+	sshCfg, err := loadSSHConfig()
+	if err != nil {
+		printErr("Failed to read SSH config: %v", err)
+		return err
+	}
+
+	for _, w := range sshCfg.Warnings(action.Host) {
+		printInfo("Warning: gh-context can't model this SSH config line; check the result: %s", w)
+	}
+
+	if action.AllAliases {
+		err = sshCfg.ActivateKeyForHostName(action.Host, action.SSHKey)
+	} else if action.Force {
+		var created, added bool
+		created, added, err = sshCfg.ForceActiveKey(action.Host, action.HostName, action.SSHKey)
+		if created {
+			printInfo("Created Host %s with IdentityFile %s", action.Host, action.SSHKey)
+		} else if added {
+			printInfo("Added IdentityFile %s to Host %s", action.SSHKey, action.Host)
+		}
+	} else if action.AddKey {
+		var added bool
+		added, err = sshCfg.EnsureActiveKey(action.Host, action.SSHKey)
+		if added {
+			printInfo("Added IdentityFile %s to Host %s", action.SSHKey, action.Host)
+		}
+	} else {
+		err = sshCfg.ActivateKey(action.Host, action.SSHKey)
+	}
+	if err != nil {
+		printErr("Failed to activate SSH key: %v", err)
+		printInfo("You may need to manually update your ~/.ssh/config")
+		return withExitCode(ExitSSH, err)
+	}
+
+	if err := sshCfg.Save(); err != nil {
+		printErr("Failed to save SSH config: %v", err)
+		return withExitCode(ExitSSH, err)
+	}
+	printSSHSaved(sshCfg)
+	return nil
+}
+
+// bindingOverrides returns the git config overrides in the .ghcontext of the
+// repo at dir ("" = working directory), or without one the workspace marker
+// it inherits, when it binds the context name. A marker that can't be parsed
+// is reported and its overrides ignored.
+func bindingOverrides(dir, name string) map[string]string {
+	b, err := git.ReadBindingIn(dir)
+	if err == nil && b == nil && len(workspaces) > 0 {
+		var path string
+		if path, err = resolve.WorkspaceMarker(dir, workspaces); err == nil && path != "" {
+			b, err = git.ReadBinding(path)
+		}
+	}
+	if err != nil {
+		printErr("Ignoring repository overrides: %v", err)
+		return nil
+	}
+	if b == nil || b.Context != name {
+		return nil
+	}
+	return b.Overrides
+}
+
+// applyGitConfig writes a context's entries to the local git config of the
+// repo in the working directory, unsetting entries a previous context wrote.
+func applyGitConfig(entries map[string]string) error {
+	unset, err := git.ApplyConfig(entries)
+	for _, key := range unset {
+		printInfo("Unset git config %s", key)
+	}
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		printOk("Applied %d git config entries to this repository", len(entries))
+	}
+	return nil
+}
+
+// bindRepo executes a bind-repo action for the repo in the working directory.
+func bindRepo(action switcher.Action) error {
+	if action.Local {
+		if err := git.SetLocalBinding(action.Context); err != nil {
+			return err
+		}
+		printOk("Bound repo to context '%s' locally", action.Context)
+		return nil
+	}
+
+	if err := git.SetBinding(action.Context); err != nil {
+		return err
+	}
+	printOk("Bound repo to context '%s'", action.Context)
+	return nil
+}
+
+// setGitProtocol executes a set-git-protocol action, leaving gh's config
+// untouched when it already matches.
+func setGitProtocol(action switcher.Action) error {
+	if current, err := auth.GitProtocol(action.Host); err == nil && current == action.Protocol {
+		return nil
+	}
+	if err := auth.SetGitProtocol(action.Host, action.Protocol); err != nil {
+		return err
+	}
+	printInfo("Set gh git_protocol on %s to %s", action.Host, action.Protocol)
+	return nil
+}
+
+// switchExtraHost executes a switch-extra-host action, failing if the
+// account isn't logged in or gh ends up on a different one.
+func switchExtraHost(action switcher.Action) error {
+	if !auth.IsUserLoggedIn(action.Host, action.User) {
+		printInfo("Log in with: gh auth login --hostname %s --username %s", action.Host, action.User)
+		return withExitCode(ExitAuth, fmt.Errorf("%s is not logged in to %s", action.User, action.Host))
+	}
+	if err := auth.SwitchUser(action.Host, action.User); err != nil {
+		return withExitCode(ExitAuth, err)
+	}
+	if actual, err := auth.GetCurrentUserFromSession(action.Host); err == nil && actual != action.User {
+		return withExitCode(ExitAuth, fmt.Errorf("authenticated as %s, expected %s", actual, action.User))
+	}
+	printOk("Switched %s to %s", action.Host, action.User)
+	return nil
+}
+
+// switchAuth executes a switch-auth action, printing login instructions if needed.
+// Returns an error if gh ends up on a different account than the context's, or
+// the account it ended up on can't be confirmed.
+func switchAuth(ctx *config.Context) error {
 	// Test if authentication works
 	printInfo("Testing authentication...")
-	authenticated, testErr := auth.TestAuth(ctx.Hostname, ctx.User)
-	if testErr == nil && authenticated {
-		printOk("Authentication verified")
+	if !auth.IsUserLoggedIn(ctx.Hostname, ctx.User) || auth.SwitchUser(ctx.Hostname, ctx.User) != nil {
+		printLoginHelp(ctx)
 		return nil
 	}
 
-	// Authentication failed - prompt user to fix it
+	// Confirm the switch took effect for the intended user
+	actual, err := auth.GetCurrentUserFromSession(ctx.Hostname)
+	if err != nil {
+		printErr("Couldn't confirm the account gh uses on %s: %v", ctx.Hostname, err)
+		return withExitCode(ExitAuth, err)
+	}
+	if actual != ctx.User {
+		printErr("gh switched to %s on %s, not %s", actual, ctx.Hostname, ctx.User)
+		return withExitCode(ExitAuth, fmt.Errorf("authenticated as %s, expected %s", actual, ctx.User))
+	}
+
+	printOk("Authentication verified")
+	return nil
+}
+
+// printLoginHelp tells the user how to authenticate a context's account.
+func printLoginHelp(ctx *config.Context) {
+	// Authentication failed - prompt user to fix it, even from a quiet apply
+	quiet := quietOutput
+	quietOutput = false
+	defer func() { quietOutput = quiet }()
 	printErr("Authentication required for %s@%s", ctx.User, ctx.Hostname)
-	fmt.Println()
+	printPlain("")
 	printInfo("Your context has been set, but authentication is needed.")
 	printInfo("Please authenticate and your context will work automatically:")
-	fmt.Println()
+	printPlain("")
 	printInfo("  gh auth login --hostname %s --username %s --scopes repo,read:org", ctx.Hostname, ctx.User)
-	fmt.Println()
+	printPlain("")
 	printInfo("After authentication, all gh commands will use the correct account.")
+}
 
-	return nil
+// applyContextProxy routes subsequent API calls through the context's proxy settings.
+func applyContextProxy(ctx *config.Context) error {
+	return auth.SetProxy(auth.Proxy{URL: ctx.Proxy, Disabled: ctx.NoProxy})
 }
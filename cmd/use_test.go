@@ -0,0 +1,613 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+)
+
+// multiHostContext spans two hosts served by fakeAPI at proxy.
+func multiHostContext(proxy string) *config.Context {
+	return &config.Context{
+		Name: "both", Hostname: "github.localhost", User: "me", Transport: "https", Proxy: proxy,
+		Extra: []config.HostEntry{{Hostname: "api.github.localhost", User: "me-ghec"}},
+	}
+}
+
+// setupMultiHost saves the two-host context and an "old" one that is
+// active, with gh on old's accounts on both hosts.
+func setupMultiHost(t *testing.T) {
+	t.Helper()
+	setupCmd(t, multiHostContext(fakeAPI(t)), &config.Context{Name: "old", Hostname: "github.localhost", User: "old", Transport: "https"})
+	loginAs(t, "github.localhost/me", "github.localhost/old", "api.github.localhost/me-ghec", "api.github.localhost/old-ghec")
+	setGhUser(t, "github.localhost", "old")
+	setGhUser(t, "api.github.localhost", "old-ghec")
+	if err := config.SetActive("old"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUseMultiHost(t *testing.T) {
+	setupMultiHost(t)
+
+	if err := useContext(useCmd, []string{"both"}, switchFlags{}); err != nil {
+		t.Fatalf("use: %v", err)
+	}
+	if got := ghUser(t, "github.localhost"); got != "me" {
+		t.Errorf("github.localhost account = %q, want me", got)
+	}
+	if got := ghUser(t, "api.github.localhost"); got != "me-ghec" {
+		t.Errorf("api.github.localhost account = %q, want me-ghec", got)
+	}
+	if active, _ := config.GetActive(); active != "both" {
+		t.Errorf("active context = %q, want both", active)
+	}
+}
+
+func TestUseMultiHostRollsBack(t *testing.T) {
+	setupMultiHost(t)
+	if err := os.WriteFile(os.Getenv("GH_PATH")+".fail-api.github.localhost", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := useContext(useCmd, []string{"both"}, switchFlags{}); err == nil {
+		t.Fatal("use succeeded with a host that can't switch")
+	}
+	if got := ghUser(t, "github.localhost"); got != "old" {
+		t.Errorf("github.localhost account = %q, want old restored", got)
+	}
+	if got := ghUser(t, "api.github.localhost"); got != "old-ghec" {
+		t.Errorf("api.github.localhost account = %q, want old-ghec untouched", got)
+	}
+	if active, _ := config.GetActive(); active != "old" {
+		t.Errorf("active context = %q, want old kept", active)
+	}
+}
+
+func TestUseMultiHostRollsBackUnverifiedPrimary(t *testing.T) {
+	setupMultiHost(t)
+	// gh switches the primary host to the wrong account
+	if err := os.WriteFile(os.Getenv("GH_PATH")+".as-github.localhost", []byte("intruder\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	captureOutput(t, func() { err = useContext(useCmd, []string{"both"}, switchFlags{}) })
+	if ExitCode(err) != ExitAuth {
+		t.Fatalf("use = %v, want exit %d for the unverified account", err, ExitAuth)
+	}
+	log, _ := os.ReadFile(os.Getenv("GH_PATH") + ".log")
+	if !strings.Contains(string(log), "auth switch --hostname github.localhost --user old") {
+		t.Errorf("github.localhost wasn't switched back to old:\n%s", log)
+	}
+	if strings.Contains(string(log), "--hostname api.github.localhost --user me-ghec") {
+		t.Errorf("use went on to switch the extra host:\n%s", log)
+	}
+	if got := ghUser(t, "api.github.localhost"); got != "old-ghec" {
+		t.Errorf("api.github.localhost account = %q, want old-ghec untouched", got)
+	}
+	if active, _ := config.GetActive(); active != "old" {
+		t.Errorf("active context = %q, want old kept", active)
+	}
+}
+
+func TestSwitchAuth(t *testing.T) {
+	ctx := &config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "https"}
+	ctx.Proxy = fakeAPI(t)
+	setupCmd(t, ctx)
+	loginAs(t, "github.localhost/me", "github.localhost/old")
+	if err := applyContextProxy(ctx); err != nil {
+		t.Fatal(err)
+	}
+	as := os.Getenv("GH_PATH") + ".as-github.localhost"
+
+	tests := []struct {
+		name    string
+		as      string // Account gh really switches to; "-" for the requested one
+		wantErr string // Expected in the error; empty for none
+	}{
+		{"account matches", "-", ""},
+		{"account differs", "old", "authenticated as old, expected me"},
+		{"lookup fails", "", "token not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(as)
+			if tt.as != "-" {
+				if err := os.WriteFile(as, []byte(tt.as), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := switchAuth(ctx)
+			if (err != nil) != (tt.wantErr != "") {
+				t.Fatalf("switchAuth = %v, want error %q", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("switchAuth = %v, want it to say %q", err, tt.wantErr)
+			}
+			if err != nil && ExitCode(err) != ExitAuth {
+				t.Errorf("exit code %d (%v), want %d", ExitCode(err), err, ExitAuth)
+			}
+		})
+	}
+}
+
+// captureOutput runs f with stdout and stderr sent to files, returning what
+// each received.
+func captureOutput(t *testing.T, f func()) (stdout, stderr string) {
+	t.Helper()
+	dir := t.TempDir()
+	files := make([]*os.File, 2)
+	for i, name := range []string{"stdout", "stderr"} {
+		file, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		files[i] = file
+	}
+	oldOut, oldErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = files[0], files[1]
+	defer func() { os.Stdout, os.Stderr = oldOut, oldErr }()
+	f()
+
+	out := make([]string, 2)
+	for i, file := range files {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[i] = string(data)
+	}
+	return out[0], out[1]
+}
+
+func TestUsePrintEnv(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "ssh", Proxy: fakeAPI(t),
+			SSHKey: "~/.ssh/id_work", SSHManaged: true, Strategy: config.StrategyGitCommand},
+		&config.Context{Name: "old", Hostname: "github.localhost", User: "old", Transport: "https"},
+	)
+	loginAs(t, "github.localhost/me", "github.localhost/old")
+	if err := config.SetActive("old"); err != nil {
+		t.Fatal(err)
+	}
+	usePrintEnv = true
+	t.Cleanup(func() { usePrintEnv = false })
+
+	var err error
+	stdout, stderr := captureOutput(t, func() { err = runUse(useCmd, []string{"work"}) })
+	if err != nil {
+		t.Fatalf("use --print-env: %v", err)
+	}
+
+	if active, _ := config.GetActive(); active != "work" {
+		t.Errorf("active context = %q, want work", active)
+	}
+	if got := ghUser(t, "github.localhost"); got != "me" {
+		t.Errorf("github.localhost account = %q, want me", got)
+	}
+	want := "export GIT_SSH_COMMAND='" + ssh.GitSSHCommand("~/.ssh/id_work") + "'\n"
+	if stdout != want {
+		t.Errorf("stdout = %q, want only the exports %q", stdout, want)
+	}
+	if !strings.Contains(stderr, "Authentication verified") {
+		t.Errorf("stderr = %q, want the switch's progress", stderr)
+	}
+	if progressOut != nil {
+		t.Error("progress output still redirected after use")
+	}
+}
+
+func TestUseNoSSH(t *testing.T) {
+	tests := []struct {
+		name    string
+		managed bool
+		noSSH   bool
+		edits   bool // Whether ~/.ssh/config is expected to change
+	}{
+		{"ssh-managed context", true, false, true},
+		{"context not ssh-managed", false, false, false},
+		{"--no-ssh", true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCmd(t,
+				&config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "ssh", Proxy: fakeAPI(t),
+					SSHKey: "~/.ssh/id_work", SSHManaged: tt.managed},
+				&config.Context{Name: "old", Hostname: "github.localhost", User: "old", Transport: "https"},
+			)
+			loginAs(t, "github.localhost/me", "github.localhost/old")
+			setGhUser(t, "github.localhost", "old")
+
+			sshConfig := filepath.Join(os.Getenv("HOME"), ".ssh", "config")
+			const content = "Host github.localhost\n    IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_work\n"
+			if err := os.MkdirAll(filepath.Dir(sshConfig), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(sshConfig, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := useContext(useCmd, []string{"work"}, switchFlags{NoSSH: tt.noSSH}); err != nil {
+				t.Fatalf("use: %v", err)
+			}
+			if got := ghUser(t, "github.localhost"); got != "me" {
+				t.Errorf("github.localhost account = %q, want me", got)
+			}
+			data, _ := os.ReadFile(sshConfig)
+			if changed := string(data) != content; changed != tt.edits {
+				t.Errorf("~/.ssh/config changed %v, want %v:\n%s", changed, tt.edits, data)
+			}
+			if entries, _ := os.ReadDir(filepath.Dir(sshConfig)); !tt.edits && len(entries) != 1 {
+				t.Errorf("~/.ssh holds %d files, want only the untouched config (no backup)", len(entries))
+			}
+		})
+	}
+}
+
+func TestUseSSHAlias(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "work", Hostname: "github.localhost", SSHHost: "github-work", User: "me", Transport: "ssh", Proxy: fakeAPI(t),
+			SSHKey: "~/.ssh/id_work", SSHManaged: true},
+	)
+	loginAs(t, "github.localhost/me", "github.localhost/old")
+	setGhUser(t, "github.localhost", "old")
+	sshConfig := filepath.Join(os.Getenv("HOME"), ".ssh", "config")
+	if err := os.MkdirAll(filepath.Dir(sshConfig), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sshConfig, []byte("Host github-work\n    HostName github.localhost\n    IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_work\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := useContext(useCmd, []string{"work"}, switchFlags{}); err != nil {
+		t.Fatalf("use: %v", err)
+	}
+	log, _ := os.ReadFile(os.Getenv("GH_PATH") + ".log")
+	if !strings.Contains(string(log), "auth switch --hostname github.localhost --user me") {
+		t.Errorf("gh wasn't switched on the canonical host:\n%s", log)
+	}
+	if got := ghUser(t, "github.localhost"); got != "me" {
+		t.Errorf("github.localhost account = %q, want me", got)
+	}
+	cfg, err := ssh.ParseConfig(sshConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetActiveIdentityFile("github-work"); got != "~/.ssh/id_work" {
+		t.Errorf("alias block's active key = %q, want ~/.ssh/id_work", got)
+	}
+}
+
+func TestUseCustomGitConfig(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "https", Proxy: fakeAPI(t),
+			GitConfig: map[string]string{
+				"core.sshCommand":                "ssh -i ~/.ssh/id_work",
+				"url.git@github-work:.insteadOf": "https://github.com/",
+			}},
+		&config.Context{Name: "personal", Hostname: "github.localhost", User: "old", Transport: "https", Proxy: fakeAPI(t)},
+	)
+	loginAs(t, "github.localhost/me", "github.localhost/old")
+	repo := newRepo(t, filepath.Join(os.Getenv("HOME"), "repo"), "", "")
+	chdir(t, repo)
+
+	if err := useContext(useCmd, []string{"work"}, switchFlags{}); err != nil {
+		t.Fatalf("use work: %v", err)
+	}
+	for key, want := range map[string]string{
+		"core.sshCommand":                "ssh -i ~/.ssh/id_work",
+		"url.git@github-work:.insteadOf": "https://github.com/",
+	} {
+		if got, _ := git.GetConfig(key); got != want {
+			t.Errorf("after use work: %s = %q, want %q", key, got, want)
+		}
+	}
+
+	if err := useContext(useCmd, []string{"personal"}, switchFlags{}); err != nil {
+		t.Fatalf("use personal: %v", err)
+	}
+	for _, key := range []string{"core.sshCommand", "url.git@github-work:.insteadOf"} {
+		if got, _ := git.GetConfig(key); got != "" {
+			t.Errorf("after use personal: %s = %q, want it unset", key, got)
+		}
+	}
+	if managed, _ := git.ManagedKeys(); len(managed) != 0 {
+		t.Errorf("managed keys after switching away = %v, want none", managed)
+	}
+}
+
+func TestUseSuggestsNearMiss(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "work", Hostname: "github.com", User: "me", Transport: "https"},
+		&config.Context{Name: "personal", Hostname: "github.com", User: "old", Transport: "https"},
+	)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"wrok", "Did you mean: work?"},
+		{"kubernetes", "Available contexts: [personal work]"},
+	}
+	for _, tt := range tests {
+		var err error
+		stdout, stderr := captureOutput(t, func() { err = useContext(useCmd, []string{tt.name}, switchFlags{}) })
+		out := stderr + stdout
+		if ExitCode(err) != ExitNotFound {
+			t.Errorf("use %s: exit code %d (%v), want %d", tt.name, ExitCode(err), err, ExitNotFound)
+		}
+		if !strings.Contains(out, tt.want) {
+			t.Errorf("use %s: output = %q, want %q", tt.name, out, tt.want)
+		}
+		if tt.name == "kubernetes" && strings.Contains(out, "Did you mean") {
+			t.Errorf("use %s suggested a context: %q", tt.name, out)
+		}
+	}
+}
+
+func TestUseForce(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string // Starting ~/.ssh/config; "-" for none
+	}{
+		{"no config", "-"},
+		{"empty config", ""},
+		{"different active key", "Host github.localhost\n    IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_work\n"},
+		{"key not listed", "Host github.localhost\n    IdentityFile ~/.ssh/id_personal\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCmd(t, &config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "ssh", Proxy: fakeAPI(t),
+				SSHKey: "~/.ssh/id_work", SSHManaged: true})
+			loginAs(t, "github.localhost/me")
+			sshConfig := filepath.Join(os.Getenv("HOME"), ".ssh", "config")
+			if err := os.MkdirAll(filepath.Dir(sshConfig), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if tt.config != "-" {
+				if err := os.WriteFile(sshConfig, []byte(tt.config), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := useContext(useCmd, []string{"work"}, switchFlags{Force: true}); err != nil {
+				t.Fatalf("use --force: %v", err)
+			}
+			cfg, err := ssh.ParseConfig(sshConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.GetActiveIdentityFile("github.localhost"); got != "~/.ssh/id_work" {
+				data, _ := os.ReadFile(sshConfig)
+				t.Errorf("active key = %q, want ~/.ssh/id_work:\n%s", got, data)
+			}
+
+			// Forcing again leaves the config as it is
+			before, _ := os.ReadFile(sshConfig)
+			if err := useContext(useCmd, []string{"work"}, switchFlags{Force: true}); err != nil {
+				t.Fatalf("second use --force: %v", err)
+			}
+			if after, _ := os.ReadFile(sshConfig); string(after) != string(before) {
+				t.Errorf("second use --force changed the config:\n%s\nto:\n%s", before, after)
+			}
+		})
+	}
+}
+
+func TestUseBind(t *testing.T) {
+	tests := []struct {
+		name       string
+		bind       bool
+		local      bool
+		wantShared bool // .ghcontext written
+		wantLocal  bool // .git/info/ghcontext written
+	}{
+		{"use alone", false, false, false, false},
+		{"--bind", true, false, true, false},
+		{"--bind --local", true, true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCmd(t, &config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "https", Proxy: fakeAPI(t)})
+			loginAs(t, "github.localhost/me")
+			repo := newRepo(t, filepath.Join(os.Getenv("HOME"), "repo"), "", "")
+			chdir(t, repo)
+			useBind, useBindLocal = tt.bind, tt.local
+			t.Cleanup(func() { useBind, useBindLocal = false, false })
+
+			if err := useContext(useCmd, []string{"work"}, switchFlags{}); err != nil {
+				t.Fatalf("use: %v", err)
+			}
+			localPath, err := git.LocalBindingPath()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for path, want := range map[string]bool{filepath.Join(repo, git.MarkerFile): tt.wantShared, localPath: tt.wantLocal} {
+				data, err := os.ReadFile(path)
+				if got := err == nil; got != want {
+					t.Errorf("%s written = %v, want %v", path, got, want)
+				}
+				if want && strings.TrimSpace(string(data)) != "work" {
+					t.Errorf("%s = %q, want work", path, data)
+				}
+			}
+		})
+	}
+}
+
+func TestUseSetsGitProtocol(t *testing.T) {
+	proxy := fakeAPI(t)
+	setupCmd(t,
+		&config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "ssh", Proxy: proxy, SSHKey: "~/.ssh/id_work"},
+		&config.Context{Name: "personal", Hostname: "github.localhost", User: "old", Transport: "https", Proxy: proxy},
+		multiHostContext(proxy),
+	)
+	loginAs(t, "github.localhost/me", "github.localhost/old", "api.github.localhost/me-ghec")
+
+	tests := []struct {
+		context string
+		want    []string
+	}{
+		{"work", []string{"config set git_protocol ssh --host github.localhost"}},
+		{"personal", []string{"config set git_protocol https --host github.localhost"}},
+		{"both", []string{"config set git_protocol https --host github.localhost", "config set git_protocol https --host api.github.localhost"}},
+	}
+	for _, tt := range tests {
+		os.Remove(os.Getenv("GH_PATH") + ".log")
+		if err := useContext(useCmd, []string{tt.context}, switchFlags{NoSSH: true}); err != nil {
+			t.Fatalf("use %s: %v", tt.context, err)
+		}
+		log, _ := os.ReadFile(os.Getenv("GH_PATH") + ".log")
+		for _, want := range tt.want {
+			if !strings.Contains(string(log), want+"\n") {
+				t.Errorf("use %s didn't run gh %s:\n%s", tt.context, want, log)
+			}
+		}
+	}
+}
+
+func TestUseHostOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     string
+		save     bool
+		wantErr  string // Expected in the error; empty for none
+		wantSave string // Hostname stored in the context afterwards
+	}{
+		{"no override", "", false, "has no host; pass --host", ""},
+		{"--host", "github.localhost", false, "", ""},
+		{"--host --save-host", "github.localhost", true, "", "github.localhost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCmd(t, &config.Context{Name: "legacy", User: "me", Transport: "https", Proxy: fakeAPI(t)})
+			loginAs(t, "github.localhost/me")
+			useHost, useSaveHost = tt.host, tt.save
+			t.Cleanup(func() { useHost, useSaveHost = "", false })
+
+			err := useContext(useCmd, []string{"legacy"}, switchFlags{})
+			if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("use = %v, want error %q", err, tt.wantErr)
+			}
+			if err == nil {
+				if got := ghUser(t, "github.localhost"); got != "me" {
+					t.Errorf("github.localhost account = %q, want me", got)
+				}
+			} else if active, _ := config.GetActive(); active != "" {
+				t.Errorf("active context = %q after a failed use", active)
+			}
+			ctx, err := config.Load("legacy")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ctx.Hostname != tt.wantSave {
+				t.Errorf("stored host = %q, want %q", ctx.Hostname, tt.wantSave)
+			}
+		})
+	}
+
+	t.Run("context with a host", func(t *testing.T) {
+		setupCmd(t, &config.Context{Name: "work", Hostname: "github.com", User: "me", Transport: "https"})
+		useHost = "ghes.corp"
+		t.Cleanup(func() { useHost = "" })
+		if err := useContext(useCmd, []string{"work"}, switchFlags{}); ExitCode(err) != ExitUsage {
+			t.Errorf("use --host on a context with a host: exit code %d (%v), want %d", ExitCode(err), err, ExitUsage)
+		}
+	})
+}
+
+func TestUseStdin(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int    // Exit code
+		msg   string // Expected in the error; empty for none
+	}{
+		{"valid name", "work\n", ExitOK, ""},
+		{"surrounding blank lines", "\n  work  \n\n", ExitOK, ""},
+		{"unknown name", "wrok\n", ExitNotFound, "not found"},
+		{"invalid name", "../work\n", ExitError, "invalid"},
+		{"empty input", "", ExitError, "no context name on stdin"},
+		{"two names", "work\nold\n", ExitError, "expected one context name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCmd(t,
+				&config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "https", Proxy: fakeAPI(t)},
+				&config.Context{Name: "old", Hostname: "github.localhost", User: "old", Transport: "https"},
+			)
+			loginAs(t, "github.localhost/me", "github.localhost/old")
+			stdin := filepath.Join(t.TempDir(), "stdin")
+			if err := os.WriteFile(stdin, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(stdin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			oldStdin := os.Stdin
+			os.Stdin = f
+			t.Cleanup(func() { os.Stdin = oldStdin })
+			useStdin = true
+			t.Cleanup(func() { useStdin = false })
+
+			captureOutput(t, func() { err = useContext(useCmd, nil, switchFlags{}) })
+			if got := ExitCode(err); got != tt.want {
+				t.Errorf("exit code %d (%v), want %d", got, err, tt.want)
+			}
+			if tt.msg != "" && (err == nil || !strings.Contains(err.Error(), tt.msg)) {
+				t.Errorf("error %v, want it to say %q", err, tt.msg)
+			}
+			wantActive := ""
+			if tt.want == ExitOK {
+				wantActive = "work"
+			}
+			if active, _ := config.GetActive(); active != wantActive {
+				t.Errorf("active context = %q, want %q", active, wantActive)
+			}
+		})
+	}
+
+	useStdin = true
+	t.Cleanup(func() { useStdin = false })
+	if err := useCmd.Args(useCmd, []string{"work"}); err == nil {
+		t.Error("use accepted a name argument along with --stdin")
+	}
+}
+
+func TestUseGitCommandStrategy(t *testing.T) {
+	setupCmd(t, &config.Context{Name: "work", Hostname: "github.localhost", User: "me", Transport: "ssh", Proxy: fakeAPI(t),
+		SSHKey: "~/.ssh/id_work", SSHManaged: true, Strategy: config.StrategyGitCommand})
+	loginAs(t, "github.localhost/me")
+	sshConfig := filepath.Join(os.Getenv("HOME"), ".ssh", "config")
+	const content = "Host github.localhost\n    IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_work\n"
+	if err := os.MkdirAll(filepath.Dir(sshConfig), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sshConfig, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	repo := newRepo(t, filepath.Join(os.Getenv("HOME"), "repo"), "", "")
+	chdir(t, repo)
+
+	if err := useContext(useCmd, []string{"work"}, switchFlags{}); err != nil {
+		t.Fatalf("use: %v", err)
+	}
+	if got, _ := git.GetConfig("core.sshCommand"); got != ssh.GitSSHCommand("~/.ssh/id_work") {
+		t.Errorf("core.sshCommand = %q, want %q", got, ssh.GitSSHCommand("~/.ssh/id_work"))
+	}
+	if data, _ := os.ReadFile(sshConfig); string(data) != content {
+		t.Errorf("git-command strategy edited ~/.ssh/config:\n%s", data)
+	}
+}
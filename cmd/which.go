@@ -0,0 +1,116 @@
+// ABOUTME: Which command for gh-context - shows the context a directory resolves to
+// ABOUTME: Runs the same .ghcontext lookup as apply and the shell hook without switching
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/peterjmorgan/gh-context/internal/resolve"
+	"github.com/spf13/cobra"
+)
+
+var whichCmd = &cobra.Command{
+	Use:   "which [path]",
+	Short: "Show which context would be applied for a directory",
+	Long: `Resolve the context that 'apply' and the shell hook would choose for a
+directory (default: the current one) without switching to it.
+
+Prints the context name, or "none" if the directory has no binding. Without a
+.ghcontext, the context is inferred from the origin remote's host as 'apply' does.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runWhich,
+}
+
+var whichOutput string
+
+func init() {
+	whichCmd.Flags().StringVarP(&whichOutput, "output", "o", "text", "Output format (text or json)")
+}
+
+// whichResult is the JSON form of a resolution.
+type whichResult struct {
+	Path     string `json:"path"`
+	Context  string `json:"context,omitempty"`
+	Marker   string `json:"marker,omitempty"`   // File the binding was read from
+	Inferred bool   `json:"inferred,omitempty"` // Picked from the origin remote's host
+	Reason   string `json:"reason,omitempty"`   // Why the context was chosen (see resolve.Reason)
+	Missing  string `json:"missing,omitempty"`  // Deleted context a stale marker still names
+
+	Overrides map[string]string `json:"overrides,omitempty"` // Git config the marker layers over the context's
+}
+
+func runWhich(cmd *cobra.Command, args []string) error {
+	if whichOutput != "text" && whichOutput != "json" {
+		return usageErrorf("output must be 'text' or 'json', got: %s", whichOutput)
+	}
+
+	if len(args) > 0 {
+		if err := os.Chdir(args[0]); err != nil {
+			return fmt.Errorf("cannot resolve '%s': %w", args[0], err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	result := whichResult{Path: wd}
+	var reason resolve.Reason
+	result.Context, reason, err = resolveBinding("")
+	if err != nil {
+		printResolveErr(err)
+		return err
+	}
+	result.Reason = string(reason)
+	if reason == resolve.ReasonStale {
+		result.Missing, result.Context = result.Context, ""
+	}
+	if reason == resolve.ReasonMarker || reason == resolve.ReasonLocalMarker || reason == resolve.ReasonStale {
+		if result.Marker, err = git.FindMarker(); err != nil {
+			return err
+		}
+		if reason != resolve.ReasonStale {
+			result.Overrides = bindingOverrides("", result.Context)
+		}
+	}
+	if reason == resolve.ReasonWorkspace || (reason == resolve.ReasonStale && result.Marker == "") {
+		// No marker of its own: the binding came from the workspace root
+		if result.Marker, err = resolve.WorkspaceMarker("", workspaces); err != nil {
+			return err
+		}
+		if reason != resolve.ReasonStale {
+			result.Overrides = bindingOverrides("", result.Context)
+		}
+	}
+	result.Inferred = reason == resolve.ReasonRemoteAlias || reason == resolve.ReasonHost
+
+	if whichOutput == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	if result.Missing != "" {
+		printPlain("none (bound to missing context '%s')", result.Missing)
+		return nil
+	}
+	if reason == resolve.ReasonExcluded {
+		printPlain("none (%s)", reason.Describe())
+		return nil
+	}
+	if result.Context == "" {
+		printPlain("none")
+		return nil
+	}
+	if result.Inferred {
+		printPlain("%s (%s)", result.Context, reason.Describe())
+		return nil
+	}
+	printPlain("%s", result.Context)
+	return nil
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/resolve"
+)
+
+func TestWhich(t *testing.T) {
+	setupCmd(t,
+		&config.Context{Name: "work", Hostname: "github.com", User: "work", Transport: "https"},
+		&config.Context{Name: "corp", Hostname: "ghes.corp", User: "corp", Transport: "https"},
+	)
+	home := os.Getenv("HOME")
+	bound := newRepo(t, filepath.Join(home, "bound"), "git@github.com:o/r.git", "corp")
+	unbound := newRepo(t, filepath.Join(home, "unbound"), "", "")
+	multi := newRepo(t, filepath.Join(home, "multi"), "git@ghes.corp:o/r.git", "")
+	if out, err := exec.Command("git", "-C", multi, "remote", "add", "upstream", "git@github.com:o/r.git").CombinedOutput(); err != nil {
+		t.Fatalf("git remote add: %v\n%s", err, out)
+	}
+
+	whichOutput = "json"
+	t.Cleanup(func() { whichOutput = "text" })
+	tests := []struct {
+		name   string
+		dir    string
+		want   string
+		reason resolve.Reason
+	}{
+		{"bound repo", bound, "corp", resolve.ReasonMarker}, // The marker wins over the origin's host
+		{"unbound repo", unbound, "", resolve.ReasonNone},
+		{"multi-remote repo", multi, "corp", resolve.ReasonHost}, // Inferred from origin, not upstream
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chdir(t, home)
+			var err error
+			stdout, _ := captureOutput(t, func() { err = runWhich(whichCmd, []string{tt.dir}) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got whichResult
+			if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+				t.Fatalf("which output: %v\n%s", err, stdout)
+			}
+			if got.Context != tt.want || got.Reason != string(tt.reason) {
+				t.Errorf("which = %q (%s), want %q (%s)", got.Context, got.Reason, tt.want, tt.reason)
+			}
+		})
+	}
+
+	whichOutput = "text"
+	chdir(t, home)
+	stdout, _ := captureOutput(t, func() { runWhich(whichCmd, []string{unbound}) })
+	if strings.TrimSpace(stdout) != "none" {
+		t.Errorf("which (text) in an unbound repo = %q, want none", stdout)
+	}
+}
@@ -4,55 +4,83 @@
 package auth
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/cli/go-gh/v2"
-	"github.com/cli/go-gh/v2/pkg/api"
+	ghAuth "github.com/cli/go-gh/v2/pkg/auth"
+	"github.com/peterjmorgan/gh-context/internal/logging"
 )
 
-// TestAuth checks if the given user is authenticated on the given host.
-// Returns true if authentication is valid and ready to use.
-func TestAuth(hostname, user string) (bool, error) {
-	// Check if the user has authentication for this host
-	stdout, _, err := gh.Exec("auth", "status", "--hostname", hostname)
+// execGh runs a gh subcommand, logging its arguments and outcome.
+func execGh(args ...string) (stdout, stderr bytes.Buffer, err error) {
+	logging.Info("exec gh", "args", strings.Join(args, " "))
+	ctx, cancel := newContext()
+	defer cancel()
+	stdout, stderr, err = gh.ExecContext(ctx, args...)
+	err = unreachable(ctx, "gh "+strings.Join(args, " "), err)
 	if err != nil {
-		return false, nil // Not authenticated at all
+		logging.Debug("gh failed", "args", strings.Join(args, " "), "err", err, "stderr", strings.TrimSpace(stderr.String()))
 	}
+	return stdout, stderr, err
+}
 
-	output := stdout.String()
-	expectedPattern := fmt.Sprintf("Logged in to %s account %s", hostname, user)
-	if !strings.Contains(output, expectedPattern) {
-		return false, nil // Different user or not logged in
+// TestAuth checks if the given user is authenticated on the given host.
+// Returns true if authentication is valid and ready to use. It is read-only:
+// the API call uses that account's own token, so gh's active account is
+// never switched.
+func TestAuth(hostname, user string) (bool, error) {
+	loggedIn, err := CheckLogin(hostname, user)
+	if err != nil || !loggedIn {
+		return false, err // Not authenticated at all, or a different user
 	}
 
-	// Try to switch to the user
-	_, _, err = gh.Exec("auth", "switch", "--hostname", hostname, "--user", user)
+	token, err := UserToken(hostname, user)
 	if err != nil {
-		return false, nil // Switch failed
+		return false, nil // No usable token for this account
 	}
 
-	// Verify with a quick API call
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := newContext()
 	defer cancel()
 
-	currentUser, err := getCurrentUser(ctx, hostname)
+	client, err := newRESTClientWithToken(hostname, token)
 	if err != nil {
-		return false, nil
+		return false, err
+	}
+	var response struct {
+		Login string `json:"login"`
+	}
+	if err := client.DoWithContext(ctx, "GET", "user", nil, &response); err != nil {
+		err = rateLimited(hostname, unreachable(ctx, hostname, err))
+		if IsUnreachable(err) || IsRateLimited(err) {
+			return false, err
+		}
+		return false, nil // Token rejected
 	}
 
-	return currentUser == user, nil
+	return response.Login == user, nil
+}
+
+// UserToken returns the stored token of a specific account on a host,
+// whether or not it is gh's active account there.
+func UserToken(hostname, user string) (string, error) {
+	stdout, _, err := execGh("auth", "token", "--hostname", hostname, "--user", user)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("no token for %s on %s", user, hostname)
+	}
+	return token, nil
 }
 
 // getCurrentUser fetches the current authenticated user via API.
 func getCurrentUser(ctx context.Context, hostname string) (string, error) {
-	opts := api.ClientOptions{
-		Host: hostname,
-	}
-	client, err := api.NewRESTClient(opts)
+	client, err := newRESTClient(hostname)
 	if err != nil {
 		return "", err
 	}
@@ -61,9 +89,9 @@ func getCurrentUser(ctx context.Context, hostname string) (string, error) {
 		Login string `json:"login"`
 	}
 
-	err = client.Get("user", &response)
+	err = client.DoWithContext(ctx, "GET", "user", nil, &response)
 	if err != nil {
-		return "", err
+		return "", rateLimited(hostname, unreachable(ctx, hostname, err))
 	}
 
 	return response.Login, nil
@@ -71,41 +99,56 @@ func getCurrentUser(ctx context.Context, hostname string) (string, error) {
 
 // GetCurrentUserFromSession gets the current user from the active gh session.
 func GetCurrentUserFromSession(hostname string) (string, error) {
-	opts := api.ClientOptions{
-		Host: hostname,
-	}
-	client, err := api.NewRESTClient(opts)
-	if err != nil {
-		return "", err
-	}
+	ctx, cancel := newContext()
+	defer cancel()
+	return getCurrentUser(ctx, hostname)
+}
 
-	var response struct {
-		Login string `json:"login"`
-	}
+// SwitchUser switches the gh CLI to use a specific user on a host.
+func SwitchUser(hostname, user string) error {
+	_, _, err := execGh("auth", "switch", "--hostname", hostname, "--user", user)
+	return err
+}
 
-	err = client.Get("user", &response)
+// Login runs gh auth login for hostname with the terminal attached, so the
+// user can answer its prompts. It isn't bounded by the network timeout.
+func Login(hostname string) error {
+	logging.Info("exec gh", "args", "auth login --hostname "+hostname)
+	return gh.ExecInteractive(context.Background(), "auth", "login", "--hostname", hostname)
+}
+
+// GitProtocol returns gh's git_protocol setting for a host (ssh or https),
+// or empty string if it isn't set.
+func GitProtocol(hostname string) (string, error) {
+	stdout, _, err := execGh("config", "get", "git_protocol", "--host", hostname)
 	if err != nil {
 		return "", err
 	}
-
-	return response.Login, nil
+	return strings.TrimSpace(stdout.String()), nil
 }
 
-// SwitchUser switches the gh CLI to use a specific user on a host.
-func SwitchUser(hostname, user string) error {
-	_, _, err := gh.Exec("auth", "switch", "--hostname", hostname, "--user", user)
-	return err
+// SetGitProtocol sets gh's git_protocol for a host, which decides the remote
+// URLs gh repo clone and gh pr checkout use.
+func SetGitProtocol(hostname, protocol string) error {
+	_, stderr, err := execGh("config", "set", "git_protocol", protocol, "--host", hostname)
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return err
+	}
+	return nil
 }
 
 // HasToken checks if there's an auth token for the given host.
 func HasToken(hostname string) bool {
-	_, _, err := gh.Exec("auth", "token", "--hostname", hostname)
+	_, _, err := execGh("auth", "token", "--hostname", hostname)
 	return err == nil
 }
 
 // GetAuthStatus returns raw auth status output for a hostname.
 func GetAuthStatus(hostname string) (string, error) {
-	stdout, stderr, err := gh.Exec("auth", "status", "--hostname", hostname)
+	stdout, stderr, err := execGh("auth", "status", "--hostname", hostname)
 	if err != nil {
 		// gh auth status returns non-zero if not logged in, but still outputs info
 		return stderr.String(), nil
@@ -115,26 +158,77 @@ func GetAuthStatus(hostname string) (string, error) {
 
 // IsUserLoggedIn checks if a specific user is logged in on a host.
 func IsUserLoggedIn(hostname, user string) bool {
-	stdout, _, err := gh.Exec("auth", "status", "--hostname", hostname)
-	if err != nil {
-		return false
-	}
+	loggedIn, _ := CheckLogin(hostname, user)
+	return loggedIn
+}
 
-	output := stdout.String()
-	expectedPattern := fmt.Sprintf("Logged in to %s account %s", hostname, user)
-	return strings.Contains(output, expectedPattern)
+// CheckLogin is IsUserLoggedIn that also reports errors: ErrUnreachable when
+// gh couldn't get an answer from the host in time, and gh's own failure when
+// its output says nothing about the host's accounts (gh missing, say). gh
+// auth status exits non-zero when any account on the host is broken, so the
+// output is read even then: user's account may still be fine.
+func CheckLogin(hostname, user string) (bool, error) {
+	stdout, stderr, err := execGh("auth", "status", "--hostname", hostname)
+	loggedIn, answered := parseLogin(stdout.String()+"\n"+stderr.String(), hostname, user)
+	if loggedIn {
+		return true, nil
+	}
+	if err != nil && (IsUnreachable(err) || !answered) {
+		return false, err
+	}
+	return false, nil
+}
+
+// parseLogin reads gh auth status output for hostname: loggedIn if it shows
+// user logged in there, answered if it reports on the host's accounts at all
+// (any account line, or that nobody is logged in).
+func parseLogin(output, hostname, user string) (loggedIn, answered bool) {
+	prefix := fmt.Sprintf("Logged in to %s account ", hostname)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.Contains(line, "not logged in") {
+			answered = true
+		}
+		_, rest, ok := strings.Cut(line, prefix)
+		if !ok {
+			if strings.Contains(line, hostname+" account ") {
+				answered = true // e.g. "Failed to log in to <host> account <user>"
+			}
+			continue
+		}
+		answered = true
+		if fields := strings.Fields(rest); len(fields) > 0 && fields[0] == user {
+			loggedIn = true
+		}
+	}
+	return loggedIn, answered
 }
 
 // VerifyConnectivity tests that we can reach the GitHub API on the given host.
-func VerifyConnectivity(hostname string) error {
-	opts := api.ClientOptions{
-		Host: hostname,
-	}
-	client, err := api.NewRESTClient(opts)
+func VerifyConnectivity(ctx context.Context, hostname string) error {
+	client, err := newRESTClient(hostname)
 	if err != nil {
 		return err
 	}
 
 	var response json.RawMessage
-	return client.Get("user", &response)
+	err = client.DoWithContext(ctx, "GET", "user", nil, &response)
+	return rateLimited(hostname, unreachable(ctx, hostname, err))
+}
+
+// DefaultHost returns the host gh uses when none is specified
+// (GH_HOST, then the configured default, then github.com).
+func DefaultHost() string {
+	host, _ := ghAuth.DefaultHost()
+	return host
+}
+
+// GhVersion returns the first line of "gh --version" (e.g. "gh version 2.62.0 (2024-11-14)").
+func GhVersion() (string, error) {
+	stdout, _, err := execGh("--version")
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(stdout.String(), "\n")
+	return strings.TrimSpace(line), nil
 }
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/logging"
+)
+
+// fakeGh points GH_PATH at a script standing in for gh, logging every call
+// to gh.log beside it. "auth status" reports the accounts ("host/user")
+// logged in, "auth token --user <u>" prints u as the token, and "auth
+// switch" records the new active account in gh.active. Returns the script.
+func fakeGh(t *testing.T, accounts ...string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gh is a shell script")
+	}
+	var status strings.Builder
+	for _, a := range accounts {
+		host, user, _ := strings.Cut(a, "/")
+		status.WriteString("  ✓ Logged in to " + host + " account " + user + " (keyring)\n")
+	}
+	script := filepath.Join(t.TempDir(), "gh")
+	content := `#!/bin/sh
+echo "$*" >> "$0.log"
+user= prev=
+for arg; do
+  [ "$prev" = --user ] && user=$arg
+  prev=$arg
+done
+case "$1 $2" in
+"auth status") printf '%s' '` + status.String() + `' ;;
+"auth switch") echo "$user" > "$0.active" ;;
+"auth token") [ -n "$user" ] && echo "$user" ;;
+esac
+`
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GH_PATH", script)
+	return script
+}
+
+// loginAPI routes API calls to a stub that answers /user with the request's
+// token as the login, or 401 for the token "revoked".
+func loginAPI(t *testing.T) {
+	t.Helper()
+	isolateGh(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "token ")
+		if token == "revoked" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "{\"login\": %q}", token)
+	}))
+	t.Cleanup(srv.Close)
+	if err := SetProxy(Proxy{URL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseLogin(t *testing.T) {
+	const brokenSibling = `github.com
+  ✓ Logged in to github.com account alice (keyring)
+  - Active account: true
+  - Git operations protocol: ssh
+  - Token: gho_************************************
+
+  X Failed to log in to github.com account bob (keyring)
+  - Active account: false
+  - The token in keyring is invalid.
+`
+	tests := []struct {
+		name         string
+		output       string
+		user         string
+		wantLoggedIn bool
+		wantAnswered bool
+	}{
+		{"logged in", brokenSibling, "alice", true, true},
+		{"broken sibling's own account", brokenSibling, "bob", false, true},
+		{"absent user", brokenSibling, "carol", false, true},
+		{"user name is a prefix of another", brokenSibling, "ali", false, true},
+		{"nobody logged in", "You are not logged into any GitHub hosts. To log in, run: gh auth login\n", "alice", false, true},
+		{"no host accounts", "You are not logged into any accounts on github.com\n", "alice", false, true},
+		{"nothing parseable", "gh: command not found\n", "alice", false, false},
+		{"empty", "", "alice", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loggedIn, answered := parseLogin(tt.output, "github.com", tt.user)
+			if loggedIn != tt.wantLoggedIn || answered != tt.wantAnswered {
+				t.Errorf("parseLogin(%s) = %v, %v; want %v, %v", tt.user, loggedIn, answered, tt.wantLoggedIn, tt.wantAnswered)
+			}
+		})
+	}
+}
+
+func TestAuthIsReadOnly(t *testing.T) {
+	gh := fakeGh(t, "github.localhost/alice", "github.localhost/bob")
+	loginAPI(t)
+	if err := os.WriteFile(gh+".active", []byte("bob\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := TestAuth("github.localhost", "alice")
+	if err != nil || !ok {
+		t.Fatalf("TestAuth(alice) = %v, %v; want true", ok, err)
+	}
+
+	log, _ := os.ReadFile(gh + ".log")
+	if !strings.Contains(string(log), "auth token --hostname github.localhost --user alice") {
+		t.Errorf("TestAuth didn't use alice's own token:\n%s", log)
+	}
+	if strings.Contains(string(log), "auth switch") {
+		t.Errorf("TestAuth switched accounts:\n%s", log)
+	}
+	if active, _ := os.ReadFile(gh + ".active"); string(active) != "bob\n" {
+		t.Errorf("active account = %q after TestAuth, want bob", active)
+	}
+}
+
+func TestTestAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		accounts []string
+		host     string
+		user     string
+		want     bool
+	}{
+		{"logged in", []string{"github.localhost/alice"}, "github.localhost", "alice", true},
+		{"logged out", nil, "github.localhost", "alice", false},
+		{"other host only", []string{"ghes.localhost/alice"}, "github.localhost", "alice", false},
+		{"wrong user", []string{"github.localhost/bob"}, "github.localhost", "alice", false},
+		{"revoked token", []string{"github.localhost/revoked"}, "github.localhost", "revoked", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeGh(t, tt.accounts...)
+			loginAPI(t)
+			ok, err := TestAuth(tt.host, tt.user)
+			if err != nil {
+				t.Fatalf("TestAuth: %v", err)
+			}
+			if ok != tt.want {
+				t.Errorf("TestAuth(%s, %s) = %v, want %v", tt.host, tt.user, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecGhLogsArgs(t *testing.T) {
+	fakeGh(t, "github.localhost/alice")
+	var buf bytes.Buffer
+	logging.SetOutput(&buf)
+	t.Cleanup(func() {
+		logging.SetOutput(os.Stderr)
+		logging.Configure(false, false)
+	})
+
+	for _, verbose := range []bool{false, true} {
+		buf.Reset()
+		logging.Configure(verbose, false)
+		if _, err := CheckLogin("github.localhost", "alice"); err != nil {
+			t.Fatal(err)
+		}
+		logged := strings.Contains(buf.String(), `args="auth status --hostname github.localhost"`)
+		if logged != verbose {
+			t.Errorf("with verbose %v, gh args logged %v:\n%s", verbose, logged, buf.String())
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// ABOUTME: Registered SSH key listing for gh-context
+// ABOUTME: Fetches an account's SSH keys from the API so a context's key can be matched against them
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+)
+
+// KeyInfo is an SSH key registered on a GitHub account.
+type KeyInfo struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title,omitempty"` // Empty from the public listing
+	Key         string `json:"key"`             // authorized_keys form, without comment
+	Fingerprint string `json:"fingerprint"`     // SHA256:..., as ssh.KeyFingerprint returns
+}
+
+// ListSSHKeys returns the SSH keys registered on user's account on hostname.
+// It calls GET /user/keys with that account's own token, so gh's active
+// account needn't be user. Tokens without the read:public_key scope are
+// refused there, in which case the public GET /users/{user}/keys listing
+// (the same keys, without titles) is used instead.
+func ListSSHKeys(ctx context.Context, hostname, user string) ([]KeyInfo, error) {
+	token, err := UserToken(hostname, user)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newRESTClientWithToken(hostname, token)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []KeyInfo
+	err = client.DoWithContext(ctx, "GET", "user/keys", nil, &keys)
+	if scopeRefused(hostname, err) {
+		keys = nil
+		err = client.DoWithContext(ctx, "GET", fmt.Sprintf("users/%s/keys", url.PathEscape(user)), nil, &keys)
+	}
+	if err != nil {
+		return nil, rateLimited(hostname, unreachable(ctx, hostname, err))
+	}
+
+	for i := range keys {
+		keys[i].Fingerprint, _ = ssh.PublicKeyFingerprint(keys[i].Key)
+	}
+	return keys, nil
+}
+
+// scopeRefused reports whether err is the API refusing a token the scope an
+// endpoint needs (403 or 404, as opposed to a rate limit).
+func scopeRefused(hostname string, err error) bool {
+	var httpErr *api.HTTPError
+	if !errors.As(err, &httpErr) || IsRateLimited(rateLimited(hostname, err)) {
+		return false
+	}
+	return httpErr.StatusCode == http.StatusForbidden || httpErr.StatusCode == http.StatusNotFound
+}
+
+// HasSSHKey reports whether fingerprint is among keys.
+func HasSSHKey(keys []KeyInfo, fingerprint string) bool {
+	for _, k := range keys {
+		if k.Fingerprint != "" && k.Fingerprint == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// SSHKeys is ListSSHKeys bounded by the configured timeout.
+func SSHKeys(hostname, user string) ([]KeyInfo, error) {
+	ctx, cancel := newContext()
+	defer cancel()
+	return ListSSHKeys(ctx, hostname, user)
+}
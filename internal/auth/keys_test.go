@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A fixture public key and its fingerprint as printed by ssh-keygen -l
+const (
+	workKey   = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIIyhAGDFvv6M8QbJgbaCDcPSd862IkZvo3RK60QZZ7ww"
+	workKeyFP = "SHA256:o8lJPYNDLeY3l7mFfV0x8qT5obCNkZdpMZ2M6mwikm4"
+	otherFP   = "SHA256:P07Ki+n+JEhihh6zry534Oj4AFN0qsxjQQ/6u1jXghI"
+)
+
+// keysAPI routes API calls to a stub serving workKey as the only key of
+// every account. /user/keys refuses the token "noscope" as GitHub does for a
+// token without read:public_key; the public /users/{user}/keys always answers.
+// Returns the paths requested.
+func keysAPI(t *testing.T) *[]string {
+	t.Helper()
+	isolateGh(t)
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "token ")
+		keys := []KeyInfo{{ID: 1, Key: workKey}}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/user/keys") && token == "noscope":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case strings.HasSuffix(r.URL.Path, "/user/keys"):
+			keys[0].Title = "work laptop"
+		case !strings.Contains(r.URL.Path, "/users/"):
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(keys)
+	}))
+	t.Cleanup(srv.Close)
+	if err := SetProxy(Proxy{URL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	return &paths
+}
+
+func TestSSHKeys(t *testing.T) {
+	tests := []struct {
+		user      string
+		wantTitle string
+		wantPath  string
+	}{
+		{"alice", "work laptop", "/user/keys"},
+		{"noscope", "", "/users/noscope/keys"}, // Falls back to the public listing
+	}
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			paths := keysAPI(t)
+			fakeGh(t, "github.localhost/"+tt.user)
+
+			keys, err := SSHKeys("github.localhost", tt.user)
+			if err != nil {
+				t.Fatalf("SSHKeys: %v", err)
+			}
+			if len(keys) != 1 || keys[0].Fingerprint != workKeyFP || keys[0].Title != tt.wantTitle {
+				t.Fatalf("keys = %+v, want the work key titled %q", keys, tt.wantTitle)
+			}
+			if got := (*paths)[len(*paths)-1]; !strings.HasSuffix(got, tt.wantPath) {
+				t.Errorf("last request to %s, want %s", got, tt.wantPath)
+			}
+			if !HasSSHKey(keys, workKeyFP) {
+				t.Error("registered key not matched")
+			}
+			if HasSSHKey(keys, otherFP) {
+				t.Error("unregistered key matched")
+			}
+		})
+	}
+}
+
+func TestHasSSHKeyIgnoresUnparsedKeys(t *testing.T) {
+	if HasSSHKey([]KeyInfo{{Key: "garbage"}}, "") {
+		t.Error("an empty fingerprint matched a key that didn't parse")
+	}
+}
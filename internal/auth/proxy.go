@@ -0,0 +1,77 @@
+// ABOUTME: Per-context HTTP proxy support for gh-context API calls
+// ABOUTME: Builds the transport used by REST clients instead of relying on env alone
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/logging"
+)
+
+// Proxy describes how API calls for a context reach GitHub.
+// The zero value defers to the process environment (HTTPS_PROXY, NO_PROXY).
+type Proxy struct {
+	URL      string // Explicit proxy URL (e.g., http://proxy.corp:3128)
+	Disabled bool   // Connect directly, ignoring any proxy environment
+}
+
+// activeProxy is applied to every REST client created by this package.
+var activeProxy Proxy
+
+// SetProxy sets the proxy used by subsequent API calls. The URL needs an
+// http, https or socks5 scheme and a host (see config.ValidateProxy).
+func SetProxy(p Proxy) error {
+	if err := config.ValidateProxy(p.URL); err != nil {
+		return err
+	}
+	activeProxy = p
+	return nil
+}
+
+// Transport returns an HTTP transport honoring the proxy settings.
+func (p Proxy) Transport() (http.RoundTripper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	switch {
+	case p.Disabled:
+		transport.Proxy = nil
+	case p.URL != "":
+		proxyURL, err := url.Parse(p.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL '%s': %w", p.URL, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	default:
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+
+	return transport, nil
+}
+
+// newRESTClient creates a REST client for hostname using the active proxy.
+func newRESTClient(hostname string) (*api.RESTClient, error) {
+	return newRESTClientWithToken(hostname, "")
+}
+
+// newRESTClientWithToken is newRESTClient authenticating with token instead
+// of gh's active account (empty = active account).
+func newRESTClientWithToken(hostname, token string) (*api.RESTClient, error) {
+	transport, err := activeProxy.Transport()
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Info("api client", "host", hostname, "proxy", activeProxy.URL, "noProxy", activeProxy.Disabled)
+	opts := api.ClientOptions{
+		Host:      hostname,
+		Transport: transport,
+		Timeout:   requestTimeout,
+		AuthToken: token,
+	}
+	return api.NewRESTClient(opts)
+}
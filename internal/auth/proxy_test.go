@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// stubProxy serves as an HTTP proxy that answers every request itself with
+// a login, counting the requests it sees.
+func stubProxy(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"login": "via-proxy", "url": %q}`, r.URL.String())
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+// isolateGh gives API clients a throwaway gh config and a fixed token.
+func isolateGh(t *testing.T) {
+	t.Helper()
+	t.Setenv("GH_CONFIG_DIR", t.TempDir())
+	t.Setenv("GH_TOKEN", "test-token") // go-gh treats github.localhost like github.com
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_ENTERPRISE_TOKEN", "")
+	t.Setenv("GITHUB_ENTERPRISE_TOKEN", "")
+	t.Cleanup(func() { SetProxy(Proxy{}) })
+}
+
+func TestProxyRoutesAPICalls(t *testing.T) {
+	isolateGh(t)
+	proxy, hits := stubProxy(t)
+	if err := SetProxy(Proxy{URL: proxy.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	login, err := getCurrentUser(context.Background(), "github.localhost")
+	if err != nil {
+		t.Fatalf("getCurrentUser through the proxy: %v", err)
+	}
+	if login != "via-proxy" || hits.Load() != 1 {
+		t.Errorf("login = %q after %d proxy requests, want via-proxy after 1", login, hits.Load())
+	}
+}
+
+func TestNoProxyBypassesProxy(t *testing.T) {
+	isolateGh(t)
+	proxy, hits := stubProxy(t)
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "direct")
+	}))
+	t.Cleanup(direct.Close)
+	t.Setenv("HTTP_PROXY", proxy.URL)
+	t.Setenv("http_proxy", proxy.URL)
+
+	transport, err := Proxy{Disabled: true}.Transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get(direct.URL)
+	if err != nil {
+		t.Fatalf("direct request: %v", err)
+	}
+	resp.Body.Close()
+	if hits.Load() != 0 {
+		t.Errorf("NOPROXY context sent %d requests through the proxy", hits.Load())
+	}
+
+	transport, err = Proxy{URL: proxy.URL}.Transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = (&http.Client{Transport: transport}).Get(direct.URL)
+	if err != nil {
+		t.Fatalf("proxied request: %v", err)
+	}
+	resp.Body.Close()
+	if hits.Load() != 1 {
+		t.Errorf("proxied context sent %d requests through the proxy, want 1", hits.Load())
+	}
+}
+
+func TestSetProxyValidates(t *testing.T) {
+	t.Cleanup(func() { SetProxy(Proxy{}) })
+	for _, bad := range []string{"user:pass@proxy.corp:8080", "proxy.corp:3128", "ftp://proxy.corp"} {
+		if err := SetProxy(Proxy{URL: bad}); err == nil {
+			t.Errorf("SetProxy(%q) succeeded", bad)
+		}
+	}
+	if err := SetProxy(Proxy{URL: "socks5://proxy.corp:1080"}); err != nil {
+		t.Errorf("SetProxy(socks5): %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+// ABOUTME: API rate-limit detection for gh-context
+// ABOUTME: Turns throttled API responses into RateLimitError so callers don't report them as auth failures
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+)
+
+// RateLimitError is returned when a host refused an API call because the
+// token's rate limit is exhausted.
+type RateLimitError struct {
+	Host  string
+	Reset time.Time // When the limit resets (zero if the host didn't say)
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Reset.IsZero() {
+		return fmt.Sprintf("rate limited by %s", e.Host)
+	}
+	return fmt.Sprintf("rate limited by %s until %s", e.Host, e.Reset.Local().Format("15:04:05"))
+}
+
+// IsRateLimited reports whether err is (or wraps) a RateLimitError.
+func IsRateLimited(err error) bool {
+	var rl *RateLimitError
+	return errors.As(err, &rl)
+}
+
+// rateLimited converts a throttled API response into a RateLimitError.
+// GitHub signals primary limits with 403/429 and X-RateLimit-Remaining: 0,
+// and secondary limits with Retry-After.
+func rateLimited(hostname string, err error) error {
+	var httpErr *api.HTTPError
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+	if httpErr.StatusCode != http.StatusForbidden && httpErr.StatusCode != http.StatusTooManyRequests {
+		return err
+	}
+
+	h := httpErr.Headers
+	switch {
+	case h.Get("X-RateLimit-Remaining") == "0":
+		rl := &RateLimitError{Host: hostname}
+		if secs, perr := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); perr == nil {
+			rl.Reset = time.Unix(secs, 0)
+		}
+		return rl
+	case h.Get("Retry-After") != "":
+		rl := &RateLimitError{Host: hostname}
+		if secs, perr := strconv.Atoi(h.Get("Retry-After")); perr == nil {
+			rl.Reset = time.Now().Add(time.Duration(secs) * time.Second)
+		}
+		return rl
+	case httpErr.StatusCode == http.StatusTooManyRequests:
+		return &RateLimitError{Host: hostname}
+	}
+	return err
+}
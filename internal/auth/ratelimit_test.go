@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+)
+
+func TestRateLimited(t *testing.T) {
+	httpErr := func(status int, headers ...string) error {
+		h := http.Header{}
+		for i := 0; i < len(headers); i += 2 {
+			h.Set(headers[i], headers[i+1])
+		}
+		return &api.HTTPError{StatusCode: status, Headers: h}
+	}
+	tests := []struct {
+		name    string
+		err     error
+		limited bool
+		reset   time.Duration // Expected Reset from now; 0 for none
+	}{
+		{"403 remaining 0 with reset", httpErr(403, "X-RateLimit-Remaining", "0", "X-RateLimit-Reset", "4102444800"), true, time.Until(time.Unix(4102444800, 0))},
+		{"429 remaining 0", httpErr(429, "X-RateLimit-Remaining", "0"), true, 0},
+		{"403 retry-after", httpErr(403, "Retry-After", "60"), true, time.Minute},
+		{"429 retry-after", httpErr(429, "Retry-After", "30"), true, 30 * time.Second},
+		{"429 without headers", httpErr(429), true, 0},
+		{"403 with quota left", httpErr(403, "X-RateLimit-Remaining", "12"), false, 0},
+		{"401 remaining 0", httpErr(401, "X-RateLimit-Remaining", "0"), false, 0},
+		{"not an HTTP error", errors.New("boom"), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rateLimited("github.com", tt.err)
+			if IsRateLimited(err) != tt.limited {
+				t.Fatalf("rateLimited = %v, want rate limited %v", err, tt.limited)
+			}
+			if !tt.limited {
+				if err != tt.err {
+					t.Errorf("rateLimited changed a non-limit error to %v", err)
+				}
+				return
+			}
+			var rl *RateLimitError
+			errors.As(err, &rl)
+			if rl.Host != "github.com" {
+				t.Errorf("Host = %q", rl.Host)
+			}
+			if tt.reset == 0 {
+				if !rl.Reset.IsZero() {
+					t.Errorf("Reset = %v, want none", rl.Reset)
+				}
+			} else if d := time.Until(rl.Reset) - tt.reset; d < -5*time.Second || d > 5*time.Second {
+				t.Errorf("Reset = %v, want about %v from now", rl.Reset, tt.reset)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// ABOUTME: Structured parsing of `gh auth status` output for gh-context
+// ABOUTME: Turns per-host, per-account status text into AccountStatus records
+
+package auth
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	ghAuth "github.com/cli/go-gh/v2/pkg/auth"
+)
+
+// AccountStatus describes one account on one host as reported by gh auth status.
+type AccountStatus struct {
+	Hostname    string   `json:"hostname"`
+	User        string   `json:"user"`
+	LoggedIn    bool     `json:"loggedIn"`
+	Active      bool     `json:"active"`
+	HasToken    bool     `json:"hasToken"`
+	TokenSource string   `json:"tokenSource,omitempty"` // e.g. keyring, GH_TOKEN, hosts.yml
+	GitProtocol string   `json:"gitProtocol,omitempty"`
+	Scopes      []string `json:"scopes,omitempty"`
+}
+
+// accountLinePattern matches the per-account header lines, e.g.
+// "✓ Logged in to github.com account octocat (keyring)" or
+// "X Failed to log in to github.com account octocat (GH_TOKEN)".
+var accountLinePattern = regexp.MustCompile(`(Logged in|Failed to log in) to (\S+) account (\S+)(?: \(([^)]*)\))?`)
+
+// ParseStatus parses the text output of `gh auth status` into account records.
+func ParseStatus(output string) []AccountStatus {
+	var accounts []AccountStatus
+	var current *AccountStatus
+
+	for _, raw := range strings.Split(output, "\n") {
+		line := strings.TrimSpace(raw)
+
+		if match := accountLinePattern.FindStringSubmatch(line); match != nil {
+			if current != nil {
+				accounts = append(accounts, *current)
+			}
+			current = &AccountStatus{
+				Hostname:    match[2],
+				User:        match[3],
+				LoggedIn:    match[1] == "Logged in",
+				TokenSource: match[4],
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		field := strings.TrimPrefix(line, "- ")
+		key, value, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "Active account":
+			current.Active = value == "true"
+		case "Git operations protocol":
+			current.GitProtocol = value
+		case "Token":
+			current.HasToken = value != "" && value != "none"
+		case "Token scopes":
+			for _, scope := range strings.Split(value, ",") {
+				scope = strings.Trim(strings.TrimSpace(scope), "'")
+				if scope != "" {
+					current.Scopes = append(current.Scopes, scope)
+				}
+			}
+		}
+	}
+
+	if current != nil {
+		accounts = append(accounts, *current)
+	}
+
+	return accounts
+}
+
+// GetStatus runs gh auth status and returns structured account records.
+// If hostname is empty, all configured hosts are reported.
+func GetStatus(hostname string) ([]AccountStatus, error) {
+	args := []string{"auth", "status"}
+	if hostname != "" {
+		args = append(args, "--hostname", hostname)
+	}
+
+	// gh auth status exits non-zero when any account is broken, but still
+	// prints the full report (to stdout or stderr depending on gh version).
+	// Its error only matters when there's no report: gh failed to run, timed
+	// out, or printed something else entirely.
+	stdout, stderr, err := execGh(args...)
+	output := stdout.String() + "\n" + stderr.String()
+	accounts := ParseStatus(output)
+	if err != nil && (IsUnreachable(err) || len(accounts) == 0 && !strings.Contains(output, "not logged in")) {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// ListHosts returns the hosts gh is authenticated to, in the order gh reports them.
+func ListHosts() ([]string, error) {
+	accounts, err := GetStatus("")
+	if err != nil {
+		return nil, err
+	}
+	return HostsFromStatus(accounts), nil
+}
+
+// HostsFromStatus returns the distinct hosts that have a logged-in account.
+func HostsFromStatus(accounts []AccountStatus) []string {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, acct := range accounts {
+		if !acct.LoggedIn || seen[acct.Hostname] {
+			continue
+		}
+		seen[acct.Hostname] = true
+		hosts = append(hosts, acct.Hostname)
+	}
+	return hosts
+}
+
+// tokenEnvVars lists the environment variables gh reads a token from, in
+// precedence order, for github.com/ghe.com hosts and for enterprise servers.
+var (
+	tokenEnvVars           = []string{"GH_TOKEN", "GITHUB_TOKEN"}
+	enterpriseTokenEnvVars = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+)
+
+// EnvTokenVar returns the environment variable supplying hostname's token,
+// or empty string if gh will use its stored credentials.
+func EnvTokenVar(hostname string) string {
+	vars := tokenEnvVars
+	if ghAuth.IsEnterprise(hostname) {
+		vars = enterpriseTokenEnvVars
+	}
+	for _, name := range vars {
+		if os.Getenv(name) != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// TokenSource reports where gh gets its token for hostname: an environment
+// variable name (which overrides stored credentials), "keyring", or the path
+// of the file holding it, as shown by gh auth status.
+func TokenSource(hostname string) (string, error) {
+	if name := EnvTokenVar(hostname); name != "" {
+		return name, nil
+	}
+
+	accounts, err := GetStatus(hostname)
+	if err != nil {
+		return "", err
+	}
+
+	source := ""
+	for _, acct := range accounts {
+		if acct.Hostname != hostname || acct.TokenSource == "" {
+			continue
+		}
+		if acct.Active {
+			return acct.TokenSource, nil
+		}
+		if source == "" {
+			source = acct.TokenSource
+		}
+	}
+	if source == "" {
+		return "", fmt.Errorf("no token found for %s", hostname)
+	}
+	return source, nil
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHostsFromStatus(t *testing.T) {
+	accounts := []AccountStatus{
+		{Hostname: "github.com", User: "alice", LoggedIn: true},
+		{Hostname: "github.com", User: "bob", LoggedIn: true},
+		{Hostname: "broken.corp", User: "carol"},
+		{Hostname: "ghes.corp", User: "dave", LoggedIn: true},
+	}
+	want := []string{"github.com", "ghes.corp"}
+	if got := HostsFromStatus(accounts); !reflect.DeepEqual(got, want) {
+		t.Errorf("HostsFromStatus = %v, want %v", got, want)
+	}
+}
+
+func TestGetStatusReportsExecFailure(t *testing.T) {
+	t.Setenv("GH_PATH", filepath.Join(t.TempDir(), "no-such-gh"))
+	accounts, err := GetStatus("")
+	if err == nil {
+		t.Fatalf("GetStatus = %v, nil; want the error from running gh", accounts)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []AccountStatus
+	}{
+		{
+			name: "several hosts and accounts",
+			output: `github.com
+  ✓ Logged in to github.com account alice (keyring)
+  - Active account: true
+  - Git operations protocol: ssh
+  - Token: gho_************************************
+  - Token scopes: 'gist', 'read:org', 'repo'
+
+  ✓ Logged in to github.com account bob (/home/bob/.config/gh/hosts.yml)
+  - Active account: false
+  - Git operations protocol: https
+  - Token: gho_************************************
+  - Token scopes: 'repo'
+
+ghes.corp
+  X Failed to log in to ghes.corp account carol (GH_ENTERPRISE_TOKEN)
+  - Active account: true
+  - The token in GH_ENTERPRISE_TOKEN is invalid.
+`,
+			want: []AccountStatus{
+				{Hostname: "github.com", User: "alice", LoggedIn: true, Active: true, HasToken: true, TokenSource: "keyring", GitProtocol: "ssh", Scopes: []string{"gist", "read:org", "repo"}},
+				{Hostname: "github.com", User: "bob", LoggedIn: true, HasToken: true, TokenSource: "/home/bob/.config/gh/hosts.yml", GitProtocol: "https", Scopes: []string{"repo"}},
+				{Hostname: "ghes.corp", User: "carol", Active: true, TokenSource: "GH_ENTERPRISE_TOKEN"},
+			},
+		},
+		{
+			name: "older gh without token source or active line",
+			output: `github.com
+  ✓ Logged in to github.com account alice
+  ✓ Git operations for github.com configured to use https protocol.
+  ✓ Token: none
+`,
+			want: []AccountStatus{
+				{Hostname: "github.com", User: "alice", LoggedIn: true},
+			},
+		},
+		{
+			name:   "not logged in",
+			output: "You are not logged into any GitHub hosts. To log in, run: gh auth login\n",
+		},
+		{
+			name:   "fields before any account are ignored",
+			output: "- Active account: true\n- Token: gho_xxx\n",
+		},
+		{
+			name:   "CRLF",
+			output: "github.com\r\n  ✓ Logged in to github.com account alice (keyring)\r\n  - Active account: true\r\n",
+			want: []AccountStatus{
+				{Hostname: "github.com", User: "alice", LoggedIn: true, Active: true, TokenSource: "keyring"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseStatus(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseStatus =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvTokenVar(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      []string
+		hostname string
+		want     string
+	}{
+		{"none set", nil, "github.com", ""},
+		{"GH_TOKEN", []string{"GITHUB_TOKEN", "GH_TOKEN"}, "github.com", "GH_TOKEN"},
+		{"GITHUB_TOKEN", []string{"GITHUB_TOKEN"}, "github.com", "GITHUB_TOKEN"},
+		{"enterprise ignores GH_TOKEN", []string{"GH_TOKEN"}, "ghes.corp", ""},
+		{"GH_ENTERPRISE_TOKEN", []string{"GITHUB_ENTERPRISE_TOKEN", "GH_ENTERPRISE_TOKEN"}, "ghes.corp", "GH_ENTERPRISE_TOKEN"},
+		{"GITHUB_ENTERPRISE_TOKEN", []string{"GITHUB_ENTERPRISE_TOKEN"}, "ghes.corp", "GITHUB_ENTERPRISE_TOKEN"},
+		{"github.com ignores enterprise", []string{"GH_ENTERPRISE_TOKEN"}, "github.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range append(tokenEnvVars, enterpriseTokenEnvVars...) {
+				t.Setenv(name, "")
+			}
+			for _, name := range tt.env {
+				t.Setenv(name, "token")
+			}
+			if got := EnvTokenVar(tt.hostname); got != tt.want {
+				t.Errorf("EnvTokenVar(%q) = %q, want %q", tt.hostname, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenSourcePrefersEnv(t *testing.T) {
+	// The variable wins without asking gh, which would fail here
+	t.Setenv("GH_PATH", filepath.Join(t.TempDir(), "no-such-gh"))
+	t.Setenv("GH_TOKEN", "token")
+	source, err := TokenSource("github.com")
+	if err != nil || source != "GH_TOKEN" {
+		t.Errorf("TokenSource = %q, %v; want GH_TOKEN, nil", source, err)
+	}
+}
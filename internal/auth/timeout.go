@@ -0,0 +1,65 @@
+// ABOUTME: Network timeouts for gh-context API calls and gh subprocesses
+// ABOUTME: Bounds every request so a dead host is reported unreachable instead of hanging
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultTimeout bounds each network operation unless overridden with SetTimeout.
+const DefaultTimeout = 10 * time.Second
+
+// TimeoutEnv names the environment variable that overrides DefaultTimeout
+// (e.g. GH_CONTEXT_TIMEOUT=3s).
+const TimeoutEnv = "GH_CONTEXT_TIMEOUT"
+
+// ErrUnreachable is returned (wrapped) when a host doesn't answer in time.
+var ErrUnreachable = errors.New("host unreachable")
+
+// requestTimeout is applied to every gh call and API request made by this package.
+var requestTimeout = DefaultTimeout
+
+// SetTimeout sets the limit for subsequent network operations. Zero disables it.
+func SetTimeout(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("timeout must not be negative, got: %s", d)
+	}
+	requestTimeout = d
+	return nil
+}
+
+// Timeout returns the current network operation limit.
+func Timeout() time.Duration {
+	return requestTimeout
+}
+
+// newContext returns a context bounded by the configured timeout.
+func newContext() (context.Context, context.CancelFunc) {
+	if requestTimeout == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), requestTimeout)
+}
+
+// IsUnreachable reports whether err means the host didn't respond in time.
+func IsUnreachable(err error) bool {
+	return errors.Is(err, ErrUnreachable)
+}
+
+// unreachable wraps err as ErrUnreachable if it was caused by a timeout.
+func unreachable(ctx context.Context, hostname string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var netErr net.Error
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %s did not respond within %s", ErrUnreachable, hostname, requestTimeout)
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutReportsUnreachable(t *testing.T) {
+	isolateGh(t)
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select { // Never answer
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(done) })
+	if err := SetProxy(Proxy{URL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	const timeout = 200 * time.Millisecond
+	if err := SetTimeout(timeout); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetTimeout(DefaultTimeout) })
+
+	start := time.Now()
+	_, err := GetCurrentUserFromSession("github.localhost")
+	if elapsed := time.Since(start); elapsed > timeout+time.Second {
+		t.Errorf("call returned after %s with a %s timeout", elapsed, timeout)
+	}
+	if !IsUnreachable(err) {
+		t.Errorf("err = %v, want host unreachable", err)
+	}
+}
+
+func TestSetTimeoutRejectsNegative(t *testing.T) {
+	t.Cleanup(func() { SetTimeout(DefaultTimeout) })
+	if err := SetTimeout(-time.Second); err == nil {
+		t.Error("SetTimeout(-1s) succeeded")
+	}
+}
@@ -6,20 +6,101 @@ package config
 import (
 	"bufio"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
 )
 
 // Context represents a saved GitHub CLI context (account/host configuration).
 type Context struct {
-	Name      string // Context name (derived from filename, not stored in file)
-	Hostname  string // GitHub host (e.g., github.com)
-	User      string // GitHub username
-	Transport string // ssh or https
-	SSHKey    string // Path to SSH key (e.g., ~/.ssh/id_personal)
+	Name       string // Context name (derived from filename, not stored in file)
+	Hostname   string // GitHub host (e.g., github.com)
+	User       string // GitHub username
+	Transport  string // ssh or https
+	SSHKey     string // Path to SSH key (e.g., ~/.ssh/id_personal)
+	SSHHost    string // SSH Host alias whose block holds the key (empty = Hostname)
+	SSHManaged bool   // Whether use edits ~/.ssh/config (false for token-only contexts)
+	Strategy   string // How use activates SSHKey: StrategySSHConfig (empty) or StrategyGitCommand
+	Proxy      string // HTTP(S) proxy URL for API calls (empty = use environment)
+	NoProxy    bool   // Connect directly, ignoring any proxy environment
+	SSHBanner  string // Regexp for the host's ssh -T greeting, user in group 1 (empty = settings or GitHub's)
+	Announce   *bool  // Whether apply reports switching to it (nil = APPLY_QUIET in the settings file decides)
+
+	GitConfig map[string]string // Local git config written to the repo on use (e.g. core.sshCommand)
+	Extra     []HostEntry       // Further hosts the same identity uses, switched together on use
+}
+
+// HostEntry is one additional host of a multi-host context.
+type HostEntry struct {
+	Hostname string // gh host (e.g. ghec.corp)
+	User     string // Account on that host
+	SSHKey   string // Key to activate for the host (empty = leave SSH config alone)
+	SSHHost  string // SSH Host alias whose block holds the key (empty = Hostname)
+}
+
+// SSHBlockHost returns the SSH Host block name whose keys this entry toggles.
+func (e HostEntry) SSHBlockHost() string {
+	if e.SSHHost != "" {
+		return e.SSHHost
+	}
+	return e.Hostname
+}
+
+// SSH key activation strategies.
+const (
+	StrategySSHConfig  = "ssh-config"  // Toggle IdentityFile lines in ~/.ssh/config (the default)
+	StrategyGitCommand = "git-command" // Write core.sshCommand to the repo instead; env exports GIT_SSH_COMMAND
+)
+
+// ValidateStrategy checks that s names a known strategy ("" = default).
+func ValidateStrategy(s string) error {
+	switch s {
+	case "", StrategySSHConfig, StrategyGitCommand:
+		return nil
+	}
+	return fmt.Errorf("strategy must be '%s' or '%s', got: %s", StrategySSHConfig, StrategyGitCommand, s)
+}
+
+// ValidateProxy checks that proxy ("" = none) is a URL net/http can use: an
+// http, https or socks5 scheme and a host. Without the scheme, values like
+// proxy.corp:3128 or user:pass@proxy.corp:3128 would parse but be misread.
+func ValidateProxy(proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("proxy must be a URL like http://proxy.corp:3128, got: %s", proxy)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return nil
+	}
+	return fmt.Errorf("proxy scheme must be http, https or socks5, got: %s", proxy)
 }
 
+// UsesGitCommand reports whether the context's key is used via core.sshCommand
+// rather than by editing ~/.ssh/config.
+func (c *Context) UsesGitCommand() bool {
+	return c.Strategy == StrategyGitCommand
+}
+
+// extraPrefix marks context file lines describing an additional host, e.g.
+// EXTRA.ghec.corp.USER=workuser and EXTRA.ghec.corp.SSH_KEY=~/.ssh/id_ghec
+const extraPrefix = "EXTRA."
+
+// extraFields lists the per-host fields, matched as suffixes since hostnames contain dots.
+var extraFields = []string{"USER", "SSH_KEY", "SSH_HOST"}
+
+// gitConfigPrefix marks context file lines holding git config entries,
+// e.g. GIT_CONFIG.core.sshCommand=ssh -i ~/.ssh/id_work
+const gitConfigPrefix = "GIT_CONFIG."
+
 // validNamePattern defines valid context name characters.
 var validNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
@@ -31,6 +112,16 @@ func ValidateName(name string) error {
 	return nil
 }
 
+// NotFoundError is returned when a named context or profile doesn't exist.
+type NotFoundError struct {
+	Kind string // "context" or "profile"
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s '%s' not found", e.Kind, e.Name)
+}
+
 // Load reads a context from a .ctx file.
 func Load(name string) (*Context, error) {
 	path, err := ContextFile(name)
@@ -41,17 +132,23 @@ func Load(name string) (*Context, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("context '%s' not found", name)
+			return nil, &NotFoundError{Kind: "context", Name: name}
 		}
 		return nil, err
 	}
 	defer file.Close()
 
-	ctx := &Context{Name: name}
-	scanner := bufio.NewScanner(file)
+	return parseContext(name, file, strings.TrimSpace)
+}
+
+// parseContext reads a context's KEY=VALUE lines from r. Each line is passed
+// through clean first, which lets the legacy format share this parser.
+func parseContext(name string, r io.Reader, clean func(string) string) (*Context, error) {
+	ctx := &Context{Name: name, SSHManaged: true}
+	scanner := bufio.NewScanner(r)
 
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+		line := clean(scanner.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
@@ -73,11 +170,37 @@ func Load(name string) (*Context, error) {
 			ctx.Transport = value
 		case "SSH_KEY":
 			ctx.SSHKey = value
+		case "SSH_HOST":
+			ctx.SSHHost = value
+		case "SSH_MANAGED":
+			ctx.SSHManaged = value != "false"
+		case "SSH_STRATEGY":
+			ctx.Strategy = value
+		case "PROXY":
+			ctx.Proxy = value
+		case "NO_PROXY":
+			ctx.NoProxy = value == "true"
+		case "SSH_BANNER":
+			ctx.SSHBanner = value
+		case "ANNOUNCE":
+			announce := value != "false"
+			ctx.Announce = &announce
 		case "SSH_HOST_ALIAS":
 			// Legacy field - migrate to SSH_KEY if SSH_KEY not set
 			if ctx.SSHKey == "" {
 				ctx.SSHKey = value
 			}
+		default:
+			if rest := strings.TrimPrefix(key, extraPrefix); rest != key {
+				ctx.setExtraField(rest, value)
+				continue
+			}
+			if gitKey := strings.TrimPrefix(key, gitConfigPrefix); gitKey != key && gitKey != "" {
+				if ctx.GitConfig == nil {
+					ctx.GitConfig = make(map[string]string)
+				}
+				ctx.GitConfig[gitKey] = value
+			}
 		}
 	}
 
@@ -85,15 +208,50 @@ func Load(name string) (*Context, error) {
 		return nil, err
 	}
 
+	sort.Slice(ctx.Extra, func(i, j int) bool { return ctx.Extra[i].Hostname < ctx.Extra[j].Hostname })
 	return ctx, nil
 }
 
+// setExtraField records one "<host>.<FIELD>" value of an additional host.
+func (c *Context) setExtraField(key, value string) {
+	for _, field := range extraFields {
+		host := strings.TrimSuffix(key, "."+field)
+		if host == key || host == "" {
+			continue
+		}
+
+		var entry *HostEntry
+		for i := range c.Extra {
+			if c.Extra[i].Hostname == host {
+				entry = &c.Extra[i]
+			}
+		}
+		if entry == nil {
+			c.Extra = append(c.Extra, HostEntry{Hostname: host})
+			entry = &c.Extra[len(c.Extra)-1]
+		}
+
+		switch field {
+		case "USER":
+			entry.User = value
+		case "SSH_KEY":
+			entry.SSHKey = value
+		case "SSH_HOST":
+			entry.SSHHost = value
+		}
+		return
+	}
+}
+
 // Save writes a context to a .ctx file.
 func (c *Context) Save() error {
 	path, err := ContextFile(c.Name)
 	if err != nil {
 		return err
 	}
+	if err := ValidateProxy(c.Proxy); err != nil {
+		return err
+	}
 
 	file, err := os.Create(path)
 	if err != nil {
@@ -105,6 +263,44 @@ func (c *Context) Save() error {
 	fmt.Fprintf(file, "USER=%s\n", c.User)
 	fmt.Fprintf(file, "TRANSPORT=%s\n", c.Transport)
 	fmt.Fprintf(file, "SSH_KEY=%s\n", c.SSHKey)
+	if c.SSHHost != "" {
+		fmt.Fprintf(file, "SSH_HOST=%s\n", c.SSHHost)
+	}
+	fmt.Fprintf(file, "SSH_MANAGED=%t\n", c.SSHManaged)
+	if c.Strategy != "" && c.Strategy != StrategySSHConfig {
+		fmt.Fprintf(file, "SSH_STRATEGY=%s\n", c.Strategy)
+	}
+	if c.Proxy != "" {
+		fmt.Fprintf(file, "PROXY=%s\n", c.Proxy)
+	}
+	if c.NoProxy {
+		fmt.Fprintf(file, "NO_PROXY=true\n")
+	}
+	if c.SSHBanner != "" {
+		fmt.Fprintf(file, "SSH_BANNER=%s\n", c.SSHBanner)
+	}
+	if c.Announce != nil {
+		fmt.Fprintf(file, "ANNOUNCE=%t\n", *c.Announce)
+	}
+
+	keys := make([]string, 0, len(c.GitConfig))
+	for key := range c.GitConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(file, "%s%s=%s\n", gitConfigPrefix, key, c.GitConfig[key])
+	}
+
+	for _, e := range c.Extra {
+		fmt.Fprintf(file, "%s%s.USER=%s\n", extraPrefix, e.Hostname, e.User)
+		if e.SSHKey != "" {
+			fmt.Fprintf(file, "%s%s.SSH_KEY=%s\n", extraPrefix, e.Hostname, e.SSHKey)
+		}
+		if e.SSHHost != "" {
+			fmt.Fprintf(file, "%s%s.SSH_HOST=%s\n", extraPrefix, e.Hostname, e.SSHHost)
+		}
+	}
 
 	return nil
 }
@@ -135,7 +331,7 @@ func Delete(name string) error {
 
 	if err := os.Remove(path); err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("context '%s' not found", name)
+			return &NotFoundError{Kind: "context", Name: name}
 		}
 		return err
 	}
@@ -151,11 +347,96 @@ func Delete(name string) error {
 	return nil
 }
 
+// Rename renames a context, its last-used time and every profile entry naming
+// it. The new file is written before the active pointer moves and the old one
+// is removed last, so at every moment the active file names a context that
+// exists.
+func Rename(oldName, newName string) error {
+	if err := ValidateName(newName); err != nil {
+		return err
+	}
+	if exists, err := Exists(newName); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("context '%s' already exists", newName)
+	}
+
+	oldPath, err := ContextFile(oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := ContextFile(newName)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(oldPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &NotFoundError{Kind: "context", Name: oldName}
+		}
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(newPath, data, 0644); err != nil {
+		return err
+	}
+
+	if active, _ := GetActive(); active == oldName {
+		if err := SetActive(newName); err != nil {
+			os.Remove(newPath)
+			return err
+		}
+	}
+
+	if err := renameUse(oldName, newName); err != nil {
+		return err
+	}
+
+	profiles, err := ListProfiles()
+	if err != nil {
+		return err
+	}
+	for _, name := range profiles {
+		p, err := LoadProfile(name)
+		if err != nil {
+			return err
+		}
+		changed := false
+		for i := range p.Entries {
+			if p.Entries[i].Context == oldName {
+				p.Entries[i].Context = newName
+				changed = true
+			}
+		}
+		if changed {
+			if err := p.Save(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return os.Remove(oldPath)
+}
+
+// SSHBlockHost returns the SSH Host block name whose keys this context toggles.
+// gh auth always targets Hostname; SSH edits target the alias when one is set.
+func (c *Context) SSHBlockHost() string {
+	if c.SSHHost != "" {
+		return c.SSHHost
+	}
+	return c.Hostname
+}
+
 // String returns a human-readable representation of the context.
 func (c *Context) String() string {
 	s := fmt.Sprintf("%s@%s, %s", c.User, c.Hostname, c.Transport)
 	if c.SSHKey != "" {
 		s += fmt.Sprintf(", key=%s", c.SSHKey)
 	}
+	if !c.SSHManaged {
+		s += ", no-ssh"
+	}
+	for _, e := range c.Extra {
+		s += fmt.Sprintf(", +%s@%s", e.User, e.Hostname)
+	}
 	return s
 }
@@ -0,0 +1,305 @@
+// ABOUTME: Validation and decoding of imported context files for gh-context
+// ABOUTME: Reads the JSON written by "list -o json", reporting problems by line
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// importField describes one known field of an imported context record.
+type importField struct {
+	kind     string // "string", "bool", "object", or "hosts"
+	required bool
+}
+
+// importFields is the known field set of an imported context record.
+var importFields = map[string]importField{
+	"name":       {kind: "string", required: true},
+	"hostname":   {kind: "string", required: true},
+	"user":       {kind: "string", required: true},
+	"transport":  {kind: "string"},
+	"sshKey":     {kind: "string"},
+	"sshHost":    {kind: "string"},
+	"sshManaged": {kind: "bool"},
+	"strategy":   {kind: "string"},
+	"proxy":      {kind: "string"},
+	"noProxy":    {kind: "bool"},
+	"gitConfig":  {kind: "object"},
+	"extraHosts": {kind: "hosts"},
+	"active":     {kind: "bool"},   // Written by list -o json; not imported
+	"lastUsed":   {kind: "string"}, // Written by list -o json; not imported
+}
+
+// importHost is one entry of an imported record's extraHosts.
+type importHost struct {
+	Hostname string `json:"hostname"`
+	User     string `json:"user"`
+	SSHKey   string `json:"sshKey"`
+	SSHHost  string `json:"sshHost"`
+}
+
+// ParseImport validates and decodes contexts from JSON: an array of records as
+// written by "list -o json", or a single record. Missing required fields and
+// wrong types are errors naming the record's line; unknown fields are returned
+// as warnings so newer exports still import.
+func ParseImport(data []byte) ([]*Context, []string, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, nil, fmt.Errorf("import file is empty")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var records []json.RawMessage
+	var starts []int64
+
+	if trimmed[0] == '[' {
+		if _, err := dec.Token(); err != nil {
+			return nil, nil, syntaxError(data, err)
+		}
+		for dec.More() {
+			start := skipSpace(data, dec.InputOffset())
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, nil, syntaxError(data, err)
+			}
+			records = append(records, raw)
+			starts = append(starts, start)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, nil, syntaxError(data, err)
+		}
+	} else {
+		start := skipSpace(data, 0)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil, syntaxError(data, err)
+		}
+		records = append(records, raw)
+		starts = append(starts, start)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, nil, fmt.Errorf("line %d: unexpected data after the contexts", lineAt(data, dec.InputOffset()))
+	}
+
+	var contexts []*Context
+	var warnings []string
+	seen := make(map[string]int)
+
+	for i, raw := range records {
+		line := lineAt(data, starts[i])
+		ctx, recWarnings, err := parseImportRecord(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: context %d: %w", line, i+1, err)
+		}
+		for _, w := range recWarnings {
+			warnings = append(warnings, fmt.Sprintf("line %d: context '%s': %s", line, ctx.Name, w))
+		}
+		if prev, ok := seen[ctx.Name]; ok {
+			return nil, nil, fmt.Errorf("line %d: context '%s' already defined on line %d", line, ctx.Name, prev)
+		}
+		seen[ctx.Name] = line
+		contexts = append(contexts, ctx)
+	}
+
+	return contexts, warnings, nil
+}
+
+// parseImportRecord validates one record against importFields.
+func parseImportRecord(raw json.RawMessage) (*Context, []string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, nil, fmt.Errorf("must be a JSON object")
+	}
+
+	var warnings []string
+	var unknown []string
+	for key := range fields {
+		if _, ok := importFields[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		warnings = append(warnings, fmt.Sprintf("ignoring unknown field %q", key))
+	}
+
+	names := make([]string, 0, len(importFields))
+	for key := range importFields {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	strs := make(map[string]string)
+	bools := make(map[string]bool)
+	var gitConfig map[string]string
+	var extra []HostEntry
+
+	for _, key := range names {
+		spec := importFields[key]
+		value, ok := fields[key]
+		if !ok || string(value) == "null" {
+			if spec.required {
+				return nil, nil, fmt.Errorf("missing required field %q", key)
+			}
+			continue
+		}
+
+		var err error
+		switch spec.kind {
+		case "string":
+			var v string
+			if err = json.Unmarshal(value, &v); err == nil {
+				strs[key] = strings.TrimSpace(v)
+			}
+		case "bool":
+			var v bool
+			if err = json.Unmarshal(value, &v); err == nil {
+				bools[key] = v
+			}
+		case "object":
+			err = json.Unmarshal(value, &gitConfig)
+		case "hosts":
+			var hosts []importHost
+			if err = json.Unmarshal(value, &hosts); err == nil {
+				if extra, err = importHostEntries(hosts); err != nil {
+					return nil, nil, fmt.Errorf("field %q: %w", key, err)
+				}
+			}
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("field %q must be %s, got %s", key, kindName(spec.kind), jsonType(value))
+		}
+		if spec.required && strs[key] == "" && spec.kind == "string" {
+			return nil, nil, fmt.Errorf("required field %q is empty", key)
+		}
+	}
+
+	ctx := &Context{
+		Name:       strs["name"],
+		Hostname:   strs["hostname"],
+		User:       strs["user"],
+		Transport:  strs["transport"],
+		SSHKey:     strs["sshKey"],
+		SSHHost:    strs["sshHost"],
+		SSHManaged: true,
+		Strategy:   strs["strategy"],
+		Proxy:      strs["proxy"],
+		NoProxy:    bools["noProxy"],
+		GitConfig:  gitConfig,
+		Extra:      extra,
+	}
+	if v, ok := bools["sshManaged"]; ok {
+		ctx.SSHManaged = v
+	}
+	if ctx.Transport == "" {
+		ctx.Transport = "ssh"
+	}
+
+	if err := ValidateName(ctx.Name); err != nil {
+		return nil, nil, err
+	}
+	if ctx.Transport != "ssh" && ctx.Transport != "https" {
+		return nil, nil, fmt.Errorf("field \"transport\" must be 'ssh' or 'https', got: %s", ctx.Transport)
+	}
+	if err := ValidateStrategy(ctx.Strategy); err != nil {
+		return nil, nil, fmt.Errorf("field \"strategy\": %w", err)
+	}
+	if err := ValidateProxy(ctx.Proxy); err != nil {
+		return nil, nil, fmt.Errorf("field \"proxy\": %w", err)
+	}
+	if ctx.Proxy != "" && ctx.NoProxy {
+		return nil, nil, fmt.Errorf("fields \"proxy\" and \"noProxy\" are mutually exclusive")
+	}
+	for _, e := range ctx.Extra {
+		if strings.EqualFold(e.Hostname, ctx.Hostname) {
+			return nil, nil, fmt.Errorf("field \"extraHosts\" repeats the context's own host %s", e.Hostname)
+		}
+	}
+
+	return ctx, warnings, nil
+}
+
+// importHostEntries validates extraHosts entries, returning them sorted by
+// host as Load does.
+func importHostEntries(hosts []importHost) ([]HostEntry, error) {
+	var entries []HostEntry
+	seen := make(map[string]bool)
+	for i, h := range hosts {
+		host := strings.ToLower(strings.TrimSpace(h.Hostname))
+		user := strings.TrimSpace(h.User)
+		if host == "" || user == "" {
+			return nil, fmt.Errorf("entry %d needs a hostname and a user", i+1)
+		}
+		if seen[host] {
+			return nil, fmt.Errorf("lists %s more than once", host)
+		}
+		seen[host] = true
+		entries = append(entries, HostEntry{Hostname: host, User: user, SSHKey: strings.TrimSpace(h.SSHKey), SSHHost: strings.TrimSpace(h.SSHHost)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Hostname < entries[j].Hostname })
+	return entries, nil
+}
+
+// kindName describes an importField kind for error messages.
+func kindName(kind string) string {
+	switch kind {
+	case "bool":
+		return "a boolean"
+	case "object":
+		return "an object of string values"
+	case "hosts":
+		return "an array of host objects"
+	default:
+		return "a string"
+	}
+}
+
+// jsonType names the JSON type of a raw value for error messages.
+func jsonType(raw json.RawMessage) string {
+	switch raw[0] {
+	case '"':
+		return "a string"
+	case 't', 'f':
+		return "a boolean"
+	case '{':
+		return "an object"
+	case '[':
+		return "an array"
+	default:
+		return "a number"
+	}
+}
+
+// syntaxError adds a line number to JSON decoding errors that carry an offset.
+func syntaxError(data []byte, err error) error {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return fmt.Errorf("line %d: invalid JSON: %v", lineAt(data, e.Offset), e)
+	case *json.UnmarshalTypeError:
+		return fmt.Errorf("line %d: invalid JSON: %v", lineAt(data, e.Offset), e)
+	default:
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+}
+
+// skipSpace returns the offset of the first non-space, non-comma byte at or after off.
+func skipSpace(data []byte, off int64) int64 {
+	for off < int64(len(data)) && strings.ContainsRune(" \t\r\n,", rune(data[off])) {
+		off++
+	}
+	return off
+}
+
+// lineAt returns the 1-based line number of a byte offset.
+func lineAt(data []byte, off int64) int {
+	if off > int64(len(data)) {
+		off = int64(len(data))
+	}
+	return bytes.Count(data[:off], []byte("\n")) + 1
+}
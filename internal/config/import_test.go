@@ -0,0 +1,127 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseImportExtraHosts(t *testing.T) {
+	data := []byte(`[
+  {
+    "name": "both",
+    "hostname": "github.com",
+    "user": "me",
+    "extraHosts": [
+      {"hostname": "GHEC.corp", "user": "me-ghec", "sshKey": "~/.ssh/id_ghec"},
+      {"hostname": "ghes.corp", "user": "me-ghes", "sshHost": "ghes-work"}
+    ]
+  }
+]`)
+	contexts, warnings, err := ParseImport(data)
+	if err != nil || len(warnings) > 0 {
+		t.Fatalf("ParseImport: %v (warnings %v)", err, warnings)
+	}
+	want := []HostEntry{
+		{Hostname: "ghec.corp", User: "me-ghec", SSHKey: "~/.ssh/id_ghec"},
+		{Hostname: "ghes.corp", User: "me-ghes", SSHHost: "ghes-work"},
+	}
+	if got := contexts[0].Extra; !reflect.DeepEqual(got, want) {
+		t.Errorf("Extra = %+v, want %+v", got, want)
+	}
+
+	bad := map[string]string{
+		"missing user": `{"name": "a", "hostname": "github.com", "user": "me", "extraHosts": [{"hostname": "ghec.corp"}]}`,
+		"repeated":     `{"name": "a", "hostname": "github.com", "user": "me", "extraHosts": [{"hostname": "x", "user": "u"}, {"hostname": "X", "user": "v"}]}`,
+		"own host":     `{"name": "a", "hostname": "github.com", "user": "me", "extraHosts": [{"hostname": "github.com", "user": "u"}]}`,
+		"not an array": `{"name": "a", "hostname": "github.com", "user": "me", "extraHosts": {"hostname": "x"}}`,
+	}
+	for name, record := range bad {
+		if _, _, err := ParseImport([]byte(record)); err == nil {
+			t.Errorf("%s: ParseImport accepted %s", name, record)
+		}
+	}
+}
+
+func TestParseImportListFields(t *testing.T) {
+	// Fields list -o json writes that aren't imported still aren't unknown
+	data := []byte(`[{"name": "work", "hostname": "github.com", "user": "me", "active": true, "lastUsed": "2026-10-01T09:30:00Z"}]`)
+	contexts, warnings, err := ParseImport(data)
+	if err != nil {
+		t.Fatalf("ParseImport: %v", err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("warnings = %v, want none", warnings)
+	}
+	if len(contexts) != 1 || contexts[0].Name != "work" {
+		t.Errorf("contexts = %v, want work", contexts)
+	}
+}
+
+func TestParseImportErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string // Prefix of the error
+	}{
+		{"empty", "  \n", "import file is empty"},
+		{"syntax error", "[\n  {\"name\": \"a\",\n    \"hostname\" \"github.com\"}\n]", "line 3: invalid JSON"},
+		{"unclosed array", "[\n  {\"name\": \"a\", \"hostname\": \"github.com\", \"user\": \"me\"}\n", "line 3: invalid JSON"},
+		{"trailing data", "{\"name\": \"a\", \"hostname\": \"github.com\", \"user\": \"me\"}\n\n{}", "line 3: unexpected data after the contexts"},
+		{"not an object", "[\n  {\"name\": \"a\", \"hostname\": \"github.com\", \"user\": \"me\"},\n  \"b\"\n]", "line 3: context 2: must be a JSON object"},
+		{"missing field", "[\n\n  {\"name\": \"a\", \"hostname\": \"github.com\"}\n]", `line 3: context 1: missing required field "user"`},
+		{"empty field", `{"name": "a", "hostname": " ", "user": "me"}`, `line 1: context 1: required field "hostname" is empty`},
+		{"string type", "[\n  {\"name\": \"a\", \"hostname\": \"github.com\", \"user\": 42}\n]", `line 2: context 1: field "user" must be a string, got a number`},
+		{"bool type", `[{"name": "a", "hostname": "github.com", "user": "me", "sshManaged": "yes"}]`, `line 1: context 1: field "sshManaged" must be a boolean, got a string`},
+		{"object type", `[{"name": "a", "hostname": "github.com", "user": "me", "gitConfig": ["x"]}]`, `line 1: context 1: field "gitConfig" must be an object of string values, got an array`},
+		{"bad transport", `{"name": "a", "hostname": "github.com", "user": "me", "transport": "ftp"}`, `line 1: context 1: field "transport" must be 'ssh' or 'https'`},
+		{"duplicate", "[\n  {\"name\": \"a\", \"hostname\": \"github.com\", \"user\": \"me\"},\n  {\"name\": \"a\", \"hostname\": \"ghes.corp\", \"user\": \"me\"}\n]", "line 3: context 'a' already defined on line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ParseImport([]byte(tt.data))
+			if err == nil {
+				t.Fatalf("ParseImport succeeded, want %q", tt.want)
+			}
+			if !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to start with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImportUnknownFields(t *testing.T) {
+	data := []byte(`[
+  {"name": "work", "hostname": "github.com", "user": "me"},
+  {
+    "name": "corp", "hostname": "ghes.corp", "user": "me",
+    "zeta": 1, "alpha": {"x": true}
+  }
+]`)
+	contexts, warnings, err := ParseImport(data)
+	if err != nil {
+		t.Fatalf("ParseImport: %v", err)
+	}
+	if len(contexts) != 2 {
+		t.Errorf("imported %d contexts, want 2", len(contexts))
+	}
+	want := []string{
+		`line 3: context 'corp': ignoring unknown field "alpha"`,
+		`line 3: context 'corp': ignoring unknown field "zeta"`,
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings = %q, want %q", warnings, want)
+	}
+}
+
+func TestLineAt(t *testing.T) {
+	data := []byte("a\nb\n\nc")
+	for off, want := range map[int64]int{0: 1, 1: 1, 2: 2, 4: 3, 5: 4, 6: 4, 100: 4} {
+		if got := lineAt(data, off); got != want {
+			t.Errorf("lineAt(%d) = %d, want %d", off, got, want)
+		}
+	}
+	if got := skipSpace([]byte(" ,\n\t{"), 0); got != 4 {
+		t.Errorf("skipSpace = %d, want 4", got)
+	}
+}
@@ -0,0 +1,195 @@
+// ABOUTME: Migration of contexts saved by the original bash script to the current format
+// ABOUTME: Finds flat, shell-style context files and rewrites them as .ctx files after backing up the directory
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Migration is one context file in a legacy format.
+type Migration struct {
+	Name    string   // Context name
+	Path    string   // File holding it
+	Reasons []string // What makes it legacy
+	Skip    string   // Why it can't be migrated (empty = it can)
+	Context *Context // The context as it will be saved
+}
+
+// FindMigrations returns the context files still in a legacy format: flat
+// files named after the context without the .ctx extension (as the bash
+// script wrote them), and files using shell syntax (export, quoted values),
+// the old SSH_HOST_ALIAS field, or no TRANSPORT. Files already in the current
+// format aren't listed, so once migrated a directory yields nothing.
+func FindMigrations() ([]Migration, error) {
+	dir, err := ContextDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		file := entry.Name()
+		name := strings.TrimSuffix(file, ".ctx")
+		flat := name == file
+		if flat && (strings.Contains(file, ".") || file == "active" || file == "config" || ValidateName(file) != nil) {
+			continue
+		}
+
+		path := filepath.Join(dir, file)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		reasons := legacyReasons(string(data))
+		if flat {
+			if !strings.Contains(string(data), "USER=") {
+				continue // Not a context file
+			}
+			reasons = append([]string{"no .ctx extension"}, reasons...)
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+
+		ctx, err := parseContext(name, strings.NewReader(string(data)), legacyLine)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if ctx.Transport == "" {
+			ctx.Transport = "https"
+			if ctx.SSHKey != "" {
+				ctx.Transport = "ssh"
+			}
+		}
+
+		m := Migration{Name: name, Path: path, Reasons: reasons, Context: ctx}
+		if flat {
+			if exists, err := Exists(name); err != nil {
+				return nil, err
+			} else if exists {
+				m.Skip = fmt.Sprintf("%s.ctx already exists", name)
+			}
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations, nil
+}
+
+// Migrate copies the contexts directory to a timestamped backup beside it,
+// then saves each migration's context in the current format, removing the
+// flat file it came from. Migrations with Skip set are left alone. Returns
+// the backup directory.
+func Migrate(migrations []Migration) (string, error) {
+	dir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	backup := fmt.Sprintf("%s-backup-%s", dir, time.Now().Format("20060102-150405"))
+	if err := copyDir(dir, backup); err != nil {
+		return "", fmt.Errorf("backing up %s: %w", dir, err)
+	}
+
+	for _, m := range migrations {
+		if m.Skip != "" {
+			continue
+		}
+		if err := m.Context.Save(); err != nil {
+			return backup, fmt.Errorf("saving context '%s': %w", m.Name, err)
+		}
+		if filepath.Ext(m.Path) != ".ctx" {
+			if err := os.Remove(m.Path); err != nil {
+				return backup, err
+			}
+		}
+	}
+	return backup, nil
+}
+
+// legacyReasons lists the legacy constructs in a context file's content.
+func legacyReasons(content string) []string {
+	var reasons []string
+	add := func(r string) {
+		for _, existing := range reasons {
+			if existing == r {
+				return
+			}
+		}
+		reasons = append(reasons, r)
+	}
+
+	transport := false
+	for _, raw := range strings.Split(content, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "export ") {
+			add("export prefix")
+		}
+		key, value, _ := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if value = strings.TrimSpace(value); value != "" && (value[0] == '"' || value[0] == '\'') {
+			add("quoted values")
+		}
+		switch strings.TrimSpace(key) {
+		case "SSH_HOST_ALIAS":
+			add("SSH_HOST_ALIAS field")
+		case "TRANSPORT":
+			transport = true
+		}
+	}
+	if !transport {
+		add("no TRANSPORT")
+	}
+	return reasons
+}
+
+// legacyLine turns a shell assignment (export KEY="value") into a KEY=VALUE line.
+func legacyLine(line string) string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return line
+	}
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return strings.TrimSpace(key) + "=" + value
+}
+
+// copyDir copies the regular files directly in src to a new directory dst.
+func copyDir(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.Mkdir(dst, 0700); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dst, entry.Name()), data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "contexts")
+	SetDir(dir)
+	t.Cleanup(func() { SetDir("") })
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	current := "HOSTNAME=github.com\nUSER=me\nTRANSPORT=https\nSSH_KEY=\nSSH_MANAGED=false\n"
+	legacy := map[string]string{
+		// As the bash script wrote them
+		"personal": "export HOSTNAME=\"github.com\"\nexport USER=\"me\"\nexport SSH_KEY=\"~/.ssh/id_personal\"\nexport SSH_MANAGED=\"true\"\n",
+		"work.ctx": "HOSTNAME=github.com\nUSER=work\nSSH_HOST_ALIAS=github-work\nSSH_KEY='~/.ssh/id_work'\nTRANSPORT=ssh\nSSH_MANAGED=true\n",
+		"dup":      "export HOSTNAME=\"github.com\"\nexport USER=\"old\"\n",
+		"dup.ctx":  current,
+		"now.ctx":  current,
+		"active":   "personal\n",
+		"notes":    "not a context\n",
+	}
+	for name, content := range legacy {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrations, err := FindMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := map[string]string{}
+	var names []string
+	for _, m := range migrations {
+		names = append(names, m.Name)
+		if m.Skip != "" {
+			skipped[m.Name] = m.Skip
+		}
+	}
+	if want := []string{"dup", "personal", "work"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("migrations = %v, want %v", names, want)
+	}
+	if want := map[string]string{"dup": "dup.ctx already exists"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped = %v, want %v", skipped, want)
+	}
+
+	backup, err := Migrate(migrations)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	for name, content := range legacy {
+		if got, err := os.ReadFile(filepath.Join(backup, name)); err != nil || string(got) != content {
+			t.Errorf("backup of %s = %q (%v), want the original", name, got, err)
+		}
+	}
+
+	// Migrated files are exactly what Save writes today
+	for name, want := range map[string]*Context{
+		"personal": {Name: "personal", Hostname: "github.com", User: "me", Transport: "ssh", SSHKey: "~/.ssh/id_personal", SSHManaged: true},
+		"work":     {Name: "work", Hostname: "github.com", User: "work", Transport: "ssh", SSHKey: "~/.ssh/id_work", SSHManaged: true},
+	} {
+		got, err := Load(name)
+		if err != nil {
+			t.Fatalf("Load(%s): %v", name, err)
+		}
+		if got.Hostname != want.Hostname || got.User != want.User || got.Transport != want.Transport ||
+			got.SSHKey != want.SSHKey || got.SSHHost != want.SSHHost || got.SSHManaged != want.SSHManaged {
+			t.Errorf("%s = %+v, want %+v", name, *got, *want)
+		}
+		path, _ := ContextFile(name)
+		migrated, _ := os.ReadFile(path)
+		if err := got.Save(); err != nil {
+			t.Fatal(err)
+		}
+		if resaved, _ := os.ReadFile(path); string(resaved) != string(migrated) {
+			t.Errorf("%s isn't in the current format:\n%s\nSave writes:\n%s", name, migrated, resaved)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "personal")); !os.IsNotExist(err) {
+		t.Errorf("flat file personal left behind (%v)", err)
+	}
+	for _, name := range []string{"now.ctx", "dup", "dup.ctx", "active", "notes"} {
+		if got, _ := os.ReadFile(filepath.Join(dir, name)); string(got) != legacy[name] {
+			t.Errorf("%s changed to %q", name, got)
+		}
+	}
+
+	// Re-running finds nothing left to do but the conflict
+	again, err := FindMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 1 || again[0].Name != "dup" || again[0].Skip == "" {
+		t.Errorf("after migrating, FindMigrations = %+v, want only the skipped dup", again)
+	}
+}
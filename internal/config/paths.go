@@ -1,5 +1,5 @@
 // ABOUTME: Cross-platform path resolution for gh-context configuration
-// ABOUTME: Handles XDG on Unix and APPDATA on Windows via go-gh
+// ABOUTME: Handles XDG on Unix and APPDATA on Windows via go-gh, or a --config-dir/GH_CONTEXT_DIR override
 
 package config
 
@@ -8,16 +8,36 @@ import (
 	"path/filepath"
 
 	ghConfig "github.com/cli/go-gh/v2/pkg/config"
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
 )
 
-// ContextDir returns the directory where contexts are stored.
-// Uses go-gh's config directory resolution which handles:
+// DirEnv names the environment variable that relocates all gh-context state.
+const DirEnv = "GH_CONTEXT_DIR"
+
+// dirOverride is the state directory set with SetDir (the --config-dir flag).
+var dirOverride string
+
+// SetDir relocates everything gh-context stores (contexts, profiles, the
+// active pointer and the settings file) to dir, taking precedence over
+// GH_CONTEXT_DIR. An empty dir restores the default.
+func SetDir(dir string) {
+	dirOverride = dir
+}
+
+// ContextDir returns the directory where contexts are stored: the SetDir
+// directory, else GH_CONTEXT_DIR, else "contexts" under gh's config
+// directory, whose resolution (by go-gh) handles:
 // - GH_CONFIG_DIR environment variable
 // - XDG_CONFIG_HOME on Unix (~/.config/gh)
 // - APPDATA on Windows
 func ContextDir() (string, error) {
-	configDir := ghConfig.ConfigDir()
-	contextDir := filepath.Join(configDir, "contexts")
+	contextDir := dirOverride
+	if contextDir == "" {
+		contextDir = fsutil.ExpandPath(os.Getenv(DirEnv))
+	}
+	if contextDir == "" {
+		contextDir = filepath.Join(ghConfig.ConfigDir(), "contexts")
+	}
 
 	// Ensure the directory exists
 	if err := os.MkdirAll(contextDir, 0755); err != nil {
@@ -36,6 +56,15 @@ func ContextFile(name string) (string, error) {
 	return filepath.Join(dir, name+".ctx"), nil
 }
 
+// ProfileFile returns the full path to a profile file by name.
+func ProfileFile(name string) (string, error) {
+	dir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".profile"), nil
+}
+
 // ActiveFile returns the path to the active context pointer file.
 func ActiveFile() (string, error) {
 	dir, err := ContextDir()
@@ -0,0 +1,171 @@
+// ABOUTME: Profile definition and serialization for gh-context
+// ABOUTME: A profile is a named set of host→context pairs applied together
+
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ProfileEntry pairs a GitHub host with the context to use on it.
+type ProfileEntry struct {
+	Hostname string
+	Context  string
+}
+
+// Profile represents a saved set of contexts, at most one per host.
+type Profile struct {
+	Name    string         // Profile name (derived from filename, not stored in file)
+	Entries []ProfileEntry // Host→context pairs in file order
+}
+
+// LoadProfile reads a profile from a .profile file (HOST=CONTEXT lines).
+func LoadProfile(name string) (*Profile, error) {
+	path, err := ProfileFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &NotFoundError{Kind: "profile", Name: name}
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	p := &Profile{Name: name}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		p.Entries = append(p.Entries, ProfileEntry{
+			Hostname: strings.TrimSpace(parts[0]),
+			Context:  strings.TrimSpace(parts[1]),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Save writes a profile to a .profile file.
+func (p *Profile) Save() error {
+	path, err := ProfileFile(p.Name)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for _, e := range p.Entries {
+		fmt.Fprintf(file, "%s=%s\n", e.Hostname, e.Context)
+	}
+
+	return nil
+}
+
+// NewProfile builds a profile from context names, keyed by each context's host.
+// Returns an error if a context doesn't exist or two contexts share a host.
+func NewProfile(name string, contextNames []string) (*Profile, error) {
+	p := &Profile{Name: name}
+	seen := make(map[string]string)
+
+	for _, ctxName := range contextNames {
+		ctx, err := Load(ctxName)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[ctx.Hostname]; ok {
+			return nil, fmt.Errorf("contexts '%s' and '%s' both target %s", other, ctxName, ctx.Hostname)
+		}
+		seen[ctx.Hostname] = ctxName
+		p.Entries = append(p.Entries, ProfileEntry{Hostname: ctx.Hostname, Context: ctxName})
+	}
+
+	return p, nil
+}
+
+// ListProfiles returns all saved profile names, sorted.
+func ListProfiles() ([]string, error) {
+	dir, err := ContextDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	var profiles []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if strings.HasSuffix(name, ".profile") {
+			profiles = append(profiles, strings.TrimSuffix(name, ".profile"))
+		}
+	}
+
+	sort.Strings(profiles)
+	return profiles, nil
+}
+
+// ProfileExists checks if a profile with the given name exists.
+func ProfileExists(name string) (bool, error) {
+	path, err := ProfileFile(name)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// DeleteProfile removes a profile file.
+func DeleteProfile(name string) error {
+	path, err := ProfileFile(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return &NotFoundError{Kind: "profile", Name: name}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestProfileRoundTrip(t *testing.T) {
+	SetDir(t.TempDir())
+	t.Cleanup(func() { SetDir("") })
+	for _, ctx := range []*Context{
+		{Name: "personal", Hostname: "github.com", User: "me"},
+		{Name: "corp", Hostname: "ghes.corp", User: "me-corp"},
+		{Name: "other", Hostname: "github.com", User: "other"},
+	} {
+		if err := ctx.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := NewProfile("clash", []string{"personal", "other"}); err == nil {
+		t.Error("NewProfile accepted two contexts on github.com")
+	}
+
+	p, err := NewProfile("daytime", []string{"personal", "corp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadProfile("daytime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ProfileEntry{{Hostname: "github.com", Context: "personal"}, {Hostname: "ghes.corp", Context: "corp"}}
+	if !reflect.DeepEqual(loaded.Entries, want) {
+		t.Errorf("entries = %+v, want %+v", loaded.Entries, want)
+	}
+	if names, _ := ListProfiles(); !reflect.DeepEqual(names, []string{"daytime"}) {
+		t.Errorf("ListProfiles = %v, want [daytime]", names)
+	}
+}
+
+func TestDeleteProfile(t *testing.T) {
+	SetDir(t.TempDir())
+	t.Cleanup(func() { SetDir("") })
+	if err := (&Context{Name: "personal", Hostname: "github.com", User: "me"}).Save(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProfile("daytime", []string{"personal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteProfile("daytime"); err != nil {
+		t.Fatalf("DeleteProfile: %v", err)
+	}
+	if exists, _ := ProfileExists("daytime"); exists {
+		t.Error("profile still exists")
+	}
+	if exists, _ := Exists("personal"); !exists {
+		t.Error("deleting the profile removed its context")
+	}
+	var notFound *NotFoundError
+	if err := DeleteProfile("daytime"); !errors.As(err, &notFound) || notFound.Kind != "profile" {
+		t.Errorf("deleting it again = %v, want a profile NotFoundError", err)
+	}
+}
@@ -0,0 +1,147 @@
+// ABOUTME: Global settings file for gh-context defaults
+// ABOUTME: Reads KEY=VALUE preferences (timeout, backups, verbosity) that flags and env override
+
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Settings keys recognized in the settings file.
+const (
+	SettingTimeout   = "TIMEOUT"    // Network operation limit, e.g. 5s (0 disables)
+	SettingBackupDir = "BACKUP_DIR" // Directory for ~/.ssh/config backups
+	SettingNoBackup  = "NO_BACKUP"  // Skip ~/.ssh/config backups (true/false)
+	SettingVerbose   = "VERBOSE"    // Log the steps taken to stderr (true/false)
+
+	SettingBlockUser           = "NEW_BLOCK_USER"            // Write "User git" in Host blocks use --force creates (default true)
+	SettingBlockIdentitiesOnly = "NEW_BLOCK_IDENTITIES_ONLY" // Write "IdentitiesOnly yes" in those blocks (default true)
+
+	SettingApplyAllow = "APPLY_ALLOW"     // Comma-separated directory globs apply is limited to, e.g. ~/work/**
+	SettingApplyDeny  = "APPLY_DENY"      // Comma-separated directory globs apply never runs in
+	SettingWorkspaces = "WORKSPACE_ROOTS" // Comma-separated directories whose .ghcontext nested repos inherit
+	SettingApplyQuiet = "APPLY_QUIET"     // apply switches silently unless the context sets ANNOUNCE (true/false)
+
+	SettingSSHBanner = "SSH_BANNER" // Regexp for the ssh -T greeting, for GHES hosts that customize it
+)
+
+// knownSettings lists the keys LoadSettings accepts.
+var knownSettings = map[string]bool{
+	SettingTimeout:   true,
+	SettingBackupDir: true,
+	SettingNoBackup:  true,
+	SettingVerbose:   true,
+
+	SettingBlockUser:           true,
+	SettingBlockIdentitiesOnly: true,
+
+	SettingApplyAllow: true,
+	SettingApplyDeny:  true,
+	SettingWorkspaces: true,
+	SettingApplyQuiet: true,
+
+	SettingSSHBanner: true,
+}
+
+// Settings holds the values read from the settings file.
+type Settings struct {
+	Path   string
+	values map[string]string
+}
+
+// SettingsFile returns the path to the global settings file.
+func SettingsFile() (string, error) {
+	dir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config"), nil
+}
+
+// LoadSettings reads the settings file. A missing file yields empty settings.
+func LoadSettings() (*Settings, error) {
+	path, err := SettingsFile()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Settings{Path: path, values: make(map[string]string)}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !knownSettings[key] {
+			return nil, fmt.Errorf("%s:%d: unknown setting: %s", path, lineNum, line)
+		}
+		s.values[key] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// String returns a setting's value and whether it was set.
+func (s *Settings) String(key string) (string, bool) {
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// List returns a comma-separated setting's non-empty items.
+func (s *Settings) List(key string) []string {
+	var items []string
+	for _, item := range strings.Split(s.values[key], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Bool returns a boolean setting (1/true/yes or 0/false/no).
+func (s *Settings) Bool(key string) (value, ok bool, err error) {
+	raw, ok := s.values[key]
+	if !ok {
+		return false, false, nil
+	}
+	switch strings.ToLower(raw) {
+	case "1", "true", "yes":
+		return true, true, nil
+	case "0", "false", "no", "":
+		return false, true, nil
+	}
+	return false, false, fmt.Errorf("%s: %s must be true or false, got: %s", s.Path, key, raw)
+}
+
+// Duration returns a duration setting such as 5s or 1m.
+func (s *Settings) Duration(key string) (time.Duration, bool, error) {
+	raw, ok := s.values[key]
+	if !ok {
+		return 0, false, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %s must be a duration like 5s, got: %s", s.Path, key, raw)
+	}
+	return d, true, nil
+}
@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
 )
 
 // List returns all saved context names.
@@ -58,7 +60,8 @@ func ListContexts() ([]*Context, error) {
 }
 
 // GetActive returns the name of the currently active context.
-// Returns empty string if no context is active.
+// Returns empty string if no context is active. Only the first line is read,
+// so a reader racing a writer never gets more than one name.
 func GetActive() (string, error) {
 	path, err := ActiveFile()
 	if err != nil {
@@ -73,7 +76,8 @@ func GetActive() (string, error) {
 		return "", err
 	}
 
-	return strings.TrimSpace(string(data)), nil
+	line, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimSpace(line), nil
 }
 
 // SetActive sets the active context pointer.
@@ -89,7 +93,8 @@ func SetActive(name string) error {
 		return err
 	}
 
-	return os.WriteFile(path, []byte(name+"\n"), 0644)
+	// Shell hooks read this on every prompt, so never expose a half-written name
+	return fsutil.WriteFileAtomic(path, []byte(name+"\n"), 0644)
 }
 
 // ClearActive removes the active context pointer.
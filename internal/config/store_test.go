@@ -0,0 +1,60 @@
+package config
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestActivePointerConcurrent(t *testing.T) {
+	SetDir(t.TempDir())
+	t.Cleanup(func() { SetDir("") })
+
+	names := []string{"personal", "work-enterprise-account", "x"}
+	valid := map[string]bool{"": true}
+	for _, n := range names {
+		valid[n] = true
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				// Windows refuses a rename over a file another goroutine has
+				// open; a refused write is fine here, a torn read is not
+				if err := SetActive(names[(w+i)%len(names)]); err != nil && runtime.GOOS != "windows" {
+					t.Errorf("SetActive: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				got, err := GetActive()
+				if err != nil && runtime.GOOS != "windows" {
+					t.Errorf("GetActive: %v", err)
+					return
+				}
+				if !valid[got] || strings.ContainsAny(got, "\n") {
+					t.Errorf("GetActive = %q, want one of %v", got, names)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := SetActive("work-enterprise-account"); err != nil {
+		t.Fatalf("SetActive: %v", err)
+	}
+	if got, err := GetActive(); err != nil || got != "work-enterprise-account" {
+		t.Errorf("GetActive = %q, %v; want work-enterprise-account", got, err)
+	}
+}
@@ -0,0 +1,76 @@
+// ABOUTME: Near-miss name matching for gh-context error messages
+// ABOUTME: Suggests saved names within a small edit distance of a mistyped one
+
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// Suggest returns the candidates close enough to name to be a likely typo,
+// closest first. Transposed letters count as one edit ("wrok" → "work").
+func Suggest(name string, candidates []string) []string {
+	maxDist := len(name) / 3
+	if maxDist < 1 {
+		maxDist = 1
+	}
+	if maxDist > 3 {
+		maxDist = 3
+	}
+
+	type match struct {
+		name string
+		dist int
+	}
+	var matches []match
+	for _, c := range candidates {
+		if c == name {
+			continue
+		}
+		if d := editDistance(strings.ToLower(name), strings.ToLower(c)); d <= maxDist {
+			matches = append(matches, match{c, d})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	suggestions := make([]string, 0, len(matches))
+	for _, m := range matches {
+		suggestions = append(suggestions, m.name)
+	}
+	return suggestions
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// insertions, deletions, substitutions, and adjacent transpositions.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := 0; j <= len(rb); j++ {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	names := []string{"work", "personal", "corp", "oss-work"}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"wrok", []string{"work"}},
+		{"Work", []string{"work"}},
+		{"persnal", []string{"personal"}},
+		{"crop", []string{"corp"}},
+		{"kubernetes", nil},
+		{"work", nil},
+	}
+	for _, tt := range tests {
+		got := Suggest(tt.name, names)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Suggest(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// ABOUTME: Last-used timestamps for saved contexts
+// ABOUTME: Kept as name=RFC3339 lines in the contexts directory's last-used file, updated on each switch
+
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+)
+
+// UsageFile returns the path to the file recording when each context was
+// last switched to.
+func UsageFile() (string, error) {
+	dir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "last-used"), nil
+}
+
+// LastUsed returns when each context was last switched to. Contexts never
+// switched to since recording began are absent. A missing file yields none.
+func LastUsed() (map[string]time.Time, error) {
+	path, err := UsageFile()
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]time.Time)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return used, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, stamp, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, stamp); err == nil {
+			used[name] = t
+		}
+	}
+	return used, scanner.Err()
+}
+
+// RecordUse notes that the context name was switched to now.
+func RecordUse(name string) error {
+	used, err := LastUsed()
+	if err != nil {
+		return err
+	}
+	used[name] = time.Now().UTC().Truncate(time.Second)
+	return saveUsage(used)
+}
+
+// renameUse carries a context's last-used time over to its new name.
+func renameUse(oldName, newName string) error {
+	used, err := LastUsed()
+	if err != nil {
+		return err
+	}
+	t, ok := used[oldName]
+	if !ok {
+		return nil
+	}
+	delete(used, oldName)
+	used[newName] = t
+	return saveUsage(used)
+}
+
+// saveUsage rewrites the last-used file, sorted by name.
+func saveUsage(used map[string]time.Time) error {
+	names := make([]string, 0, len(used))
+	for n := range used {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, n := range names {
+		fmt.Fprintf(&b, "%s=%s\n", n, used[n].Format(time.RFC3339))
+	}
+
+	path, err := UsageFile()
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, []byte(b.String()), 0644)
+}
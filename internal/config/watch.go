@@ -0,0 +1,175 @@
+// ABOUTME: Change notifications for the active pointer and saved context files
+// ABOUTME: Watches the contexts directory with fsnotify so embedders can react without polling
+
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// EventKind says what happened to a watched file.
+type EventKind int
+
+const (
+	EventCreate EventKind = iota
+	EventModify
+	EventDelete
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventCreate:
+		return "create"
+	case EventModify:
+		return "modify"
+	case EventDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change to the active pointer or a saved context.
+type Event struct {
+	Kind    EventKind
+	Path    string // File that changed
+	Active  bool   // The active pointer changed (see GetActive)
+	Context string // Context whose .ctx file changed; empty when Active
+}
+
+// replaceSettle is how long a removed file is given to reappear before the
+// removal is reported. Editors and fsutil.WriteFileAtomic replace a file by renaming a
+// new one over it, or by moving it aside and writing it again; either way that
+// shows up as a remove followed by a create, reported together as one modify.
+const replaceSettle = 100 * time.Millisecond
+
+// Watch reports changes to the active pointer and the saved .ctx files until
+// ctx is done, then closes the returned channel. A file replaced in place
+// (renamed over, or moved aside and rewritten) is reported once, as a modify.
+// Errors from the watcher after it has started, such as a dropped-events
+// overflow, are not reported; callers needing certainty should re-read.
+func Watch(ctx context.Context) (<-chan Event, error) {
+	dir, err := ContextDir()
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// Watch the directory, not the files: a rename over a file would end a
+	// watch on the file itself
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			known[entry.Name()] = true
+		}
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer watcher.Close()
+
+		type removal struct {
+			name string
+			gen  int
+		}
+		pending := make(map[string]int) // Name → generation of its pending removal
+		gen := 0
+		settled := make(chan removal)
+		done := make(chan struct{})
+		defer close(done)
+
+		send := func(kind EventKind, name string) bool {
+			ev := Event{Kind: kind, Path: filepath.Join(dir, name)}
+			if name == "active" {
+				ev.Active = true
+			} else {
+				ev.Context = strings.TrimSuffix(name, ".ctx")
+			}
+			select {
+			case events <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case r := <-settled:
+				if pending[r.name] != r.gen {
+					continue // It came back, or was removed again since
+				}
+				delete(pending, r.name)
+				delete(known, r.name)
+				if !send(EventDelete, r.name) {
+					return
+				}
+
+			case fe, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Base(fe.Name)
+				if name != "active" && !strings.HasSuffix(name, ".ctx") {
+					continue // Temp files, profiles, backups
+				}
+
+				switch {
+				case fe.Has(fsnotify.Create):
+					_, replaced := pending[name]
+					delete(pending, name)
+					kind := EventCreate
+					if replaced || known[name] {
+						kind = EventModify
+					}
+					known[name] = true
+					if !send(kind, name) {
+						return
+					}
+				case fe.Has(fsnotify.Write):
+					kind := EventModify
+					if !known[name] {
+						kind = EventCreate
+					}
+					known[name] = true
+					if !send(kind, name) {
+						return
+					}
+				case fe.Has(fsnotify.Remove), fe.Has(fsnotify.Rename):
+					gen++
+					pending[name] = gen
+					r := removal{name, gen}
+					time.AfterFunc(replaceSettle, func() {
+						select {
+						case settled <- r:
+						case <-done:
+						}
+					})
+				}
+
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// nextEvent waits for the watcher's next event.
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event within 5s")
+		return Event{}
+	}
+}
+
+// expectNoEvent fails if the watcher reports anything for a few settle
+// periods, other than modifies of allowed (writes after a create).
+func expectNoEvent(t *testing.T, events <-chan Event, allowed string) {
+	t.Helper()
+	timeout := time.After(3 * replaceSettle)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Kind != EventModify || ev.Path != allowed {
+				t.Fatalf("unexpected event %s %s", ev.Kind, ev.Path)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	SetDir(t.TempDir())
+	t.Cleanup(func() { SetDir("") })
+	if err := SetActive("work"); err != nil {
+		t.Fatal(err)
+	}
+	active, err := ActiveFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func() error
+		want   Event
+		writes bool // The file is written after it's created, reported as modifies
+	}{
+		{
+			name:   "atomic rename over active",
+			change: func() error { return SetActive("personal") },
+			want:   Event{Kind: EventModify, Path: active, Active: true},
+		},
+		{
+			name: "active moved aside and rewritten",
+			change: func() error {
+				if err := os.Rename(active, active+".old"); err != nil {
+					return err
+				}
+				return os.WriteFile(active, []byte("personal\n"), 0644)
+			},
+			want:   Event{Kind: EventModify, Path: active, Active: true},
+			writes: true,
+		},
+		{
+			name:   "context saved",
+			change: func() error { return (&Context{Name: "work", Hostname: "github.com", User: "me"}).Save() },
+			want:   Event{Kind: EventCreate, Context: "work"},
+			writes: true,
+		},
+		{
+			name:   "context deleted",
+			change: func() error { return Delete("work") },
+			want:   Event{Kind: EventDelete, Context: "work"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); err != nil {
+				t.Fatal(err)
+			}
+			got := nextEvent(t, events)
+			if tt.want.Path == "" {
+				tt.want.Path, _ = ContextFile(tt.want.Context)
+			}
+			if got != tt.want {
+				t.Errorf("event = %+v, want %+v", got, tt.want)
+			}
+			allowed := ""
+			if tt.writes {
+				allowed = got.Path
+			}
+			expectNoEvent(t, events, allowed)
+		})
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("events channel still open after cancel")
+	}
+}
@@ -0,0 +1,49 @@
+// ABOUTME: File helpers shared by gh-context's config and SSH packages
+// ABOUTME: Atomic writes so readers never see a partially written file, and ~ expansion
+
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandPath expands ~ in a path to the home directory.
+func ExpandPath(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			return filepath.Join(home, p[2:])
+		}
+	}
+	return p
+}
+
+// WriteFileAtomic writes data to a temp file in the same directory, syncs it
+// and renames it over path, so readers see either the old content or the new,
+// never a mix. The temp file is named .gh-context-* and removed on failure.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gh-context-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
@@ -0,0 +1,58 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "active")
+
+	for _, content := range []string{"work\n", "personal\n"} {
+		if err := WriteFileAtomic(path, []byte(content), 0600); err != nil {
+			t.Fatalf("WriteFileAtomic: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("content = %q, want %q", data, content)
+		}
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 && runtime.GOOS != "windows" {
+		t.Errorf("mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the written one (temp files left behind)", len(entries))
+	}
+
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "active"), []byte("x"), 0600); err == nil {
+		t.Error("WriteFileAtomic succeeded in a directory that doesn't exist")
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	for in, want := range map[string]string{
+		"~/.ssh/id_work": filepath.Join(home, ".ssh", "id_work"),
+		"~":              "~",
+		"~other/id":      "~other/id",
+		"/abs/~/id":      "/abs/~/id",
+		"rel/id":         "rel/id",
+	} {
+		if got := ExpandPath(in); got != want {
+			t.Errorf("ExpandPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
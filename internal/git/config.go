@@ -0,0 +1,200 @@
+// ABOUTME: Repository-local git config management for gh-context
+// ABOUTME: Writes a context's git config entries and unsets ones it set before
+
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/logging"
+)
+
+// ManagedKeysFile lists (relative to the git dir) the local git config keys
+// gh-context has set and the values it set them to, one KEY=VALUE per line,
+// so switching contexts can unset them again.
+const ManagedKeysFile = "info/ghcontext-managed"
+
+// SetConfig sets a key in the current repository's local git config,
+// replacing any existing values.
+func SetConfig(key, value string) error {
+	return SetConfigIn("", key, value)
+}
+
+// SetConfigIn is SetConfig for the repository containing dir ("" = working directory).
+func SetConfigIn(dir, key, value string) error {
+	if _, err := gitOutputIn(dir, "config", "--local", "--replace-all", key, value); err != nil {
+		return fmt.Errorf("git config %s: %w", key, err)
+	}
+	return nil
+}
+
+// UnsetConfig removes every value of a key from the local git config.
+// A key that isn't set is not an error.
+func UnsetConfig(key string) error {
+	return UnsetConfigIn("", key)
+}
+
+// UnsetConfigIn is UnsetConfig for the repository containing dir.
+func UnsetConfigIn(dir, key string) error {
+	_, err := gitOutputIn(dir, "config", "--local", "--unset-all", key)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 5 {
+		return nil // Key wasn't set
+	}
+	if err != nil {
+		return fmt.Errorf("git config --unset-all %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetConfig returns a key's value in the local git config, or empty string
+// if it isn't set.
+func GetConfig(key string) (string, error) {
+	return GetConfigIn("", key)
+}
+
+// GetConfigIn is GetConfig for the repository containing dir.
+func GetConfigIn(dir, key string) (string, error) {
+	output, err := gitOutputIn(dir, "config", "--local", "--get", key)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", nil // Key isn't set
+	}
+	if err != nil {
+		return "", fmt.Errorf("git config --get %s: %w", key, err)
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+// managedEntry is one key gh-context set, with the value it wrote.
+type managedEntry struct {
+	key   string
+	value string
+	known bool // Value was recorded (older files list bare keys)
+}
+
+// ManagedKeys returns the local git config keys gh-context last set here.
+func ManagedKeys() ([]string, error) {
+	entries, err := managedEntries("")
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(entries))
+	for _, e := range entries {
+		keys = append(keys, e.key)
+	}
+	return keys, nil
+}
+
+// managedEntries reads the managed-keys file of the repository containing dir.
+func managedEntries(dir string) ([]managedEntry, error) {
+	path, err := gitPathIn(dir, ManagedKeysFile)
+	if err != nil || path == "" {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []managedEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		key, value, known := strings.Cut(line, "=")
+		entries = append(entries, managedEntry{key: key, value: value, known: known})
+	}
+	return entries, nil
+}
+
+// setManagedKeys records the entries gh-context has set, removing the file if none.
+func setManagedKeys(dir string, keys []string, entries map[string]string) error {
+	path, err := gitPathIn(dir, ManagedKeysFile)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("not inside a Git repository")
+	}
+
+	if len(keys) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", key, entries[key])
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// ApplyConfig makes the repository's local git config match entries. Keys a
+// previous context set that entries doesn't define are unset first, so one
+// context's settings (e.g. user.email) never bleed into the next; a key whose
+// value was changed by hand since gh-context set it is left alone. Then every
+// entry is written. Returns the keys that were unset.
+func ApplyConfig(entries map[string]string) ([]string, error) {
+	return ApplyConfigIn("", entries)
+}
+
+// ApplyConfigIn is ApplyConfig for the repository containing dir ("" = working directory).
+func ApplyConfigIn(dir string, entries map[string]string) ([]string, error) {
+	previous, err := managedEntries(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var unset []string
+	for _, prev := range previous {
+		if _, ok := entries[prev.key]; ok {
+			continue
+		}
+		if prev.known {
+			current, err := GetConfigIn(dir, prev.key)
+			if err != nil {
+				return unset, err
+			}
+			if current != prev.value {
+				logging.Info("keeping git config changed since gh-context set it", "key", prev.key)
+				continue
+			}
+		}
+		if err := UnsetConfigIn(dir, prev.key); err != nil {
+			return unset, err
+		}
+		unset = append(unset, prev.key)
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := SetConfigIn(dir, key, entries[key]); err != nil {
+			return unset, err
+		}
+	}
+
+	return unset, setManagedKeys(dir, keys, entries)
+}
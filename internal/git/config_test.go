@@ -0,0 +1,54 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestApplyConfigUnsetsPreviousContext(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("HOME", t.TempDir())
+	repo := t.TempDir()
+	gitIn(t, repo, "init", "-q")
+
+	// Context A
+	if _, err := ApplyConfigIn(repo, map[string]string{"user.email": "a@example.com", "user.name": "A"}); err != nil {
+		t.Fatalf("apply A: %v", err)
+	}
+	if got, _ := GetConfigIn(repo, "user.email"); got != "a@example.com" {
+		t.Fatalf("user.email = %q after A", got)
+	}
+
+	// Context B defines a name but no email
+	unset, err := ApplyConfigIn(repo, map[string]string{"user.name": "B"})
+	if err != nil {
+		t.Fatalf("apply B: %v", err)
+	}
+	if len(unset) != 1 || unset[0] != "user.email" {
+		t.Errorf("unset = %v, want [user.email]", unset)
+	}
+	if got, _ := GetConfigIn(repo, "user.email"); got != "" {
+		t.Errorf("A's user.email = %q still set after switching to B", got)
+	}
+	if got, _ := GetConfigIn(repo, "user.name"); got != "B" {
+		t.Errorf("user.name = %q, want B", got)
+	}
+
+	// A value changed by hand since gh-context set it is kept
+	if err := SetConfigIn(repo, "user.name", "Me"); err != nil {
+		t.Fatal(err)
+	}
+	if unset, err := ApplyConfigIn(repo, nil); err != nil || len(unset) != 0 {
+		t.Errorf("apply none = %v, %v; want nothing unset", unset, err)
+	}
+	if got, _ := GetConfigIn(repo, "user.name"); got != "Me" {
+		t.Errorf("hand-set user.name = %q, want Me kept", got)
+	}
+	path, _ := gitPathIn(repo, ManagedKeysFile)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("managed-keys file left with nothing managed: %v", err)
+	}
+}
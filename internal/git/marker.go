@@ -0,0 +1,106 @@
+// ABOUTME: .ghcontext marker parsing for gh-context
+// ABOUTME: The first line names the context; optional KEY=VALUE lines after it override its git config
+
+package git
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Binding is the content of a .ghcontext marker:
+//
+//	work
+//	user.email=me@example.com
+//
+// The first line is the context name. Any further lines are git config
+// entries layered over the context's own when it is applied in this repo;
+// blank lines and # comments are ignored. Only the identity keys in
+// OverrideKeys may be overridden: a marker arrives with a clone and is applied
+// on cd, so keys like core.sshCommand or core.hooksPath would let a repository
+// run commands.
+type Binding struct {
+	Context   string
+	Overrides map[string]string
+}
+
+// OverrideKeys lists the git config keys a marker may override, lowercased
+// (git config keys are case-insensitive).
+var OverrideKeys = map[string]bool{
+	"user.name":       true,
+	"user.email":      true,
+	"user.signingkey": true,
+	"commit.gpgsign":  true,
+}
+
+// ParseBinding parses a marker's content. A key outside OverrideKeys is an error.
+func ParseBinding(content string) (*Binding, error) {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	b := &Binding{Context: strings.TrimSpace(lines[0])}
+
+	for i, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !strings.Contains(key, ".") || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: expected a git config KEY=VALUE such as user.email=me@example.com, got: %s", i+2, line)
+		}
+		if !OverrideKeys[strings.ToLower(key)] {
+			return nil, fmt.Errorf("line %d: %s can't be overridden by a marker (allowed: user.name, user.email, user.signingkey, commit.gpgsign)", i+2, key)
+		}
+		if b.Overrides == nil {
+			b.Overrides = make(map[string]string)
+		}
+		b.Overrides[key] = strings.TrimSpace(value)
+	}
+	return b, nil
+}
+
+// ReadBinding reads and parses the marker at path.
+func ReadBinding(path string) (*Binding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ParseBinding(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
+
+// ReadBindingIn reads the marker that applies to dir ("" = working
+// directory; see FindMarker). Returns nil if there is none.
+func ReadBindingIn(dir string) (*Binding, error) {
+	path, err := FindMarkerIn(dir)
+	if err != nil || path == "" {
+		return nil, err
+	}
+	b, err := ReadBinding(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// WriteMarker sets the context named by the marker at path, creating it if
+// needed. Override lines already in the marker are kept.
+func WriteMarker(path, contextName string) error {
+	content := contextName + "\n"
+	perm := os.FileMode(0644)
+	if data, err := os.ReadFile(path); err == nil {
+		if _, body, ok := strings.Cut(string(data), "\n"); ok {
+			content += body
+		}
+		if info, err := os.Stat(path); err == nil {
+			perm = info.Mode().Perm()
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), perm)
+}
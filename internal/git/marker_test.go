@@ -0,0 +1,53 @@
+package git
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBindingAllowsIdentityOverrides(t *testing.T) {
+	b, err := ParseBinding("work\n# comment\nuser.email=me@example.org\nUser.Name = Me\nuser.signingkey=ABC\ncommit.gpgsign=true\n")
+	if err != nil {
+		t.Fatalf("ParseBinding: %v", err)
+	}
+	if b.Context != "work" {
+		t.Errorf("Context = %q, want work", b.Context)
+	}
+	want := map[string]string{
+		"user.email":      "me@example.org",
+		"User.Name":       "Me",
+		"user.signingkey": "ABC",
+		"commit.gpgsign":  "true",
+	}
+	if len(b.Overrides) != len(want) {
+		t.Fatalf("Overrides = %v, want %v", b.Overrides, want)
+	}
+	for k, v := range want {
+		if b.Overrides[k] != v {
+			t.Errorf("Overrides[%s] = %q, want %q", k, b.Overrides[k], v)
+		}
+	}
+}
+
+func TestParseBindingRejectsOtherKeys(t *testing.T) {
+	for _, key := range []string{"core.sshCommand", "core.fsmonitor", "core.hooksPath", "credential.helper", "CORE.SSHCOMMAND", "url.x.insteadOf"} {
+		_, err := ParseBinding("work\n" + key + "=evil\n")
+		if err == nil {
+			t.Errorf("ParseBinding accepted %s", key)
+			continue
+		}
+		if !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("error for %s doesn't name the line: %v", key, err)
+		}
+	}
+}
+
+func TestParseBindingNameOnly(t *testing.T) {
+	b, err := ParseBinding("work\r\n")
+	if err != nil {
+		t.Fatalf("ParseBinding: %v", err)
+	}
+	if b.Context != "work" || b.Overrides != nil {
+		t.Errorf("got %+v, want just the name", b)
+	}
+}
@@ -9,15 +9,26 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/logging"
 )
 
-const ghContextFile = ".ghcontext"
+// MarkerFile is the name of the binding file in a repository's work tree.
+const MarkerFile = ".ghcontext"
+
+// LocalMarkerFile is the git-internal, never-committed marker location
+// (relative to the git dir), written by "bind --local".
+const LocalMarkerFile = "info/ghcontext"
 
 // RepoRoot returns the root directory of the current git repository.
 // Returns empty string if not in a git repository.
 func RepoRoot() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	output, err := cmd.Output()
+	return RepoRootIn("")
+}
+
+// RepoRootIn is RepoRoot for the repository containing dir ("" = working directory).
+func RepoRootIn(dir string) (string, error) {
+	output, err := gitOutputIn(dir, "rev-parse", "--show-toplevel")
 	if err != nil {
 		// Not in a git repository
 		return "", nil
@@ -25,10 +36,55 @@ func RepoRoot() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-// GetBinding reads the context name from .ghcontext in the repo root.
-// Returns empty string if no binding exists.
-func GetBinding() (string, error) {
-	root, err := RepoRoot()
+// MainWorktreeRoot returns the root of the main working tree when the current
+// directory is inside a linked worktree. Returns empty string otherwise.
+func MainWorktreeRoot() (string, error) {
+	return MainWorktreeRootIn("")
+}
+
+// MainWorktreeRootIn is MainWorktreeRoot for dir ("" = working directory).
+func MainWorktreeRootIn(dir string) (string, error) {
+	output, err := gitOutputIn(dir, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", nil
+	}
+
+	commonDir := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(commonDir) {
+		base, err := absDir(dir)
+		if err != nil {
+			return "", err
+		}
+		commonDir = filepath.Join(base, commonDir)
+	}
+
+	// Bare repositories have no main working tree
+	if filepath.Base(commonDir) != ".git" {
+		return "", nil
+	}
+
+	mainRoot := filepath.Dir(commonDir)
+	root, err := RepoRootIn(dir)
+	if err != nil {
+		return "", err
+	}
+	if sameDir(mainRoot, root) {
+		return "", nil
+	}
+	return mainRoot, nil
+}
+
+// FindMarker returns the path of the .ghcontext file that applies here.
+// The worktree's own marker is preferred; in a linked worktree the main
+// working tree's marker is used as a fallback, then .git/info/ghcontext.
+// Returns empty string if none.
+func FindMarker() (string, error) {
+	return FindMarkerIn("")
+}
+
+// FindMarkerIn is FindMarker for dir ("" = working directory).
+func FindMarkerIn(dir string) (string, error) {
+	root, err := RepoRootIn(dir)
 	if err != nil {
 		return "", err
 	}
@@ -36,19 +92,140 @@ func GetBinding() (string, error) {
 		return "", nil
 	}
 
-	bindingPath := filepath.Join(root, ghContextFile)
-	data, err := os.ReadFile(bindingPath)
+	candidates := []string{root}
+	mainRoot, err := MainWorktreeRootIn(dir)
+	if err != nil {
+		return "", err
+	}
+	if mainRoot != "" {
+		candidates = append(candidates, mainRoot)
+	}
+
+	var paths []string
+	for _, dir := range candidates {
+		paths = append(paths, filepath.Join(dir, MarkerFile))
+	}
+
+	// Work-tree markers win over the local-only one in .git/info
+	localPath, err := LocalBindingPathIn(dir)
+	if err != nil {
+		return "", err
+	}
+	if localPath != "" {
+		paths = append(paths, localPath)
+	}
+
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// LocalBindingPath returns the path of the local-only marker (.git/info/ghcontext).
+// Returns empty string if not in a git repository.
+func LocalBindingPath() (string, error) {
+	return LocalBindingPathIn("")
+}
+
+// LocalBindingPathIn is LocalBindingPath for the repository containing dir.
+func LocalBindingPathIn(dir string) (string, error) {
+	return gitPathIn(dir, LocalMarkerFile)
+}
+
+// gitPathIn resolves a path inside the git dir of the repository containing
+// dir (git rev-parse --git-path) to an absolute path. Returns empty string if
+// not in a git repository.
+func gitPathIn(dir, rel string) (string, error) {
+	output, err := gitOutputIn(dir, "rev-parse", "--git-path", rel)
+	if err != nil {
+		return "", nil
+	}
+
+	path := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(path) {
+		base, err := absDir(dir)
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(base, path)
+	}
+	return path, nil
+}
+
+// SetLocalBinding writes a context name to .git/info/ghcontext, which is never
+// part of the work tree and so can't be committed. Existing overrides are kept.
+func SetLocalBinding(contextName string) error {
+	path, err := LocalBindingPath()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("not inside a Git repository")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return WriteMarker(path, contextName)
+}
+
+// RemoveLocalBinding deletes .git/info/ghcontext.
+func RemoveLocalBinding() error {
+	path, err := LocalBindingPath()
 	if err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("not inside a Git repository")
+	}
+
+	if err := os.Remove(path); err != nil {
 		if os.IsNotExist(err) {
-			return "", nil
+			return nil // Already gone, not an error
 		}
-		return "", err
+		return err
+	}
+	return nil
+}
+
+// HasLocalBinding checks if the current repo has a .git/info/ghcontext file.
+func HasLocalBinding() (bool, error) {
+	path, err := LocalBindingPath()
+	if err != nil || path == "" {
+		return false, err
 	}
 
-	return strings.TrimSpace(string(data)), nil
+	_, err = os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// GetBinding reads the context name from the applicable .ghcontext (see FindMarker).
+// Returns empty string if no binding exists.
+func GetBinding() (string, error) {
+	return GetBindingIn("")
+}
+
+// GetBindingIn is GetBinding for dir ("" = working directory).
+func GetBindingIn(dir string) (string, error) {
+	b, err := ReadBindingIn(dir)
+	if err != nil || b == nil {
+		return "", err
+	}
+	return b.Context, nil
 }
 
-// SetBinding writes a context name to .ghcontext in the repo root.
+// SetBinding writes a context name to .ghcontext in the repo root, keeping
+// any overrides already in it.
 func SetBinding(contextName string) error {
 	root, err := RepoRoot()
 	if err != nil {
@@ -58,8 +235,7 @@ func SetBinding(contextName string) error {
 		return fmt.Errorf("not inside a Git repository")
 	}
 
-	bindingPath := filepath.Join(root, ghContextFile)
-	return os.WriteFile(bindingPath, []byte(contextName+"\n"), 0644)
+	return WriteMarker(filepath.Join(root, MarkerFile), contextName)
 }
 
 // RemoveBinding deletes the .ghcontext file from the repo root.
@@ -72,7 +248,7 @@ func RemoveBinding() error {
 		return fmt.Errorf("not inside a Git repository")
 	}
 
-	bindingPath := filepath.Join(root, ghContextFile)
+	bindingPath := filepath.Join(root, MarkerFile)
 	if err := os.Remove(bindingPath); err != nil {
 		if os.IsNotExist(err) {
 			return nil // Already gone, not an error
@@ -92,7 +268,7 @@ func HasBinding() (bool, error) {
 		return false, nil
 	}
 
-	bindingPath := filepath.Join(root, ghContextFile)
+	bindingPath := filepath.Join(root, MarkerFile)
 	_, err = os.Stat(bindingPath)
 	if err == nil {
 		return true, nil
@@ -113,5 +289,111 @@ func BindingPath() (string, error) {
 	if root == "" {
 		return "", nil
 	}
-	return filepath.Join(root, ghContextFile), nil
+	return filepath.Join(root, MarkerFile), nil
+}
+
+// RemoteHost returns the host of a remote's URL (e.g. "ghes.corp" for
+// git@ghes.corp:org/repo.git). Returns empty string if the remote doesn't exist.
+func RemoteHost(remote string) (string, error) {
+	return RemoteHostIn("", remote)
+}
+
+// RemoteHostIn is RemoteHost for the repository containing dir.
+func RemoteHostIn(dir, remote string) (string, error) {
+	output, err := gitOutputIn(dir, "remote", "get-url", remote)
+	if err != nil {
+		return "", nil
+	}
+	return ParseRemoteHost(strings.TrimSpace(string(output))), nil
+}
+
+// ParseRemoteHost extracts the host from a git remote URL in URL form
+// (https://host/..., ssh://user@host:port/...) or scp-like form (user@host:path).
+// Returns empty string for local paths.
+func ParseRemoteHost(remoteURL string) string {
+	if scheme, rest, ok := strings.Cut(remoteURL, "://"); ok {
+		if scheme == "file" {
+			return ""
+		}
+		hostPart, _, _ := strings.Cut(rest, "/")
+		if i := strings.LastIndex(hostPart, "@"); i >= 0 {
+			hostPart = hostPart[i+1:]
+		}
+		if h, _, ok := strings.Cut(hostPart, ":"); ok {
+			hostPart = h
+		}
+		return strings.ToLower(hostPart)
+	}
+
+	// scp-like syntax needs a colon before any slash
+	colon := strings.Index(remoteURL, ":")
+	if colon < 0 || strings.Contains(remoteURL[:colon], "/") {
+		return ""
+	}
+	hostPart := remoteURL[:colon]
+	if i := strings.LastIndex(hostPart, "@"); i >= 0 {
+		hostPart = hostPart[i+1:]
+	}
+	return strings.ToLower(hostPart)
+}
+
+// FindRepos walks root and returns the top-level directory of every git
+// repository beneath it (including root itself). Repositories nested inside
+// another repository, and hidden directories other than .git, are not searched.
+func FindRepos(root string) ([]string, error) {
+	var repos []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			logging.Debug("skipping unreadable directory", "path", path, "err", err)
+			return filepath.SkipDir
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+			repos = append(repos, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return repos, err
+}
+
+// sameDir reports whether two paths refer to the same directory.
+func sameDir(a, b string) bool {
+	if ra, err := filepath.EvalSymlinks(a); err == nil {
+		a = ra
+	}
+	if rb, err := filepath.EvalSymlinks(b); err == nil {
+		b = rb
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// gitOutput runs a git subcommand and returns its stdout, logging the call.
+func gitOutput(args ...string) ([]byte, error) {
+	return gitOutputIn("", args...)
+}
+
+// gitOutputIn runs a git subcommand in dir ("" = working directory).
+func gitOutputIn(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	logging.Debug("exec git", "dir", dir, "args", strings.Join(args, " "), "err", err)
+	return output, err
+}
+
+// absDir returns dir as an absolute path, or the working directory if dir is empty.
+func absDir(dir string) (string, error) {
+	if dir == "" {
+		return os.Getwd()
+	}
+	return filepath.Abs(dir)
 }
@@ -0,0 +1,62 @@
+// ABOUTME: Leveled stderr logging for gh-context diagnostics
+// ABOUTME: Silent by default; enabled with the global -v/--verbose or --debug flags
+
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+// levelOff is above every level we log at, so nothing is emitted by default.
+const levelOff = slog.Level(100)
+
+var level = new(slog.LevelVar)
+
+var logger = newLogger(os.Stderr)
+
+// newLogger returns a logger writing text records to w at the shared level.
+func newLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// Timestamps are noise for a short-lived CLI
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func init() {
+	level.Set(levelOff)
+}
+
+// Configure enables logging: verbose shows the steps taken, debug adds detail.
+func Configure(verbose, debug bool) {
+	switch {
+	case debug:
+		level.Set(slog.LevelDebug)
+	case verbose:
+		level.Set(slog.LevelInfo)
+	default:
+		level.Set(levelOff)
+	}
+}
+
+// SetOutput sends log records to w instead of stderr.
+func SetOutput(w io.Writer) {
+	logger = newLogger(w)
+}
+
+// Info logs a step (shown with --verbose or --debug).
+func Info(msg string, args ...any) {
+	logger.Info(msg, args...)
+}
+
+// Debug logs detail (shown with --debug only).
+func Debug(msg string, args ...any) {
+	logger.Debug(msg, args...)
+}
@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLevels(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	t.Cleanup(func() {
+		SetOutput(os.Stderr)
+		Configure(false, false)
+	})
+
+	tests := []struct {
+		name           string
+		verbose, debug bool
+		wantInfo       bool
+		wantDebug      bool
+	}{
+		{"default", false, false, false, false},
+		{"verbose", true, false, true, false},
+		{"debug", false, true, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			Configure(tt.verbose, tt.debug)
+			Info("exec gh", "args", "auth status")
+			Debug("gh failed", "stderr", "boom")
+			out := buf.String()
+			if got := strings.Contains(out, `msg="exec gh" args="auth status"`); got != tt.wantInfo {
+				t.Errorf("info logged %v, want %v:\n%s", got, tt.wantInfo, out)
+			}
+			if got := strings.Contains(out, `msg="gh failed"`); got != tt.wantDebug {
+				t.Errorf("debug logged %v, want %v:\n%s", got, tt.wantDebug, out)
+			}
+			if strings.Contains(out, "time=") {
+				t.Errorf("records carry timestamps:\n%s", out)
+			}
+		})
+	}
+}
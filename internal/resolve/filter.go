@@ -0,0 +1,64 @@
+// ABOUTME: Directory allow/deny globs for gh-context auto-apply
+// ABOUTME: Decides whether a repository path may have its context applied automatically
+
+package resolve
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+)
+
+// Allowed reports whether auto-apply may run in dir. With allow globs set,
+// dir must match one of them; a matching deny glob always wins. Globs use
+// path.Match syntax per segment, "**" matches any number of directories, and
+// a leading ~/ is the home directory, so "~/work/**" covers ~/work and
+// everything under it.
+func Allowed(dir string, allow, deny []string) bool {
+	for _, pattern := range deny {
+		if MatchDir(pattern, dir) {
+			return false
+		}
+	}
+	if len(allow) == 0 {
+		return true
+	}
+	for _, pattern := range allow {
+		if MatchDir(pattern, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchDir reports whether the directory dir matches pattern.
+func MatchDir(pattern, dir string) bool {
+	pattern = filepath.ToSlash(filepath.Clean(fsutil.ExpandPath(pattern)))
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(dir, "/"))
+}
+
+// matchSegments matches path segments, letting "**" stand for zero or more.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
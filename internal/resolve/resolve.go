@@ -0,0 +1,267 @@
+// ABOUTME: Context resolution for gh-context - decides which context applies to a directory
+// ABOUTME: Shared by apply, which and current so marker, remote and inference rules agree
+
+package resolve
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+	"github.com/peterjmorgan/gh-context/internal/git"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+)
+
+// Reason says why a context was chosen, in precedence order.
+type Reason string
+
+const (
+	ReasonEnv         Reason = "env"              // Options.Env, from GH_CONTEXT
+	ReasonMarker      Reason = "marker"           // Committed .ghcontext in the work tree (or main worktree)
+	ReasonLocalMarker Reason = "local-marker"     // Local-only .git/info/ghcontext
+	ReasonWorkspace   Reason = "workspace-marker" // .ghcontext above the repo, under an Options.Workspaces root
+	ReasonRemoteAlias Reason = "remote-alias"     // Origin remote uses a context's SSH Host alias
+	ReasonHost        Reason = "host-inference"   // Origin remote's host matches one context's hostname
+	ReasonDefault     Reason = "default"          // Options.Default, when nothing else matched
+	ReasonStale       Reason = "stale-marker"     // Marker names a context that no longer exists
+	ReasonExcluded    Reason = "excluded"         // Options.Allow/Deny rule the directory out
+	ReasonNone        Reason = ""                 // No context applies
+)
+
+// EnvOverride names the environment variable that picks the context for
+// every directory, e.g. in CI.
+const EnvOverride = "GH_CONTEXT"
+
+// Options adjusts how a directory is resolved.
+type Options struct {
+	Env string // Context that wins over every rule below, from EnvOverride (empty = none)
+
+	Workspaces []string // Directories whose .ghcontext (or a nearer one below them) repos without a marker inherit
+	Infer      bool     // Without a marker, pick the context matching the origin remote
+	Default    string   // Context to fall back to (empty = none)
+
+	Allow []string // Directory globs auto-apply is limited to (empty = everywhere)
+	Deny  []string // Directory globs auto-apply never runs in
+}
+
+// AmbiguousError reports that the origin remote matches several contexts equally.
+type AmbiguousError struct {
+	Host    string
+	Matches []string
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("ambiguous context for host %s: %s", e.Host, strings.Join(e.Matches, ", "))
+}
+
+// Resolve returns the context that applies to dir ("" = working directory) and
+// why. A marker always wins; then a workspace marker (see WorkspaceMarker);
+// then, with opts.Infer, an SSH Host alias match on
+// the origin remote beats a plain hostname match; then opts.Default.
+// A marker naming a deleted context still wins, as ReasonStale with the name
+// it holds, so a dangling binding is reported rather than silently replaced.
+// Before any of that, a repository opts.Allow/Deny rule out resolves to
+// nothing, as ReasonExcluded. opts.Env comes first of all: it must name a
+// saved context and applies whatever the directory.
+func Resolve(dir string, opts Options) (string, Reason, error) {
+	if opts.Env != "" {
+		if exists, err := config.Exists(opts.Env); err != nil {
+			return "", ReasonNone, err
+		} else if !exists {
+			return "", ReasonNone, fmt.Errorf("%s=%s: %w", EnvOverride, opts.Env, &config.NotFoundError{Kind: "context", Name: opts.Env})
+		}
+		return opts.Env, ReasonEnv, nil
+	}
+
+	if len(opts.Allow) > 0 || len(opts.Deny) > 0 {
+		target, err := filterDir(dir)
+		if err != nil {
+			return "", ReasonNone, err
+		}
+		if !Allowed(target, opts.Allow, opts.Deny) {
+			return "", ReasonExcluded, nil
+		}
+	}
+
+	marker, err := git.FindMarkerIn(dir)
+	if err != nil {
+		return "", ReasonNone, err
+	}
+	if marker != "" {
+		name, err := git.GetBindingIn(dir)
+		if err != nil {
+			return "", ReasonNone, err
+		}
+		if name != "" {
+			if exists, err := config.Exists(name); err != nil {
+				return "", ReasonNone, err
+			} else if !exists {
+				return name, ReasonStale, nil
+			}
+			if local, _ := git.LocalBindingPathIn(dir); local != "" && filepath.Clean(local) == filepath.Clean(marker) {
+				return name, ReasonLocalMarker, nil
+			}
+			return name, ReasonMarker, nil
+		}
+	}
+
+	if len(opts.Workspaces) > 0 {
+		path, err := WorkspaceMarker(dir, opts.Workspaces)
+		if err != nil {
+			return "", ReasonNone, err
+		}
+		if path != "" {
+			b, err := git.ReadBinding(path)
+			if err != nil {
+				return "", ReasonNone, err
+			}
+			if b.Context != "" {
+				if exists, err := config.Exists(b.Context); err != nil {
+					return "", ReasonNone, err
+				} else if !exists {
+					return b.Context, ReasonStale, nil
+				}
+				return b.Context, ReasonWorkspace, nil
+			}
+		}
+	}
+
+	if opts.Infer {
+		name, reason, err := infer(dir)
+		if err != nil || name != "" {
+			return name, reason, err
+		}
+	}
+
+	if opts.Default != "" {
+		return opts.Default, ReasonDefault, nil
+	}
+	return "", ReasonNone, nil
+}
+
+// WorkspaceMarker returns the .ghcontext a repository without its own
+// inherits: the nearest one in the directories above the repository
+// containing dir, searching no higher than the workspace root (one of roots)
+// the repository lies under. Returns empty string if dir isn't in a
+// repository, the repository isn't under any root, or there is no marker.
+func WorkspaceMarker(dir string, roots []string) (string, error) {
+	repo, err := git.RepoRootIn(dir)
+	if err != nil || repo == "" {
+		return "", err
+	}
+
+	for parent := filepath.Dir(repo); underAny(parent, roots); parent = filepath.Dir(parent) {
+		path := filepath.Join(parent, git.MarkerFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		} else if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		if parent == filepath.Dir(parent) {
+			break
+		}
+	}
+	return "", nil
+}
+
+// underAny reports whether path is one of roots or inside one. Roots may
+// start with ~ and are compared after resolving symlinks, as git reports
+// repository roots.
+func underAny(path string, roots []string) bool {
+	for _, root := range roots {
+		root, err := filepath.Abs(fsutil.ExpandPath(root))
+		if err != nil {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(root); err == nil {
+			root = real
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterDir returns the path allow/deny globs are matched against: the
+// repository root containing dir, or dir itself outside a repository.
+func filterDir(dir string) (string, error) {
+	if root, err := git.RepoRootIn(dir); err == nil && root != "" {
+		return root, nil
+	}
+	if dir == "" {
+		dir = "."
+	}
+	return filepath.Abs(dir)
+}
+
+// infer picks the saved context whose host matches dir's origin remote.
+// Returns empty name if nothing matches, and an *AmbiguousError if several
+// contexts match equally.
+func infer(dir string) (string, Reason, error) {
+	host, err := git.RemoteHostIn(dir, "origin")
+	if err != nil || host == "" {
+		return "", ReasonNone, err
+	}
+
+	contexts, err := config.ListContexts()
+	if err != nil {
+		return "", ReasonNone, err
+	}
+
+	canonical := host
+	if sshCfg, err := ssh.ParseConfig(""); err == nil {
+		canonical = strings.ToLower(sshCfg.CanonicalHost(host))
+	}
+
+	var byAlias, byHost []string
+	for _, ctx := range contexts {
+		switch {
+		case ctx.SSHHost != "" && strings.EqualFold(ctx.SSHHost, host):
+			byAlias = append(byAlias, ctx.Name)
+		case strings.EqualFold(ctx.Hostname, canonical):
+			byHost = append(byHost, ctx.Name)
+		}
+	}
+
+	matches, reason := byAlias, ReasonRemoteAlias
+	if len(matches) == 0 {
+		matches, reason = byHost, ReasonHost
+	}
+	switch len(matches) {
+	case 0:
+		return "", ReasonNone, nil
+	case 1:
+		return matches[0], reason, nil
+	default:
+		return "", ReasonNone, &AmbiguousError{Host: host, Matches: matches}
+	}
+}
+
+// Describe returns a short human-readable explanation of a reason.
+func (r Reason) Describe() string {
+	switch r {
+	case ReasonEnv:
+		return EnvOverride
+	case ReasonMarker:
+		return ".ghcontext"
+	case ReasonLocalMarker:
+		return ".git/info/ghcontext"
+	case ReasonWorkspace:
+		return "workspace .ghcontext"
+	case ReasonRemoteAlias:
+		return "origin remote's SSH Host alias"
+	case ReasonHost:
+		return "inferred from origin remote"
+	case ReasonDefault:
+		return "default"
+	case ReasonStale:
+		return "bound context no longer exists"
+	case ReasonExcluded:
+		return "excluded by APPLY_ALLOW/APPLY_DENY"
+	}
+	return "none"
+}
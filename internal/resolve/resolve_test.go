@@ -0,0 +1,203 @@
+package resolve
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+// setup gives the test its own config dir and HOME (for ~/.ssh/config)
+// holding the named contexts as hostname[/sshHost] entries.
+func setup(t *testing.T, contexts map[string][2]string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	config.SetDir(filepath.Join(home, "contexts"))
+	t.Cleanup(func() { config.SetDir("") })
+
+	for name, hosts := range contexts {
+		ctx := &config.Context{Name: name, Hostname: hosts[0], SSHHost: hosts[1], User: name, Transport: "ssh", SSHManaged: true}
+		if err := ctx.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newRepo creates a git repository at dir, with an origin remote if given.
+func newRepo(t *testing.T, dir, origin string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q")
+	if origin != "" {
+		run("remote", "add", "origin", origin)
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	setup(t, map[string][2]string{
+		"env":      {"github.com", ""},
+		"personal": {"github.com", ""},
+		"work":     {"github.com", "github-work"},
+		"corp":     {"ghes.corp", ""},
+		"space":    {"gitlab.example", ""},
+	})
+	root := t.TempDir()
+	workspace := filepath.Join(root, "ws")
+	writeFile(t, filepath.Join(workspace, ".ghcontext"), "space\n")
+
+	type layout struct {
+		origin string
+		marker string // .ghcontext content
+		local  string // .git/info/ghcontext content
+		inWS   bool   // Repository lies under the workspace root
+	}
+	tests := []struct {
+		name       string
+		repo       layout
+		opts       Options
+		wantName   string
+		wantReason Reason
+	}{
+		{"env beats marker", layout{origin: "git@ghes.corp:o/r.git", marker: "personal\n"}, Options{Env: "env", Infer: true}, "env", ReasonEnv},
+		{"marker beats local marker", layout{marker: "personal\n", local: "corp\n"}, Options{Infer: true}, "personal", ReasonMarker},
+		{"local marker beats workspace", layout{local: "corp\n", inWS: true}, Options{Infer: true, Workspaces: []string{workspace}}, "corp", ReasonLocalMarker},
+		{"marker beats inference", layout{origin: "git@ghes.corp:o/r.git", marker: "personal\n"}, Options{Infer: true}, "personal", ReasonMarker},
+		{"workspace beats inference", layout{origin: "git@ghes.corp:o/r.git", inWS: true}, Options{Infer: true, Workspaces: []string{workspace}}, "space", ReasonWorkspace},
+		{"workspace needs a root", layout{origin: "git@ghes.corp:o/r.git", inWS: true}, Options{Infer: true}, "corp", ReasonHost},
+		{"alias beats hostname", layout{origin: "git@github-work:o/r.git"}, Options{Infer: true}, "work", ReasonRemoteAlias},
+		{"hostname inference", layout{origin: "https://ghes.corp/o/r.git"}, Options{Infer: true}, "corp", ReasonHost},
+		{"inference off", layout{origin: "git@ghes.corp:o/r.git"}, Options{Default: "personal"}, "personal", ReasonDefault},
+		{"default last", layout{}, Options{Infer: true, Default: "personal"}, "personal", ReasonDefault},
+		{"nothing", layout{}, Options{Infer: true}, "", ReasonNone},
+		{"stale marker still wins", layout{origin: "git@ghes.corp:o/r.git", marker: "gone\n"}, Options{Infer: true}, "gone", ReasonStale},
+		{"excluded", layout{marker: "personal\n", inWS: true}, Options{Deny: []string{"**/ws/*"}}, "", ReasonExcluded},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := root
+			if tt.repo.inWS {
+				parent = workspace
+			}
+			dir := newRepo(t, filepath.Join(parent, "repo"+string(rune('a'+i))), tt.repo.origin)
+			if tt.repo.marker != "" {
+				writeFile(t, filepath.Join(dir, ".ghcontext"), tt.repo.marker)
+			}
+			if tt.repo.local != "" {
+				writeFile(t, filepath.Join(dir, ".git", "info", "ghcontext"), tt.repo.local)
+			}
+
+			name, reason, err := Resolve(dir, tt.opts)
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if name != tt.wantName || reason != tt.wantReason {
+				t.Errorf("Resolve = %q (%s), want %q (%s)", name, reason, tt.wantName, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestResolveEnvMustExist(t *testing.T) {
+	setup(t, nil)
+	if _, _, err := Resolve(t.TempDir(), Options{Env: "missing"}); err == nil {
+		t.Error("Resolve accepted a GH_CONTEXT naming no saved context")
+	}
+}
+
+func TestResolveAmbiguous(t *testing.T) {
+	setup(t, map[string][2]string{"a": {"github.com", ""}, "b": {"github.com", ""}})
+	dir := newRepo(t, filepath.Join(t.TempDir(), "repo"), "git@github.com:o/r.git")
+	_, _, err := Resolve(dir, Options{Infer: true})
+	if _, ok := err.(*AmbiguousError); !ok {
+		t.Errorf("Resolve error = %v, want *AmbiguousError", err)
+	}
+}
+
+func TestInfer(t *testing.T) {
+	setup(t, map[string][2]string{
+		"personal": {"github.com", ""},
+		"work":     {"github.com", "github-work"},
+		"corp":     {"ghes.corp", ""},
+		"bot-a":    {"gitlab.example", "deploy"},
+		"bot-b":    {"gitlab.example", "deploy"},
+	})
+	// corp-mirror is another name for ghes.corp in the user's SSH config
+	writeFile(t, filepath.Join(os.Getenv("HOME"), ".ssh", "config"), "Host corp-mirror\n    HostName ghes.corp\n")
+	root := t.TempDir()
+
+	tests := []struct {
+		name       string
+		origin     string
+		wantName   string
+		wantReason Reason
+		ambiguous  []string // Matches of the expected *AmbiguousError
+	}{
+		{"https host", "https://ghes.corp/o/r.git", "corp", ReasonHost, nil},
+		{"host case-insensitive", "git@GHES.corp:o/r.git", "corp", ReasonHost, nil},
+		{"ssh URL with port", "ssh://git@ghes.corp:2222/o/r.git", "corp", ReasonHost, nil},
+		{"host via SSH config HostName", "git@corp-mirror:o/r.git", "corp", ReasonHost, nil},
+		{"alias", "git@github-work:o/r.git", "work", ReasonRemoteAlias, nil},
+		{"alias case-insensitive", "git@GitHub-Work:o/r.git", "work", ReasonRemoteAlias, nil},
+		{"unknown host", "git@bitbucket.org:o/r.git", "", ReasonNone, nil},
+		{"no origin", "", "", ReasonNone, nil},
+		{"ambiguous host", "git@github.com:o/r.git", "", ReasonNone, []string{"personal", "work"}},
+		{"ambiguous alias", "git@deploy:o/r.git", "", ReasonNone, []string{"bot-a", "bot-b"}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newRepo(t, filepath.Join(root, "repo"+string(rune('a'+i))), tt.origin)
+			name, reason, err := infer(dir)
+			if tt.ambiguous != nil {
+				var amb *AmbiguousError
+				if !errors.As(err, &amb) {
+					t.Fatalf("infer error = %v, want *AmbiguousError", err)
+				}
+				if !reflect.DeepEqual(amb.Matches, tt.ambiguous) {
+					t.Errorf("matches = %v, want %v", amb.Matches, tt.ambiguous)
+				}
+				for _, m := range tt.ambiguous {
+					if !strings.Contains(amb.Error(), m) {
+						t.Errorf("error %q doesn't name %s", amb.Error(), m)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("infer: %v", err)
+			}
+			if name != tt.wantName || reason != tt.wantReason {
+				t.Errorf("infer = %q (%s), want %q (%s)", name, reason, tt.wantName, tt.wantReason)
+			}
+		})
+	}
+}
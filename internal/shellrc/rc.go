@@ -0,0 +1,201 @@
+// ABOUTME: Shell rc file editing for gh-context hook installation
+// ABOUTME: Finds, replaces, and strips the marker-bounded gh-context block
+
+package shellrc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Markers bracket every generated hook so it can be found again later.
+const (
+	BeginMarker = "# >>> gh-context >>>"
+	EndMarker   = "# <<< gh-context <<<"
+)
+
+// Wrap brackets a hook with the begin and end markers.
+func Wrap(hook string) string {
+	if !strings.HasSuffix(hook, "\n") {
+		hook += "\n"
+	}
+	return BeginMarker + "\n" + hook + EndMarker + "\n"
+}
+
+// Strip removes every marker-bounded block from content, markers included.
+// Returns the remaining content and the number of blocks removed.
+func Strip(content string) (string, int, error) {
+	lines := strings.SplitAfter(content, "\n")
+	var kept []string
+	removed := 0
+	inBlock := false
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inBlock && trimmed == BeginMarker:
+			inBlock = true
+		case inBlock && trimmed == EndMarker:
+			inBlock = false
+			removed++
+		case inBlock && trimmed == BeginMarker:
+			return "", 0, fmt.Errorf("line %d: gh-context block starts inside another block", i+1)
+		case !inBlock:
+			kept = append(kept, line)
+		}
+	}
+
+	if inBlock {
+		return "", 0, fmt.Errorf("gh-context block is missing its end marker (%s)", EndMarker)
+	}
+	return strings.Join(kept, ""), removed, nil
+}
+
+// Replace puts block where the first existing gh-context block is, dropping
+// any further copies, or appends it if content has none.
+func Replace(content, block string) (string, error) {
+	idx := -1
+	lines := strings.SplitAfter(content, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == BeginMarker {
+			idx = i
+			break
+		}
+	}
+
+	if idx < 0 {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		return content + block, nil
+	}
+
+	before := strings.Join(lines[:idx], "")
+	rest, _, err := Strip(strings.Join(lines[idx:], ""))
+	if err != nil {
+		return "", err
+	}
+	return before + block + rest, nil
+}
+
+// InstallBlock writes block into the file at path, replacing any existing
+// gh-context block so installing repeatedly leaves exactly one copy.
+// Returns true if an existing block was replaced.
+func InstallBlock(path, block string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	_, existing, err := Strip(string(data))
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+
+	updated, err := Replace(string(data), block)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return false, err
+		}
+	}
+	if err := writeFile(path, updated); err != nil {
+		return false, err
+	}
+	return existing > 0, nil
+}
+
+// RemoveBlock strips the gh-context block(s) from the file at path.
+// A missing file has nothing to remove. Returns the number of blocks removed.
+func RemoveBlock(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	stripped, removed, err := Strip(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	if err := writeFile(path, stripped); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// DefaultFile returns the rc file a shell's hook is conventionally installed in.
+func DefaultFile(shell string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	switch shell {
+	case "bash":
+		return filepath.Join(home, ".bashrc"), nil
+	case "zsh":
+		if dir := os.Getenv("ZDOTDIR"); dir != "" {
+			return filepath.Join(dir, ".zshrc"), nil
+		}
+		return filepath.Join(home, ".zshrc"), nil
+	case "fish":
+		if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+			return filepath.Join(dir, "fish", "config.fish"), nil
+		}
+		return filepath.Join(home, ".config", "fish", "config.fish"), nil
+	case "direnv":
+		return ".envrc", nil
+	case "powershell", "pwsh":
+		return "", fmt.Errorf("the PowerShell profile location varies; pass --file $PROFILE")
+	default:
+		return "", fmt.Errorf("unsupported shell: %s", shell)
+	}
+}
+
+// DetectShell guesses the shell gh-context was run from, returning the shell
+// name and the variable it was read from. ZSH_VERSION and FISH_VERSION are
+// checked first since they only reach us when exported by the running shell,
+// then the login shell in SHELL, then PSModulePath, which PowerShell sets.
+// Returns "bash" and an empty source if nothing matches.
+func DetectShell() (shell, source string) {
+	if os.Getenv("ZSH_VERSION") != "" {
+		return "zsh", "$ZSH_VERSION"
+	}
+	if os.Getenv("FISH_VERSION") != "" {
+		return "fish", "$FISH_VERSION"
+	}
+	if sh := os.Getenv("SHELL"); sh != "" {
+		name := strings.TrimSuffix(filepath.Base(sh), ".exe")
+		switch name {
+		case "bash", "zsh", "fish", "pwsh":
+			return name, "$SHELL"
+		case "powershell":
+			return "powershell", "$SHELL"
+		}
+	}
+	if os.Getenv("PSModulePath") != "" {
+		return "pwsh", "$PSModulePath"
+	}
+	return "bash", ""
+}
+
+// writeFile replaces path's contents, keeping its permissions.
+func writeFile(path, content string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return os.WriteFile(path, []byte(content), mode)
+}
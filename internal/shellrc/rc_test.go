@@ -0,0 +1,186 @@
+package shellrc
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestStrip(t *testing.T) {
+	block := Wrap("eval hook")
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		removed int
+	}{
+		{"no block", "export A=1\n", "export A=1\n", 0},
+		{"only block", block, "", 1},
+		{"block between lines", "export A=1\n" + block + "alias g=git\n", "export A=1\nalias g=git\n", 1},
+		{"two blocks", block + "export A=1\n" + block, "export A=1\n", 2},
+		{"indented markers", "  " + BeginMarker + "\neval hook\n\t" + EndMarker + "\n", "", 1},
+		{"no trailing newline", "export A=1\n" + BeginMarker + "\neval hook\n" + EndMarker, "export A=1\n", 1},
+		{"no trailing newline after block", block + "alias g=git", "alias g=git", 1},
+		{"CRLF", "export A=1\r\n" + BeginMarker + "\r\neval hook\r\n" + EndMarker + "\r\n", "export A=1\r\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, removed, err := Strip(tt.content)
+			if err != nil {
+				t.Fatalf("Strip: %v", err)
+			}
+			if got != tt.want || removed != tt.removed {
+				t.Errorf("Strip = %q, %d; want %q, %d", got, removed, tt.want, tt.removed)
+			}
+		})
+	}
+}
+
+func TestStripMalformed(t *testing.T) {
+	for name, content := range map[string]string{
+		"missing end": "export A=1\n" + BeginMarker + "\neval hook\n",
+		"nested":      BeginMarker + "\n" + BeginMarker + "\n" + EndMarker + "\n",
+	} {
+		if _, _, err := Strip(content); err == nil {
+			t.Errorf("%s: Strip accepted %q", name, content)
+		}
+	}
+}
+
+func TestStripIdempotent(t *testing.T) {
+	content := "export A=1\n" + Wrap("eval hook") + "alias g=git"
+	once, _, err := Strip(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	twice, removed, err := Strip(once)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if twice != once || removed != 0 {
+		t.Errorf("second Strip = %q, %d; want %q, 0", twice, removed, once)
+	}
+}
+
+func TestRemoveBlockIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".bashrc")
+	if err := os.WriteFile(path, []byte("export A=1\n"+Wrap("eval hook")+"alias g=git"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []int{1, 0} {
+		removed, err := RemoveBlock(path)
+		if err != nil {
+			t.Fatalf("RemoveBlock #%d: %v", i+1, err)
+		}
+		if removed != want {
+			t.Errorf("RemoveBlock #%d removed %d blocks, want %d", i+1, removed, want)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "export A=1\nalias g=git" {
+		t.Errorf("file = %q, want the lines around the block", got)
+	}
+}
+
+func TestRemoveBlockMissingFile(t *testing.T) {
+	removed, err := RemoveBlock(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || removed != 0 {
+		t.Errorf("RemoveBlock = %d, %v; want 0, nil", removed, err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	old, block := Wrap("eval old"), Wrap("eval new")
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "", block},
+		{"appends", "export A=1\n", "export A=1\n" + block},
+		{"appends after missing newline", "export A=1", "export A=1\n" + block},
+		{"in place", "export A=1\n" + old + "alias g=git\n", "export A=1\n" + block + "alias g=git\n"},
+		{"drops copies", old + "export A=1\n" + old, block + "export A=1\n"},
+		{"end marker without newline", "export A=1\n" + BeginMarker + "\neval old\n" + EndMarker, "export A=1\n" + block},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Replace(tt.content, block)
+			if err != nil {
+				t.Fatalf("Replace: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Replace = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallBlockIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", "fish", "config.fish")
+	block := Wrap("eval hook")
+
+	for i, want := range []bool{false, true, true} {
+		replaced, err := InstallBlock(path, block)
+		if err != nil {
+			t.Fatalf("InstallBlock #%d: %v", i+1, err)
+		}
+		if replaced != want {
+			t.Errorf("InstallBlock #%d replaced = %v, want %v", i+1, replaced, want)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != block {
+		t.Errorf("file = %q, want one copy of the block", got)
+	}
+}
+
+func TestInstallThenUninstall(t *testing.T) {
+	// An rc file whose last line has no newline keeps that line intact
+	path := filepath.Join(t.TempDir(), ".zshrc")
+	if err := os.WriteFile(path, []byte("export A=1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := InstallBlock(path, Wrap("eval hook")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InstallBlock(path, Wrap("eval hook")); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := RemoveBlock(path); err != nil || removed != 1 {
+		t.Fatalf("RemoveBlock = %d, %v; want 1, nil", removed, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "export A=1\n" {
+		t.Errorf("file = %q, want %q", got, "export A=1\n")
+	}
+}
+
+func TestInstallBlockKeepsMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".bashrc")
+	if err := os.WriteFile(path, []byte("export A=1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InstallBlock(path, Wrap("eval hook")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+}
@@ -6,10 +6,16 @@ package ssh
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
+
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+	"github.com/peterjmorgan/gh-context/internal/logging"
 )
 
 // DefaultConfigPath returns the default SSH config path.
@@ -23,72 +29,201 @@ func DefaultConfigPath() string {
 
 // HostBlock represents a Host block in SSH config.
 type HostBlock struct {
-	StartLine    int      // Line number where "Host X" appears (0-indexed)
-	EndLine      int      // Line number of last line in block (exclusive)
-	Hostname     string   // The hostname pattern from "Host X"
-	Lines        []string // All lines in the block including Host line
+	StartLine     int      // Line number where "Host X" appears (0-indexed)
+	EndLine       int      // Line number of last line in block (exclusive)
+	Hostname      string   // The hostname pattern from "Host X"
+	Lines         []string // All lines in the block including Host line
 	IdentityFiles []IdentityFileLine
+	IdentityAgent string   // Active (uncommented) IdentityAgent socket path, if any
+	HostName      string   // Value of the block's HostName directive (empty if absent)
+	Unrecognized  []string // Lines the parser can't model (Match sections, continuations, etc.)
 }
 
 // IdentityFileLine represents an IdentityFile line (commented or not).
 type IdentityFileLine struct {
-	LineIndex  int    // Index within HostBlock.Lines
-	Path       string // The path to the key (without ~ expansion)
+	LineIndex   int    // Index within HostBlock.Lines
+	Path        string // The path to the key (without ~ expansion)
 	IsCommented bool
-	FullLine   string // Original line content
+	FullLine    string // Original line content
+}
+
+// BackupDirEnv names the environment variable that relocates config backups.
+const BackupDirEnv = "GH_CONTEXT_SSH_BACKUP_DIR"
+
+// NoBackupEnv names the environment variable that disables config backups
+// when set to a true value (1, true, yes).
+const NoBackupEnv = "GH_CONTEXT_NO_BACKUP"
+
+// noBackupFromEnv reports whether NoBackupEnv asks to skip backups.
+func noBackupFromEnv() bool {
+	switch strings.ToLower(os.Getenv(NoBackupEnv)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
 }
 
 // ConfigFile represents a parsed SSH config file.
+//
+// Methods on ConfigFile are safe for concurrent use: each edit holds a lock
+// across the mutate+reparse sequence. Callers that read Lines or Blocks
+// directly, or hold a *HostBlock from FindHostBlock across an edit, must
+// not share the ConfigFile with goroutines that edit it.
 type ConfigFile struct {
-	Path   string
-	Lines  []string
-	Blocks []HostBlock
+	Path      string
+	Lines     []string
+	Blocks    []HostBlock
+	BackupDir string        // Directory for backups (empty = next to Path as <Path>.bak)
+	NoBackup  bool          // Skip the backup step in Save (writes stay atomic)
+	Includes  []*ConfigFile // Files pulled in by Include directives, in directive order
+	NewBlock  BlockOptions  // Contents of Host blocks created by ForceActiveKey
+
+	includeAt []int // Line index of each Include directive in Lines
+	directive int   // Ordinal of the parent's Include directive that pulled this file in
+	dirty     bool  // Edited since parse (only dirty included files are rewritten)
+
+	mu sync.Mutex
 }
 
-// ParseConfig reads and parses an SSH config file.
+// BlockOptions trims the Host blocks gh-context creates. The zero value gives
+// the block GitHub expects: "User git", the key, and "IdentitiesOnly yes" so
+// ssh offers only that key.
+type BlockOptions struct {
+	OmitUser           bool // Leave out "User git"
+	OmitIdentitiesOnly bool // Leave out "IdentitiesOnly yes"
+}
+
+// ParseOptions adjusts how a parsed config and its included files are saved.
+type ParseOptions struct {
+	BackupDir string // Directory for backups, overriding BackupDirEnv (empty = keep)
+	NoBackup  bool   // Skip backups, whatever NoBackupEnv says
+}
+
+// ParseConfig reads and parses an SSH config file, following Include directives.
 func ParseConfig(path string) (*ConfigFile, error) {
+	return ParseConfigWith(path, ParseOptions{})
+}
+
+// ParseConfigWith is ParseConfig with opts applied to the config and every
+// file it includes.
+func ParseConfigWith(path string, opts ParseOptions) (*ConfigFile, error) {
 	if path == "" {
 		path = DefaultConfigPath()
 	}
 
+	cfg, err := parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if opts.BackupDir != "" {
+		cfg.BackupDir = opts.BackupDir
+	}
+	if opts.NoBackup {
+		cfg.NoBackup = true
+	}
+	// Included files take their backup settings from cfg
+	if err := cfg.resolveIncludes(filepath.Dir(path), 0); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ParseConfigString parses SSH config content held in memory. Include
+// directives are recorded but not followed, and Save fails because there is
+// no file to write; String returns the content after any edits.
+func ParseConfigString(content string) *ConfigFile {
+	lines, _ := readLines(strings.NewReader(content), len(content)) // Can't fail with a buffer this size
+	return newConfigFile("", lines)
+}
+
+// ParseConfigReader is ParseConfigString for content read from r.
+func ParseConfigReader(r io.Reader) (*ConfigFile, error) {
+	lines, err := readLines(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	return newConfigFile("", lines), nil
+}
+
+// parseFile reads and parses a single SSH config file without following includes.
+func parseFile(path string) (*ConfigFile, error) {
+	logging.Info("parsing SSH config", "path", path)
+
 	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return &ConfigFile{Path: path, Lines: []string{}, Blocks: []HostBlock{}}, nil
+			return newConfigFile(path, []string{}), nil
 		}
 		return nil, err
 	}
 	defer file.Close()
 
+	lines, err := readLines(file, 0)
+	if err != nil {
+		return nil, err
+	}
+	return newConfigFile(path, lines), nil
+}
+
+// readLines splits r into lines the way ssh reads them. maxLine raises the
+// scanner's line length limit (0 = default).
+func readLines(r io.Reader, maxLine int) ([]string, error) {
 	var lines []string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
+	if maxLine > 0 {
+		scanner.Buffer(nil, maxLine+1)
+	}
 	for scanner.Scan() {
 		lines = append(lines, scanner.Text())
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
+	return lines, nil
+}
 
+// newConfigFile parses lines into a ConfigFile for path ("" = in memory).
+func newConfigFile(path string, lines []string) *ConfigFile {
 	cfg := &ConfigFile{
-		Path:  path,
-		Lines: lines,
+		Path:      path,
+		Lines:     lines,
+		BackupDir: os.Getenv(BackupDirEnv),
+		NoBackup:  noBackupFromEnv(),
 	}
 	cfg.parseBlocks()
-	return cfg, nil
+	return cfg
 }
 
 // hostPattern matches "Host <pattern>" lines.
-var hostPattern = regexp.MustCompile(`(?i)^\s*Host\s+(.+?)\s*$`)
+var hostPattern = regexp.MustCompile(`(?i)^\s*Host(?:\s*=\s*|\s+)(.+?)\s*$`)
+
+// identityAgentPattern matches uncommented "IdentityAgent <path>" lines.
+var identityAgentPattern = regexp.MustCompile(`(?i)^\s*IdentityAgent(?:\s*=\s*|\s+)(.+?)\s*$`)
+
+// hostNamePattern matches uncommented "HostName <host>" lines.
+var hostNamePattern = regexp.MustCompile(`(?i)^\s*HostName(?:\s*=\s*|\s+)(\S+)\s*$`)
 
 // identityFilePattern matches "IdentityFile <path>" lines (commented or not).
-var identityFilePattern = regexp.MustCompile(`(?i)^\s*(#\s*)?(IdentityFile)\s+(.+?)\s*$`)
+// IdentityFile must be the first keyword (optionally after a single #) and be
+// followed by exactly one path token, so prose comments that merely mention
+// IdentityFile (e.g. "# IdentityFile for work is below") are left alone.
+// The path may be double-quoted to contain spaces. Like ssh, the keyword and
+// value may be separated by "=" (IdentityFile=~/.ssh/id_work) instead of
+// whitespace; edits only touch the comment prefix or the path, so the original
+// separator survives a rewrite.
+var identityFilePattern = regexp.MustCompile(`(?i)^\s*(#\s*)?(IdentityFile)(?:\s*=\s*|\s+)("[^"]*"|\S+)\s*$`)
 
 func (c *ConfigFile) parseBlocks() {
 	c.Blocks = nil
+	c.includeAt = nil
 
 	var currentBlock *HostBlock
 
 	for i, line := range c.Lines {
+		if includePattern.MatchString(line) {
+			c.includeAt = append(c.includeAt, i)
+		}
+
 		if match := hostPattern.FindStringSubmatch(line); match != nil {
 			// Save previous block
 			if currentBlock != nil {
@@ -110,10 +245,17 @@ func (c *ConfigFile) parseBlocks() {
 				ifl := IdentityFileLine{
 					LineIndex:   len(currentBlock.Lines) - 1,
 					IsCommented: match[1] != "",
-					Path:        strings.TrimSpace(match[3]),
+					Path:        unquote(match[3]),
 					FullLine:    line,
 				}
 				currentBlock.IdentityFiles = append(currentBlock.IdentityFiles, ifl)
+			} else if match := identityAgentPattern.FindStringSubmatch(line); match != nil && currentBlock.IdentityAgent == "" {
+				// First IdentityAgent wins, matching ssh's own semantics
+				currentBlock.IdentityAgent = strings.TrimSpace(match[1])
+			} else if match := hostNamePattern.FindStringSubmatch(line); match != nil && currentBlock.HostName == "" {
+				currentBlock.HostName = match[1]
+			} else if unrecognizedLine(line) {
+				currentBlock.Unrecognized = append(currentBlock.Unrecognized, line)
 			}
 		}
 	}
@@ -125,19 +267,144 @@ func (c *ConfigFile) parseBlocks() {
 	}
 }
 
-// FindHostBlock finds a Host block by hostname.
+// keywordLinePattern matches an ordinary "Keyword value" or "Keyword=value" line.
+var keywordLinePattern = regexp.MustCompile(`^\s*[A-Za-z][A-Za-z0-9]*(?:\s*=\s*|\s+)\S`)
+
+// matchPattern matches "Match ..." lines, which start a section this parser
+// folds into the preceding Host block.
+var matchPattern = regexp.MustCompile(`(?i)^\s*Match(?:\s*=\s*|\s+)`)
+
+// unrecognizedLine reports whether a line inside a Host block is something
+// gh-context can't model, so edits near it might not do what ssh would expect.
+func unrecognizedLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+		return false
+	case strings.HasSuffix(trimmed, "\\"):
+		return true // Line continuation
+	case matchPattern.MatchString(line):
+		return true
+	default:
+		return !keywordLinePattern.MatchString(line)
+	}
+}
+
+// Warnings describes constructs in the Host block ssh would consult for
+// hostname that gh-context can't model. Empty if there are none.
+func (c *ConfigFile) Warnings(hostname string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, block := c.findHostBlock(hostname)
+	if block == nil {
+		return nil
+	}
+	warnings := make([]string, 0, len(block.Unrecognized))
+	for _, line := range block.Unrecognized {
+		warnings = append(warnings, fmt.Sprintf("%s (Host %s): %s", f.Path, block.Hostname, strings.TrimSpace(line)))
+	}
+	return warnings
+}
+
+// FindHostBlock finds the Host block ssh would consult first for hostname,
+// following Include directives in the order ssh reads them.
 func (c *ConfigFile) FindHostBlock(hostname string) *HostBlock {
-	for i := range c.Blocks {
-		if c.Blocks[i].Hostname == hostname {
-			return &c.Blocks[i]
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, block := c.findHostBlock(hostname)
+	return block
+}
+
+// findHostBlock returns the first matching block in effective order, along
+// with the file (main config or an include) that contains it.
+func (c *ConfigFile) findHostBlock(hostname string) (*ConfigFile, *HostBlock) {
+	for _, ref := range c.effectiveBlocks() {
+		if ref.block.Matches(hostname) {
+			return ref.file, ref.block
 		}
 	}
-	return nil
+	return nil, nil
+}
+
+// Matches reports whether hostname is listed by name on the block's Host line.
+// Wildcard patterns are not expanded: gh-context only edits blocks written
+// for a specific host, so a global "Host *" block never matches, even when it
+// comes before the host's own block or the host has no block at all.
+// Negated patterns are honored as ssh honors them: if hostname matches any
+// "!pattern" (wildcards included), the block doesn't apply to it at all.
+func (b *HostBlock) Matches(hostname string) bool {
+	listed := false
+	for _, pattern := range strings.Fields(b.Hostname) {
+		if negated := strings.TrimPrefix(pattern, "!"); negated != pattern {
+			if matchHostPattern(negated, hostname) {
+				return false
+			}
+			continue
+		}
+		if !isWildcard(pattern) && strings.EqualFold(pattern, hostname) {
+			listed = true
+		}
+	}
+	return listed
+}
+
+// matchHostPattern reports whether hostname matches one ssh Host pattern, where
+// * matches any run of characters and ? any one, case-insensitively.
+func matchHostPattern(pattern, hostname string) bool {
+	pattern, hostname = strings.ToLower(pattern), strings.ToLower(hostname)
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(hostname); i >= 0; i-- {
+				if matchHostPattern(pattern[1:], hostname[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if hostname == "" {
+				return false
+			}
+		default:
+			if hostname == "" || pattern[0] != hostname[0] {
+				return false
+			}
+		}
+		pattern, hostname = pattern[1:], hostname[1:]
+	}
+	return hostname == ""
+}
+
+// isWildcard reports whether a Host pattern uses ssh's wildcard or negation syntax.
+func isWildcard(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?!")
+}
+
+// CanonicalHost resolves an SSH Host alias (e.g. github-work) to the real host
+// from its block's HostName directive (e.g. github.com). Returns the input
+// unchanged when there is no such block or it has no HostName.
+func (c *ConfigFile) CanonicalHost(alias string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, block := c.findHostBlock(alias)
+	if block == nil || block.HostName == "" {
+		return alias
+	}
+	return block.HostName
 }
 
 // GetActiveIdentityFile returns the currently active (uncommented) IdentityFile for a host.
 func (c *ConfigFile) GetActiveIdentityFile(hostname string) string {
-	block := c.FindHostBlock(hostname)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.activeIdentityFile(hostname)
+}
+
+// activeIdentityFile is GetActiveIdentityFile without locking.
+func (c *ConfigFile) activeIdentityFile(hostname string) string {
+	_, block := c.findHostBlock(hostname)
 	if block == nil {
 		return ""
 	}
@@ -150,96 +417,564 @@ func (c *ConfigFile) GetActiveIdentityFile(hostname string) string {
 	return ""
 }
 
+// KeyForUser guesses which IdentityFile in host's Host block belongs to a
+// GitHub user: the one whose file name contains the user name as a whole
+// word (id_alice, alice-ed25519, id_ed25519.alice). Returns empty string if
+// no key or more than one matches.
+func (c *ConfigFile) KeyForUser(hostname, user string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, block := c.findHostBlock(hostname)
+	if block == nil || user == "" {
+		return ""
+	}
+
+	word := regexp.MustCompile(`(?i)(^|[_.-])` + regexp.QuoteMeta(user) + `($|[_.-])`)
+	match := ""
+	for _, ifl := range block.IdentityFiles {
+		if !word.MatchString(filepath.Base(ifl.Path)) || ifl.Path == match {
+			continue
+		}
+		if match != "" {
+			return "" // Ambiguous
+		}
+		match = ifl.Path
+	}
+	return match
+}
+
+// ActiveKeyMap returns each host named on a Host line (wildcard patterns
+// excluded) mapped to the IdentityFile ssh would use for it, as reported by
+// GetActiveIdentityFile. Hosts with every key commented out, or that rely on
+// an IdentityAgent, map to empty string.
+func ActiveKeyMap(cfg *ConfigFile) map[string]string {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	keys := make(map[string]string)
+	for _, ref := range cfg.effectiveBlocks() {
+		for _, host := range strings.Fields(ref.block.Hostname) {
+			if _, seen := keys[host]; !seen && !isWildcard(host) {
+				keys[host] = cfg.activeIdentityFile(host)
+			}
+		}
+	}
+	return keys
+}
+
+// GetIdentityAgent returns the IdentityAgent configured for a host, if any.
+// Agent-based setups (1Password, Secretive, etc.) authenticate without an
+// IdentityFile; gh-context surfaces these but never toggles them.
+func (c *ConfigFile) GetIdentityAgent(hostname string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, block := c.findHostBlock(hostname)
+	if block == nil {
+		return ""
+	}
+	return block.IdentityAgent
+}
+
 // ActivateKey activates a specific SSH key for a hostname by:
 // - Uncommenting the IdentityFile line matching keyPath
 // - Commenting out all other IdentityFile lines
 // Returns error if the key is not found in the config.
 func (c *ConfigFile) ActivateKey(hostname, keyPath string) error {
-	block := c.FindHostBlock(hostname)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.activateKey(hostname, keyPath)
+}
+
+func (c *ConfigFile) activateKey(hostname, keyPath string) error {
+	f, block := c.findHostBlock(hostname)
 	if block == nil {
 		return fmt.Errorf("no Host block found for '%s' in SSH config", hostname)
 	}
 
-	// Normalize the key path for comparison
-	normalizedKeyPath := normalizePath(keyPath)
+	logging.Info("matched Host block", "host", hostname, "file", f.Path, "line", block.StartLine+1)
+	if !block.HasIdentityFile(keyPath) {
+		return fmt.Errorf("IdentityFile '%s' not found in Host %s block\nAdd it to your SSH config first", keyPath, hostname)
+	}
 
-	// Check if the key exists in the block
-	found := false
-	for _, ifl := range block.IdentityFiles {
-		if normalizePath(ifl.Path) == normalizedKeyPath {
-			found = true
-			break
+	toggleBlock(f, block, keyPath)
+
+	// Re-parse to update internal state
+	f.parseBlocks()
+	f.dirty = true
+	return nil
+}
+
+// ActivateKeyForHostName activates keyPath in every block whose effective host
+// is hostName: blocks with "HostName <hostName>" and blocks named hostName with
+// no HostName of their own (e.g. "Host github.com" plus "Host github-work").
+// Blocks that don't list keyPath are left untouched.
+// Returns error if no such block lists the key.
+func (c *ConfigFile) ActivateKeyForHostName(hostName, keyPath string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	touched := make(map[*ConfigFile]bool)
+	for _, ref := range c.effectiveBlocks() {
+		if !ref.block.resolvesTo(hostName) {
+			continue
 		}
+		if !ref.block.HasIdentityFile(keyPath) {
+			logging.Info("skipping Host block without key", "host", ref.block.Hostname, "file", ref.file.Path)
+			continue
+		}
+
+		logging.Info("matched Host block", "host", ref.block.Hostname, "file", ref.file.Path, "line", ref.block.StartLine+1)
+		toggleBlock(ref.file, ref.block, keyPath)
+		touched[ref.file] = true
 	}
 
-	if !found {
-		return fmt.Errorf("IdentityFile '%s' not found in Host %s block\nAdd it to your SSH config first", keyPath, hostname)
+	if len(touched) == 0 {
+		return fmt.Errorf("IdentityFile '%s' not found in any Host block for %s\nAdd it to your SSH config first", keyPath, hostName)
+	}
+
+	// Toggling never changes line counts, so block offsets stay valid until here
+	for f := range touched {
+		f.parseBlocks()
+		f.dirty = true
+	}
+	return nil
+}
+
+// ReplaceIdentityFile rewrites every IdentityFile line (commented or not, in
+// all blocks and included files) that points at oldPath to point at newPath,
+// keeping each line's comment state, indentation, and spacing. If activate is
+// set, newPath is also made the active key in each block that was changed.
+// Returns the number of lines rewritten.
+func (c *ConfigFile) ReplaceIdentityFile(oldPath, newPath string, activate bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := 0
+	touched := make(map[*ConfigFile]bool)
+	for _, ref := range c.effectiveBlocks() {
+		changed := false
+		for _, ifl := range ref.block.IdentityFiles {
+			if !SamePath(ifl.Path, oldPath) {
+				continue
+			}
+			idx := ref.block.StartLine + ifl.LineIndex
+			ref.file.Lines[idx] = replaceIdentityFilePath(ref.file.Lines[idx], newPath)
+			logging.Info("replaced IdentityFile", "file", ref.file.Path, "line", idx+1, "old", ifl.Path, "new", newPath)
+			changed = true
+			count++
+		}
+		if !changed {
+			continue
+		}
+		touched[ref.file] = true
+
+		if activate {
+			// IdentityFiles still hold the old paths; toggle by line instead
+			block := *ref.block
+			block.IdentityFiles = nil
+			for _, ifl := range ref.block.IdentityFiles {
+				if SamePath(ifl.Path, oldPath) {
+					ifl.Path = newPath
+				}
+				block.IdentityFiles = append(block.IdentityFiles, ifl)
+			}
+			toggleBlock(ref.file, &block, newPath)
+		}
 	}
 
-	// Now modify the lines
+	for f := range touched {
+		f.parseBlocks()
+		f.dirty = true
+	}
+	return count
+}
+
+// replaceIdentityFilePath swaps the path token of an IdentityFile line.
+func replaceIdentityFilePath(line, newPath string) string {
+	loc := identityFilePattern.FindStringSubmatchIndex(line)
+	if loc == nil {
+		return line
+	}
+	return line[:loc[6]] + quoteIfNeeded(newPath) + line[loc[7]:]
+}
+
+// resolvesTo reports whether the block's effective host is hostName.
+func (b *HostBlock) resolvesTo(hostName string) bool {
+	if b.HostName != "" {
+		return strings.EqualFold(b.HostName, hostName)
+	}
+	return b.Matches(hostName)
+}
+
+// toggleBlock uncomments keyPath and comments out every other IdentityFile in
+// block, editing f.Lines in place. The caller re-parses f.
+func toggleBlock(f *ConfigFile, block *HostBlock, keyPath string) {
+	if len(block.Unrecognized) > 0 {
+		logging.Info("editing Host block with unrecognized lines", "host", block.Hostname, "lines", len(block.Unrecognized))
+	}
+	normalizedKeyPath := normalizePath(keyPath)
+
+	// Match the user's existing comment style
+	marker := commentMarker(block, f.Lines)
 	for _, ifl := range block.IdentityFiles {
 		globalLineIdx := block.StartLine + ifl.LineIndex
-		originalLine := c.Lines[globalLineIdx]
+		originalLine := f.Lines[globalLineIdx]
 
 		if normalizePath(ifl.Path) == normalizedKeyPath {
 			// This is the key we want active - uncomment it
-			c.Lines[globalLineIdx] = uncommentIdentityFile(originalLine)
+			f.Lines[globalLineIdx] = uncommentIdentityFile(originalLine)
+			logging.Info("activating IdentityFile", "path", ifl.Path, "line", globalLineIdx+1)
 		} else {
 			// This is a different key - comment it out
-			c.Lines[globalLineIdx] = commentIdentityFile(originalLine)
+			f.Lines[globalLineIdx] = commentIdentityFile(originalLine, marker)
+			logging.Debug("deactivating IdentityFile", "path", ifl.Path, "line", globalLineIdx+1)
+		}
+	}
+}
+
+// EnsureActiveKey activates keyPath for hostname, first adding it to the
+// Host block as an IdentityFile line if it isn't there yet.
+// Returns error if the Host block doesn't exist.
+func (c *ConfigFile) EnsureActiveKey(hostname, keyPath string) (added bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, block := c.findHostBlock(hostname)
+	if block == nil {
+		return false, fmt.Errorf("no Host block found for '%s' in SSH config", hostname)
+	}
+
+	if !block.HasIdentityFile(keyPath) {
+		if err := c.addIdentityFile(hostname, keyPath, true); err != nil {
+			return false, err
 		}
+		added = true
 	}
 
-	// Re-parse to update internal state
+	return added, c.activateKey(hostname, keyPath)
+}
+
+// ForceActiveKey guarantees keyPath is the active key for hostname, whatever
+// the starting config: the Host block is created if missing (with HostName
+// hostName when that differs from hostname), the IdentityFile line added if
+// missing, and every other key in the block commented out.
+func (c *ConfigFile) ForceActiveKey(hostname, hostName, keyPath string) (created, added bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, block := c.findHostBlock(hostname); block == nil {
+		c.addHostBlock(hostname, hostName, keyPath)
+		return true, false, nil
+	} else if !block.HasIdentityFile(keyPath) {
+		if err := c.addIdentityFile(hostname, keyPath, true); err != nil {
+			return false, false, err
+		}
+		added = true
+	}
+
+	return false, added, c.activateKey(hostname, keyPath)
+}
+
+// addHostBlock adds a Host block holding keyPath as its only IdentityFile to
+// the main config file: before a global "Host *" block if there is one,
+// otherwise at the end. c.NewBlock decides the other lines.
+func (c *ConfigFile) addHostBlock(hostname, hostName, keyPath string) {
+	// Match the existing blocks' style; top-level lines are usually flush left
+	indent := "    "
+	body := []string{""}
+	for _, b := range c.Blocks {
+		body = append(body, b.Lines[1:]...)
+	}
+	if len(body) > 1 {
+		indent = detectIndent(body)
+	}
+
+	block := []string{"Host " + hostname}
+	if hostName != "" && !strings.EqualFold(hostName, hostname) {
+		block = append(block, indent+"HostName "+hostName)
+	}
+	if !c.NewBlock.OmitUser {
+		block = append(block, indent+"User git")
+	}
+	block = append(block, indent+"IdentityFile "+quoteIfNeeded(keyPath))
+	if !c.NewBlock.OmitIdentitiesOnly {
+		block = append(block, indent+"IdentitiesOnly yes")
+	}
+
+	if at := c.globalBlockLine(); at >= 0 {
+		// ssh uses the first value it finds, so the new block must come
+		// before a "Host *" block for its settings to take effect
+		lines := append([]string{}, c.Lines[:at]...)
+		lines = append(lines, block...)
+		lines = append(lines, "")
+		c.Lines = append(lines, c.Lines[at:]...)
+	} else {
+		if len(c.Lines) > 0 && strings.TrimSpace(c.Lines[len(c.Lines)-1]) != "" {
+			c.Lines = append(c.Lines, "")
+		}
+		c.Lines = append(c.Lines, block...)
+	}
+
+	logging.Info("added Host block", "host", hostname, "key", keyPath)
 	c.parseBlocks()
-	return nil
+	c.dirty = true
+}
+
+// globalBlockLine returns the line where the first block whose Host patterns
+// are all wildcards (e.g. "Host *") starts, including any comment lines directly
+// above it, or -1 if there is none.
+func (c *ConfigFile) globalBlockLine() int {
+	for _, block := range c.Blocks {
+		patterns := strings.Fields(block.Hostname)
+		global := len(patterns) > 0
+		for _, pattern := range patterns {
+			if !isWildcard(pattern) {
+				global = false
+				break
+			}
+		}
+		if !global {
+			continue
+		}
+
+		at := block.StartLine
+		for at > 0 && strings.HasPrefix(strings.TrimSpace(c.Lines[at-1]), "#") {
+			at--
+		}
+		return at
+	}
+	return -1
+}
+
+// HasIdentityFile reports whether the block has an IdentityFile line (commented
+// or not) for keyPath.
+func (b *HostBlock) HasIdentityFile(keyPath string) bool {
+	normalizedKeyPath := normalizePath(keyPath)
+	for _, ifl := range b.IdentityFiles {
+		if normalizePath(ifl.Path) == normalizedKeyPath {
+			return true
+		}
+	}
+	return false
 }
 
 // AddIdentityFile adds a new IdentityFile line to a Host block.
 // If the block doesn't exist, returns an error.
 func (c *ConfigFile) AddIdentityFile(hostname, keyPath string, active bool) error {
-	block := c.FindHostBlock(hostname)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.addIdentityFile(hostname, keyPath, active)
+}
+
+func (c *ConfigFile) addIdentityFile(hostname, keyPath string, active bool) error {
+	f, insertIdx, newLine, err := c.planIdentityFile(hostname, keyPath, active)
+	if err != nil || f == nil {
+		return err
+	}
+
+	// Insert the line
+	f.Lines = append(f.Lines[:insertIdx], append([]string{newLine}, f.Lines[insertIdx:]...)...)
+
+	// Re-parse
+	f.parseBlocks()
+	f.dirty = true
+	return nil
+}
+
+// AddIdentityFilePreview returns the line AddIdentityFile would insert for
+// the same arguments and the index in Lines it would go at, without changing
+// anything. The index is into the file holding the block, which is an
+// included file's Lines when the block comes from an Include. Returns -1 and
+// an empty line if the block already lists the key.
+func (c *ConfigFile) AddIdentityFilePreview(hostname, keyPath string, active bool) (insertIndex int, newLine string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, insertIndex, newLine, err := c.planIdentityFile(hostname, keyPath, active)
+	if err != nil {
+		return -1, "", err
+	}
+	if f == nil {
+		return -1, "", nil
+	}
+	return insertIndex, newLine, nil
+}
+
+// planIdentityFile works out where addIdentityFile inserts keyPath's line and
+// what it says. Returns a nil file if the block already lists the key.
+func (c *ConfigFile) planIdentityFile(hostname, keyPath string, active bool) (*ConfigFile, int, string, error) {
+	f, block := c.findHostBlock(hostname)
 	if block == nil {
-		return fmt.Errorf("no Host block found for '%s' in SSH config", hostname)
+		return nil, 0, "", fmt.Errorf("no Host block found for '%s' in SSH config", hostname)
 	}
 
 	// Check if it already exists
-	normalizedKeyPath := normalizePath(keyPath)
-	for _, ifl := range block.IdentityFiles {
-		if normalizePath(ifl.Path) == normalizedKeyPath {
-			return nil // Already exists
-		}
+	if block.HasIdentityFile(keyPath) {
+		return nil, 0, "", nil
 	}
 
 	// Create the new line
 	indent := detectIndent(block.Lines)
 	var newLine string
 	if active {
-		newLine = fmt.Sprintf("%sIdentityFile %s", indent, keyPath)
+		newLine = fmt.Sprintf("%sIdentityFile %s", indent, quoteIfNeeded(keyPath))
 	} else {
-		newLine = fmt.Sprintf("%s# IdentityFile %s", indent, keyPath)
+		newLine = fmt.Sprintf("%s%sIdentityFile %s", indent, defaultCommentMarker, quoteIfNeeded(keyPath))
 	}
 
-	// Find insertion point (after last IdentityFile, or after Host line)
+	// Find insertion point (after last IdentityFile, or after Host line),
+	// never inside the comments and blank lines trailing the block
 	insertIdx := block.StartLine + 1 // Default: right after Host line
-	if len(block.IdentityFiles) > 0 {
-		lastIF := block.IdentityFiles[len(block.IdentityFiles)-1]
-		insertIdx = block.StartLine + lastIF.LineIndex + 1
+	tail := blockTail(block.Lines)
+	for _, ifl := range block.IdentityFiles {
+		if ifl.LineIndex < tail {
+			insertIdx = block.StartLine + ifl.LineIndex + 1
+		}
 	}
+	return f, insertIdx, newLine, nil
+}
 
-	// Insert the line
-	c.Lines = append(c.Lines[:insertIdx], append([]string{newLine}, c.Lines[insertIdx:]...)...)
+// NormalizeBlock reorders the IdentityFile lines of the Host block for
+// hostname into a canonical order: active lines first, then commented ones,
+// each group keeping its existing relative order (the order ssh offers active
+// keys in). The lines swap places among the slots IdentityFile lines already
+// occupy, so every other directive, comment and blank line stays on its line.
+// Lines in the comments trailing the block are left alone. Nothing else calls
+// this; it only runs when asked.
+func (c *ConfigFile) NormalizeBlock(hostname string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// Re-parse
-	c.parseBlocks()
+	f, block := c.findHostBlock(hostname)
+	if block == nil {
+		return fmt.Errorf("no Host block found for '%s' in SSH config", hostname)
+	}
+
+	var slots []int
+	var active, commented []string
+	tail := blockTail(block.Lines)
+	for _, ifl := range block.IdentityFiles {
+		if ifl.LineIndex >= tail {
+			continue
+		}
+		slots = append(slots, block.StartLine+ifl.LineIndex)
+		if ifl.IsCommented {
+			commented = append(commented, ifl.FullLine)
+		} else {
+			active = append(active, ifl.FullLine)
+		}
+	}
+
+	changed := false
+	for i, line := range append(active, commented...) {
+		if f.Lines[slots[i]] != line {
+			f.Lines[slots[i]] = line
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	logging.Info("normalized IdentityFile order", "host", block.Hostname, "file", f.Path)
+	f.parseBlocks()
+	f.dirty = true
 	return nil
 }
 
-// Save writes the config back to disk, creating a backup first.
+// RemoveIdentityFile deletes every IdentityFile line (commented or not) for
+// keyPath from the Host block for hostname. Returns the number of lines
+// removed; a block that doesn't list the key is not an error.
+func (c *ConfigFile) RemoveIdentityFile(hostname, keyPath string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, block := c.findHostBlock(hostname)
+	if block == nil {
+		return 0, fmt.Errorf("no Host block found for '%s' in SSH config", hostname)
+	}
+
+	drop := make(map[int]bool)
+	normalizedKeyPath := normalizePath(keyPath)
+	for _, ifl := range block.IdentityFiles {
+		if normalizePath(ifl.Path) == normalizedKeyPath {
+			drop[block.StartLine+ifl.LineIndex] = true
+		}
+	}
+	if len(drop) == 0 {
+		return 0, nil
+	}
+
+	lines := make([]string, 0, len(f.Lines)-len(drop))
+	for i, line := range f.Lines {
+		if !drop[i] {
+			lines = append(lines, line)
+		}
+	}
+	f.Lines = lines
+
+	logging.Info("removed IdentityFile", "host", block.Hostname, "key", keyPath, "file", f.Path)
+	f.parseBlocks()
+	f.dirty = true
+	return len(drop), nil
+}
+
+// Save writes the config back to disk atomically, creating a backup first
+// unless NoBackup is set.
+// The config directory is created (0700) if missing, and checked for
+// writability before any backup is made. Included files are rewritten
+// (with their own backups) only if they were edited.
 func (c *ConfigFile) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.write(); err != nil {
+		return err
+	}
+	return c.saveIncludes()
+}
+
+// saveIncludes writes every edited included file, recursively.
+func (c *ConfigFile) saveIncludes() error {
+	for _, inc := range c.Includes {
+		if inc.dirty {
+			if err := inc.write(); err != nil {
+				return err
+			}
+		}
+		if err := inc.saveIncludes(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write backs up and rewrites this single file.
+func (c *ConfigFile) write() error {
+	if c.Path == "" {
+		return fmt.Errorf("SSH config was parsed from memory and has no file to save to")
+	}
+
+	// Write through symlinks (e.g. a dotfiles-managed config) rather than replacing them
+	target := c.Path
+	if resolved, err := filepath.EvalSymlinks(c.Path); err == nil {
+		target = resolved
+	}
+
+	if err := ensureWritable(target); err != nil {
+		return err
+	}
+
 	// Create backup
-	backupPath := c.Path + ".bak"
-	if _, err := os.Stat(c.Path); err == nil {
+	backupPath := c.BackupPath()
+	if c.BackupDir != "" && !c.NoBackup {
+		if err := os.MkdirAll(c.BackupDir, 0700); err != nil {
+			return fmt.Errorf("failed to create backup directory: %w", err)
+		}
+	}
+	if _, err := os.Stat(c.Path); err == nil && !c.NoBackup {
 		data, err := os.ReadFile(c.Path)
 		if err != nil {
 			return fmt.Errorf("failed to read config for backup: %w", err)
@@ -250,53 +985,142 @@ func (c *ConfigFile) Save() error {
 	}
 
 	// Write new config
+	if err := fsutil.WriteFileAtomic(target, []byte(c.content()), 0600); err != nil {
+		return fmt.Errorf("failed to write SSH config: %w", err)
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// String returns the config's content as it would be saved (this file only,
+// not included ones).
+func (c *ConfigFile) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.content()
+}
+
+func (c *ConfigFile) content() string {
 	content := strings.Join(c.Lines, "\n")
 	if len(c.Lines) > 0 && !strings.HasSuffix(content, "\n") {
 		content += "\n"
 	}
+	return content
+}
 
-	if err := os.WriteFile(c.Path, []byte(content), 0600); err != nil {
-		return fmt.Errorf("failed to write SSH config: %w", err)
+// BackupPath returns where Save writes the backup of this config.
+// With a BackupDir the file name is qualified by the config's full path
+// (e.g. home_me_.ssh_config.bak) so backups of different configs don't collide.
+func (c *ConfigFile) BackupPath() string {
+	if c.BackupDir == "" {
+		return c.Path + ".bak"
 	}
 
-	return nil
+	abs, err := filepath.Abs(c.Path)
+	if err != nil {
+		abs = c.Path
+	}
+	qualified := strings.Trim(filepath.ToSlash(abs), "/")
+	qualified = strings.NewReplacer("/", "_", ":", "").Replace(qualified)
+	return filepath.Join(c.BackupDir, qualified+".bak")
 }
 
 // Helper functions
 
+// ensureWritable creates the config's parent directory if needed and verifies
+// that both the directory and any existing config file can be written.
+func ensureWritable(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("cannot create SSH config directory %s: %w\nCreate it with: mkdir -m 700 %s", dir, err, dir)
+	}
+
+	probe, err := os.CreateTemp(dir, ".gh-context-*")
+	if err != nil {
+		return fmt.Errorf("SSH config directory %s is not writable: %w\nCheck its permissions (expected 0700 and owned by you)", dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	if _, err := os.Stat(path); err == nil {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("SSH config %s is not writable: %w\nCheck its permissions (expected 0600 and owned by you)", path, err)
+		}
+		f.Close()
+	}
+
+	return nil
+}
+
+// SamePath reports whether two key paths refer to the same file, using the
+// same resolution as IdentityFile matching (~ expansion, bare names in ~/.ssh).
+func SamePath(a, b string) bool {
+	return normalizePath(a) == normalizePath(b)
+}
+
+// normalizePath turns a key path into a canonical absolute form for comparison.
+// Like ssh, bare relative names (e.g. "id_work") resolve against ~/.ssh, so
+// "id_work", "~/.ssh/id_work", and "/home/me/.ssh/id_work" all compare equal.
+// Only comparisons use this; config lines keep the form the user wrote.
 func normalizePath(p string) string {
-	// Expand ~ to home directory for comparison
-	if strings.HasPrefix(p, "~/") {
-		home, err := os.UserHomeDir()
-		if err == nil {
+	home, err := os.UserHomeDir()
+	if err == nil {
+		switch {
+		case strings.HasPrefix(p, "~/"):
+			// Expand ~ to home directory
 			p = filepath.Join(home, p[2:])
+		case p != "" && !filepath.IsAbs(p) && !strings.HasPrefix(p, "~"):
+			p = filepath.Join(home, ".ssh", p)
 		}
 	}
 	// Clean the path
 	return filepath.Clean(p)
 }
 
+// unquote strips surrounding double quotes from an SSH config value.
+func unquote(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && strings.HasPrefix(v, `"`) && strings.HasSuffix(v, `"`) {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// quoteIfNeeded double-quotes a value containing whitespace, as ssh requires.
+func quoteIfNeeded(v string) string {
+	if strings.ContainsAny(v, " \t") {
+		return `"` + v + `"`
+	}
+	return v
+}
+
+// defaultCommentMarker is used when the config has no commented IdentityFile
+// lines to copy the style from.
+const defaultCommentMarker = "# "
+
+// leadingIndent returns the run of spaces and tabs that starts line.
+func leadingIndent(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// uncommentIdentityFile strips the comment marker ("#", "# ", "#  ", ...) from
+// an IdentityFile line, leaving indentation and the rest of the line as-is.
 func uncommentIdentityFile(line string) string {
-	// Remove leading # and normalize spacing
 	match := identityFilePattern.FindStringSubmatch(line)
-	if match == nil {
+	if match == nil || match[1] == "" {
 		return line
 	}
 
-	// Preserve original indentation
-	indent := ""
-	for _, ch := range line {
-		if ch == ' ' || ch == '\t' {
-			indent += string(ch)
-		} else {
-			break
-		}
-	}
-
-	return fmt.Sprintf("%sIdentityFile %s", indent, strings.TrimSpace(match[3]))
+	indent := leadingIndent(line)
+	return indent + line[len(indent)+len(match[1]):]
 }
 
-func commentIdentityFile(line string) string {
+// commentIdentityFile comments out an IdentityFile line using marker (the
+// "#" plus any spacing the user's config already uses), keeping the rest
+// of the line as-is.
+func commentIdentityFile(line, marker string) string {
 	match := identityFilePattern.FindStringSubmatch(line)
 	if match == nil {
 		return line
@@ -307,45 +1131,119 @@ func commentIdentityFile(line string) string {
 		return line
 	}
 
-	// Preserve original indentation
-	indent := ""
-	for _, ch := range line {
-		if ch == ' ' || ch == '\t' {
-			indent += string(ch)
-		} else {
-			break
+	indent := leadingIndent(line)
+	return indent + marker + line[len(indent):]
+}
+
+// commentMarker detects the comment style for IdentityFile lines, preferring
+// the block's own commented lines, then any in the file.
+func commentMarker(block *HostBlock, lines []string) string {
+	for _, ifl := range block.IdentityFiles {
+		if ifl.IsCommented {
+			return identityFilePattern.FindStringSubmatch(ifl.FullLine)[1]
 		}
 	}
-
-	return fmt.Sprintf("%s# IdentityFile %s", indent, strings.TrimSpace(match[3]))
+	for _, line := range lines {
+		if match := identityFilePattern.FindStringSubmatch(line); match != nil && match[1] != "" {
+			return match[1]
+		}
+	}
+	return defaultCommentMarker
 }
 
+// detectIndent returns the indentation most of the lines after the first
+// use (none, for a config written flush left), counting directives before
+// comments, which are often indented differently. Host and Match lines don't
+// count. Ties go to the style seen first.
 func detectIndent(lines []string) string {
-	// Look at existing lines to detect indentation style
-	for _, line := range lines[1:] { // Skip Host line
-		trimmed := strings.TrimLeft(line, " \t")
-		if trimmed != "" && trimmed != line {
+	for _, comments := range []bool{false, true} {
+		counts := make(map[string]int)
+		best, found := "", false
+		for _, line := range lines[1:] { // Skip Host line
+			trimmed := strings.TrimLeft(line, " \t")
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") != comments || hostPattern.MatchString(line) || matchPattern.MatchString(line) {
+				continue
+			}
+			if comments && trimmed == line {
+				continue // A flush-left comment says nothing about the block's style
+			}
 			indent := line[:len(line)-len(trimmed)]
-			return indent
+			counts[indent]++
+			if !found || counts[indent] > counts[best] {
+				best, found = indent, true
+			}
+		}
+		if found {
+			return best
 		}
 	}
 	return "    " // Default to 4 spaces
 }
 
-// ExpandPath expands ~ in a path to the home directory.
-func ExpandPath(p string) string {
-	if strings.HasPrefix(p, "~/") {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			return filepath.Join(home, p[2:])
+// blockTail returns the index within lines where a block's trailing
+// decoration starts: the blank lines and comments after its last directive
+// that follow a blank line or, in an indented block, sit in column 0 like a
+// banner for the next block. Returns len(lines) if there is none.
+func blockTail(lines []string) int {
+	indented := false
+	for _, line := range lines[1:] {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			indented = line != strings.TrimLeft(line, " \t")
+			break
 		}
 	}
-	return p
+
+	tail := len(lines)
+	for i := len(lines) - 1; i > 0; i-- {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break // Last directive
+		}
+		if trimmed == "" || indented && lines[i] == strings.TrimLeft(lines[i], " \t") {
+			tail = i
+		}
+	}
+	return tail
 }
 
 // KeyExists checks if an SSH key file exists.
 func KeyExists(keyPath string) bool {
-	expanded := ExpandPath(keyPath)
+	expanded := fsutil.ExpandPath(keyPath)
 	_, err := os.Stat(expanded)
 	return err == nil
 }
+
+// KeyStatus describes an SSH key file as found on disk.
+type KeyStatus string
+
+const (
+	KeyOK       KeyStatus = "ok"       // Present and private to its owner
+	KeyMissing  KeyStatus = "missing"  // No such file
+	KeyInsecure KeyStatus = "insecure" // Readable or writable by group or others
+)
+
+// CheckKey reports whether a key file exists and, on Unix, whether its
+// permissions are tight enough for ssh to use it (no group/other access).
+// Windows has no comparable mode bits, so any existing key is ok there.
+func CheckKey(keyPath string) (KeyStatus, os.FileMode) {
+	info, err := os.Stat(fsutil.ExpandPath(keyPath))
+	if err != nil {
+		return KeyMissing, 0
+	}
+	mode := info.Mode().Perm()
+	if runtime.GOOS != "windows" && mode&0077 != 0 {
+		return KeyInsecure, mode
+	}
+	return KeyOK, mode
+}
+
+// GitSSHCommand returns an ssh command line that uses only keyPath, for
+// core.sshCommand or GIT_SSH_COMMAND. git runs it through a shell, so the
+// path is quoted when it needs to be.
+func GitSSHCommand(keyPath string) string {
+	path := fsutil.ExpandPath(keyPath)
+	if strings.ContainsAny(path, " \t'\"$`\\") {
+		path = "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+	}
+	return fmt.Sprintf("ssh -i %s -o IdentitiesOnly=yes", path)
+}
@@ -0,0 +1,1056 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAddIdentityFileConcurrent(t *testing.T) {
+	cfg := ParseConfigString("Host github.com\n    HostName github.com\n    IdentityFile ~/.ssh/id_main\n\nHost ghes.corp\n    User git\n")
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			host := "github.com"
+			if i%2 == 1 {
+				host = "ghes.corp"
+			}
+			if err := cfg.AddIdentityFile(host, fmt.Sprintf("~/.ssh/id_%d", i), false); err != nil {
+				t.Errorf("AddIdentityFile(%s, %d): %v", host, i, err)
+			}
+			_ = cfg.String()
+			_ = cfg.GetActiveIdentityFile(host)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		host := "github.com"
+		if i%2 == 1 {
+			host = "ghes.corp"
+		}
+		if block := cfg.FindHostBlock(host); block == nil || !block.HasIdentityFile(fmt.Sprintf("~/.ssh/id_%d", i)) {
+			t.Errorf("Host %s is missing ~/.ssh/id_%d:\n%s", host, i, cfg.String())
+		}
+	}
+	if got := cfg.GetActiveIdentityFile("github.com"); got != "~/.ssh/id_main" {
+		t.Errorf("active key for github.com = %q, want ~/.ssh/id_main", got)
+	}
+}
+
+func TestNormalizeBlock(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "active keys move ahead of commented ones",
+			in: "Host github.com\n" +
+				"    # IdentityFile ~/.ssh/id_old\n" +
+				"    IdentityFile ~/.ssh/id_work\n" +
+				"    #IdentityFile ~/.ssh/id_personal\n" +
+				"    IdentityFile ~/.ssh/id_backup\n",
+			want: "Host github.com\n" +
+				"    IdentityFile ~/.ssh/id_work\n" +
+				"    IdentityFile ~/.ssh/id_backup\n" +
+				"    # IdentityFile ~/.ssh/id_old\n" +
+				"    #IdentityFile ~/.ssh/id_personal\n",
+		},
+		{
+			name: "other lines stay put",
+			in: "Host github.com\n" +
+				"    # IdentityFile ~/.ssh/id_old\n" +
+				"    HostName github.com\n" +
+				"    # work key below\n" +
+				"\n" +
+				"    IdentityFile ~/.ssh/id_work\n" +
+				"    User git\n",
+			want: "Host github.com\n" +
+				"    IdentityFile ~/.ssh/id_work\n" +
+				"    HostName github.com\n" +
+				"    # work key below\n" +
+				"\n" +
+				"    # IdentityFile ~/.ssh/id_old\n" +
+				"    User git\n",
+		},
+		{
+			name: "trailing comments are skipped",
+			in: "Host github.com\n" +
+				"    # IdentityFile ~/.ssh/id_old\n" +
+				"    IdentityFile ~/.ssh/id_work\n" +
+				"\n" +
+				"# IdentityFile ~/.ssh/id_next\n" +
+				"Host ghes.corp\n" +
+				"    IdentityFile ~/.ssh/id_corp\n",
+			want: "Host github.com\n" +
+				"    IdentityFile ~/.ssh/id_work\n" +
+				"    # IdentityFile ~/.ssh/id_old\n" +
+				"\n" +
+				"# IdentityFile ~/.ssh/id_next\n" +
+				"Host ghes.corp\n" +
+				"    IdentityFile ~/.ssh/id_corp\n",
+		},
+		{
+			name: "canonical order is unchanged",
+			in: "Host github.com\n" +
+				"    IdentityFile ~/.ssh/id_work\n" +
+				"    # IdentityFile ~/.ssh/id_old\n",
+			want: "Host github.com\n" +
+				"    IdentityFile ~/.ssh/id_work\n" +
+				"    # IdentityFile ~/.ssh/id_old\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ParseConfigString(tt.in)
+			if err := cfg.NormalizeBlock("github.com"); err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.String(); got != tt.want {
+				t.Errorf("NormalizeBlock:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if cfg.dirty != (tt.in != tt.want) {
+				t.Errorf("dirty = %v, want %v", cfg.dirty, tt.in != tt.want)
+			}
+		})
+	}
+
+	if err := ParseConfigString("Host ghes.corp\n").NormalizeBlock("github.com"); err == nil {
+		t.Error("NormalizeBlock succeeded without a Host block")
+	}
+}
+
+func TestActiveKeyMap(t *testing.T) {
+	cfg := ParseConfigString(`Host github.com github-work
+    IdentityFile ~/.ssh/id_work
+    # IdentityFile ~/.ssh/id_personal
+
+Host ghes.corp
+    # IdentityFile ~/.ssh/id_corp
+
+Host gitlab.com
+    IdentityAgent ~/.1password/agent.sock
+
+Host *.corp !build.corp
+    IdentityFile ~/.ssh/id_wildcard
+
+Host github.com
+    IdentityFile ~/.ssh/id_shadowed
+`)
+
+	got := ActiveKeyMap(cfg)
+	want := map[string]string{
+		"github.com":  "~/.ssh/id_work", // First block wins, as in ssh
+		"github-work": "~/.ssh/id_work",
+		"ghes.corp":   "", // Every key commented out
+		"gitlab.com":  "", // Agent only
+	}
+	if len(got) != len(want) {
+		t.Errorf("ActiveKeyMap = %v, want %v", got, want)
+	}
+	for host, key := range want {
+		if k, ok := got[host]; !ok || k != key {
+			t.Errorf("ActiveKeyMap[%s] = %q (present %v), want %q", host, k, ok, key)
+		}
+	}
+}
+
+func TestParseConfigStringMatchesFile(t *testing.T) {
+	tests := map[string]string{
+		"empty":            "",
+		"blocks":           "# Personal\nHost github.com\n    IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_work\n\nHost ghes.corp\n    HostName ghes.internal\n    IdentityAgent ~/agent.sock\n",
+		"no final newline": "Host github.com\n  IdentityFile ~/.ssh/id_work",
+		"crlf":             "Host github.com\r\n  IdentityFile ~/.ssh/id_work\r\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			want, err := ParseConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fromReader, err := ParseConfigReader(strings.NewReader(content))
+			if err != nil {
+				t.Fatalf("ParseConfigReader: %v", err)
+			}
+			for parser, got := range map[string]*ConfigFile{"ParseConfigString": ParseConfigString(content), "ParseConfigReader": fromReader} {
+				if !reflect.DeepEqual(got.Lines, want.Lines) || !reflect.DeepEqual(got.Blocks, want.Blocks) {
+					t.Errorf("%s parsed\n%#v\nParseConfig parsed\n%#v", parser, got.Blocks, want.Blocks)
+				}
+				if got.String() != want.String() {
+					t.Errorf("%s String() = %q, ParseConfig String() = %q", parser, got.String(), want.String())
+				}
+			}
+		})
+	}
+}
+
+func TestQuoteIfNeeded(t *testing.T) {
+	for in, want := range map[string]string{
+		"~/.ssh/id_work":    "~/.ssh/id_work",
+		"~/Keys/My Key":     `"~/Keys/My Key"`,
+		"C:\\Keys\\My\tKey": "\"C:\\Keys\\My\tKey\"",
+		"":                  "",
+	} {
+		if got := quoteIfNeeded(in); got != want {
+			t.Errorf("quoteIfNeeded(%q) = %q, want %q", in, got, want)
+		}
+		if got := unquote(quoteIfNeeded(in)); got != in {
+			t.Errorf("unquote(quoteIfNeeded(%q)) = %q", in, got)
+		}
+	}
+}
+
+func TestQuotedIdentityFile(t *testing.T) {
+	const config = "Host github.com\n" +
+		"    IdentityFile ~/.ssh/id_personal\n" +
+		"    # IdentityFile \"~/Keys/Work Key\"\n"
+
+	t.Run("parse", func(t *testing.T) {
+		block := ParseConfigString(config).FindHostBlock("github.com")
+		if !block.HasIdentityFile("~/Keys/Work Key") {
+			t.Errorf("IdentityFiles = %+v, want the unquoted path ~/Keys/Work Key", block.IdentityFiles)
+		}
+	})
+
+	t.Run("toggle", func(t *testing.T) {
+		cfg := ParseConfigString(config)
+		if err := cfg.ActivateKey("github.com", "~/Keys/Work Key"); err != nil {
+			t.Fatal(err)
+		}
+		want := "Host github.com\n" +
+			"    # IdentityFile ~/.ssh/id_personal\n" +
+			"    IdentityFile \"~/Keys/Work Key\"\n"
+		if got := cfg.String(); got != want {
+			t.Errorf("after activating:\n%s\nwant:\n%s", got, want)
+		}
+
+		if err := cfg.ActivateKey("github.com", "~/.ssh/id_personal"); err != nil {
+			t.Fatal(err)
+		}
+		if got := cfg.String(); got != config {
+			t.Errorf("after switching back:\n%s\nwant the original:\n%s", got, config)
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		cfg := ParseConfigString(config)
+		if n := cfg.ReplaceIdentityFile("~/Keys/Work Key", "~/Keys/New Work Key", false); n != 1 {
+			t.Fatalf("replaced %d lines, want 1", n)
+		}
+		if n := cfg.ReplaceIdentityFile("~/.ssh/id_personal", "~/My Keys/id_personal", false); n != 1 {
+			t.Fatalf("replaced %d lines, want 1", n)
+		}
+		want := "Host github.com\n" +
+			"    IdentityFile \"~/My Keys/id_personal\"\n" +
+			"    # IdentityFile \"~/Keys/New Work Key\"\n"
+		if got := cfg.String(); got != want {
+			t.Errorf("after replacing:\n%s\nwant:\n%s", got, want)
+		}
+
+		// And back to paths without spaces, which need no quotes
+		cfg.ReplaceIdentityFile("~/Keys/New Work Key", "~/.ssh/id_work", true)
+		want = "Host github.com\n" +
+			"    # IdentityFile \"~/My Keys/id_personal\"\n" +
+			"    IdentityFile ~/.ssh/id_work\n"
+		if got := cfg.String(); got != want {
+			t.Errorf("after replacing and activating:\n%s\nwant:\n%s", got, want)
+		}
+		if got := cfg.GetActiveIdentityFile("github.com"); got != "~/.ssh/id_work" {
+			t.Errorf("active key = %q, want ~/.ssh/id_work", got)
+		}
+	})
+}
+
+func TestIdentityFileEqualsSeparator(t *testing.T) {
+	const config = "Host github.com\n" +
+		"    IdentityFile=~/.ssh/id_personal\n" +
+		"    #IdentityFile = ~/.ssh/id_work\n" +
+		"    identityfile= \"~/Keys/Old Key\"\n"
+
+	cfg := ParseConfigString(config)
+	block := cfg.FindHostBlock("github.com")
+	for _, key := range []string{"~/.ssh/id_personal", "~/.ssh/id_work", "~/Keys/Old Key"} {
+		if !block.HasIdentityFile(key) {
+			t.Errorf("IdentityFiles = %+v, missing %s", block.IdentityFiles, key)
+		}
+	}
+
+	if err := cfg.ActivateKey("github.com", "~/.ssh/id_work"); err != nil {
+		t.Fatal(err)
+	}
+	want := "Host github.com\n" +
+		"    #IdentityFile=~/.ssh/id_personal\n" +
+		"    IdentityFile = ~/.ssh/id_work\n" +
+		"    #identityfile= \"~/Keys/Old Key\"\n"
+	if got := cfg.String(); got != want {
+		t.Errorf("after activating:\n%s\nwant:\n%s", got, want)
+	}
+
+	cfg.ReplaceIdentityFile("~/.ssh/id_work", "~/.ssh/id_ed25519_work", false)
+	want = strings.Replace(want, "= ~/.ssh/id_work", "= ~/.ssh/id_ed25519_work", 1)
+	if got := cfg.String(); got != want {
+		t.Errorf("after replacing:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := cfg.ActivateKey("github.com", "~/.ssh/id_personal"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetActiveIdentityFile("github.com"); got != "~/.ssh/id_personal" {
+		t.Errorf("active key = %q, want ~/.ssh/id_personal", got)
+	}
+	if got := cfg.Lines[1]; got != "    IdentityFile=~/.ssh/id_personal" {
+		t.Errorf("reactivated line = %q, want the original", got)
+	}
+}
+
+func TestHostBlockMatches(t *testing.T) {
+	tests := []struct {
+		host     string
+		hostname string
+		want     bool
+	}{
+		{"github.com", "github.com", true},
+		{"github.com", "GitHub.com", true},
+		{"github.com github-work", "github-work", true},
+		{"github.com", "ghes.corp", false},
+		{"*", "github.com", false},     // Wildcards never select a block to edit
+		{"*.corp", "ghes.corp", false}, // Nor do partial ones
+		{"github.com !github.com", "github.com", false},
+		{"!github.com github.com", "github.com", false},
+		{"github.com !ghes.*", "github.com", true},
+		{"ghes.corp !ghes.*", "ghes.corp", false}, // Negated wildcards are expanded
+		{"ghes.corp !GHES.CORP", "ghes.corp", false},
+		{"ghes.corp !ghes.c?rp", "ghes.corp", false},
+		{"ghes.corp !ghes.c?rp", "ghes.corporate", false}, // Not listed at all
+		{"* !ghes.*", "github.com", false},
+	}
+	for _, tt := range tests {
+		block := HostBlock{Hostname: tt.host}
+		if got := block.Matches(tt.hostname); got != tt.want {
+			t.Errorf("Host %s: Matches(%q) = %v, want %v", tt.host, tt.hostname, got, tt.want)
+		}
+	}
+}
+
+func TestMatchHostPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		hostname string
+		want     bool
+	}{
+		{"github.com", "github.com", true},
+		{"*", "anything", true},
+		{"*", "", true},
+		{"ghes.*", "ghes.corp", true},
+		{"ghes.*", "github.com", false},
+		{"*.corp", "ghes.corp", true},
+		{"*.corp", "ghes.corp.evil", false},
+		{"gh?s.corp", "ghes.corp", true},
+		{"gh?s.corp", "ghs.corp", false},
+		{"*h*b*", "github.com", true},
+		{"GHES.*", "ghes.corp", true},
+	}
+	for _, tt := range tests {
+		if got := matchHostPattern(tt.pattern, tt.hostname); got != tt.want {
+			t.Errorf("matchHostPattern(%q, %q) = %v, want %v", tt.pattern, tt.hostname, got, tt.want)
+		}
+	}
+}
+
+func TestFindHostBlockSkipsNegatedBlocks(t *testing.T) {
+	cfg := ParseConfigString("Host github.com !github.com\n    IdentityFile ~/.ssh/id_never\n\n" +
+		"Host ghes.corp github.com !ghes.*\n    IdentityFile ~/.ssh/id_github\n")
+
+	if block := cfg.FindHostBlock("github.com"); block == nil || block.StartLine != 3 {
+		t.Errorf("FindHostBlock(github.com) = %+v, want the block on line 4", block)
+	}
+	if block := cfg.FindHostBlock("ghes.corp"); block != nil {
+		t.Errorf("FindHostBlock(ghes.corp) = %+v, want none: every block negates it", block)
+	}
+}
+
+// saveEdited parses the config at path, switches github.com to id_work and saves.
+func saveEdited(t *testing.T, path string) *ConfigFile {
+	t.Helper()
+	cfg, err := ParseConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ActivateKey("github.com", "~/.ssh/id_work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+const backupTestConfig = "Host github.com\n    IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_work\n"
+
+func TestBackupDir(t *testing.T) {
+	sshDir := t.TempDir()
+	backupDir := filepath.Join(t.TempDir(), "backups")
+	t.Setenv(BackupDirEnv, backupDir)
+	t.Setenv(NoBackupEnv, "")
+	path := filepath.Join(sshDir, "config")
+	if err := os.WriteFile(path, []byte(backupTestConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := saveEdited(t, path)
+
+	backup := cfg.BackupPath()
+	if filepath.Dir(backup) != backupDir {
+		t.Errorf("backup %s is not in %s", backup, backupDir)
+	}
+	if base := filepath.Base(backup); !strings.HasSuffix(base, "_config.bak") || strings.ContainsAny(base, `/\:`) {
+		t.Errorf("backup name %q isn't qualified by the config's path", base)
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("backup also written beside the config (%v)", err)
+	}
+	if info, err := os.Stat(backupDir); err != nil || info.Mode().Perm() != 0700 && runtime.GOOS != "windows" {
+		t.Errorf("backup directory not created 0700 (%v)", err)
+	}
+
+	// Restoring is copying the backup back
+	data, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != backupTestConfig {
+		t.Errorf("backup holds:\n%s\nwant the original:\n%s", data, backupTestConfig)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := ParseConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.GetActiveIdentityFile("github.com"); got != "~/.ssh/id_personal" {
+		t.Errorf("restored active key = %q, want ~/.ssh/id_personal", got)
+	}
+}
+
+func TestBackupPath(t *testing.T) {
+	cfg := &ConfigFile{Path: filepath.Join(string(filepath.Separator)+"home", "me", ".ssh", "config")}
+	if got, want := cfg.BackupPath(), cfg.Path+".bak"; got != want {
+		t.Errorf("default BackupPath = %q, want %q", got, want)
+	}
+
+	other := &ConfigFile{Path: filepath.Join(string(filepath.Separator)+"home", "me", ".ssh", "config.d", "config"), BackupDir: "backups"}
+	cfg.BackupDir = "backups"
+	if cfg.BackupPath() == other.BackupPath() {
+		t.Errorf("configs %s and %s share the backup %s", cfg.Path, other.Path, cfg.BackupPath())
+	}
+}
+
+func TestNoBackup(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    string
+		field  bool
+		backup bool
+	}{
+		{name: "default", backup: true},
+		{name: "env", env: "1"},
+		{name: "env false", env: "false", backup: true},
+		{name: "field", field: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(BackupDirEnv, "")
+			t.Setenv(NoBackupEnv, tt.env)
+			path := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(path, []byte(backupTestConfig), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := ParseConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.field {
+				cfg.NoBackup = true
+			}
+			if err := cfg.ActivateKey("github.com", "~/.ssh/id_work"); err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = os.Stat(path + ".bak")
+			if tt.backup && err != nil {
+				t.Errorf("no backup written: %v", err)
+			}
+			if !tt.backup && !os.IsNotExist(err) {
+				t.Errorf("backup written with backups off (%v)", err)
+			}
+			if got := ParseConfigString(mustRead(t, path)).GetActiveIdentityFile("github.com"); got != "~/.ssh/id_work" {
+				t.Errorf("saved active key = %q, want ~/.ssh/id_work", got)
+			}
+		})
+	}
+}
+
+// mustRead returns the content of path.
+func mustRead(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSaveSSHDir(t *testing.T) {
+	t.Setenv(BackupDirEnv, "")
+	t.Setenv(NoBackupEnv, "")
+
+	t.Run("normal write", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config")
+		if err := os.WriteFile(path, []byte(backupTestConfig), 0600); err != nil {
+			t.Fatal(err)
+		}
+		cfg := saveEdited(t, path)
+		if got := ParseConfigString(mustRead(t, path)).GetActiveIdentityFile("github.com"); got != "~/.ssh/id_work" {
+			t.Errorf("active key = %q after save, want ~/.ssh/id_work", got)
+		}
+		if mustRead(t, cfg.BackupPath()) != backupTestConfig {
+			t.Error("backup doesn't hold the original config")
+		}
+	})
+
+	t.Run("missing dir is created", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), ".ssh")
+		cfg, err := ParseConfig(filepath.Join(dir, "config"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := cfg.ForceActiveKey("github.com", "github.com", "~/.ssh/id_work"); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Save(); err != nil {
+			t.Fatalf("Save without ~/.ssh: %v", err)
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != 0700 {
+			t.Errorf("created %s with mode %04o, want 0700", dir, info.Mode().Perm())
+		}
+		if got := ParseConfigString(mustRead(t, cfg.Path)).GetActiveIdentityFile("github.com"); got != "~/.ssh/id_work" {
+			t.Errorf("active key = %q, want ~/.ssh/id_work", got)
+		}
+	})
+
+	t.Run("dir can't be created", func(t *testing.T) {
+		home := t.TempDir()
+		dir := filepath.Join(home, ".ssh")
+		if err := os.WriteFile(dir, nil, 0600); err != nil { // A file where ~/.ssh should be
+			t.Fatal(err)
+		}
+		cfg := ParseConfigString(backupTestConfig)
+		cfg.Path = filepath.Join(dir, "config")
+		err := cfg.Save()
+		if err == nil || !strings.Contains(err.Error(), "mkdir -m 700") {
+			t.Errorf("Save = %v, want an error saying how to create the directory", err)
+		}
+	})
+
+	t.Run("read-only dir", func(t *testing.T) {
+		if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+			t.Skip("directory permissions don't bind here")
+		}
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config")
+		if err := os.WriteFile(path, []byte(backupTestConfig), 0600); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := ParseConfig(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.ActivateKey("github.com", "~/.ssh/id_work"); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(dir, 0500); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Chmod(dir, 0700) })
+
+		err = cfg.Save()
+		if err == nil || !strings.Contains(err.Error(), "is not writable") {
+			t.Errorf("Save = %v, want a not-writable error", err)
+		}
+		if _, err := os.Stat(cfg.BackupPath()); !os.IsNotExist(err) {
+			t.Errorf("backup written before the failed save (%v)", err)
+		}
+		if mustRead(t, path) != backupTestConfig {
+			t.Error("config changed by the failed save")
+		}
+	})
+}
+
+func TestIdentityAgent(t *testing.T) {
+	cfg := ParseConfigString(`Host github.com
+    IdentityAgent "~/Library/Group Containers/2BUA8C4S2C.com.1password/t/agent.sock"
+    IdentityAgent ~/.second.sock
+
+Host ghes.corp
+    # IdentityAgent ~/.commented.sock
+    IdentityAgent=SSH_AUTH_SOCK
+
+Host other.corp
+    IdentityFile ~/.ssh/id_other
+`)
+	tests := []struct {
+		host  string
+		agent string
+	}{
+		{"github.com", `"~/Library/Group Containers/2BUA8C4S2C.com.1password/t/agent.sock"`}, // First one wins, as in ssh
+		{"ghes.corp", "SSH_AUTH_SOCK"},
+		{"other.corp", ""},
+		{"unknown.corp", ""},
+	}
+	for _, tt := range tests {
+		if got := cfg.GetIdentityAgent(tt.host); got != tt.agent {
+			t.Errorf("GetIdentityAgent(%s) = %q, want %q", tt.host, got, tt.agent)
+		}
+	}
+	if got := cfg.GetActiveIdentityFile("github.com"); got != "" {
+		t.Errorf("agent-only block has active key %q, want none", got)
+	}
+	if keys := ActiveKeyMap(cfg); keys["github.com"] != "" || keys["other.corp"] != "~/.ssh/id_other" {
+		t.Errorf("ActiveKeyMap = %v", keys)
+	}
+}
+
+func TestEnsureActiveKey(t *testing.T) {
+	const base = "Host github.com\n    IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_work\n"
+	tests := []struct {
+		name      string
+		key       string
+		wantAdded bool
+		want      string
+	}{
+		{
+			name: "existing key is toggled",
+			key:  "~/.ssh/id_work",
+			want: "Host github.com\n    # IdentityFile ~/.ssh/id_personal\n    IdentityFile ~/.ssh/id_work\n",
+		},
+		{
+			name:      "missing key is added active",
+			key:       "~/.ssh/id_new",
+			wantAdded: true,
+			want:      "Host github.com\n    # IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_work\n    IdentityFile ~/.ssh/id_new\n",
+		},
+		{
+			name: "already active key is left alone",
+			key:  "~/.ssh/id_personal",
+			want: base,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ParseConfigString(base)
+			added, err := cfg.EnsureActiveKey("github.com", tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if added != tt.wantAdded {
+				t.Errorf("added = %v, want %v", added, tt.wantAdded)
+			}
+			if got := cfg.String(); got != tt.want {
+				t.Errorf("config:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing key without adding", func(t *testing.T) {
+		cfg := ParseConfigString(base)
+		if err := cfg.ActivateKey("github.com", "~/.ssh/id_new"); err == nil {
+			t.Error("ActivateKey activated a key the block doesn't list")
+		}
+		if got := cfg.String(); got != base {
+			t.Errorf("failed ActivateKey changed the config:\n%s", got)
+		}
+	})
+
+	t.Run("missing block", func(t *testing.T) {
+		if _, err := ParseConfigString(base).EnsureActiveKey("ghes.corp", "~/.ssh/id_work"); err == nil {
+			t.Error("EnsureActiveKey succeeded without a Host block")
+		}
+	})
+}
+
+func TestProseCommentsMentioningIdentityFile(t *testing.T) {
+	const config = `Host github.com
+    # IdentityFile for work is below, keep it second
+    # Set IdentityFile ~/.ssh/id_x only on laptops
+    IdentityFile ~/.ssh/id_personal
+    #IdentityFile ~/.ssh/id_work
+    # Note: IdentityFile lines are toggled by gh-context
+`
+	cfg := ParseConfigString(config)
+	block := cfg.FindHostBlock("github.com")
+	if len(block.IdentityFiles) != 2 {
+		t.Fatalf("parsed %d IdentityFile lines, want 2 (prose comments excluded): %+v", len(block.IdentityFiles), block.IdentityFiles)
+	}
+
+	if err := cfg.ActivateKey("github.com", "~/.ssh/id_work"); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(strings.Replace(config,
+		"    IdentityFile ~/.ssh/id_personal", "    #IdentityFile ~/.ssh/id_personal", 1),
+		"    #IdentityFile ~/.ssh/id_work", "    IdentityFile ~/.ssh/id_work", 1)
+	if got := cfg.String(); got != want {
+		t.Errorf("config:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, prose := range []string{
+		"    # IdentityFile for work is below, keep it second",
+		"    # Set IdentityFile ~/.ssh/id_x only on laptops",
+		"    ## IdentityFile ~/.ssh/id_x",
+	} {
+		if got := uncommentIdentityFile(prose); got != prose {
+			t.Errorf("uncommentIdentityFile(%q) = %q", prose, got)
+		}
+		if got := commentIdentityFile(prose, "# "); got != prose {
+			t.Errorf("commentIdentityFile(%q) = %q", prose, got)
+		}
+	}
+}
+
+func TestCanonicalHost(t *testing.T) {
+	cfg := ParseConfigString("Host github-work\n    HostName github.com\n\nHost HostName-less\n    User git\n")
+	for alias, want := range map[string]string{
+		"github-work":   "github.com",
+		"HostName-less": "HostName-less",
+		"github.com":    "github.com",
+	} {
+		if got := cfg.CanonicalHost(alias); got != want {
+			t.Errorf("CanonicalHost(%s) = %q, want %q", alias, got, want)
+		}
+	}
+}
+
+func TestToggleKeepsCommentStyle(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "no space after #",
+			config: "Host github.com\n    IdentityFile ~/.ssh/id_personal\n    #IdentityFile ~/.ssh/id_work\n",
+			want:   "Host github.com\n    #IdentityFile ~/.ssh/id_personal\n    IdentityFile ~/.ssh/id_work\n",
+		},
+		{
+			name:   "two spaces after #",
+			config: "Host github.com\n    IdentityFile ~/.ssh/id_personal\n    #  IdentityFile ~/.ssh/id_work\n",
+			want:   "Host github.com\n    #  IdentityFile ~/.ssh/id_personal\n    IdentityFile ~/.ssh/id_work\n",
+		},
+		{
+			name:   "style taken from another block",
+			config: "Host ghes.corp\n    #IdentityFile ~/.ssh/id_corp\n\nHost github.com\n    IdentityFile ~/.ssh/id_personal\n    IdentityFile ~/.ssh/id_work\n",
+			want:   "Host ghes.corp\n    #IdentityFile ~/.ssh/id_corp\n\nHost github.com\n    #IdentityFile ~/.ssh/id_personal\n    IdentityFile ~/.ssh/id_work\n",
+		},
+		{
+			name:   "default without any commented lines",
+			config: "Host github.com\n    IdentityFile ~/.ssh/id_personal\n    IdentityFile ~/.ssh/id_work\n",
+			want:   "Host github.com\n    # IdentityFile ~/.ssh/id_personal\n    IdentityFile ~/.ssh/id_work\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ParseConfigString(tt.config)
+			if err := cfg.ActivateKey("github.com", "~/.ssh/id_work"); err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.String(); got != tt.want {
+				t.Errorf("config:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActivateKeyForHostName(t *testing.T) {
+	const config = `Host github.com
+    IdentityFile ~/.ssh/id_personal
+    # IdentityFile ~/.ssh/id_work
+
+Host github-work
+    HostName github.com
+    IdentityFile ~/.ssh/id_personal
+    # IdentityFile ~/.ssh/id_work
+
+Host gh-alt
+    HostName github.com
+    IdentityFile ~/.ssh/id_personal
+    # IdentityFile ~/.ssh/id_work
+
+Host gh-other-key
+    HostName github.com
+    IdentityFile ~/.ssh/id_other
+
+Host ghes.corp
+    IdentityFile ~/.ssh/id_personal
+    # IdentityFile ~/.ssh/id_work
+`
+	cfg := ParseConfigString(config)
+	if err := cfg.ActivateKeyForHostName("github.com", "~/.ssh/id_work"); err != nil {
+		t.Fatal(err)
+	}
+	for host, want := range map[string]string{
+		"github.com":   "~/.ssh/id_work",
+		"github-work":  "~/.ssh/id_work",
+		"gh-alt":       "~/.ssh/id_work",
+		"gh-other-key": "~/.ssh/id_other",    // Doesn't list the key, so untouched
+		"ghes.corp":    "~/.ssh/id_personal", // Another host
+	} {
+		if got := cfg.GetActiveIdentityFile(host); got != want {
+			t.Errorf("%s: active key = %q, want %q", host, got, want)
+		}
+	}
+
+	if err := ParseConfigString(config).ActivateKeyForHostName("github.com", "~/.ssh/id_missing"); err == nil {
+		t.Error("ActivateKeyForHostName succeeded for a key no block lists")
+	}
+}
+
+func TestRelativeIdentityFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	abs := filepath.Join(home, ".ssh", "id_work")
+
+	for _, form := range []string{"id_work", "~/.ssh/id_work", abs, "./id_work"} {
+		if !SamePath(form, "id_work") {
+			t.Errorf("SamePath(%q, id_work) = false", form)
+		}
+	}
+	if SamePath("id_work", filepath.Join(home, "id_work")) {
+		t.Error("bare id_work matched ~/id_work, not ~/.ssh/id_work")
+	}
+
+	// Each form activates a key written as the bare name, which is kept as written
+	for _, form := range []string{"id_work", "~/.ssh/id_work", abs} {
+		cfg := ParseConfigString("Host github.com\n    IdentityFile id_personal\n    # IdentityFile id_work\n")
+		if err := cfg.ActivateKey("github.com", form); err != nil {
+			t.Errorf("ActivateKey(%s): %v", form, err)
+			continue
+		}
+		if want := "Host github.com\n    # IdentityFile id_personal\n    IdentityFile id_work\n"; cfg.String() != want {
+			t.Errorf("ActivateKey(%s) config:\n%s\nwant:\n%s", form, cfg, want)
+		}
+	}
+}
+
+func TestUnrecognizedLines(t *testing.T) {
+	t.Setenv(BackupDirEnv, "")
+	t.Setenv(NoBackupEnv, "")
+	const content = "Host github.com\n" +
+		"    IdentityFile ~/.ssh/id_personal\n" +
+		"    ProxyCommand ssh -W %h:%p \\\n" +
+		"        bastion.corp\n" +
+		"    # IdentityFile ~/.ssh/id_work\n" +
+		"Match exec \"test -f ~/.vpn\"\n" +
+		"    ServerAliveInterval 30\n" +
+		"\n" +
+		"Host ghes.corp\n" +
+		"    User git\n"
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := ParseConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warnings := cfg.Warnings("github.com")
+	for _, want := range []string{"ProxyCommand ssh -W %h:%p \\", `Match exec "test -f ~/.vpn"`} {
+		found := false
+		for _, w := range warnings {
+			found = found || strings.HasSuffix(w, "(Host github.com): "+want)
+		}
+		if !found {
+			t.Errorf("no warning for %q in %q", want, warnings)
+		}
+	}
+	if w := cfg.Warnings("ghes.corp"); len(w) != 0 {
+		t.Errorf("plain block flagged: %q", w)
+	}
+
+	// Editing the block keeps the lines it couldn't model
+	if err := cfg.ActivateKey("github.com", "~/.ssh/id_work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	got := mustRead(t, path)
+	for _, line := range []string{"    ProxyCommand ssh -W %h:%p \\\n        bastion.corp\n", "Match exec \"test -f ~/.vpn\"\n    ServerAliveInterval 30\n"} {
+		if !strings.Contains(got, line) {
+			t.Errorf("edit dropped %q:\n%s", line, got)
+		}
+	}
+}
+
+func TestEditSkipsGlobalBlock(t *testing.T) {
+	const global = "Host *\n    ServerAliveInterval 60\n    IdentityFile ~/.ssh/id_default\n    # IdentityFile ~/.ssh/id_work\n"
+	cfg := ParseConfigString(global + "\nHost github.com\n    IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_work\n")
+
+	if block := cfg.FindHostBlock("github.com"); block == nil || block.Hostname != "github.com" {
+		t.Fatalf("FindHostBlock(github.com) = %+v, want the github.com block", block)
+	}
+	if err := cfg.ActivateKey("github.com", "~/.ssh/id_work"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetActiveIdentityFile("github.com"); got != "~/.ssh/id_work" {
+		t.Errorf("github.com active key = %q, want ~/.ssh/id_work", got)
+	}
+
+	// A host with no block of its own gets one (ahead of Host *, so it takes
+	// effect); Host * itself is never the target
+	if _, _, err := cfg.ForceActiveKey("ghes.corp", "ghes.corp", "~/.ssh/id_work"); err != nil {
+		t.Fatal(err)
+	}
+	if block := cfg.FindHostBlock("ghes.corp"); block == nil || block.Hostname != "ghes.corp" {
+		t.Errorf("FindHostBlock(ghes.corp) = %+v, want a new ghes.corp block", block)
+	}
+	if got := cfg.String(); !strings.Contains(got, global) {
+		t.Errorf("Host * block edited:\n%s", got)
+	}
+}
+
+func TestNewBlockOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts BlockOptions
+		want string
+	}{
+		{"default", BlockOptions{}, "Host ghes.corp\n    User git\n    IdentityFile ~/.ssh/id_work\n    IdentitiesOnly yes"},
+		{"without User", BlockOptions{OmitUser: true}, "Host ghes.corp\n    IdentityFile ~/.ssh/id_work\n    IdentitiesOnly yes"},
+		{"without IdentitiesOnly", BlockOptions{OmitIdentitiesOnly: true}, "Host ghes.corp\n    User git\n    IdentityFile ~/.ssh/id_work"},
+		{"key only", BlockOptions{OmitUser: true, OmitIdentitiesOnly: true}, "Host ghes.corp\n    IdentityFile ~/.ssh/id_work"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ParseConfigString("")
+			cfg.NewBlock = tt.opts
+			created, _, err := cfg.ForceActiveKey("ghes.corp", "ghes.corp", "~/.ssh/id_work")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !created {
+				t.Error("no block created")
+			}
+			if got := strings.TrimSpace(cfg.String()); got != tt.want {
+				t.Errorf("new block =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddIdentityFileBeforeTrailingComments(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			"after the last key, above the closing comment",
+			"Host github.com\n    IdentityFile ~/.ssh/id_personal\n    User git\n    # ---- end github ----\n\nHost ghes.corp\n    User git\n",
+			"Host github.com\n    IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_work\n    User git\n    # ---- end github ----\n\nHost ghes.corp\n    User git\n",
+		},
+		{
+			"commented key in the trailing comments isn't an anchor",
+			"Host github.com\n\tIdentityFile ~/.ssh/id_personal\n\n# Old keys:\n# IdentityFile ~/.ssh/id_old\n",
+			"Host github.com\n\tIdentityFile ~/.ssh/id_personal\n\t# IdentityFile ~/.ssh/id_work\n\n# Old keys:\n# IdentityFile ~/.ssh/id_old\n",
+		},
+		{
+			"block without keys",
+			"Host github.com\n  User git\n  # managed by dotfiles\n",
+			"Host github.com\n  # IdentityFile ~/.ssh/id_work\n  User git\n  # managed by dotfiles\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ParseConfigString(tt.content)
+			if err := cfg.AddIdentityFile("github.com", "~/.ssh/id_work", false); err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddIdentityFilePreview(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		active   bool
+		wantIdx  int
+		wantLine string
+	}{
+		{"after the last key", "Host github.com\n    IdentityFile ~/.ssh/id_personal\n    User git\n", false, 2, "    # IdentityFile ~/.ssh/id_work"},
+		{"active", "Host github.com\n    IdentityFile ~/.ssh/id_personal\n", true, 2, "    IdentityFile ~/.ssh/id_work"},
+		{"block without keys", "Host other\n  User git\n\nHost github.com\n\tUser git\n", false, 4, "\t# IdentityFile ~/.ssh/id_work"},
+		{"above trailing comments", "Host github.com\n  IdentityFile ~/.ssh/id_personal\n\n# IdentityFile ~/.ssh/id_old\n", true, 2, "  IdentityFile ~/.ssh/id_work"},
+		{"already listed", "Host github.com\n    # IdentityFile ~/.ssh/id_work\n", false, -1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ParseConfigString(tt.content)
+			idx, line, err := cfg.AddIdentityFilePreview("github.com", "~/.ssh/id_work", tt.active)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idx != tt.wantIdx || line != tt.wantLine {
+				t.Errorf("preview = %d, %q, want %d, %q", idx, line, tt.wantIdx, tt.wantLine)
+			}
+			if got := cfg.String(); got != tt.content || cfg.dirty {
+				t.Errorf("preview changed the config:\n%s", got)
+			}
+
+			// The real add puts the same line at the same index
+			if err := cfg.AddIdentityFile("github.com", "~/.ssh/id_work", tt.active); err != nil {
+				t.Fatal(err)
+			}
+			if idx < 0 {
+				if got := cfg.String(); got != tt.content {
+					t.Errorf("add after an empty preview changed the config:\n%s", got)
+				}
+				return
+			}
+			if idx >= len(cfg.Lines) || cfg.Lines[idx] != line {
+				t.Errorf("add wrote lines %q, not %q at %d", cfg.Lines, line, idx)
+			}
+		})
+	}
+
+	if _, _, err := ParseConfigString("Host other\n").AddIdentityFilePreview("github.com", "~/.ssh/id_work", false); err == nil {
+		t.Error("preview for a missing Host block succeeded")
+	}
+}
@@ -0,0 +1,132 @@
+// ABOUTME: Effective SSH settings for a host, as ssh -G resolves them
+// ABOUTME: Turns the resolved identity files, port and proxies into an ssh command to reproduce a connection
+
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/logging"
+)
+
+// Effective is what ssh would use to connect to a host after reading its
+// config files, per ssh -G.
+type Effective struct {
+	Host           string   // Destination ssh was asked about ([user@]alias or hostname)
+	HostName       string   // Host actually connected to
+	User           string   // Remote user
+	Port           string   // Remote port
+	IdentityFiles  []string // Keys offered, in order (ssh's defaults when none is configured)
+	IdentitiesOnly bool     // Only IdentityFiles are offered, not every agent key
+	ProxyJump      string   // Jump hosts (empty = none)
+	ProxyCommand   string   // Proxy command (empty = none)
+}
+
+// ResolveEffective runs ssh -G for host ([user@]host, as in a git remote URL),
+// with opts (e.g. "-i", key) given ahead of the host as git's core.sshCommand
+// would pass them, and returns the settings ssh resolves. The user's config
+// file is the one gh-context edits (DefaultConfigPath), when it exists.
+func ResolveEffective(host string, opts ...string) (*Effective, error) {
+	args := []string{"-G"}
+	if path := DefaultConfigPath(); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			args = append(args, "-F", path)
+		}
+	}
+	args = append(args, opts...)
+	args = append(args, host)
+	logging.Info("exec ssh", "args", strings.Join(args, " "))
+
+	output, err := exec.Command("ssh", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if msg := firstLine(strings.TrimSpace(string(exitErr.Stderr))); msg != "" {
+				return nil, fmt.Errorf("ssh -G %s: %s", host, msg)
+			}
+		}
+		return nil, fmt.Errorf("ssh -G %s: %w", host, err)
+	}
+	return parseEffective(host, string(output)), nil
+}
+
+// parseEffective reads the "keyword value" lines ssh -G prints.
+func parseEffective(host, output string) *Effective {
+	e := &Effective{Host: host}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "hostname":
+			e.HostName = value
+		case "user":
+			e.User = value
+		case "port":
+			e.Port = value
+		case "identityfile":
+			e.IdentityFiles = append(e.IdentityFiles, value)
+		case "identitiesonly":
+			e.IdentitiesOnly = value == "yes"
+		case "proxyjump":
+			if value != "none" {
+				e.ProxyJump = value
+			}
+		case "proxycommand":
+			if value != "none" {
+				e.ProxyCommand = value
+			}
+		}
+	}
+	return e
+}
+
+// Command returns an ssh -T command making the same connection with every
+// setting spelled out, so it behaves the same pasted into a shell as git's
+// ssh does, whatever Host block would otherwise match.
+func (e *Effective) Command() string {
+	parts := []string{"ssh", "-T"}
+	for _, f := range e.IdentityFiles {
+		parts = append(parts, "-i", shellQuote(f))
+	}
+	if e.IdentitiesOnly {
+		parts = append(parts, "-o", "IdentitiesOnly=yes")
+	}
+	if e.Port != "" && e.Port != "22" {
+		parts = append(parts, "-p", e.Port)
+	}
+	if e.ProxyJump != "" {
+		parts = append(parts, "-J", shellQuote(e.ProxyJump))
+	}
+	if e.ProxyCommand != "" {
+		parts = append(parts, "-o", shellQuote("ProxyCommand="+e.ProxyCommand))
+	}
+
+	target := e.HostName
+	if target == "" {
+		target = e.Host
+	}
+	if e.User != "" {
+		target = e.User + "@" + target
+	}
+	return strings.Join(append(parts, shellQuote(target)), " ")
+}
+
+// shellQuote single-quotes s for a POSIX shell if it holds anything the
+// shell would interpret. A leading ~/ is left outside the quotes so the
+// shell still expands it.
+func shellQuote(s string) string {
+	if !strings.ContainsAny(s, " \t'\"$`\\|&;<>()*?[]#!{}") {
+		return s
+	}
+	if rest, ok := strings.CutPrefix(s, "~/"); ok {
+		return "~/" + shellQuote(rest)
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,36 @@
+package ssh
+
+import (
+	"testing"
+)
+
+func TestEffectiveCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string // ssh -G output, abridged
+		want   string
+	}{
+		{
+			"defaults",
+			"host github.com\nhostname github.com\nuser git\nport 22\nidentityfile ~/.ssh/id_ed25519\nidentitiesonly no\nproxyjump none\n",
+			"ssh -T -i ~/.ssh/id_ed25519 git@github.com",
+		},
+		{
+			"block settings",
+			"hostname ghes.internal\nuser git\nport 2222\nidentityfile /home/me/.ssh/id_corp\nidentityfile /home/me/my keys/id_old\nidentitiesonly yes\nproxyjump bastion.corp\n",
+			"ssh -T -i /home/me/.ssh/id_corp -i '/home/me/my keys/id_old' -o IdentitiesOnly=yes -p 2222 -J bastion.corp git@ghes.internal",
+		},
+		{
+			"proxy command",
+			"hostname github.com\nuser git\nport 22\nidentityfile ~/.ssh/id work\nproxycommand nc -X 5 -x proxy:1080 %h %p\n",
+			"ssh -T -i ~/'.ssh/id work' -o 'ProxyCommand=nc -X 5 -x proxy:1080 %h %p' git@github.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseEffective("git@github.com", tt.output).Command(); got != tt.want {
+				t.Errorf("Command() = %s\nwant        %s", got, tt.want)
+			}
+		})
+	}
+}
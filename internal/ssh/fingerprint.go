@@ -0,0 +1,79 @@
+// ABOUTME: SSH public key fingerprints for gh-context
+// ABOUTME: Computes the SHA256:... form ssh-keygen -l prints, to match keys against the ones GitHub lists
+
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+)
+
+// KeyFingerprint returns the SHA256 fingerprint ("SHA256:...", as printed by
+// ssh-keygen -l) of the key at keyPath. The fingerprint comes from the public
+// key, so keyPath may name either half of the pair: for a private key the
+// .pub file beside it is read.
+func KeyFingerprint(keyPath string) (string, error) {
+	path := PublicKeyPath(keyPath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("public key not found: %s", path)
+		}
+		return "", err
+	}
+	fp, err := PublicKeyFingerprint(string(data))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return fp, nil
+}
+
+// PublicKeyPath returns the public key file for keyPath, expanded: keyPath
+// itself if it ends in .pub, else keyPath with .pub appended.
+func PublicKeyPath(keyPath string) string {
+	path := fsutil.ExpandPath(keyPath)
+	if strings.HasSuffix(path, ".pub") {
+		return path
+	}
+	return path + ".pub"
+}
+
+// PublicKeyFingerprint returns the SHA256 fingerprint of a public key in
+// authorized_keys form ("ssh-ed25519 AAAA... comment"), the form both .pub
+// files and GitHub's key API use. Options before the key type are not
+// supported; only the first non-comment line is read.
+func PublicKeyFingerprint(authorizedKey string) (string, error) {
+	var fields []string
+	for _, line := range strings.Split(authorizedKey, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			fields = strings.Fields(line)
+			break
+		}
+	}
+	if len(fields) < 2 {
+		return "", fmt.Errorf("not a public key (expected \"TYPE BASE64 [COMMENT]\")")
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", fmt.Errorf("not a public key: %v", err)
+	}
+	// The blob starts with its own key type as a length-prefixed string,
+	// which must agree with the type written before it
+	if len(blob) < 4 {
+		return "", fmt.Errorf("not a public key: truncated")
+	}
+	n := int(blob[0])<<24 | int(blob[1])<<16 | int(blob[2])<<8 | int(blob[3])
+	if n > len(blob)-4 || string(blob[4:4+n]) != fields[0] {
+		return "", fmt.Errorf("not a public key: data doesn't match type %s", fields[0])
+	}
+
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,67 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Fixture public keys with their fingerprints as printed by ssh-keygen -l
+const (
+	ed25519Pub = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIIyhAGDFvv6M8QbJgbaCDcPSd862IkZvo3RK60QZZ7ww work@example.com\n"
+	ed25519FP  = "SHA256:o8lJPYNDLeY3l7mFfV0x8qT5obCNkZdpMZ2M6mwikm4"
+	rsaPub     = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQDVExK2W37H2WFidy/IdrzD3+cTGD0C/N2DJ5sSPb8w2MlAJ0gHTkgSLL8KVSqiauwy7A0j0VAm+6JMXyXOa0u8W5BW8twJVTauaRoxDTDGQLcZs86LYRBqp+aA21hoEsD+wHouwu2nafRv5+PApuhIa7qaLPy+rNrbYDij+NatTT+iqVj3OalSbdIymmZvMmDCskcuiihLHjhgusrX/11NFF33xcIoxQimFTX02oIt0UuyVPx7YlcOMHw/g40wzv+w0GiFHKD25HI4UTG8MCTHTpMa7dqxn73vFOsrNfEBo/kPtTenSxTHPGPcX763J+aY61NgogeGAEdZV3O5Ql4D legacy\n"
+	rsaFP      = "SHA256:P07Ki+n+JEhihh6zry534Oj4AFN0qsxjQQ/6u1jXghI"
+)
+
+func TestKeyFingerprint(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	sshDir := filepath.Join(home, ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"id_ed25519.pub": ed25519Pub, "id_rsa.pub": rsaPub, "id_bad.pub": "ssh-rsa " + ed25519Pub[len("ssh-ed25519 "):]} {
+		if err := os.WriteFile(filepath.Join(sshDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"~/.ssh/id_ed25519", ed25519FP, false}, // Private key path: reads the .pub
+		{"~/.ssh/id_ed25519.pub", ed25519FP, false},
+		{"~/.ssh/id_rsa", rsaFP, false},
+		{"~/.ssh/id_bad", "", true}, // Type doesn't match the key data
+		{"~/.ssh/id_missing", "", true},
+	}
+	for _, tt := range tests {
+		got, err := KeyFingerprint(tt.key)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("KeyFingerprint(%s) = %q, %v; want %q (error %v)", tt.key, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPublicKeyFingerprint(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"# comment\n\n" + rsaPub, rsaFP, false},
+		{ed25519Pub[:len(ed25519Pub)-len(" work@example.com\n")], ed25519FP, false}, // No comment
+		{"ssh-ed25519", "", true},
+		{"ssh-ed25519 not-base64!", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := PublicKeyFingerprint(tt.key)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("PublicKeyFingerprint(%q) = %q, %v; want %q (error %v)", tt.key, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
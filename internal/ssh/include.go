@@ -0,0 +1,103 @@
+// ABOUTME: SSH config Include support for gh-context
+// ABOUTME: Resolves Include directives and orders blocks the way ssh reads them
+
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+)
+
+// includePattern matches uncommented "Include <path> [<path>...]" lines.
+var includePattern = regexp.MustCompile(`(?i)^\s*Include(?:\s*=\s*|\s+)(.+?)\s*$`)
+
+// maxIncludeDepth mirrors ssh's own recursion limit for nested includes.
+const maxIncludeDepth = 16
+
+// blockRef locates a Host block within the file that defines it.
+type blockRef struct {
+	file  *ConfigFile
+	block *HostBlock
+}
+
+// resolveIncludes parses the files named by each Include directive.
+// Relative paths are resolved against baseDir (~/.ssh for the user config),
+// and globs expand in lexical order, as ssh does. Missing files are skipped.
+func (c *ConfigFile) resolveIncludes(baseDir string, depth int) error {
+	c.Includes = nil
+	if depth >= maxIncludeDepth {
+		return nil
+	}
+
+	for ordinal, lineIdx := range c.includeAt {
+		match := includePattern.FindStringSubmatch(c.Lines[lineIdx])
+		for _, pattern := range strings.Fields(match[1]) {
+			for _, path := range expandInclude(pattern, baseDir) {
+				child, err := parseFile(path)
+				if err != nil {
+					return err
+				}
+				child.directive = ordinal
+				child.NoBackup = c.NoBackup
+				child.BackupDir = c.BackupDir
+				if child.BackupDir == "" {
+					// A .bak next to the include could match the same glob
+					// (e.g. "Include config.d/*"), so keep it beside the main config
+					child.BackupDir = baseDir
+				}
+				if err := child.resolveIncludes(baseDir, depth+1); err != nil {
+					return err
+				}
+				c.Includes = append(c.Includes, child)
+			}
+		}
+	}
+	return nil
+}
+
+// expandInclude turns an Include argument into the existing files it names.
+func expandInclude(pattern, baseDir string) []string {
+	pattern = fsutil.ExpandPath(pattern)
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(baseDir, pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && !info.IsDir() {
+			files = append(files, m)
+		}
+	}
+	return files
+}
+
+// effectiveBlocks returns every Host block in the order ssh evaluates them:
+// an included file's blocks sit at the position of its Include directive.
+func (c *ConfigFile) effectiveBlocks() []blockRef {
+	var refs []blockRef
+	next := 0
+
+	emitBefore := func(line int) {
+		for next < len(c.Blocks) && c.Blocks[next].StartLine < line {
+			refs = append(refs, blockRef{file: c, block: &c.Blocks[next]})
+			next++
+		}
+	}
+
+	for _, inc := range c.Includes {
+		emitBefore(c.includeAt[inc.directive])
+		refs = append(refs, inc.effectiveBlocks()...)
+	}
+	emitBefore(len(c.Lines) + 1)
+
+	return refs
+}
@@ -0,0 +1,190 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfigTree writes files (relative path → content) under a new
+// directory standing in for ~/.ssh, returning the directory.
+func writeConfigTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	t.Setenv(BackupDirEnv, "")
+	t.Setenv(NoBackupEnv, "")
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestIncludeFirstMatchWins(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "include before the main block",
+			files: map[string]string{
+				"config":            "Include config.d/*\n\nHost github.com\n    IdentityFile ~/.ssh/id_main\n",
+				"config.d/personal": "Host github.com\n    IdentityFile ~/.ssh/id_included\n",
+			},
+			want: "~/.ssh/id_included",
+		},
+		{
+			name: "main block before the include",
+			files: map[string]string{
+				"config":            "Host github.com\n    IdentityFile ~/.ssh/id_main\n\nInclude config.d/*\n",
+				"config.d/personal": "Host github.com\n    IdentityFile ~/.ssh/id_included\n",
+			},
+			want: "~/.ssh/id_main",
+		},
+		{
+			name: "globs expand in lexical order",
+			files: map[string]string{
+				"config":     "Include config.d/*\n",
+				"config.d/b": "Host github.com\n    IdentityFile ~/.ssh/id_b\n",
+				"config.d/a": "Host github.com\n    IdentityFile ~/.ssh/id_a\n",
+			},
+			want: "~/.ssh/id_a",
+		},
+		{
+			name: "nested includes sit at their directive",
+			files: map[string]string{
+				"config": "Include outer\nHost github.com\n    IdentityFile ~/.ssh/id_main\n",
+				"outer":  "Host ghes.corp\n    IdentityFile ~/.ssh/id_corp\nInclude inner\n",
+				"inner":  "Host github.com\n    IdentityFile ~/.ssh/id_inner\n",
+			},
+			want: "~/.ssh/id_inner",
+		},
+		{
+			name: "missing include is skipped",
+			files: map[string]string{
+				"config": "Include nowhere/*\nHost github.com\n    IdentityFile ~/.ssh/id_main\n",
+			},
+			want: "~/.ssh/id_main",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigTree(t, tt.files)
+			cfg, err := ParseConfig(filepath.Join(dir, "config"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.GetActiveIdentityFile("github.com"); got != tt.want {
+				t.Errorf("active key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncludeSaveRewritesEditedFileOnly(t *testing.T) {
+	dir := writeConfigTree(t, map[string]string{
+		"config":     "Include config.d/*\n",
+		"config.d/a": "Host ghes.corp\n    IdentityFile ~/.ssh/id_corp\n",
+		"config.d/b": "Host github.com\n    IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_work\n",
+	})
+	untouched := filepath.Join(dir, "config.d", "a")
+	edited := filepath.Join(dir, "config.d", "b")
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(untouched, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := ParseConfig(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ActivateKey("github.com", "~/.ssh/id_work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Host github.com\n    # IdentityFile ~/.ssh/id_personal\n    IdentityFile ~/.ssh/id_work\n"; string(data) != want {
+		t.Errorf("edited include:\n%s\nwant:\n%s", data, want)
+	}
+	if _, err := os.Stat(cfg.Includes[1].BackupPath()); err != nil {
+		t.Errorf("edited include has no backup: %v", err)
+	}
+	if filepath.Dir(cfg.Includes[1].BackupPath()) != dir {
+		t.Errorf("include backup %s is not beside the main config, where the glob can't match it", cfg.Includes[1].BackupPath())
+	}
+
+	if info, err := os.Stat(untouched); err != nil || !info.ModTime().Equal(past) {
+		t.Errorf("unedited include was rewritten (%v)", err)
+	}
+	if _, err := os.Stat(cfg.Includes[0].BackupPath()); !os.IsNotExist(err) {
+		t.Errorf("unedited include was backed up (%v)", err)
+	}
+}
+
+func TestIncludeBackupOptions(t *testing.T) {
+	files := map[string]string{
+		"config":        "Include config.d/*\n",
+		"config.d/work": "Host github.com\n    IdentityFile ~/.ssh/id_personal\n    # IdentityFile ~/.ssh/id_work\n",
+	}
+	// backups lists the .bak files under dir
+	backups := func(dir string) []string {
+		var found []string
+		filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err == nil && filepath.Ext(path) == ".bak" {
+				found = append(found, path)
+			}
+			return nil
+		})
+		return found
+	}
+	edit := func(t *testing.T, dir string, opts ParseOptions) {
+		t.Helper()
+		cfg, err := ParseConfigWith(filepath.Join(dir, "config"), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.ActivateKey("github.com", "~/.ssh/id_work"); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Save(); err != nil {
+			t.Fatal(err)
+		}
+		if got := ParseConfigString(mustRead(t, filepath.Join(dir, "config.d", "work"))).GetActiveIdentityFile("github.com"); got != "~/.ssh/id_work" {
+			t.Errorf("included block's active key = %q, want ~/.ssh/id_work", got)
+		}
+	}
+
+	t.Run("no backup", func(t *testing.T) {
+		dir := writeConfigTree(t, files)
+		edit(t, dir, ParseOptions{NoBackup: true})
+		if found := backups(dir); len(found) > 0 {
+			t.Errorf("backups written with NoBackup: %v", found)
+		}
+	})
+
+	t.Run("backup dir", func(t *testing.T) {
+		dir := writeConfigTree(t, files)
+		t.Setenv(BackupDirEnv, filepath.Join(dir, "env-backups")) // The option wins over the env
+		backupDir := t.TempDir()
+		edit(t, dir, ParseOptions{BackupDir: backupDir})
+		if found := backups(dir); len(found) > 0 {
+			t.Errorf("backups written outside the backup dir: %v", found)
+		}
+		if found := backups(backupDir); len(found) != 2 {
+			t.Errorf("backup dir holds %v, want backups of the config and the edited include", found)
+		}
+	})
+}
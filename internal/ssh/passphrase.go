@@ -0,0 +1,100 @@
+// ABOUTME: Passphrase detection for SSH private keys and ssh-agent lookups
+// ABOUTME: Reads key headers without decrypting, so doctor can tell which keys need the agent
+
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+	"github.com/peterjmorgan/gh-context/internal/logging"
+)
+
+// opensshMagic starts the body of an "OPENSSH PRIVATE KEY" block.
+const opensshMagic = "openssh-key-v1\x00"
+
+// IsKeyEncrypted reports whether the private key at keyPath is protected by a
+// passphrase. Nothing is decrypted: PEM keys are encrypted when their
+// Proc-Type header says ENCRYPTED or they are PKCS#8 "ENCRYPTED PRIVATE KEY"
+// blocks, and OpenSSH-format keys when their cipher isn't "none".
+func IsKeyEncrypted(keyPath string) (bool, error) {
+	path := fsutil.ExpandPath(keyPath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return false, fmt.Errorf("%s: not a PEM private key", path)
+	}
+	switch block.Type {
+	case "ENCRYPTED PRIVATE KEY":
+		return true, nil
+	case "OPENSSH PRIVATE KEY":
+		cipher, err := opensshCipher(block.Bytes)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", path, err)
+		}
+		return cipher != "none", nil
+	}
+	if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		return false, fmt.Errorf("%s: not a private key (%s)", path, block.Type)
+	}
+	return strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED"), nil
+}
+
+// opensshCipher returns the cipher name from an OpenSSH private key body,
+// the first length-prefixed string after the magic.
+func opensshCipher(body []byte) (string, error) {
+	if !bytes.HasPrefix(body, []byte(opensshMagic)) {
+		return "", errors.New("not an OpenSSH private key")
+	}
+	rest := body[len(opensshMagic):]
+	if len(rest) < 4 {
+		return "", errors.New("OpenSSH private key truncated")
+	}
+	n := binary.BigEndian.Uint32(rest)
+	if uint64(n) > uint64(len(rest)-4) {
+		return "", errors.New("OpenSSH private key truncated")
+	}
+	return string(rest[4 : 4+n]), nil
+}
+
+// AgentFingerprints returns the SHA256 fingerprints of the keys ssh-agent
+// holds (ssh-add -l -E sha256). An agent holding no keys yields none.
+func AgentFingerprints() ([]string, error) {
+	if os.Getenv("SSH_AUTH_SOCK") == "" {
+		return nil, errors.New("no ssh-agent (SSH_AUTH_SOCK is not set)")
+	}
+	logging.Info("exec ssh-add", "args", "-l -E sha256")
+	output, err := exec.Command("ssh-add", "-l", "-E", "sha256").CombinedOutput()
+
+	// Exit status 1 means the agent answered but holds no keys
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return nil, nil
+	}
+	if err != nil {
+		if msg := firstLine(strings.TrimSpace(string(output))); msg != "" {
+			return nil, fmt.Errorf("ssh-add -l: %s", msg)
+		}
+		return nil, fmt.Errorf("ssh-add -l: %w", err)
+	}
+
+	// Lines look like "256 SHA256:... comment (ED25519)"
+	var fingerprints []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && strings.HasPrefix(fields[1], "SHA256:") {
+			fingerprints = append(fingerprints, fields[1])
+		}
+	}
+	return fingerprints, nil
+}
@@ -0,0 +1,102 @@
+// ABOUTME: SSH authentication probes for gh-context
+// ABOUTME: Runs ssh -T against a GitHub host and parses the greeting banner
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/peterjmorgan/gh-context/internal/fsutil"
+	"github.com/peterjmorgan/gh-context/internal/logging"
+)
+
+// DefaultBanner matches GitHub's greeting for a successful ssh -T, e.g.
+// "Hi octocat! You've successfully authenticated, but GitHub does not provide shell access."
+const DefaultBanner = `Hi ([^!\s]+)! You've successfully authenticated`
+
+// defaultBanner is DefaultBanner compiled.
+var defaultBanner = regexp.MustCompile(DefaultBanner)
+
+// CompileBanner compiles a pattern for a server's ssh -T greeting, for
+// GitHub Enterprise Server instances that customize it. Its first capture
+// group must match the account name. An empty pattern yields DefaultBanner.
+func CompileBanner(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return defaultBanner, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH banner pattern: %v", err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("SSH banner pattern needs a (group) capturing the user: %s", pattern)
+	}
+	return re, nil
+}
+
+// probeTimeout bounds a single ssh -T probe.
+const probeTimeout = 15 * time.Second
+
+// TestConnection authenticates to hostname over SSH with only keyPath
+// (ssh -i <key> -o IdentitiesOnly=yes -T git@<hostname>) and returns the GitHub
+// account the key belongs to, read from the greeting with banner (nil =
+// DefaultBanner; see CompileBanner). hostname may be an SSH Host alias.
+func TestConnection(hostname, keyPath string, banner *regexp.Regexp) (string, error) {
+	if !KeyExists(keyPath) {
+		return "", fmt.Errorf("SSH key not found: %s", fsutil.ExpandPath(keyPath))
+	}
+	if banner == nil {
+		banner = defaultBanner
+	}
+	return probe(hostname, probeTimeout, banner, "-i", fsutil.ExpandPath(keyPath), "-o", "IdentitiesOnly=yes")
+}
+
+// probe runs ssh -T with extra options and parses the banner.
+func probe(host string, timeout time.Duration, banner *regexp.Regexp, opts ...string) (string, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	args := append([]string{"-T", "-o", "BatchMode=yes"}, opts...)
+	args = append(args, "git@"+host)
+	logging.Info("exec ssh", "args", strings.Join(args, " "))
+
+	// GitHub closes the session with exit status 1 even on success, so the
+	// banner decides the outcome, not the exit code
+	output, err := exec.CommandContext(ctx, "ssh", args...).CombinedOutput()
+	return parseBanner(host, string(output), banner, err)
+}
+
+// parseBanner extracts the greeted user from ssh -T output.
+func parseBanner(host, output string, banner *regexp.Regexp, runErr error) (string, error) {
+	if match := banner.FindStringSubmatch(output); match != nil && match[1] != "" {
+		return match[1], nil
+	}
+
+	output = strings.TrimSpace(output)
+	logging.Debug("ssh probe failed", "host", host, "err", runErr, "output", output)
+	switch {
+	case strings.Contains(output, "Permission denied"):
+		return "", fmt.Errorf("permission denied by %s: key not authorized", host)
+	case output != "":
+		return "", fmt.Errorf("unexpected response from %s: %s", host, firstLine(output))
+	case runErr != nil:
+		return "", fmt.Errorf("ssh to %s failed: %w", host, runErr)
+	default:
+		return "", fmt.Errorf("no greeting from %s", host)
+	}
+}
+
+// firstLine returns s up to its first newline.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,83 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSSH puts a script standing in for ssh first on PATH. It logs its
+// arguments to the returned file and prints output, exiting with status 1
+// the way GitHub's ssh -T does.
+func fakeSSH(t *testing.T, output string) string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "ssh.log")
+	out := filepath.Join(dir, "ssh.out")
+	if err := os.WriteFile(out, []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho \"$@\" >> '" + log + "'\ncat '" + out + "' >&2\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+func TestTestConnection(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	key := filepath.Join(home, ".ssh", "id_work")
+	if err := os.MkdirAll(filepath.Dir(key), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(key, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		output   string
+		wantUser string
+		wantErr  string
+	}{
+		{"success banner", "Hi octocat! You've successfully authenticated, but GitHub does not provide shell access.\n", "octocat", ""},
+		{"permission denied", "git@github.com: Permission denied (publickey).\n", "", "permission denied by github.com"},
+		{"unexpected output", "Connection closed by remote host\n", "", "unexpected response from github.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := fakeSSH(t, tt.output)
+			user, err := TestConnection("github.com", "~/.ssh/id_work", nil)
+			if user != tt.wantUser {
+				t.Errorf("user = %q, want %q", user, tt.wantUser)
+			}
+			if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+			args, _ := os.ReadFile(log)
+			if want := "-i " + key + " -o IdentitiesOnly=yes git@github.com"; !strings.Contains(string(args), want) {
+				t.Errorf("ssh called with %q, want %q", args, want)
+			}
+		})
+	}
+
+	if _, err := TestConnection("github.com", "~/.ssh/id_missing", nil); err == nil || !strings.Contains(err.Error(), "SSH key not found") {
+		t.Errorf("missing key: err = %v, want SSH key not found", err)
+	}
+}
+
+func TestCompileBanner(t *testing.T) {
+	re, err := CompileBanner(`Welcome, (\w+)\.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err := parseBanner("ghes.corp", "Welcome, admin. No shell here.", re, nil); err != nil || user != "admin" {
+		t.Errorf("custom banner = %q, %v; want admin", user, err)
+	}
+	if _, err := CompileBanner(`Welcome, \w+\.`); err == nil {
+		t.Error("pattern without a capture group accepted")
+	}
+}
@@ -0,0 +1,241 @@
+// ABOUTME: Switch planning for gh-context - describes what using a context will do
+// ABOUTME: Commands render a Plan for --dry-run and execute its actions otherwise
+
+package switcher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+	"github.com/peterjmorgan/gh-context/internal/ssh"
+)
+
+// ActionKind identifies one step of switching to a context.
+type ActionKind string
+
+const (
+	ActionSetActive   ActionKind = "set-active"       // Point the active file at the context
+	ActionActivateKey ActionKind = "activate-ssh-key" // Toggle IdentityFile lines in ~/.ssh/config
+	ActionSwitchAuth  ActionKind = "switch-auth"      // gh auth switch to the context's user
+	ActionGitConfig   ActionKind = "apply-git-config" // Write the context's local git config in the repo
+	ActionBindRepo    ActionKind = "bind-repo"        // Write the repo's .ghcontext marker
+	ActionGitProtocol ActionKind = "set-git-protocol" // gh config set git_protocol for the host
+	ActionSwitchHost  ActionKind = "switch-host"      // gh auth switch on one of a multi-host context's other hosts
+)
+
+// Action is one intended change, in execution order.
+type Action struct {
+	Kind        ActionKind `json:"kind"`
+	Description string     `json:"description"`
+	Host        string     `json:"host,omitempty"`       // gh host, or SSH Host block for key activation
+	User        string     `json:"user,omitempty"`       // Target gh user
+	SSHKey      string     `json:"sshKey,omitempty"`     // Key to activate
+	AddKey      bool       `json:"addKey,omitempty"`     // Add the IdentityFile line if it's missing
+	AllAliases  bool       `json:"allAliases,omitempty"` // Toggle every block resolving to the gh host
+	Context     string     `json:"context,omitempty"`    // Context made active
+	Repo        string     `json:"repo,omitempty"`       // Repository whose local git config is written
+	Force       bool       `json:"force,omitempty"`      // Create the Host block and IdentityFile line as needed
+	HostName    string     `json:"hostName,omitempty"`   // HostName for a Host block created by Force
+	Local       bool       `json:"local,omitempty"`      // Bind via .git/info/ghcontext instead of .ghcontext
+	Protocol    string     `json:"protocol,omitempty"`   // git_protocol gh should use on Host
+
+	GitConfig map[string]string `json:"gitConfig,omitempty"` // Local git config entries to write
+}
+
+// Plan describes everything switching to a context will change.
+type Plan struct {
+	Context *config.Context `json:"-"`
+	Name    string          `json:"context"`
+	Actions []Action        `json:"actions"`
+}
+
+// Options adjusts what a plan includes.
+type Options struct {
+	NoSSH      bool // Leave ~/.ssh/config alone regardless of the context
+	AddKey     bool // Add the context's IdentityFile to its Host block if missing
+	AllAliases bool // Activate the key in every Host block whose HostName is the gh host
+	Force      bool // Create the Host block and IdentityFile line if either is missing
+
+	Repo        string            // Root of the repository being switched in (empty = not in a repo)
+	RepoManaged bool              // The repo has git config entries set by a previous context
+	RepoGit     map[string]string // Overrides from the repo's .ghcontext, layered over the context's git config
+	Bind        bool              // Also bind the repo to the context
+	BindLocal   bool              // Bind with the local-only marker in .git/info
+}
+
+// SSHManaged reports whether a context's SSH key would be activated in
+// ~/.ssh/config under opts.
+func SSHManaged(ctx *config.Context, opts Options) bool {
+	return !opts.NoSSH && ctx.SSHManaged && ctx.SSHKey != "" && ctx.Transport == "ssh" && !ctx.UsesGitCommand()
+}
+
+// GitCommandKey reports whether a context's SSH key would be written to the
+// repo's core.sshCommand (the git-command strategy) under opts.
+func GitCommandKey(ctx *config.Context, opts Options) bool {
+	return !opts.NoSSH && ctx.SSHManaged && ctx.SSHKey != "" && ctx.Transport == "ssh" && ctx.UsesGitCommand()
+}
+
+// RepoGitConfig returns the local git config entries to write for ctx: its
+// own entries plus, with the git-command strategy, core.sshCommand for its
+// key (unless the context sets core.sshCommand itself), with the repo's
+// overrides (opts.RepoGit) on top.
+func RepoGitConfig(ctx *config.Context, opts Options) map[string]string {
+	if !GitCommandKey(ctx, opts) && len(opts.RepoGit) == 0 {
+		return ctx.GitConfig
+	}
+	entries := make(map[string]string)
+	if GitCommandKey(ctx, opts) {
+		entries["core.sshCommand"] = ssh.GitSSHCommand(ctx.SSHKey)
+	}
+	mergeGitConfig(entries, ctx.GitConfig)
+	mergeGitConfig(entries, opts.RepoGit)
+	return entries
+}
+
+// mergeGitConfig copies src over dst. Git config keys are case-insensitive,
+// so an entry in src replaces any spelling of the same key in dst.
+func mergeGitConfig(dst, src map[string]string) {
+	for k, v := range src {
+		for existing := range dst {
+			if strings.EqualFold(existing, k) {
+				delete(dst, existing)
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// NewPlan builds the plan for switching to ctx. It has no side effects.
+func NewPlan(ctx *config.Context, opts Options) *Plan {
+	p := &Plan{Context: ctx, Name: ctx.Name}
+
+	authActions := []Action{{
+		Kind:        ActionSwitchAuth,
+		Description: fmt.Sprintf("Switch gh auth on %s to %s", ctx.Hostname, ctx.User),
+		Host:        ctx.Hostname,
+		User:        ctx.User,
+	}}
+	for _, e := range ctx.Extra {
+		authActions = append(authActions, Action{
+			Kind:        ActionSwitchHost,
+			Description: fmt.Sprintf("Switch gh auth on %s to %s", e.Hostname, e.User),
+			Host:        e.Hostname,
+			User:        e.User,
+		})
+	}
+
+	// A multi-host context switches every gh account before anything else,
+	// so a failed switch can be rolled back by switching the accounts back:
+	// the active context and SSH config haven't been touched yet
+	if len(ctx.Extra) > 0 {
+		p.Actions = append(p.Actions, authActions...)
+	}
+
+	p.Actions = append(p.Actions, Action{
+		Kind:        ActionSetActive,
+		Description: fmt.Sprintf("Set active context to '%s'", ctx.Name),
+		Context:     ctx.Name,
+	})
+
+	if SSHManaged(ctx, opts) {
+		host := ctx.SSHBlockHost()
+		desc := fmt.Sprintf("Activate SSH key %s in Host %s", ctx.SSHKey, host)
+		if opts.AllAliases {
+			host = ctx.Hostname
+			desc = fmt.Sprintf("Activate SSH key %s in every Host block for %s", ctx.SSHKey, host)
+		} else if opts.Force {
+			desc += " (creating the Host block or adding the key if missing)"
+		} else if opts.AddKey {
+			desc += " (adding it if missing)"
+		}
+		p.Actions = append(p.Actions, Action{
+			Kind:        ActionActivateKey,
+			Description: desc,
+			Host:        host,
+			SSHKey:      ctx.SSHKey,
+			AddKey:      opts.AddKey && !opts.AllAliases,
+			AllAliases:  opts.AllAliases,
+			Force:       opts.Force && !opts.AllAliases,
+			HostName:    ctx.Hostname,
+		})
+	}
+
+	gitConfig := RepoGitConfig(ctx, opts)
+	if opts.Repo != "" && (len(gitConfig) > 0 || opts.RepoManaged) {
+		desc := fmt.Sprintf("Write %d git config entries to %s", len(gitConfig), opts.Repo)
+		if GitCommandKey(ctx, opts) {
+			desc += fmt.Sprintf(" (core.sshCommand uses %s)", ctx.SSHKey)
+		}
+		if len(gitConfig) == 0 {
+			desc = fmt.Sprintf("Unset git config entries a previous context wrote to %s", opts.Repo)
+		}
+		p.Actions = append(p.Actions, Action{
+			Kind:        ActionGitConfig,
+			Description: desc,
+			Repo:        opts.Repo,
+			GitConfig:   gitConfig,
+		})
+	}
+
+	if opts.Repo != "" && opts.Bind {
+		desc := fmt.Sprintf("Bind %s to '%s' (.ghcontext)", opts.Repo, ctx.Name)
+		if opts.BindLocal {
+			desc = fmt.Sprintf("Bind %s to '%s' (.git/info/ghcontext)", opts.Repo, ctx.Name)
+		}
+		p.Actions = append(p.Actions, Action{
+			Kind:        ActionBindRepo,
+			Description: desc,
+			Repo:        opts.Repo,
+			Context:     ctx.Name,
+			Local:       opts.BindLocal,
+		})
+	}
+
+	if len(ctx.Extra) == 0 {
+		p.Actions = append(p.Actions, authActions...)
+	}
+
+	if !opts.NoSSH && ctx.SSHManaged && ctx.Transport == "ssh" && !ctx.UsesGitCommand() {
+		for _, e := range ctx.Extra {
+			if e.SSHKey == "" {
+				continue
+			}
+			p.Actions = append(p.Actions, Action{
+				Kind:        ActionActivateKey,
+				Description: fmt.Sprintf("Activate SSH key %s in Host %s", e.SSHKey, e.SSHBlockHost()),
+				Host:        e.SSHBlockHost(),
+				SSHKey:      e.SSHKey,
+				AddKey:      opts.AddKey,
+				HostName:    e.Hostname,
+			})
+		}
+	}
+
+	if ctx.Transport == "ssh" || ctx.Transport == "https" {
+		hosts := []string{ctx.Hostname}
+		for _, e := range ctx.Extra {
+			hosts = append(hosts, e.Hostname)
+		}
+		for _, host := range hosts {
+			p.Actions = append(p.Actions, Action{
+				Kind:        ActionGitProtocol,
+				Description: fmt.Sprintf("Set gh git_protocol on %s to %s", host, ctx.Transport),
+				Host:        host,
+				Protocol:    ctx.Transport,
+			})
+		}
+	}
+
+	return p
+}
+
+// Has reports whether the plan includes an action of the given kind.
+func (p *Plan) Has(kind ActionKind) bool {
+	for _, a := range p.Actions {
+		if a.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
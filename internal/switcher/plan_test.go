@@ -0,0 +1,110 @@
+package switcher
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/peterjmorgan/gh-context/internal/config"
+)
+
+func TestNewPlanActions(t *testing.T) {
+	full := &config.Context{
+		Name: "work", Hostname: "github.com", User: "me", Transport: "ssh",
+		SSHKey: "~/.ssh/id_work", SSHManaged: true,
+		GitConfig: map[string]string{"user.email": "me@work.example"},
+		Extra:     []config.HostEntry{{Hostname: "ghec.corp", User: "me-ghec", SSHKey: "~/.ssh/id_ghec"}},
+	}
+	noSSH := &config.Context{
+		Name: "token", Hostname: "github.com", User: "me", Transport: "https", SSHManaged: false,
+	}
+	gitCommand := &config.Context{
+		Name: "cmd", Hostname: "github.com", User: "me", Transport: "ssh",
+		SSHKey: "~/.ssh/id_work", SSHManaged: true, Strategy: config.StrategyGitCommand,
+	}
+
+	tests := []struct {
+		name string
+		ctx  *config.Context
+		opts Options
+		want []ActionKind
+	}{
+		{
+			name: "full context in a repo",
+			ctx:  full,
+			opts: Options{Repo: "/src/app", Bind: true},
+			want: []ActionKind{
+				ActionSwitchAuth, ActionSwitchHost, ActionSetActive, ActionActivateKey,
+				ActionGitConfig, ActionBindRepo, ActionActivateKey,
+				ActionGitProtocol, ActionGitProtocol,
+			},
+		},
+		{
+			name: "full context outside a repo",
+			ctx:  full,
+			want: []ActionKind{
+				ActionSwitchAuth, ActionSwitchHost, ActionSetActive,
+				ActionActivateKey, ActionActivateKey, ActionGitProtocol, ActionGitProtocol,
+			},
+		},
+		{
+			name: "full context with --no-ssh",
+			ctx:  full,
+			opts: Options{NoSSH: true},
+			want: []ActionKind{
+				ActionSwitchAuth, ActionSwitchHost, ActionSetActive, ActionGitProtocol, ActionGitProtocol,
+			},
+		},
+		{
+			name: "no-SSH context",
+			ctx:  noSSH,
+			want: []ActionKind{ActionSetActive, ActionSwitchAuth, ActionGitProtocol},
+		},
+		{
+			name: "no-SSH context in a repo with nothing to write",
+			ctx:  noSSH,
+			opts: Options{Repo: "/src/app"},
+			want: []ActionKind{ActionSetActive, ActionSwitchAuth, ActionGitProtocol},
+		},
+		{
+			name: "git-command context in a repo",
+			ctx:  gitCommand,
+			opts: Options{Repo: "/src/app"},
+			want: []ActionKind{ActionSetActive, ActionGitConfig, ActionSwitchAuth, ActionGitProtocol},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := NewPlan(tt.ctx, tt.opts)
+			var got []ActionKind
+			for _, a := range plan.Actions {
+				got = append(got, a.Kind)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("actions = %v\nwant      %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPlanTargets(t *testing.T) {
+	ctx := &config.Context{
+		Name: "corp", Hostname: "ghes.corp", User: "me", Transport: "ssh",
+		SSHKey: "~/.ssh/id_corp", SSHHost: "git.corp", SSHManaged: true,
+	}
+	plan := NewPlan(ctx, Options{})
+
+	got := map[ActionKind]string{}
+	for _, a := range plan.Actions {
+		got[a.Kind] = fmt.Sprintf("%s %s%s", a.Host, a.User, a.SSHKey)
+	}
+	want := map[ActionKind]string{
+		ActionSetActive:   " ",
+		ActionActivateKey: "git.corp ~/.ssh/id_corp",
+		ActionSwitchAuth:  "ghes.corp me",
+		ActionGitProtocol: "ghes.corp ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("action targets = %v, want %v", got, want)
+	}
+}